@@ -3,27 +3,73 @@ package app
 import (
 	"github.com/searchktools/fast-server/config"
 	"github.com/searchktools/fast-server/core"
+	"github.com/searchktools/fast-server/core/middleware"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 // App is the application instance using a high-performance zero-allocation engine
 type App struct {
 	cfg    *config.Config
 	engine *core.Engine
+
+	// Error from loading the config file, reported by Run and Doctor
+	setupErr error
 }
 
-// New creates an application instance
+// New creates an application instance. The middleware chain declared in
+// the config file, if any, is installed as global middleware.
 func New(cfg *config.Config) *App {
 	engine := core.NewEngine()
+	engine.SetReadTimeout(time.Duration(cfg.ReadTimeout) * time.Second)
+	engine.SetWriteTimeout(time.Duration(cfg.WriteTimeout) * time.Second)
+	engine.SetRequestTimeout(time.Duration(cfg.RequestTimeout) * time.Second)
+	if cfg.IdleTimeout > 0 {
+		engine.SetIdleTimeout(time.Duration(cfg.IdleTimeout) * time.Second)
+	}
+	engine.SetMaxBodyBytes(cfg.MaxBodyBytes)
+	engine.SetMaxHeaderBytes(cfg.MaxHeaderBytes)
+	engine.SetMaxHeaderCount(cfg.MaxHeaderCount)
+	engine.SetServerHeader(cfg.ServerHeader)
+	engine.SetStrictParsing(cfg.StrictHTTP)
 
-	return &App{
+	a := &App{
 		cfg:    cfg,
 		engine: engine,
 	}
+	if cfg.ConfigFile != "" {
+		a.setupErr = a.loadConfigFile(cfg.ConfigFile)
+	}
+	return a
+}
+
+// loadConfigFile applies the JSON config file, e.g.
+//
+//	{"middleware": ["recovery", "requestid", {"name": "cors", "allow_origins": ["https://app.example"]}]}
+func (a *App) loadConfigFile(path string) error {
+	m := config.NewManager()
+	if err := m.LoadFromJSON(path); err != nil {
+		return err
+	}
+
+	raw, ok := m.Get("middleware")
+	if !ok {
+		return nil
+	}
+	specs, err := middleware.ParseSpecs(raw)
+	if err != nil {
+		return err
+	}
+	handlers, err := middleware.Build(specs)
+	if err != nil {
+		return err
+	}
+	a.engine.Use(handlers...)
+	return nil
 }
 
 // Engine returns the underlying engine for route registration
@@ -41,6 +87,19 @@ func NewWithEngine(cfg *config.Config, engine *core.Engine) *App {
 
 // Run starts the application
 func (a *App) Run() {
+	if a.cfg.Doctor {
+		report := a.Doctor()
+		report.Print(os.Stdout)
+		if !report.OK() {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if a.setupErr != nil {
+		log.Fatalf("Config file %s: %v", a.cfg.ConfigFile, a.setupErr)
+	}
+
 	// Graceful shutdown
 	go a.awaitSignal()
 
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/searchktools/fast-server/core/poller"
+)
+
+// CheckStatus is the outcome of a single doctor check
+type CheckStatus int
+
+const (
+	CheckOK CheckStatus = iota
+	CheckWarn
+	CheckFail
+	CheckSkip
+)
+
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckOK:
+		return "OK"
+	case CheckWarn:
+		return "WARN"
+	case CheckFail:
+		return "FAIL"
+	default:
+		return "SKIP"
+	}
+}
+
+// CheckResult describes one doctor check and how to fix it
+type CheckResult struct {
+	Name   string
+	Status CheckStatus
+	Detail string
+	Fix    string
+}
+
+// DoctorReport collects the results of App.Doctor
+type DoctorReport struct {
+	Results []CheckResult
+}
+
+// OK reports whether no check failed (warnings are allowed)
+func (r *DoctorReport) OK() bool {
+	for _, res := range r.Results {
+		if res.Status == CheckFail {
+			return false
+		}
+	}
+	return true
+}
+
+// Print writes a human-readable report to w
+func (r *DoctorReport) Print(w io.Writer) {
+	for _, res := range r.Results {
+		fmt.Fprintf(w, "[%-4s] %-12s %s\n", res.Status, res.Name, res.Detail)
+		if res.Fix != "" && res.Status != CheckOK {
+			fmt.Fprintf(w, "       fix: %s\n", res.Fix)
+		}
+	}
+}
+
+func (r *DoctorReport) add(name string, status CheckStatus, detail, fix string) {
+	r.Results = append(r.Results, CheckResult{Name: name, Status: status, Detail: detail, Fix: fix})
+}
+
+// Doctor runs a startup self-test: configuration, file descriptor limits,
+// kernel network settings, poller backend, TLS certificate and port availability
+func (a *App) Doctor() *DoctorReport {
+	r := &DoctorReport{}
+	a.checkConfig(r)
+	a.checkTuning(r)
+	checkPoller(r)
+	a.checkTLS(r)
+	a.checkPort(r)
+	return r
+}
+
+func (a *App) checkConfig(r *DoctorReport) {
+	var problems []string
+	if a.cfg.Port <= 0 || a.cfg.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("port %d out of range", a.cfg.Port))
+	}
+	if a.cfg.ReadTimeout < 0 || a.cfg.WriteTimeout < 0 || a.cfg.RequestTimeout < 0 || a.cfg.IdleTimeout < 0 {
+		problems = append(problems, "timeouts must not be negative")
+	}
+	if a.cfg.MaxBodyBytes < 0 {
+		problems = append(problems, "max-body-bytes must not be negative")
+	}
+	if a.cfg.Env != "development" && a.cfg.Env != "production" {
+		problems = append(problems, fmt.Sprintf("unknown env %q", a.cfg.Env))
+	}
+	if (a.cfg.TLSCert == "") != (a.cfg.TLSKey == "") {
+		problems = append(problems, "tls-cert and tls-key must be set together")
+	}
+	if a.setupErr != nil {
+		problems = append(problems, fmt.Sprintf("config file %s: %v", a.cfg.ConfigFile, a.setupErr))
+	}
+
+	if len(problems) > 0 {
+		r.add("config", CheckFail, strings.Join(problems, "; "),
+			"check -port (1-65535), -env (development/production), timeouts, TLS flags and -config")
+		return
+	}
+	r.add("config", CheckOK, fmt.Sprintf("port=%d env=%s", a.cfg.Port, a.cfg.Env), "")
+}
+
+func (a *App) checkTuning(r *DoctorReport) {
+	advice := a.engine.CheckTuning()
+	if len(advice) == 0 {
+		r.add("tuning", CheckOK, "file limits and kernel network settings look good", "")
+		return
+	}
+	for _, adv := range advice {
+		r.add("tuning", CheckWarn,
+			fmt.Sprintf("%s=%d (suggested %d)", adv.Setting, adv.Current, adv.Suggested), adv.Fix)
+	}
+}
+
+func checkPoller(r *DoctorReport) {
+	p, err := poller.NewPoller()
+	if err != nil {
+		r.add("poller", CheckFail, err.Error(), "the epoll/kqueue backend is required; run on Linux or macOS")
+		return
+	}
+	p.Close()
+	r.add("poller", CheckOK, fmt.Sprintf("%T", p), "")
+}
+
+func (a *App) checkTLS(r *DoctorReport) {
+	if a.cfg.TLSCert == "" || a.cfg.TLSKey == "" {
+		r.add("tls", CheckSkip, "not configured", "")
+		return
+	}
+
+	pair, err := tls.LoadX509KeyPair(a.cfg.TLSCert, a.cfg.TLSKey)
+	if err != nil {
+		r.add("tls", CheckFail, err.Error(), "check that -tls-cert and -tls-key point to a matching PEM pair")
+		return
+	}
+
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		r.add("tls", CheckFail, err.Error(), "regenerate the certificate")
+		return
+	}
+
+	now := time.Now()
+	detail := fmt.Sprintf("%s expires %s", leaf.Subject.CommonName, leaf.NotAfter.Format(time.RFC3339))
+	switch {
+	case now.Before(leaf.NotBefore):
+		r.add("tls", CheckFail, "certificate not valid until "+leaf.NotBefore.Format(time.RFC3339), "check the system clock or reissue the certificate")
+	case now.After(leaf.NotAfter):
+		r.add("tls", CheckFail, "certificate expired: "+detail, "renew the certificate")
+	case leaf.NotAfter.Sub(now) < 30*24*time.Hour:
+		r.add("tls", CheckWarn, detail, "renew the certificate within 30 days")
+	default:
+		r.add("tls", CheckOK, detail, "")
+	}
+}
+
+func (a *App) checkPort(r *DoctorReport) {
+	addr := fmt.Sprintf(":%d", a.cfg.Port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		r.add("port", CheckFail, err.Error(),
+			fmt.Sprintf("stop the process using %s or choose another -port", addr))
+		return
+	}
+	ln.Close()
+	r.add("port", CheckOK, addr+" is available", "")
+}
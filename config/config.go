@@ -11,6 +11,37 @@ type Config struct {
 	ReadTimeout  int
 	WriteTimeout int
 	Env          string
+
+	// RequestTimeout bounds ctx.Context() of each request (seconds, 0: none)
+	RequestTimeout int
+
+	// IdleTimeout closes keep-alive connections idle this long (seconds)
+	IdleTimeout int
+
+	// MaxBodyBytes rejects larger request bodies with 413 (0: no limit)
+	MaxBodyBytes int
+
+	// MaxHeaderBytes and MaxHeaderCount reject larger header blocks, or
+	// ones with more fields, with 431 (0: no field limit, and up to 4MB
+	// of headers)
+	MaxHeaderBytes int
+	MaxHeaderCount int
+
+	// ServerHeader is sent as the Server header of every response ("": none)
+	ServerHeader string
+
+	// StrictHTTP rejects requests that are not strictly RFC 7230
+	StrictHTTP bool
+
+	// TLS certificate and key files (optional)
+	TLSCert string
+	TLSKey  string
+
+	// Doctor runs the startup self-test and exits instead of serving
+	Doctor bool
+
+	// JSON config file with settings not covered by flags (e.g. "middleware")
+	ConfigFile string
 }
 
 // New loads configuration from flags (and potentially env vars).
@@ -20,7 +51,18 @@ func New() *Config {
 	flag.IntVar(&cfg.Port, "port", 8080, "HTTP server port")
 	flag.IntVar(&cfg.ReadTimeout, "read-timeout", 10, "HTTP read timeout (seconds)")
 	flag.IntVar(&cfg.WriteTimeout, "write-timeout", 30, "HTTP write timeout (seconds)")
+	flag.IntVar(&cfg.RequestTimeout, "request-timeout", 0, "Handler context deadline (seconds, 0 = none)")
+	flag.IntVar(&cfg.IdleTimeout, "idle-timeout", 5, "Keep-alive idle timeout (seconds)")
+	flag.IntVar(&cfg.MaxBodyBytes, "max-body-bytes", 0, "Largest request body (bytes, 0 = no limit)")
+	flag.IntVar(&cfg.MaxHeaderBytes, "max-header-bytes", 8192, "Largest request header block (bytes, 0 = up to 4MB)")
+	flag.IntVar(&cfg.MaxHeaderCount, "max-header-count", 100, "Most request header fields (0 = no limit)")
+	flag.StringVar(&cfg.ServerHeader, "server-header", "", "Server response header (empty = none)")
+	flag.BoolVar(&cfg.StrictHTTP, "strict-http", false, "Reject requests that are not strictly RFC 7230")
 	flag.StringVar(&cfg.Env, "env", "development", "Environment (development/production)")
+	flag.StringVar(&cfg.TLSCert, "tls-cert", "", "TLS certificate file (PEM)")
+	flag.StringVar(&cfg.TLSKey, "tls-key", "", "TLS private key file (PEM)")
+	flag.BoolVar(&cfg.Doctor, "doctor", false, "Run startup self-test and exit")
+	flag.StringVar(&cfg.ConfigFile, "config", "", "JSON config file (middleware chain)")
 
 	flag.Parse()
 
@@ -0,0 +1,226 @@
+package alpn
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrMuxClosed is returned by Accept on protocol listeners after Close
+var ErrMuxClosed = errors.New("alpn: mux closed")
+
+// Config configures an ALPN mux
+type Config struct {
+	TLSConfig        *tls.Config   // Certificates; NextProtos is filled from registered protocols
+	Default          string        // Protocol for clients that negotiate none (default: "http/1.1")
+	HandshakeTimeout time.Duration // TLS handshake timeout (default: 10s)
+}
+
+// Mux terminates TLS on one listener and dispatches each connection by its
+// negotiated ALPN protocol, so HTTP/2, HTTP/1.1 and custom protocols share a
+// port and certificate.
+//
+// Connections are handed to protocol listeners (see Listener) that any
+// net.Listener-based server can Serve, or forwarded as plaintext to a local
+// address (see Forward). The fd-based Engine reads raw sockets and cannot
+// serve TLS connections directly, so "http/1.1" is typically forwarded to the
+// Engine's cleartext port on loopback.
+type Mux struct {
+	cfg Config
+	ln  net.Listener
+
+	mu        sync.Mutex
+	protos    []string
+	listeners map[string]*protoListener
+	closed    bool
+	done      chan struct{}
+}
+
+// New creates a mux on ln. Register protocols before calling Serve.
+func New(ln net.Listener, cfg Config) *Mux {
+	if cfg.Default == "" {
+		cfg.Default = "http/1.1"
+	}
+	if cfg.HandshakeTimeout == 0 {
+		cfg.HandshakeTimeout = 10 * time.Second
+	}
+	return &Mux{
+		cfg:       cfg,
+		ln:        ln,
+		listeners: make(map[string]*protoListener),
+		done:      make(chan struct{}),
+	}
+}
+
+// Listener registers proto and returns a listener yielding its connections.
+// Connections are *tls.Conn with the handshake completed. Protocols are
+// preferred in registration order.
+func (m *Mux) Listener(proto string) net.Listener {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if l, ok := m.listeners[proto]; ok {
+		return l
+	}
+	l := &protoListener{
+		mux:    m,
+		proto:  proto,
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+	m.listeners[proto] = l
+	m.protos = append(m.protos, proto)
+	return l
+}
+
+// Forward registers proto and proxies its decrypted streams to addr
+func (m *Mux) Forward(proto, addr string) {
+	ln := m.Listener(proto)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go forward(conn, addr)
+		}
+	}()
+}
+
+// Protocols returns the registered protocols in preference order
+func (m *Mux) Protocols() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.protos...)
+}
+
+// Serve accepts connections until Close is called
+func (m *Mux) Serve() error {
+	tlsConfig := m.cfg.TLSConfig.Clone()
+	tlsConfig.NextProtos = m.Protocols()
+
+	for {
+		conn, err := m.ln.Accept()
+		if err != nil {
+			if m.isClosed() {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		go m.handshake(tls.Server(conn, tlsConfig))
+	}
+}
+
+// Close stops the mux and all protocol listeners
+func (m *Mux) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.done)
+	m.mu.Unlock()
+
+	return m.ln.Close()
+}
+
+func (m *Mux) isClosed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+// handshake completes TLS and hands the connection to its protocol listener
+func (m *Mux) handshake(conn *tls.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.HandshakeTimeout)
+	defer cancel()
+
+	if err := conn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return
+	}
+
+	proto := conn.ConnectionState().NegotiatedProtocol
+	if proto == "" {
+		proto = m.cfg.Default
+	}
+
+	m.mu.Lock()
+	l := m.listeners[proto]
+	m.mu.Unlock()
+
+	if l == nil {
+		log.Printf("⚠️  alpn: no handler for protocol %q from %s", proto, conn.RemoteAddr())
+		conn.Close()
+		return
+	}
+
+	select {
+	case l.conns <- conn:
+	case <-l.closed:
+		conn.Close()
+	case <-m.done:
+		conn.Close()
+	}
+}
+
+// forward copies a connection to addr in both directions
+func forward(conn net.Conn, addr string) {
+	defer conn.Close()
+
+	upstream, err := net.Dial("tcp", addr)
+	if err != nil {
+		log.Printf("⚠️  alpn: forward to %s failed: %v", addr, err)
+		return
+	}
+	defer upstream.Close()
+
+	go func() {
+		io.Copy(upstream, conn)
+		if tc, ok := upstream.(*net.TCPConn); ok {
+			tc.CloseWrite()
+		}
+	}()
+	// The upstream closing its side ends the exchange
+	io.Copy(conn, upstream)
+}
+
+// protoListener is a net.Listener fed by the mux for one protocol
+type protoListener struct {
+	mux    *Mux
+	proto  string
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (l *protoListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	case <-l.mux.done:
+		return nil, ErrMuxClosed
+	}
+}
+
+// Close stops delivering connections for this protocol; the mux keeps running
+func (l *protoListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *protoListener) Addr() net.Addr {
+	return l.mux.ln.Addr()
+}
@@ -0,0 +1,128 @@
+package alpn
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func testCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// echoServer answers each line with the protocol name and the line
+func echoServer(ln net.Listener, name string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			conn.Write([]byte(name + ":" + line))
+		}()
+	}
+}
+
+func newTestMux(t *testing.T) *Mux {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(ln, Config{TLSConfig: &tls.Config{Certificates: []tls.Certificate{testCertificate(t)}}})
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func dial(t *testing.T, m *Mux, protos ...string) string {
+	t.Helper()
+	conn, err := tls.Dial("tcp", m.ln.Addr().String(), &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         protos,
+	})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("ping\n"))
+	reply, _ := bufio.NewReader(conn).ReadString('\n')
+	return reply
+}
+
+// TestMuxDispatchByProtocol 测试按 ALPN 协议分发连接
+func TestMuxDispatchByProtocol(t *testing.T) {
+	m := newTestMux(t)
+	go echoServer(m.Listener("http/1.1"), "http")
+	go echoServer(m.Listener("x-rpc"), "rpc")
+	go m.Serve()
+
+	if got := dial(t, m, "x-rpc"); got != "rpc:ping\n" {
+		t.Errorf("x-rpc: got %q", got)
+	}
+	if got := dial(t, m, "http/1.1"); got != "http:ping\n" {
+		t.Errorf("http/1.1: got %q", got)
+	}
+	// No ALPN falls back to the default protocol
+	if got := dial(t, m); got != "http:ping\n" {
+		t.Errorf("no ALPN: got %q", got)
+	}
+}
+
+// TestMuxForward 测试将解密后的流转发到明文地址
+func TestMuxForward(t *testing.T) {
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	go echoServer(plain, "plain")
+
+	m := newTestMux(t)
+	m.Forward("http/1.1", plain.Addr().String())
+	go m.Serve()
+
+	if got := dial(t, m, "http/1.1"); got != "plain:ping\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// TestMuxListenerClose 测试关闭协议监听器不影响其他协议
+func TestMuxListenerClose(t *testing.T) {
+	m := newTestMux(t)
+	closed := m.Listener("x-old")
+	go echoServer(m.Listener("x-rpc"), "rpc")
+	go m.Serve()
+
+	closed.Close()
+	if _, err := closed.Accept(); err == nil {
+		t.Error("expected Accept to fail after Close")
+	}
+	if got := dial(t, m, "x-rpc"); got != "rpc:ping\n" {
+		t.Errorf("x-rpc: got %q", got)
+	}
+}
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// SetBackoff sets the response sent with code, usually 429 (throttled) or
+// 503 (overloaded), by the engine, when it refuses connections beyond
+// SetMaxConnections, and by ctx.Backoff, used by the RateLimiter
+// middleware. Clients are told when to retry with Retry-After and
+// RateLimit-Reset. It must be called before Run.
+func (e *Engine) SetBackoff(code int, cfg http.BackoffConfig) {
+	e.backoffs[code] = http.NewBackoff(code, cfg)
+}
+
+// atCapacity reports whether open connections reached maxConnections
+func (e *Engine) atCapacity() bool {
+	if e.maxConnections <= 0 {
+		return false
+	}
+	e.connMu.RLock()
+	n := len(e.connections)
+	e.connMu.RUnlock()
+	return n >= e.maxConnections
+}
+
+// refuse answers a connection accepted over capacity with 503 and closes
+// it, without waiting for its request
+func (e *Engine) refuse(fd int) {
+	e.refused.Add(1)
+	netfd.Write(fd, e.backoffs[503].AppendResponse(nil, 0))
+	netfd.Close(fd)
+}
+
+// Refused returns how many connections were refused over capacity
+func (e *Engine) Refused() uint64 {
+	return e.refused.Load()
+}
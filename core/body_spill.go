@@ -0,0 +1,134 @@
+package core
+
+import (
+	"log"
+	"time"
+
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// bodySpill tracks a request body being written to the BodyStore
+type bodySpill struct {
+	body      http.SpooledBody
+	headerLen int
+	remaining int                  // Content-Length bytes still to come
+	chunks    *http.ChunkedDecoder // Decodes a chunked body (nil: Content-Length)
+}
+
+// complete reports whether the whole body is in the spool
+func (sp *bodySpill) complete() bool {
+	if sp.chunks != nil {
+		return sp.chunks.Done()
+	}
+	return sp.remaining == 0
+}
+
+// SetBodyStore spills request bodies larger than threshold bytes to store
+// (e.g. http.NewDiskStore("")), so a few large uploads cannot exhaust
+// memory. Bodies that do not fit the read buffer are spilled whatever the
+// threshold. Chunked bodies are decoded into the store once their decoded
+// size passes the threshold. Handlers read spilled bodies with ctx.BodyReader(). A nil
+// store keeps bodies in memory.
+func (e *Engine) SetBodyStore(store http.BodyStore, threshold int) {
+	e.bodyStore = store
+	e.spillThreshold = threshold
+}
+
+// startSpill begins spooling the body of the request whose header block
+// starts the read buffer, bodyLen bytes long or chunked (-1). The header
+// stays in place, since the parsed request points into it, and body bytes
+// are read in after it.
+func (e *Engine) startSpill(conn *Connection, headerLen, bodyLen int) bool {
+	if headerLen >= len(conn.readBuf) {
+		e.flushPipeline(conn)
+		e.sendError(conn, 431, "Request Header Fields Too Large")
+		e.closeConnection(conn.fd)
+		return false
+	}
+
+	body, err := e.bodyStore.Create(int64(bodyLen))
+	if err != nil {
+		log.Printf("⚠️  body store: %v", err)
+		e.flushPipeline(conn)
+		e.sendError(conn, 500, "Internal Server Error")
+		e.closeConnection(conn.fd)
+		return false
+	}
+
+	conn.spill = &bodySpill{body: body, headerLen: headerLen, remaining: bodyLen}
+	if bodyLen < 0 {
+		// Decoded from the start of the body again, into the spool
+		conn.spill.remaining = 0
+		conn.spill.chunks = &conn.chunks
+		conn.chunks.Reset()
+		conn.chunkEnd = 0
+	}
+	conn.headerDeadline = time.Time{}
+	return true
+}
+
+// feedSpill moves body bytes read after the header into the spool,
+// keeping any pipelined bytes that follow the body
+func (e *Engine) feedSpill(conn *Connection) bool {
+	sp := conn.spill
+	data := conn.readBuf[sp.headerLen:conn.readOffset]
+	if sp.chunks != nil {
+		return e.feedChunkedSpill(conn, data)
+	}
+	n := min(len(data), sp.remaining)
+	if n > 0 {
+		if _, err := sp.body.Write(data[:n]); err != nil {
+			log.Printf("⚠️  body store: %v", err)
+			e.flushPipeline(conn)
+			e.sendError(conn, 500, "Internal Server Error")
+			e.closeConnection(conn.fd)
+			return false
+		}
+		sp.remaining -= n
+	}
+	conn.readOffset = sp.headerLen + copy(data, data[n:])
+	return true
+}
+
+// feedChunkedSpill decodes the chunked body bytes in data, which follow
+// the header, into the spool
+func (e *Engine) feedChunkedSpill(conn *Connection, data []byte) bool {
+	sp := conn.spill
+	n, err := sp.chunks.Decode(data, sp.body)
+	if err != nil {
+		e.flushPipeline(conn)
+		if err == http.ErrInvalidRequest {
+			e.sendError(conn, 400, "Bad Request")
+		} else {
+			log.Printf("⚠️  body store: %v", err)
+			e.sendError(conn, 500, "Internal Server Error")
+		}
+		e.closeConnection(conn.fd)
+		return false
+	}
+	if !e.checkChunkedSize(conn) {
+		return false
+	}
+	conn.readOffset = sp.headerLen + copy(data, data[n:])
+	if !sp.chunks.Done() && conn.readOffset >= len(conn.readBuf) {
+		// A chunk-size or trailer line longer than the buffer
+		e.flushPipeline(conn)
+		e.sendError(conn, 400, "Bad Request")
+		e.closeConnection(conn.fd)
+		return false
+	}
+	return true
+}
+
+// releaseSpool removes the finished request's stored body and any body
+// still being spilled
+func (e *Engine) releaseSpool(conn *Connection) {
+	if conn.spool != nil {
+		conn.spool.Remove()
+		conn.spool = nil
+	}
+	if conn.spill != nil {
+		conn.spill.body.Remove()
+		conn.spill = nil
+	}
+}
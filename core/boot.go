@@ -0,0 +1,251 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/netfd"
+	"github.com/searchktools/fast-server/core/poller"
+	"github.com/searchktools/fast-server/core/router"
+)
+
+// BootLogFormat selects how Run logs the boot report
+type BootLogFormat uint8
+
+const (
+	BootLogText BootLogFormat = iota // key=value lines (default)
+	BootLogJSON                      // One JSON object
+	BootLogOff                       // Nothing; the report stays available
+)
+
+// BootReport describes how a server was started, for logs and fleet
+// inventory tooling (see BootAdmin)
+type BootReport struct {
+	Started   time.Time          `json:"started"`
+	Host      string             `json:"host"`
+	PID       int                `json:"pid"`
+	Listeners []ListenerInfo     `json:"listeners"`
+	Poller    string             `json:"poller"` // epoll, kqueue or netpoll
+	Router    RouterInfo         `json:"router"`
+	Pools     PoolConfig         `json:"pools"`
+	CPU       CPUInfo            `json:"cpu"`
+	Build     BuildInfo          `json:"build"`
+	Limits    map[string]int64   `json:"limits"`
+	Timeouts  map[string]float64 `json:"timeouts_seconds"`
+}
+
+// ListenerInfo describes a listening socket
+type ListenerInfo struct {
+	Network string `json:"network"`
+	Addr    string `json:"addr"` // Bound address, with the chosen port for ":0"
+	TLS     bool   `json:"tls"`  // The engine serves plaintext; terminate TLS in front of it
+}
+
+// RouterInfo describes the router and its dispatch table
+type RouterInfo struct {
+	Type string `json:"type"`
+	router.TableStats
+}
+
+// PoolConfig describes the sizes of the engine's pools
+type PoolConfig struct {
+	Connections int   `json:"connections"`    // Connection pool capacity
+	Contexts    int   `json:"context_warmup"` // Contexts preallocated
+	Requests    int   `json:"request_warmup"` // Requests preallocated
+	ByteTiers   []int `json:"byte_tiers"`     // Buffer sizes of the byte pool
+	Workers     int   `json:"workers"`
+	GOGC        int   `json:"gogc"` // -1: GC off
+}
+
+// BuildInfo identifies the binary
+type BuildInfo struct {
+	GoVersion  string `json:"go_version"`
+	Module     string `json:"module,omitempty"`
+	Version    string `json:"version,omitempty"`
+	Revision   string `json:"vcs_revision,omitempty"`
+	Time       string `json:"vcs_time,omitempty"`
+	Modified   bool   `json:"vcs_modified,omitempty"`
+	FastServer string `json:"fast_server,omitempty"` // Version of this module
+}
+
+// SetBootLog sets how Run logs the boot report (default BootLogText)
+func (e *Engine) SetBootLog(format BootLogFormat) {
+	e.bootLog = format
+}
+
+// BootReport returns the report Run logged. Before Run it describes the
+// engine as configured, without listeners.
+func (e *Engine) BootReport() BootReport {
+	if r := e.boot.Load(); r != nil {
+		return *r
+	}
+	return *e.newBootReport(-1)
+}
+
+// BootAdmin returns a handler serving the boot report as JSON (GET)
+func (e *Engine) BootAdmin() HandlerFunc {
+	return func(ctx http.Context) {
+		if ctx.Method() != "GET" && ctx.Method() != "HEAD" {
+			if fc, ok := ctx.(*http.FDContext); ok {
+				fc.SetHeader("Allow", "GET, HEAD")
+			}
+			ctx.Error(405, "Method Not Allowed")
+			return
+		}
+		ctx.JSON(200, e.BootReport())
+	}
+}
+
+// newBootReport describes the engine listening on lfd (-1: none)
+func (e *Engine) newBootReport(lfd int) *BootReport {
+	r := &BootReport{
+		Started:   time.Now(),
+		PID:       os.Getpid(),
+		Listeners: []ListenerInfo{},
+		Poller:    poller.Backend,
+		Router:    RouterInfo{Type: "compiled", TableStats: e.router.TableStats()},
+		Pools: PoolConfig{
+			Connections: e.connectionPool.Capacity(),
+			Contexts:    poolWarmup,
+			Requests:    poolWarmup,
+			ByteTiers:   e.bytePool.Sizes(),
+			Workers:     e.workerPool.Stats().NumWorkers,
+			GOGC:        gogc(),
+		},
+		CPU:   CPUs(),
+		Build: buildInfo(),
+		Limits: map[string]int64{
+			"max_connections":  int64(e.maxConnections),
+			"max_header_bytes": int64(e.maxHeaderBytes),
+			"max_header_count": int64(e.maxHeaderCount),
+			"max_body_bytes":   int64(e.maxBodyBytes),
+			"nofile":           int64(e.fdLimit),
+		},
+		Timeouts: map[string]float64{
+			"read":    e.readTimeout.Seconds(),
+			"write":   e.writeTimeout.Seconds(),
+			"idle":    e.idleTimeout.Seconds(),
+			"header":  e.headerTimeout.Seconds(),
+			"request": e.requestTimeout.Seconds(),
+		},
+	}
+	r.CPU.Workers = r.Pools.Workers
+	r.Host, _ = os.Hostname()
+
+	if lfd >= 0 {
+		l := ListenerInfo{Network: "tcp"}
+		if sa, err := netfd.Getsockname(lfd); err == nil {
+			if addr := http.SockaddrToAddr(sa); addr != nil {
+				l.Addr = addr.String()
+			}
+		}
+		r.Listeners = append(r.Listeners, l)
+	}
+	return r
+}
+
+// logBootReport logs the boot report as set by SetBootLog
+func (e *Engine) logBootReport() {
+	r := e.boot.Load()
+	switch {
+	case r == nil || e.bootLog == BootLogOff:
+	case e.bootLog == BootLogJSON:
+		data, _ := json.Marshal(r)
+		log.Printf("boot %s", data)
+	default:
+		for _, line := range r.lines() {
+			log.Print(line)
+		}
+	}
+}
+
+// lines formats the report as key=value lines, one per section
+func (r *BootReport) lines() []string {
+	addrs := make([]string, len(r.Listeners))
+	for i, l := range r.Listeners {
+		addrs[i] = l.Network + "://" + l.Addr
+		if l.TLS {
+			addrs[i] += "+tls"
+		}
+	}
+	tiers := make([]string, len(r.Pools.ByteTiers))
+	for i, n := range r.Pools.ByteTiers {
+		tiers[i] = strconv.Itoa(n)
+	}
+
+	b := r.Build
+	build := fmt.Sprintf("boot build go=%s", b.GoVersion)
+	for _, kv := range [][2]string{
+		{"module", b.Module}, {"version", b.Version}, {"revision", b.Revision},
+		{"vcs_time", b.Time}, {"fast_server", b.FastServer},
+	} {
+		if kv[1] != "" {
+			build += " " + kv[0] + "=" + kv[1]
+		}
+	}
+	if b.Modified {
+		build += " modified=true"
+	}
+
+	return []string{
+		fmt.Sprintf("boot listen=%s poller=%s host=%s pid=%d",
+			strings.Join(addrs, ","), r.Poller, r.Host, r.PID),
+		fmt.Sprintf("boot router=%s routes=%d static=%d params=%d perfect_hash=%t",
+			r.Router.Type, r.Router.Routes, r.Router.Static, r.Router.Params, r.Router.PerfectHash),
+		fmt.Sprintf("boot pools connections=%d contexts=%d requests=%d bytes=%s workers=%d gogc=%d",
+			r.Pools.Connections, r.Pools.Contexts, r.Pools.Requests, strings.Join(tiers, "/"),
+			r.Pools.Workers, r.Pools.GOGC),
+		fmt.Sprintf("boot cpu num=%d quota=%.2f effective=%d gomaxprocs=%d",
+			r.CPU.NumCPU, r.CPU.Quota, r.CPU.Effective, r.CPU.GOMAXPROCS),
+		build,
+	}
+}
+
+// buildInfo reads the build information embedded in the binary
+func buildInfo() BuildInfo {
+	info := BuildInfo{GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Module, info.Version = bi.Main.Path, bi.Main.Version
+	if bi.Main.Path == "github.com/searchktools/fast-server" {
+		info.FastServer = bi.Main.Version
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path == "github.com/searchktools/fast-server" {
+			info.FastServer = dep.Version
+		}
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.Time = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// gogc returns the current GC target percentage
+func gogc() int {
+	sample := []metrics.Sample{{Name: "/gc/gogc:percent"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 100
+	}
+	// GOGC=off reads as -1
+	return int(int64(sample[0].Value.Uint64()))
+}
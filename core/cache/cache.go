@@ -0,0 +1,312 @@
+// Package cache serves handler responses from a multi-level in-memory LRU
+// with per-route freshness policies, including stale-while-revalidate and
+// stale-if-error.
+package cache
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/searchktools/fast-server/core/clock"
+	"github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/pools"
+)
+
+// Config configures a Cache
+type Config struct {
+	// Levels is the byte budget of each LRU level, hottest first. Entries
+	// evicted from a level move down to the next; hits on a lower level
+	// move back to the first. Default: 8MB, 64MB.
+	Levels []int
+
+	// Pool runs background refreshes. Default: the global worker pool.
+	Pool *pools.WorkerPool
+
+	// Clock timestamps entries. Default: clock.Default().
+	Clock clock.Clock
+}
+
+// Policy is a route's caching policy
+type Policy struct {
+	// TTL is how long a response is served as fresh
+	TTL time.Duration
+
+	// StaleWhileRevalidate extends the TTL: within it a stale response is
+	// served immediately while a refresh runs on the worker pool
+	StaleWhileRevalidate time.Duration
+
+	// StaleIfError extends the TTL: within it a stale response replaces
+	// the handler's response when the handler fails with a 5xx
+	StaleIfError time.Duration
+
+	// Key derives the cache key. Default: path plus sorted query.
+	Key func(ctx *http.FDContext) string
+}
+
+// Entry is a cached response
+type Entry struct {
+	Response *http.RecordedResponse
+	Stored   time.Time
+	size     int
+}
+
+// Stats are cache counters
+type Stats struct {
+	Hits          uint64 // Fresh responses served
+	StaleHits     uint64 // Stale responses served while revalidating
+	StaleOnError  uint64 // Stale responses served after a handler error
+	Misses        uint64 // Requests passed to the handler
+	Refreshes     uint64 // Background refreshes run
+	RefreshErrors uint64 // Background refreshes that kept the stale entry
+}
+
+// Cache is a multi-level response cache
+type Cache struct {
+	levels []*LRU
+	pool   *pools.WorkerPool
+	clock  clock.Clock
+
+	mu         sync.Mutex
+	refreshing map[string]struct{}
+
+	stats struct {
+		hits, staleHits, staleOnError, misses atomic.Uint64
+		refreshes, refreshErrors              atomic.Uint64
+	}
+}
+
+// New creates a Cache
+func New(cfg Config) *Cache {
+	if len(cfg.Levels) == 0 {
+		cfg.Levels = []int{8 << 20, 64 << 20}
+	}
+	if cfg.Pool == nil {
+		cfg.Pool = pools.GetGlobalPool()
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Default()
+	}
+
+	c := &Cache{
+		levels:     make([]*LRU, len(cfg.Levels)),
+		pool:       cfg.Pool,
+		clock:      cfg.Clock,
+		refreshing: make(map[string]struct{}),
+	}
+	for i, size := range cfg.Levels {
+		c.levels[i] = NewLRU(size)
+	}
+	// Demote evicted entries to the next level
+	for i := 0; i < len(c.levels)-1; i++ {
+		next := c.levels[i+1]
+		c.levels[i].onEvict = func(key string, e *Entry) {
+			next.Set(key, e)
+		}
+	}
+	return c
+}
+
+// Get returns the entry for key, promoting it to the first level
+func (c *Cache) Get(key string) (*Entry, bool) {
+	if e, ok := c.levels[0].Get(key); ok {
+		return e, true
+	}
+	for _, level := range c.levels[1:] {
+		if e, ok := level.Remove(key); ok {
+			c.put(key, e)
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// Set stores a response under key
+func (c *Cache) Set(key string, r *http.RecordedResponse) {
+	// Request strings alias the connection's read buffer
+	key = strings.Clone(key)
+	c.put(key, &Entry{Response: r, Stored: c.clock.Now(), size: len(key) + r.Size()})
+}
+
+// Delete removes key from every level
+func (c *Cache) Delete(key string) {
+	for _, level := range c.levels {
+		level.Remove(key)
+	}
+}
+
+// put stores e in the first level with room for it
+func (c *Cache) put(key string, e *Entry) {
+	for _, level := range c.levels {
+		if level.Set(key, e) {
+			return
+		}
+	}
+}
+
+// Stats returns the cache counters
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Hits:          c.stats.hits.Load(),
+		StaleHits:     c.stats.staleHits.Load(),
+		StaleOnError:  c.stats.staleOnError.Load(),
+		Misses:        c.stats.misses.Load(),
+		Refreshes:     c.stats.refreshes.Load(),
+		RefreshErrors: c.stats.refreshErrors.Load(),
+	}
+}
+
+// Handler serves h's responses from the cache under policy p. Only GET
+// and HEAD requests are cached, and only 200 responses without cookies
+// or a no-store/private Cache-Control.
+func (c *Cache) Handler(p Policy, h func(ctx http.Context)) func(ctx http.Context) {
+	return func(ctx http.Context) {
+		fc, ok := ctx.(*http.FDContext)
+		if !ok || (fc.Method() != "GET" && fc.Method() != "HEAD") {
+			h(ctx)
+			return
+		}
+
+		key := p.key(fc)
+		e, found := c.Get(key)
+		if found {
+			age := c.clock.Now().Sub(e.Stored)
+			switch {
+			case age < p.TTL:
+				c.stats.hits.Add(1)
+				fc.Replay(e.Response, int(age.Seconds()))
+				return
+			case age < p.TTL+p.StaleWhileRevalidate:
+				c.stats.staleHits.Add(1)
+				fc.Replay(e.Response, int(age.Seconds()))
+				c.revalidate(key, h, fc.Request())
+				return
+			case age < p.TTL+p.StaleIfError:
+				c.stats.misses.Add(1)
+				c.serveOrStale(fc, key, e, h)
+				return
+			}
+		}
+
+		c.stats.misses.Add(1)
+		fc.Record()
+		h(fc)
+		c.store(key, fc.Recorded())
+	}
+}
+
+// serveOrStale runs h with its response held, answering with the stale
+// entry instead if h fails
+func (c *Cache) serveOrStale(fc *http.FDContext, key string, e *Entry, h func(ctx http.Context)) {
+	fc.Hold()
+	fc.Record()
+	h(fc)
+
+	r := fc.Recorded()
+	if r != nil && r.Status >= 500 && fc.Discard() {
+		c.stats.staleOnError.Add(1)
+		fc.Replay(e.Response, int(c.clock.Now().Sub(e.Stored).Seconds()))
+		return
+	}
+	fc.Release()
+	c.store(key, r)
+}
+
+// revalidate refreshes key in the background unless a refresh is running
+func (c *Cache) revalidate(key string, h func(ctx http.Context), req *http.Request) {
+	c.mu.Lock()
+	if _, busy := c.refreshing[key]; busy {
+		c.mu.Unlock()
+		return
+	}
+	key = strings.Clone(key)
+	c.refreshing[key] = struct{}{}
+	c.mu.Unlock()
+
+	req = req.Clone()
+	task := func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+		c.refresh(key, h, req)
+	}
+	if !c.pool.Submit(task) {
+		go task()
+	}
+}
+
+// refresh runs h for req without a client and stores its response. A
+// failed refresh keeps the stale entry.
+func (c *Cache) refresh(key string, h func(ctx http.Context), req *http.Request) {
+	c.stats.refreshes.Add(1)
+	defer func() {
+		if err := recover(); err != nil {
+			c.stats.refreshErrors.Add(1)
+			log.Printf("cache: refresh of %s panicked: %v", key, err)
+		}
+	}()
+
+	var discard []byte
+	ctx := http.NewFDContext(-1, req)
+	ctx.SetBatch(&discard)
+	ctx.Record()
+	h(ctx)
+
+	if !c.store(key, ctx.Recorded()) {
+		c.stats.refreshErrors.Add(1)
+	}
+}
+
+// store caches r if it is cacheable
+func (c *Cache) store(key string, r *http.RecordedResponse) bool {
+	if !cacheable(r) {
+		return false
+	}
+	c.Set(key, r)
+	return true
+}
+
+// cacheable reports whether r may be stored
+func cacheable(r *http.RecordedResponse) bool {
+	if r == nil || r.Status != 200 || len(r.Cookies) > 0 {
+		return false
+	}
+	cc := strings.ToLower(r.Header["Cache-Control"])
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "private")
+}
+
+// key returns the policy's cache key for ctx
+func (p Policy) key(ctx *http.FDContext) string {
+	if p.Key != nil {
+		return p.Key(ctx)
+	}
+	req := ctx.Request()
+	if req.QueryLen() == 0 {
+		return ctx.Path()
+	}
+
+	names := make([]string, 0, req.QueryLen())
+	for name := range req.QueryParams() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(ctx.Path())
+	for i, name := range names {
+		if i == 0 {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte('&')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(req.QueryValue(name))
+	}
+	return b.String()
+}
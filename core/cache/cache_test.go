@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/searchktools/fast-server/core/clock"
+	"github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/pools"
+)
+
+func newEntry(size int) *Entry {
+	return &Entry{Response: &http.RecordedResponse{Status: 200}, size: size}
+}
+
+func TestLRUEviction(t *testing.T) {
+	l := NewLRU(100)
+	l.Set("a", newEntry(40))
+	l.Set("b", newEntry(40))
+	l.Get("a")
+	l.Set("c", newEntry(40))
+
+	if _, ok := l.Get("b"); ok {
+		t.Error("Least recently used entry should be evicted")
+	}
+	if _, ok := l.Get("a"); !ok {
+		t.Error("Recently used entry should stay")
+	}
+	if l.Bytes() != 80 || l.Len() != 2 {
+		t.Errorf("Expected 2 entries / 80 bytes, got %d / %d", l.Len(), l.Bytes())
+	}
+	if l.Set("huge", newEntry(101)) {
+		t.Error("Entry larger than the LRU should be rejected")
+	}
+}
+
+func TestCacheLevels(t *testing.T) {
+	c := New(Config{Levels: []int{100, 1000}})
+	body := &http.RecordedResponse{Status: 200, Body: make([]byte, 60)}
+	c.Set("a", body)
+	c.Set("b", body)
+
+	// "a" was demoted to the second level
+	if _, ok := c.levels[1].Get("a"); !ok {
+		t.Fatal("Evicted entry should move to the next level")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Entry on a lower level should be found")
+	}
+	if _, ok := c.levels[0].Get("a"); !ok {
+		t.Error("Hit on a lower level should promote the entry")
+	}
+}
+
+// serve runs handler for a GET of path and returns the raw response
+func serve(t *testing.T, handler func(http.Context), path string) string {
+	t.Helper()
+	out, err := http.ServeRaw(&http.Request{Method: "GET", Path: path}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+	pool := pools.NewWorkerPool(1)
+	defer pool.Close()
+	c := New(Config{Pool: pool, Clock: fake})
+
+	version := 1
+	refreshed := make(chan struct{}, 1)
+	h := c.Handler(Policy{TTL: time.Minute, StaleWhileRevalidate: time.Minute}, func(ctx http.Context) {
+		ctx.String(200, strings.Repeat("v", version))
+		if version > 1 {
+			refreshed <- struct{}{}
+		}
+	})
+
+	if out := serve(t, h, "/r"); !strings.HasSuffix(out, "\r\n\r\nv") {
+		t.Fatalf("Miss should run the handler, got %q", out)
+	}
+	version = 2
+	if out := serve(t, h, "/r"); !strings.Contains(out, "Age: 0") || !strings.HasSuffix(out, "\r\n\r\nv") {
+		t.Fatalf("Fresh entry should be served from cache, got %q", out)
+	}
+
+	fake.Advance(90 * time.Second)
+	if out := serve(t, h, "/r"); !strings.HasSuffix(out, "\r\n\r\nv") {
+		t.Fatalf("Stale entry should be served while revalidating, got %q", out)
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("Background refresh did not run")
+	}
+	// The refresh stores its response after the handler returns
+	for busy := true; busy; {
+		c.mu.Lock()
+		busy = len(c.refreshing) > 0
+		c.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+
+	if out := serve(t, h, "/r"); !strings.HasSuffix(out, "\r\n\r\nvv") {
+		t.Errorf("Refreshed entry should be served, got %q", out)
+	}
+	if s := c.Stats(); s.Hits != 2 || s.StaleHits != 1 || s.Misses != 1 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+}
+
+func TestCacheStaleIfError(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+	c := New(Config{Clock: fake})
+
+	fail := false
+	h := c.Handler(Policy{TTL: time.Minute, StaleIfError: time.Hour}, func(ctx http.Context) {
+		if fail {
+			ctx.String(503, "down")
+			return
+		}
+		ctx.String(200, "ok")
+	})
+
+	serve(t, h, "/e")
+	fail = true
+	fake.Advance(10 * time.Minute)
+	if out := serve(t, h, "/e"); !strings.HasPrefix(out, "HTTP/1.1 200") || !strings.HasSuffix(out, "ok") {
+		t.Errorf("Stale entry should replace a 5xx, got %q", out)
+	}
+
+	fake.Advance(2 * time.Hour)
+	if out := serve(t, h, "/e"); !strings.HasPrefix(out, "HTTP/1.1 503") {
+		t.Errorf("Expired entry should not be served, got %q", out)
+	}
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU is a byte-bounded least-recently-used map of cache entries, safe
+// for concurrent use
+type LRU struct {
+	mu       sync.Mutex
+	items    map[string]*list.Element
+	order    *list.List
+	bytes    int
+	maxBytes int
+
+	// onEvict receives entries pushed out to make room (called with mu held)
+	onEvict func(key string, e *Entry)
+}
+
+type lruItem struct {
+	key   string
+	entry *Entry
+}
+
+// NewLRU creates an LRU holding up to maxBytes of responses
+func NewLRU(maxBytes int) *LRU {
+	return &LRU{
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+		maxBytes: maxBytes,
+	}
+}
+
+// Get returns the entry for key and marks it most recently used
+func (l *LRU) Get(key string) (*Entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+// Set stores e under key, evicting least recently used entries to make
+// room. It reports false if e is larger than the whole LRU.
+func (l *LRU) Set(key string, e *Entry) bool {
+	if e.size > l.maxBytes {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		item := el.Value.(*lruItem)
+		l.bytes += e.size - item.entry.size
+		item.entry = e
+		l.order.MoveToFront(el)
+	} else {
+		l.items[key] = l.order.PushFront(&lruItem{key: key, entry: e})
+		l.bytes += e.size
+	}
+
+	for l.bytes > l.maxBytes {
+		oldest := l.order.Back()
+		item := oldest.Value.(*lruItem)
+		l.removeElement(oldest)
+		if l.onEvict != nil {
+			l.onEvict(item.key, item.entry)
+		}
+	}
+	return true
+}
+
+// Remove deletes key, returning its entry if present
+func (l *LRU) Remove(key string) (*Entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.removeElement(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+// Len returns the number of entries
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// Bytes returns the approximate memory held by the entries
+func (l *LRU) Bytes() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.bytes
+}
+
+func (l *LRU) removeElement(el *list.Element) {
+	item := el.Value.(*lruItem)
+	delete(l.items, item.key)
+	l.order.Remove(el)
+	l.bytes -= item.entry.size
+}
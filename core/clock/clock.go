@@ -0,0 +1,134 @@
+package clock
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is a time source. Hot paths take a Clock so tests can use a Fake.
+type Clock interface {
+	Now() time.Time
+}
+
+// System reads the wall clock on every call
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// Coarse is a clock advanced by a single goroutine at a fixed resolution.
+// Now is an atomic load, so per-event code avoids time.Now() calls.
+// It also caches the HTTP Date header value, refreshed once per second.
+type Coarse struct {
+	now  atomic.Int64 // Unix nanoseconds
+	date atomic.Pointer[[]byte]
+	sec  int64
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewCoarse starts a coarse clock updated every resolution
+func NewCoarse(resolution time.Duration) *Coarse {
+	if resolution <= 0 {
+		resolution = time.Millisecond
+	}
+	c := &Coarse{stop: make(chan struct{})}
+	c.update(time.Now())
+	go c.run(resolution)
+	return c
+}
+
+// Now returns the time as of the last tick
+func (c *Coarse) Now() time.Time {
+	return time.Unix(0, c.now.Load())
+}
+
+// AppendDate appends the current time in HTTP Date format (RFC 7231)
+func (c *Coarse) AppendDate(b []byte) []byte {
+	return append(b, *c.date.Load()...)
+}
+
+// Stop stops the update goroutine; Now keeps returning the last tick
+func (c *Coarse) Stop() {
+	c.once.Do(func() { close(c.stop) })
+}
+
+func (c *Coarse) run(resolution time.Duration) {
+	ticker := time.NewTicker(resolution)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			c.update(now)
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// update is only called by the clock goroutine (and once by NewCoarse)
+func (c *Coarse) update(now time.Time) {
+	c.now.Store(now.UnixNano())
+	if sec := now.Unix(); sec != c.sec || c.date.Load() == nil {
+		c.sec = sec
+		date := []byte(now.UTC().Format(http.TimeFormat))
+		c.date.Store(&date)
+	}
+}
+
+var defaultCoarse = sync.OnceValue(func() *Coarse {
+	return NewCoarse(time.Millisecond)
+})
+
+// Default returns the process-wide coarse clock (1ms resolution),
+// started on first use
+func Default() *Coarse {
+	return defaultCoarse()
+}
+
+// Now returns the time from the default coarse clock
+func Now() time.Time {
+	return Default().Now()
+}
+
+// AppendDate appends the HTTP Date from the default coarse clock
+func AppendDate(b []byte) []byte {
+	return Default().AppendDate(b)
+}
+
+// Fake is a manually advanced clock for tests
+type Fake struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// NewFake creates a fake clock set to t
+func NewFake(t time.Time) *Fake {
+	return &Fake{t: t}
+}
+
+// Now returns the fake time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.t
+}
+
+// Advance moves the fake time forward by d
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.t = f.t.Add(d)
+	f.mu.Unlock()
+}
+
+// Set sets the fake time
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	f.t = t
+	f.mu.Unlock()
+}
@@ -0,0 +1,51 @@
+package clock
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCoarseAdvances(t *testing.T) {
+	c := NewCoarse(time.Millisecond)
+	defer c.Stop()
+
+	start := c.Now()
+	if d := time.Since(start); d < 0 || d > time.Second {
+		t.Fatalf("coarse time %v too far from wall clock", start)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if !c.Now().After(start) {
+		t.Error("coarse clock did not advance")
+	}
+}
+
+func TestCoarseDate(t *testing.T) {
+	c := NewCoarse(time.Millisecond)
+	defer c.Stop()
+
+	date := string(c.AppendDate(nil))
+	parsed, err := http.ParseTime(date)
+	if err != nil {
+		t.Fatalf("invalid Date %q: %v", date, err)
+	}
+	if d := time.Since(parsed); d < -time.Second || d > 2*time.Second {
+		t.Errorf("Date %q too far from wall clock", date)
+	}
+}
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+
+	f.Advance(1500 * time.Millisecond)
+	if got := f.Now().Sub(start); got != 1500*time.Millisecond {
+		t.Errorf("expected 1.5s after Advance, got %v", got)
+	}
+
+	f.Set(start)
+	if !f.Now().Equal(start) {
+		t.Errorf("expected %v after Set, got %v", start, f.Now())
+	}
+}
@@ -0,0 +1,109 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRollingRestartOneAtATime(t *testing.T) {
+	backend := NewMemoryBackend()
+	ctx := context.Background()
+
+	coords := make([]*Coordinator, 3)
+	for i := range coords {
+		coords[i] = NewCoordinator(Config{
+			InstanceID:   string(rune('a' + i)),
+			Backend:      backend,
+			MinAvailable: 2,
+			PollInterval: 5 * time.Millisecond,
+		})
+		if err := coords[i].Start(ctx); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		defer coords[i].Stop(ctx)
+	}
+
+	var active, maxActive, restarts atomic.Int32
+	var wg sync.WaitGroup
+	for _, c := range coords {
+		wg.Add(1)
+		go func(c *Coordinator) {
+			defer wg.Done()
+			err := c.RollingRestart(ctx, func(ctx context.Context) error {
+				n := active.Add(1)
+				if n > maxActive.Load() {
+					maxActive.Store(n)
+				}
+				time.Sleep(20 * time.Millisecond)
+				active.Add(-1)
+				restarts.Add(1)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("RollingRestart failed: %v", err)
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	if restarts.Load() != 3 {
+		t.Errorf("Expected 3 restarts, got %d", restarts.Load())
+	}
+	if maxActive.Load() != 1 {
+		t.Errorf("Expected one restart at a time, got %d concurrent", maxActive.Load())
+	}
+
+	members, _ := backend.Members(ctx)
+	if len(members) != 3 {
+		t.Errorf("Expected all instances serving again, got %v", members)
+	}
+}
+
+func TestRollingRestartRespectsFloor(t *testing.T) {
+	backend := NewMemoryBackend()
+	c := NewCoordinator(Config{
+		InstanceID:   "solo",
+		Backend:      backend,
+		MinAvailable: 1,
+		PollInterval: 5 * time.Millisecond,
+	})
+	c.Start(context.Background())
+	defer c.Stop(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	err := c.RollingRestart(ctx, func(context.Context) error {
+		t.Error("Restart should not run below the capacity floor")
+		return nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestReadRESP(t *testing.T) {
+	rd := bufio.NewReader(strings.NewReader("*2\r\n$1\r\na\r\n:7\r\n-ERR boom\r\n$-1\r\n"))
+
+	reply, err := readRESP(rd)
+	if err != nil {
+		t.Fatalf("readRESP failed: %v", err)
+	}
+	items := reply.([]any)
+	if items[0] != "a" || items[1] != int64(7) {
+		t.Errorf("Unexpected array reply %v", items)
+	}
+
+	if _, err := readRESP(rd); err == nil || err.Error() != "redis: ERR boom" {
+		t.Errorf("Expected error reply, got %v", err)
+	}
+
+	if reply, err := readRESP(rd); reply != nil || err != nil {
+		t.Errorf("Expected nil bulk, got %v %v", reply, err)
+	}
+}
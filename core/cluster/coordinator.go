@@ -0,0 +1,198 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCoordinatorStopped = errors.New("coordinator stopped")
+)
+
+// Backend is the shared state used to coordinate restarts across instances.
+// Implementations must make TryLock atomic across all instances.
+type Backend interface {
+	// TryLock acquires key for owner if it is free or expired
+	TryLock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// Unlock releases key if it is still held by owner
+	Unlock(ctx context.Context, key, owner string) error
+	// Heartbeat marks instance as serving until ttl elapses
+	Heartbeat(ctx context.Context, instance string, ttl time.Duration) error
+	// Leave removes instance from the serving set immediately
+	Leave(ctx context.Context, instance string) error
+	// Members returns the instances currently serving
+	Members(ctx context.Context) ([]string, error)
+}
+
+// Config configures a restart coordinator
+type Config struct {
+	InstanceID   string        // Unique ID of this instance
+	Backend      Backend       // Shared coordination backend
+	LockKey      string        // Restart lock key (default: "fast-server:restart")
+	LockTTL      time.Duration // Lock lease, must exceed a restart (default: 5m)
+	MemberTTL    time.Duration // Heartbeat lease (default: 15s)
+	MinAvailable int           // Serving instances that must remain while one restarts
+	PollInterval time.Duration // Retry interval while waiting (default: 1s)
+}
+
+// Coordinator staggers graceful restarts so only one instance restarts at
+// a time and aggregate capacity stays at or above MinAvailable
+type Coordinator struct {
+	cfg Config
+
+	mu         sync.Mutex
+	restarting bool
+	stopCh     chan struct{}
+	stopOnce   sync.Once
+}
+
+// NewCoordinator creates a restart coordinator
+func NewCoordinator(cfg Config) *Coordinator {
+	if cfg.LockKey == "" {
+		cfg.LockKey = "fast-server:restart"
+	}
+	if cfg.LockTTL == 0 {
+		cfg.LockTTL = 5 * time.Minute
+	}
+	if cfg.MemberTTL == 0 {
+		cfg.MemberTTL = 15 * time.Second
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = time.Second
+	}
+
+	return &Coordinator{
+		cfg:    cfg,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start begins heartbeating this instance into the serving set
+func (c *Coordinator) Start(ctx context.Context) error {
+	if err := c.cfg.Backend.Heartbeat(ctx, c.cfg.InstanceID, c.cfg.MemberTTL); err != nil {
+		return err
+	}
+	go c.heartbeatLoop(ctx)
+	return nil
+}
+
+// Stop stops heartbeating and leaves the serving set
+func (c *Coordinator) Stop(ctx context.Context) error {
+	c.stopOnce.Do(func() { close(c.stopCh) })
+	return c.cfg.Backend.Leave(ctx, c.cfg.InstanceID)
+}
+
+func (c *Coordinator) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.MemberTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			restarting := c.restarting
+			c.mu.Unlock()
+			if restarting {
+				continue
+			}
+			if err := c.cfg.Backend.Heartbeat(ctx, c.cfg.InstanceID, c.cfg.MemberTTL); err != nil {
+				log.Printf("⚠️  cluster: heartbeat failed: %v", err)
+			}
+		}
+	}
+}
+
+// RollingRestart waits for this instance's turn and runs restart.
+//
+// The turn comes when the restart lock is free and, without this instance,
+// at least MinAvailable instances are still serving. The instance leaves the
+// serving set before restart runs and rejoins after it returns.
+func (c *Coordinator) RollingRestart(ctx context.Context, restart func(ctx context.Context) error) error {
+	if err := c.acquireTurn(ctx); err != nil {
+		return err
+	}
+	defer c.cfg.Backend.Unlock(context.Background(), c.cfg.LockKey, c.cfg.InstanceID)
+
+	c.mu.Lock()
+	c.restarting = true
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.restarting = false
+		c.mu.Unlock()
+	}()
+
+	if err := c.cfg.Backend.Leave(ctx, c.cfg.InstanceID); err != nil {
+		return err
+	}
+
+	log.Printf("🔄 cluster: %s restarting", c.cfg.InstanceID)
+	restartErr := restart(ctx)
+
+	if err := c.cfg.Backend.Heartbeat(ctx, c.cfg.InstanceID, c.cfg.MemberTTL); err != nil && restartErr == nil {
+		return err
+	}
+	return restartErr
+}
+
+// acquireTurn blocks until the lock is held and capacity allows a restart
+func (c *Coordinator) acquireTurn(ctx context.Context) error {
+	for {
+		ok, err := c.tryTurn(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.stopCh:
+			return ErrCoordinatorStopped
+		case <-time.After(c.cfg.PollInterval):
+		}
+	}
+}
+
+func (c *Coordinator) tryTurn(ctx context.Context) (bool, error) {
+	if !c.hasCapacity(ctx) {
+		return false, nil
+	}
+
+	ok, err := c.cfg.Backend.TryLock(ctx, c.cfg.LockKey, c.cfg.InstanceID, c.cfg.LockTTL)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	// Re-check under the lock: another instance may have left meanwhile
+	if !c.hasCapacity(ctx) {
+		c.cfg.Backend.Unlock(ctx, c.cfg.LockKey, c.cfg.InstanceID)
+		return false, nil
+	}
+	return true, nil
+}
+
+// hasCapacity reports whether enough other instances are serving
+func (c *Coordinator) hasCapacity(ctx context.Context) bool {
+	members, err := c.cfg.Backend.Members(ctx)
+	if err != nil {
+		return false
+	}
+
+	others := 0
+	for _, m := range members {
+		if m != c.cfg.InstanceID {
+			others++
+		}
+	}
+	return others >= c.cfg.MinAvailable
+}
@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryBackend is an in-process Backend for tests and single-host setups
+type MemoryBackend struct {
+	mu      sync.Mutex
+	locks   map[string]memoryLock
+	members map[string]time.Time // instance -> expiry
+}
+
+type memoryLock struct {
+	owner  string
+	expiry time.Time
+}
+
+// NewMemoryBackend creates an in-process backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		locks:   make(map[string]memoryLock),
+		members: make(map[string]time.Time),
+	}
+}
+
+// TryLock implements Backend
+func (b *MemoryBackend) TryLock(_ context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if l, ok := b.locks[key]; ok && l.owner != owner && now.Before(l.expiry) {
+		return false, nil
+	}
+	b.locks[key] = memoryLock{owner: owner, expiry: now.Add(ttl)}
+	return true, nil
+}
+
+// Unlock implements Backend
+func (b *MemoryBackend) Unlock(_ context.Context, key, owner string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if l, ok := b.locks[key]; ok && l.owner == owner {
+		delete(b.locks, key)
+	}
+	return nil
+}
+
+// Heartbeat implements Backend
+func (b *MemoryBackend) Heartbeat(_ context.Context, instance string, ttl time.Duration) error {
+	b.mu.Lock()
+	b.members[instance] = time.Now().Add(ttl)
+	b.mu.Unlock()
+	return nil
+}
+
+// Leave implements Backend
+func (b *MemoryBackend) Leave(_ context.Context, instance string) error {
+	b.mu.Lock()
+	delete(b.members, instance)
+	b.mu.Unlock()
+	return nil
+}
+
+// Members implements Backend
+func (b *MemoryBackend) Members(_ context.Context) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	members := make([]string, 0, len(b.members))
+	for id, expiry := range b.members {
+		if now.After(expiry) {
+			delete(b.members, id)
+			continue
+		}
+		members = append(members, id)
+	}
+	sort.Strings(members)
+	return members, nil
+}
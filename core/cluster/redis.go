@@ -0,0 +1,250 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Lua scripts keep lock ownership checks atomic on the server
+const (
+	redisLockScript = `local v = redis.call('GET', KEYS[1])
+if v == false or v == ARGV[1] then
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+  return 1
+end
+return 0`
+
+	redisUnlockScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0`
+)
+
+// RedisBackend is a Backend on a single Redis server, speaking RESP directly.
+// Members are kept in a sorted set scored by heartbeat expiry.
+type RedisBackend struct {
+	addr       string
+	password   string
+	membersKey string
+	timeout    time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// RedisConfig configures a RedisBackend
+type RedisConfig struct {
+	Addr       string        // host:port
+	Password   string        // AUTH password (optional)
+	MembersKey string        // Sorted set of serving instances (default: "fast-server:members")
+	Timeout    time.Duration // Per-command timeout (default: 3s)
+}
+
+// NewRedisBackend creates a Redis backend. The connection is dialed lazily.
+func NewRedisBackend(cfg RedisConfig) *RedisBackend {
+	if cfg.MembersKey == "" {
+		cfg.MembersKey = "fast-server:members"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 3 * time.Second
+	}
+	return &RedisBackend{
+		addr:       cfg.Addr,
+		password:   cfg.Password,
+		membersKey: cfg.MembersKey,
+		timeout:    cfg.Timeout,
+	}
+}
+
+// TryLock implements Backend
+func (b *RedisBackend) TryLock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	reply, err := b.do(ctx, "EVAL", redisLockScript, "1", key, owner, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	n, _ := reply.(int64)
+	return n == 1, nil
+}
+
+// Unlock implements Backend
+func (b *RedisBackend) Unlock(ctx context.Context, key, owner string) error {
+	_, err := b.do(ctx, "EVAL", redisUnlockScript, "1", key, owner)
+	return err
+}
+
+// Heartbeat implements Backend
+func (b *RedisBackend) Heartbeat(ctx context.Context, instance string, ttl time.Duration) error {
+	expiry := time.Now().Add(ttl).UnixMilli()
+	_, err := b.do(ctx, "ZADD", b.membersKey, strconv.FormatInt(expiry, 10), instance)
+	return err
+}
+
+// Leave implements Backend
+func (b *RedisBackend) Leave(ctx context.Context, instance string) error {
+	_, err := b.do(ctx, "ZREM", b.membersKey, instance)
+	return err
+}
+
+// Members implements Backend
+func (b *RedisBackend) Members(ctx context.Context) ([]string, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if _, err := b.do(ctx, "ZREMRANGEBYSCORE", b.membersKey, "-inf", "("+now); err != nil {
+		return nil, err
+	}
+
+	reply, err := b.do(ctx, "ZRANGE", b.membersKey, "0", "-1")
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]any)
+	members := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			members = append(members, s)
+		}
+	}
+	return members, nil
+}
+
+// Close closes the Redis connection
+func (b *RedisBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		return nil
+	}
+	err := b.conn.Close()
+	b.conn = nil
+	return err
+}
+
+// do sends one command and reads its reply, redialing after errors
+func (b *RedisBackend) do(ctx context.Context, args ...string) (any, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conn == nil {
+		if err := b.dial(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := b.roundTrip(ctx, args)
+	if err != nil {
+		var redisErr redisError
+		if !errors.As(err, &redisErr) {
+			b.conn.Close()
+			b.conn = nil
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (b *RedisBackend) dial(ctx context.Context) error {
+	d := net.Dialer{Timeout: b.timeout}
+	conn, err := d.DialContext(ctx, "tcp", b.addr)
+	if err != nil {
+		return fmt.Errorf("redis dial: %w", err)
+	}
+	b.conn = conn
+	b.rd = bufio.NewReader(conn)
+
+	if b.password != "" {
+		if _, err := b.roundTrip(ctx, []string{"AUTH", b.password}); err != nil {
+			conn.Close()
+			b.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *RedisBackend) roundTrip(ctx context.Context, args []string) (any, error) {
+	deadline := time.Now().Add(b.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	b.conn.SetDeadline(deadline)
+
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, "\r\n"...)
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, "\r\n"...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+
+	if _, err := b.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return readRESP(b.rd)
+}
+
+// redisError is an error reply from the server (connection stays usable)
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// readRESP reads a single RESP2 reply
+func readRESP(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, errors.New("redis: malformed reply")
+	}
+	body := line[1 : len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readRESP(rd); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type %q", line[0])
+	}
+}
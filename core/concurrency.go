@@ -0,0 +1,149 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// concurrencyLimit bounds the in-flight requests of a route, parking the
+// overflow in a bounded FIFO queue (see MaxConcurrent)
+type concurrencyLimit struct {
+	max     int
+	queue   int
+	timeout time.Duration
+
+	mu      sync.Mutex
+	active  int
+	waiting []*waiter
+}
+
+// waiter is a request queued for a slot. It holds the request's handle
+// rather than its context, which is recycled if the request is answered
+// while it waits (e.g. it times out).
+type waiter struct {
+	handle *http.AsyncHandle
+	start  func()
+	timer  *time.Timer
+	done   bool // Admitted or expired
+}
+
+// withConcurrencyLimit wraps handler to run under l. Queued requests run
+// inner (the handler before Offload wrapped it) on the worker pool once
+// admitted.
+func withConcurrencyLimit(handler, inner HandlerFunc, l *concurrencyLimit) HandlerFunc {
+	return func(ctx http.Context) {
+		fc, ok := ctx.(*http.FDContext)
+		if !ok {
+			handler(ctx)
+			return
+		}
+
+		l.mu.Lock()
+		switch {
+		case l.active < l.max:
+			l.active++
+			l.mu.Unlock()
+			l.run(fc, handler)
+		case len(l.waiting) >= l.queue:
+			l.mu.Unlock()
+			l.reject(fc)
+		default:
+			l.mu.Unlock()
+			fc.DetachAfter(func(start func()) {
+				handle := fc.Async()
+				l.enqueue(handle, func() {
+					handle.OnComplete(l.release)
+					start()
+				})
+			}, inner)
+		}
+	}
+}
+
+// run serves a request that holds a slot, freeing the slot once the
+// response is complete
+func (l *concurrencyLimit) run(fc *http.FDContext, handler HandlerFunc) {
+	returned := false
+	defer func() {
+		if !returned {
+			l.release() // Panicking; the engine recovers
+		}
+	}()
+	handler(fc)
+	returned = true
+
+	if fc.IsAsync() {
+		fc.Async().OnComplete(l.release)
+	} else {
+		l.release()
+	}
+}
+
+// enqueue queues a parked request, or starts or rejects it if a slot
+// freed or the queue filled up since its handler ran
+func (l *concurrencyLimit) enqueue(handle *http.AsyncHandle, start func()) {
+	l.mu.Lock()
+	if l.active < l.max {
+		l.active++
+		l.mu.Unlock()
+		start()
+		return
+	}
+	if len(l.waiting) >= l.queue {
+		l.mu.Unlock()
+		handle.Respond(l.reject)
+		return
+	}
+	w := &waiter{handle: handle, start: start}
+	l.waiting = append(l.waiting, w)
+	if l.timeout > 0 {
+		w.timer = time.AfterFunc(l.timeout, func() { l.expire(w) })
+	}
+	l.mu.Unlock()
+}
+
+// release frees a slot, handing it to the oldest queued request
+func (l *concurrencyLimit) release() {
+	l.mu.Lock()
+	if len(l.waiting) == 0 {
+		l.active--
+		l.mu.Unlock()
+		return
+	}
+	w := l.waiting[0]
+	l.waiting[0] = nil
+	l.waiting = l.waiting[1:]
+	w.done = true
+	l.mu.Unlock()
+
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.start()
+}
+
+// expire rejects a request that waited for the timeout
+func (l *concurrencyLimit) expire(w *waiter) {
+	l.mu.Lock()
+	if w.done {
+		l.mu.Unlock()
+		return
+	}
+	w.done = true
+	for i, other := range l.waiting {
+		if other == w {
+			l.waiting = append(l.waiting[:i], l.waiting[i+1:]...)
+			break
+		}
+	}
+	l.mu.Unlock()
+
+	w.handle.Respond(l.reject)
+}
+
+// reject answers 429, suggesting a retry once the queue could have moved
+func (l *concurrencyLimit) reject(ctx http.Context) {
+	ctx.(*http.FDContext).Backoff(429, l.timeout)
+}
@@ -0,0 +1,203 @@
+package connlimit
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/searchktools/fast-server/core/clock"
+)
+
+// Kind identifies a class of long-lived connection
+type Kind int
+
+const (
+	WebSocket Kind = iota
+	SSE
+	numKinds
+)
+
+// String returns the kind name used in stats
+func (k Kind) String() string {
+	switch k {
+	case WebSocket:
+		return "websocket"
+	case SSE:
+		return "sse"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	// ErrTooManyConnections is returned when a kind's concurrency limit is reached
+	ErrTooManyConnections = errors.New("too many long-lived connections")
+	// ErrUpgradeRateLimited is returned when an IP upgrades faster than allowed
+	ErrUpgradeRateLimited = errors.New("upgrade rate limit exceeded")
+)
+
+// Config configures upgrade limits. Zero values disable a limit.
+type Config struct {
+	MaxWebSocket int     // Max concurrent WebSocket connections
+	MaxSSE       int     // Max concurrent SSE streams
+	MaxTotal     int     // Max concurrent long-lived connections of any kind
+	PerIPRate    float64 // Upgrades per second allowed per client IP
+	PerIPBurst   int     // Upgrade burst per client IP (default: 1 when PerIPRate is set)
+
+	Clock clock.Clock // Time source for rate buckets (default: coarse clock)
+}
+
+// Limiter caps long-lived connections (WebSocket, SSE) separately from
+// regular HTTP traffic, so a burst of upgrades cannot exhaust the server's
+// connection budget. It is safe for concurrent use.
+type Limiter struct {
+	cfg Config
+
+	active   [numKinds]atomic.Int64
+	total    atomic.Int64
+	accepted [numKinds]atomic.Uint64
+	rejected [numKinds]atomic.Uint64
+	limited  [numKinds]atomic.Uint64
+
+	mu        sync.Mutex
+	buckets   map[string]*ipBucket
+	lastSweep time.Time
+}
+
+type ipBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Stats contains upgrade limiter statistics for one kind
+type Stats struct {
+	Active      int64  // Currently open connections
+	Accepted    uint64 // Upgrades admitted
+	Rejected    uint64 // Upgrades refused by a concurrency limit
+	RateLimited uint64 // Upgrades refused by the per-IP rate limit
+}
+
+// New creates an upgrade limiter
+func New(cfg Config) *Limiter {
+	if cfg.PerIPRate > 0 && cfg.PerIPBurst <= 0 {
+		cfg.PerIPBurst = 1
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Default()
+	}
+	return &Limiter{
+		cfg:     cfg,
+		buckets: make(map[string]*ipBucket),
+	}
+}
+
+// Acquire admits one connection of kind from remoteAddr ("ip:port" or a
+// bare IP; empty skips the per-IP check). On success the returned release
+// func must be called exactly once when the connection closes.
+func (l *Limiter) Acquire(kind Kind, remoteAddr string) (release func(), err error) {
+	if remoteAddr != "" && l.cfg.PerIPRate > 0 && !l.allowIP(hostOf(remoteAddr)) {
+		l.limited[kind].Add(1)
+		return nil, ErrUpgradeRateLimited
+	}
+
+	if max := l.maxFor(kind); l.active[kind].Add(1) > int64(max) && max > 0 {
+		l.active[kind].Add(-1)
+		l.rejected[kind].Add(1)
+		return nil, ErrTooManyConnections
+	}
+	if l.total.Add(1) > int64(l.cfg.MaxTotal) && l.cfg.MaxTotal > 0 {
+		l.total.Add(-1)
+		l.active[kind].Add(-1)
+		l.rejected[kind].Add(1)
+		return nil, ErrTooManyConnections
+	}
+
+	l.accepted[kind].Add(1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.active[kind].Add(-1)
+			l.total.Add(-1)
+		})
+	}, nil
+}
+
+// Active returns the number of open connections of kind
+func (l *Limiter) Active(kind Kind) int64 {
+	return l.active[kind].Load()
+}
+
+// Stats returns statistics for kind
+func (l *Limiter) Stats(kind Kind) Stats {
+	return Stats{
+		Active:      l.active[kind].Load(),
+		Accepted:    l.accepted[kind].Load(),
+		Rejected:    l.rejected[kind].Load(),
+		RateLimited: l.limited[kind].Load(),
+	}
+}
+
+func (l *Limiter) maxFor(kind Kind) int {
+	switch kind {
+	case WebSocket:
+		return l.cfg.MaxWebSocket
+	case SSE:
+		return l.cfg.MaxSSE
+	default:
+		return 0
+	}
+}
+
+// allowIP takes one token from ip's bucket
+func (l *Limiter) allowIP(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.cfg.Clock.Now()
+	l.sweep(now)
+
+	burst := float64(l.cfg.PerIPBurst)
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &ipBucket{tokens: burst, last: now}
+		l.buckets[ip] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.cfg.PerIPRate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep drops buckets that have refilled completely, at most once a minute
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	full := time.Duration(float64(l.cfg.PerIPBurst) / l.cfg.PerIPRate * float64(time.Second))
+	for ip, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, ip)
+		}
+	}
+}
+
+// hostOf strips the port from an "ip:port" address
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
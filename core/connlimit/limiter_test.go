@@ -0,0 +1,82 @@
+package connlimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/searchktools/fast-server/core/clock"
+)
+
+func TestLimiterConcurrency(t *testing.T) {
+	l := New(Config{MaxWebSocket: 2, MaxSSE: 1})
+
+	r1, err := l.Acquire(WebSocket, "")
+	if err != nil {
+		t.Fatalf("first WebSocket: %v", err)
+	}
+	if _, err := l.Acquire(WebSocket, ""); err != nil {
+		t.Fatalf("second WebSocket: %v", err)
+	}
+	if _, err := l.Acquire(WebSocket, ""); !errors.Is(err, ErrTooManyConnections) {
+		t.Fatalf("expected ErrTooManyConnections, got %v", err)
+	}
+
+	// SSE has its own budget
+	if _, err := l.Acquire(SSE, ""); err != nil {
+		t.Fatalf("SSE: %v", err)
+	}
+
+	r1()
+	r1() // release is idempotent
+	if got := l.Active(WebSocket); got != 1 {
+		t.Errorf("expected 1 active WebSocket, got %d", got)
+	}
+	if _, err := l.Acquire(WebSocket, ""); err != nil {
+		t.Errorf("expected slot after release: %v", err)
+	}
+
+	st := l.Stats(WebSocket)
+	if st.Accepted != 3 || st.Rejected != 1 || st.Active != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestLimiterTotal(t *testing.T) {
+	l := New(Config{MaxTotal: 2})
+
+	l.Acquire(WebSocket, "")
+	l.Acquire(SSE, "")
+	if _, err := l.Acquire(SSE, ""); !errors.Is(err, ErrTooManyConnections) {
+		t.Fatalf("expected total limit, got %v", err)
+	}
+	if got := l.Active(SSE); got != 1 {
+		t.Errorf("rejected acquire must not count as active, got %d", got)
+	}
+}
+
+func TestLimiterPerIPRate(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	l := New(Config{PerIPRate: 1, PerIPBurst: 2, Clock: fake})
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.Acquire(WebSocket, "10.0.0.1:1234"); err != nil {
+			t.Fatalf("burst upgrade %d: %v", i, err)
+		}
+	}
+	if _, err := l.Acquire(SSE, "10.0.0.1:5678"); !errors.Is(err, ErrUpgradeRateLimited) {
+		t.Fatalf("expected rate limit across ports and kinds, got %v", err)
+	}
+	if _, err := l.Acquire(WebSocket, "10.0.0.2:1234"); err != nil {
+		t.Errorf("other IP should not be limited: %v", err)
+	}
+	if st := l.Stats(SSE); st.RateLimited != 1 {
+		t.Errorf("expected 1 rate-limited SSE upgrade, got %+v", st)
+	}
+
+	// One token refills per second
+	fake.Advance(time.Second)
+	if _, err := l.Acquire(WebSocket, "10.0.0.1:1234"); err != nil {
+		t.Errorf("expected refilled token: %v", err)
+	}
+}
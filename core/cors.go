@@ -0,0 +1,109 @@
+package core
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/middleware"
+)
+
+// corsPolicy is the engine's CORS configuration (see SetCORS)
+type corsPolicy struct {
+	anyOrigin bool
+	origins   map[string]struct{}
+	methods   string // Replaces the route's methods if set
+	headers   string
+	maxAge    time.Duration
+
+	// Prepared preflights by the route's methods (nil: the route has its
+	// own OPTIONS handler), copied on write
+	preflights atomic.Pointer[map[string]*http.Preflight]
+	mu         sync.Mutex
+}
+
+// SetCORS handles CORS in the engine. Preflights (OPTIONS with Origin and
+// Access-Control-Request-Method) to a routed path are answered with 204
+// before request hooks, middleware and routing run, from responses
+// prepared once per set of route methods: Access-Control-Allow-Methods
+// lists the route's methods unless cfg.AllowMethods is set. Other requests
+// from allowed origins get Access-Control-Allow-Origin. Routes with their
+// own OPTIONS handler answer their preflights themselves.
+func (e *Engine) SetCORS(cfg middleware.CORSConfig) {
+	p := &corsPolicy{
+		anyOrigin: len(cfg.AllowOrigins) == 0,
+		origins:   make(map[string]struct{}, len(cfg.AllowOrigins)),
+		methods:   strings.Join(cfg.AllowMethods, ", "),
+		headers:   "Content-Type, Authorization",
+		maxAge:    cfg.MaxAge,
+	}
+	if len(cfg.AllowHeaders) > 0 {
+		p.headers = strings.Join(cfg.AllowHeaders, ", ")
+	}
+	for _, o := range cfg.AllowOrigins {
+		if o == "*" {
+			p.anyOrigin = true
+		}
+		p.origins[o] = struct{}{}
+	}
+	e.cors = p
+}
+
+// serveCORS answers a CORS preflight, reporting true, or adds the
+// Allow-Origin header to a cross-origin request
+func (e *Engine) serveCORS(ctx *http.FDContext) bool {
+	origin := ctx.Header("Origin")
+	if origin == "" {
+		return false
+	}
+	if e.cors.anyOrigin {
+		origin = "*"
+	} else if _, ok := e.cors.origins[origin]; !ok {
+		return false
+	}
+
+	if ctx.Method() == "OPTIONS" && ctx.Header("Access-Control-Request-Method") != "" {
+		if allow := e.router.Allowed(ctx.Path()); allow != "" {
+			if p := e.cors.preflight(allow); p != nil {
+				ctx.WritePreflight(p, origin)
+				return true
+			}
+		}
+	}
+
+	ctx.SetHeader("Access-Control-Allow-Origin", origin)
+	if origin != "*" {
+		ctx.SetHeader("Vary", "Origin")
+	}
+	return false
+}
+
+// preflight returns the prepared preflight for routes allowing methods
+func (p *corsPolicy) preflight(methods string) *http.Preflight {
+	if m := p.preflights.Load(); m != nil {
+		if pf, ok := (*m)[methods]; ok {
+			return pf
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var pf *http.Preflight
+	if !strings.Contains(", "+methods+", ", ", OPTIONS, ") {
+		allow := p.methods
+		if allow == "" {
+			allow = methods
+		}
+		pf = http.NewPreflight(allow, p.headers, p.maxAge)
+	}
+	next := map[string]*http.Preflight{methods: pf}
+	if m := p.preflights.Load(); m != nil {
+		for k, v := range *m {
+			next[k] = v
+		}
+	}
+	p.preflights.Store(&next)
+	return pf
+}
@@ -0,0 +1,271 @@
+// Package dedup gives handlers at-most-once semantics: requests carrying
+// an ID already seen within a time window are answered without running
+// the handler again. Webhook receivers use it to ignore retried deliveries.
+package dedup
+
+import (
+	"hash/maphash"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/searchktools/fast-server/core/clock"
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// Config configures a Window
+type Config struct {
+	// Header carries the request ID. Requests without it are not
+	// deduplicated. Default: Idempotency-Key.
+	Header string
+
+	// TTL is how long an ID is remembered. Default: 1h.
+	TTL time.Duration
+
+	// Shards is the number of independently locked maps. Default: 64.
+	Shards int
+
+	// Replay answers duplicates of completed requests with the recorded
+	// response of the first one. Otherwise, and for responses that could
+	// not be recorded (status-only or streamed), duplicates get 409.
+	Replay bool
+
+	// Clock timestamps IDs. Default: clock.Default().
+	Clock clock.Clock
+}
+
+// Stats are window counters
+type Stats struct {
+	Accepted    uint64 // Requests passed to the handler
+	Rejected    uint64 // Duplicates answered with 409
+	Replayed    uint64 // Duplicates answered with the recorded response
+	Forgotten   uint64 // IDs dropped because the handler failed (5xx or panic)
+	Expired     uint64 // IDs dropped after TTL
+	Passthrough uint64 // Requests without an ID
+}
+
+// entry is a seen ID
+type entry struct {
+	seen     time.Time
+	done     bool
+	response *http.RecordedResponse
+}
+
+// shard is one lock's worth of IDs
+type shard struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+// Window remembers request IDs for a TTL
+type Window struct {
+	header string
+	ttl    time.Duration
+	replay bool
+	clock  clock.Clock
+	seed   maphash.Seed
+	shards []shard
+
+	stats struct {
+		accepted, rejected, replayed    atomic.Uint64
+		forgotten, expired, passthrough atomic.Uint64
+	}
+}
+
+// New creates a Window
+func New(cfg Config) *Window {
+	if cfg.Header == "" {
+		cfg.Header = "Idempotency-Key"
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Hour
+	}
+	if cfg.Shards <= 0 {
+		cfg.Shards = 64
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Default()
+	}
+
+	w := &Window{
+		header: cfg.Header,
+		ttl:    cfg.TTL,
+		replay: cfg.Replay,
+		clock:  cfg.Clock,
+		seed:   maphash.MakeSeed(),
+		shards: make([]shard, cfg.Shards),
+	}
+	for i := range w.shards {
+		w.shards[i].entries = make(map[string]*entry)
+	}
+	return w
+}
+
+// Handler runs h at most once per request ID within the window. A
+// duplicate arriving while the first request is still running gets 409;
+// one arriving later gets the recorded response (with Replay) or 409.
+// IDs whose handler failed with a 5xx are forgotten so the sender's
+// retry is processed. h must respond before returning: use core.Offload
+// for slow handlers rather than ctx.Detach.
+func (w *Window) Handler(h func(ctx http.Context)) func(ctx http.Context) {
+	return func(ctx http.Context) {
+		fc, ok := ctx.(*http.FDContext)
+		if !ok {
+			h(ctx)
+			return
+		}
+		id := fc.Header(w.header)
+		if id == "" {
+			w.stats.passthrough.Add(1)
+			h(ctx)
+			return
+		}
+
+		// Request strings alias the connection's read buffer
+		id = strings.Clone(id)
+		e, first := w.claim(id)
+		if !first {
+			w.duplicate(fc, e)
+			return
+		}
+
+		w.stats.accepted.Add(1)
+		if w.replay {
+			fc.Record()
+		}
+		// A panicking handler did not process the request either
+		finished := false
+		defer func() {
+			if !finished {
+				w.abandon(id, e)
+			}
+		}()
+		h(fc)
+		finished = true
+		w.complete(id, e, fc)
+	}
+}
+
+// Seen reports whether id is remembered
+func (w *Window) Seen(id string) bool {
+	s := w.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	return ok && w.clock.Now().Sub(e.seen) < w.ttl
+}
+
+// Forget drops id so its next request is processed
+func (w *Window) Forget(id string) {
+	s := w.shard(id)
+	s.mu.Lock()
+	delete(s.entries, id)
+	s.mu.Unlock()
+}
+
+// Len returns the number of remembered IDs, including expired ones not
+// yet swept
+func (w *Window) Len() int {
+	n := 0
+	for i := range w.shards {
+		s := &w.shards[i]
+		s.mu.Lock()
+		n += len(s.entries)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Stats returns the window counters
+func (w *Window) Stats() Stats {
+	return Stats{
+		Accepted:    w.stats.accepted.Load(),
+		Rejected:    w.stats.rejected.Load(),
+		Replayed:    w.stats.replayed.Load(),
+		Forgotten:   w.stats.forgotten.Load(),
+		Expired:     w.stats.expired.Load(),
+		Passthrough: w.stats.passthrough.Load(),
+	}
+}
+
+// shard returns the shard holding id
+func (w *Window) shard(id string) *shard {
+	return &w.shards[maphash.String(w.seed, id)%uint64(len(w.shards))]
+}
+
+// claim records id as in flight. It reports false with the existing
+// entry (a snapshot) if id was seen within the TTL.
+func (w *Window) claim(id string) (*entry, bool) {
+	now := w.clock.Now()
+	s := w.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[id]; ok && now.Sub(e.seen) < w.ttl {
+		snapshot := *e
+		return &snapshot, false
+	}
+	// Expired IDs are swept a few times per TTL
+	if now.Sub(s.lastSweep) >= w.ttl/4 {
+		w.sweep(s, now)
+	}
+	e := &entry{seen: now}
+	s.entries[id] = e
+	return e, true
+}
+
+// complete marks id done, keeping the recorded response, or forgets it
+// if the handler failed
+func (w *Window) complete(id string, e *entry, fc *http.FDContext) {
+	if fc.StatusCode() >= 500 {
+		w.abandon(id, e)
+		return
+	}
+
+	s := w.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.done = true
+	if w.replay {
+		e.response = fc.Recorded()
+	}
+}
+
+// abandon forgets id unless it was claimed again since e
+func (w *Window) abandon(id string, e *entry) {
+	s := w.shard(id)
+	s.mu.Lock()
+	if s.entries[id] == e {
+		delete(s.entries, id)
+	}
+	s.mu.Unlock()
+	w.stats.forgotten.Add(1)
+}
+
+// duplicate answers a request whose ID was already seen
+func (w *Window) duplicate(fc *http.FDContext, e *entry) {
+	if e.done && e.response != nil {
+		w.stats.replayed.Add(1)
+		fc.Replay(e.response, -1)
+		return
+	}
+	w.stats.rejected.Add(1)
+	if e.done {
+		fc.Error(409, "Duplicate request")
+	} else {
+		fc.Error(409, "Duplicate request in progress")
+	}
+}
+
+// sweep drops s's expired IDs; callers hold s.mu
+func (w *Window) sweep(s *shard, now time.Time) {
+	for id, e := range s.entries {
+		if now.Sub(e.seen) >= w.ttl {
+			delete(s.entries, id)
+			w.stats.expired.Add(1)
+		}
+	}
+	s.lastSweep = now
+}
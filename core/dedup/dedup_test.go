@@ -0,0 +1,97 @@
+package dedup
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/searchktools/fast-server/core/clock"
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// serve runs handler for a POST carrying id and returns the raw response
+func serve(t *testing.T, handler func(http.Context), id string) string {
+	t.Helper()
+	req := &http.Request{Method: "POST", Path: "/hook"}
+	if id != "" {
+		req.SetHeader("Idempotency-Key", id)
+	}
+	out, err := http.ServeRaw(req, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestWindowRejectsDuplicates(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+	w := New(Config{TTL: time.Minute, Clock: fake})
+
+	calls := 0
+	h := w.Handler(func(ctx http.Context) {
+		calls++
+		ctx.String(200, "processed")
+	})
+
+	if out := serve(t, h, "evt-1"); !strings.HasSuffix(out, "processed") {
+		t.Fatalf("First delivery should run the handler, got %q", out)
+	}
+	if out := serve(t, h, "evt-1"); !strings.HasPrefix(out, "HTTP/1.1 409") {
+		t.Fatalf("Duplicate should get 409, got %q", out)
+	}
+	serve(t, h, "")
+	serve(t, h, "")
+	if calls != 3 {
+		t.Fatalf("Expected 3 handler calls, got %d", calls)
+	}
+
+	fake.Advance(2 * time.Minute)
+	if serve(t, h, "evt-1"); calls != 4 {
+		t.Error("Expired ID should be processed again")
+	}
+
+	st := w.Stats()
+	if st.Accepted != 2 || st.Rejected != 1 || st.Passthrough != 2 {
+		t.Errorf("Unexpected stats %+v", st)
+	}
+}
+
+func TestWindowReplay(t *testing.T) {
+	w := New(Config{Replay: true})
+
+	calls := 0
+	h := w.Handler(func(ctx http.Context) {
+		calls++
+		ctx.String(201, "created")
+	})
+
+	serve(t, h, "order-7")
+	if out := serve(t, h, "order-7"); !strings.HasPrefix(out, "HTTP/1.1 201") || !strings.HasSuffix(out, "created") {
+		t.Fatalf("Duplicate should get the recorded response, got %q", out)
+	}
+	if calls != 1 {
+		t.Errorf("Handler should run once, ran %d times", calls)
+	}
+}
+
+func TestWindowForgetsFailures(t *testing.T) {
+	w := New(Config{})
+
+	fail := true
+	h := w.Handler(func(ctx http.Context) {
+		if fail {
+			ctx.String(503, "busy")
+			return
+		}
+		ctx.String(200, "ok")
+	})
+
+	serve(t, h, "evt-2")
+	if w.Seen("evt-2") {
+		t.Fatal("ID of a failed request should be forgotten")
+	}
+	fail = false
+	if out := serve(t, h, "evt-2"); !strings.HasSuffix(out, "ok") {
+		t.Errorf("Retry after a failure should be processed, got %q", out)
+	}
+}
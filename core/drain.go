@@ -0,0 +1,219 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// ConnSelector picks connections for Connections and Drain. Set fields
+// must all match; the zero selector matches every connection.
+type ConnSelector struct {
+	FD     int    // Descriptor (0: any)
+	IP     string // Client IP or CIDR prefix, e.g. "10.0.4.0/24" (optional)
+	Tenant string // Tenant set by a handler with ctx.SetTenant (optional)
+}
+
+// ConnInfo describes an open connection
+type ConnInfo struct {
+	FD         int           `json:"fd"`
+	RemoteAddr string        `json:"remote_addr"`
+	Tenant     string        `json:"tenant,omitempty"`
+	Active     bool          `json:"active"` // A request is in flight
+	Idle       time.Duration `json:"idle_ns"`
+	Draining   bool          `json:"draining"`
+}
+
+// DrainResult reports what Drain did
+type DrainResult struct {
+	Closed   int `json:"closed"`   // Closed at once
+	Draining int `json:"draining"` // Closed once their request completes
+}
+
+// Connections lists the open connections sel matches. Connections taken
+// over with Hijack are no longer the engine's and are not listed.
+func (e *Engine) Connections(sel ConnSelector) ([]ConnInfo, error) {
+	match, err := sel.matcher()
+	if err != nil {
+		return nil, err
+	}
+	now := e.clock.Now()
+
+	var infos []ConnInfo
+	e.connMu.RLock()
+	for fd, conn := range e.connections {
+		if !match(fd, conn) {
+			continue
+		}
+		info := ConnInfo{
+			FD:       fd,
+			Tenant:   conn.tenant,
+			Active:   conn.state == StateProcessing || conn.state == StateWriting || conn.readOffset > 0,
+			Idle:     now.Sub(conn.lastActive),
+			Draining: conn.draining.Load(),
+		}
+		if addr := http.SockaddrToAddr(conn.peer); addr != nil {
+			info.RemoteAddr = addr.String()
+		}
+		infos = append(infos, info)
+	}
+	e.connMu.RUnlock()
+	return infos, nil
+}
+
+// Drain closes the connections sel matches, e.g. those of a
+// decommissioned load balancer or a misbehaving client. Idle connections
+// are closed at once; busy ones finish their request and are closed after
+// it. With force, busy connections are shut down mid-request too. by and
+// reason are logged for the audit trail.
+func (e *Engine) Drain(sel ConnSelector, force bool, by, reason string) (DrainResult, error) {
+	match, err := sel.matcher()
+	if err != nil {
+		return DrainResult{}, err
+	}
+
+	type target struct {
+		fd   int
+		addr string
+		busy bool
+	}
+	var targets []target
+	e.connMu.RLock()
+	for fd, conn := range e.connections {
+		if !match(fd, conn) {
+			continue
+		}
+		t := target{fd: fd, busy: conn.state == StateProcessing || conn.state == StateWriting || conn.readOffset > 0}
+		if addr := http.SockaddrToAddr(conn.peer); addr != nil {
+			t.addr = addr.String()
+		}
+		conn.draining.Store(true)
+		targets = append(targets, t)
+	}
+	e.connMu.RUnlock()
+
+	var res DrainResult
+	for _, t := range targets {
+		action := "closed"
+		switch {
+		case !t.busy:
+			e.closeConnection(t.fd)
+			res.Closed++
+		case force:
+			// Shutting the socket down fails the request's reads and
+			// writes, so the engine closes it without racing the handler
+			netfd.Shutdown(t.fd)
+			res.Closed++
+			action = "shut down"
+		default:
+			res.Draining++
+			action = "draining"
+		}
+		log.Printf("🔌 drain: %s fd %d (%s) by %s: %s", action, t.fd, t.addr, by, reason)
+	}
+	return res, nil
+}
+
+// ConnAdmin returns a handler for listing and draining connections. GET
+// lists the connections matching the fd, ip and tenant query parameters;
+// POST drains them (force=1 to close busy ones at once), logging the
+// caller named by actor (nil: the client address) and the reason
+// parameter. Draining every connection requires all=1. Mount it behind
+// authentication.
+func (e *Engine) ConnAdmin(actor func(ctx http.Context) string) HandlerFunc {
+	return func(ctx http.Context) {
+		sel := ConnSelector{IP: ctx.Query("ip"), Tenant: ctx.Query("tenant")}
+		if fd := ctx.Query("fd"); fd != "" {
+			n, err := strconv.Atoi(fd)
+			if err != nil || n <= 0 {
+				ctx.Error(400, "invalid fd")
+				return
+			}
+			sel.FD = n
+		}
+
+		switch ctx.Method() {
+		case "GET":
+			infos, err := e.Connections(sel)
+			if err != nil {
+				ctx.Error(400, err.Error())
+				return
+			}
+			data, _ := json.Marshal(infos)
+			ctx.Data(200, "application/json", data)
+
+		case "POST":
+			if sel == (ConnSelector{}) && ctx.Query("all") != "1" {
+				ctx.Error(400, "no connections selected (set fd, ip or tenant, or all=1)")
+				return
+			}
+			by := "unknown"
+			if actor != nil {
+				by = actor(ctx)
+			} else if addr := ctx.RemoteAddr(); addr != nil {
+				by = addr.String()
+			}
+			res, err := e.Drain(sel, ctx.Query("force") == "1", by, ctx.Query("reason"))
+			if err != nil {
+				ctx.Error(400, err.Error())
+				return
+			}
+			ctx.JSON(200, res)
+
+		default:
+			if fc, ok := ctx.(*http.FDContext); ok {
+				fc.SetHeader("Allow", "GET, POST")
+			}
+			ctx.Error(405, "Method Not Allowed")
+		}
+	}
+}
+
+// matcher compiles sel into a connection predicate, to be called with
+// connMu held
+func (sel ConnSelector) matcher() (func(fd int, conn *Connection) bool, error) {
+	var prefix netip.Prefix
+	if sel.IP != "" {
+		if p, err := netip.ParsePrefix(sel.IP); err == nil {
+			prefix = p.Masked()
+		} else if a, err := netip.ParseAddr(sel.IP); err == nil {
+			prefix = netip.PrefixFrom(a.Unmap(), a.Unmap().BitLen())
+		} else {
+			return nil, errors.New("invalid ip: " + sel.IP)
+		}
+	}
+
+	return func(fd int, conn *Connection) bool {
+		if sel.FD != 0 && fd != sel.FD {
+			return false
+		}
+		if sel.Tenant != "" && conn.tenant != sel.Tenant {
+			return false
+		}
+		if prefix.IsValid() {
+			addr, ok := peerAddr(conn)
+			if !ok || !prefix.Contains(addr) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// peerAddr returns the client IP of conn
+func peerAddr(conn *Connection) (netip.Addr, bool) {
+	switch sa := conn.peer.(type) {
+	case *syscall.SockaddrInet4:
+		return netip.AddrFrom4(sa.Addr), true
+	case *syscall.SockaddrInet6:
+		return netip.AddrFrom16(sa.Addr).Unmap(), true
+	}
+	return netip.Addr{}, false
+}
@@ -1,17 +1,24 @@
 package core
 
 import (
+	"bytes"
 	"log"
-	"net"
-	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/searchktools/fast-server/core/clock"
+	"github.com/searchktools/fast-server/core/events"
 	"github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/middleware"
+	"github.com/searchktools/fast-server/core/netfd"
+	"github.com/searchktools/fast-server/core/observability"
 	"github.com/searchktools/fast-server/core/poller"
 	"github.com/searchktools/fast-server/core/pools"
 	"github.com/searchktools/fast-server/core/router"
+	"github.com/searchktools/fast-server/core/stats"
 )
 
 // HandlerFunc defines the handler function type (accepts http.Context interface)
@@ -34,8 +41,77 @@ type Connection struct {
 	request    *http.Request
 	context    *http.FDContext
 	lastActive time.Time
-	keepAlive  bool
 	closeAfter bool
+
+	// keepAlive is set once the connection has served a request and is
+	// kept open for the next one; such connections may be evicted
+	keepAlive bool
+
+	// idleTimeout overrides the engine's idle timeout until the next
+	// request (0: engine default), see ctx.SetIdleTimeout
+	idleTimeout time.Duration
+
+	// tenant is set by handlers with ctx.SetTenant (written under connMu)
+	tenant string
+
+	// draining closes the connection after its current request (see Drain)
+	draining atomic.Bool
+
+	// requestSize is how much of readBuf the current request occupies;
+	// pipelined requests may follow it
+	requestSize int
+
+	// pipelined queues responses to pipelined requests for one write
+	pipelined []byte
+
+	// peer is the client address returned by accept
+	peer syscall.Sockaddr
+
+	// spill is the body being written to the BodyStore; spool is the
+	// stored body of the request being served
+	spill *bodySpill
+	spool http.SpooledBody
+
+	// headerDeadline is the time by which the current request's headers
+	// must be complete (zero while no request is in flight)
+	headerDeadline time.Time
+
+	// readDeadline is the time by which the whole current request
+	// must be read (zero while no request is in flight)
+	readDeadline time.Time
+
+	// sniffed is set once the first bytes showed an HTTP connection
+	// (see SetSniffing)
+	sniffed bool
+
+	// started is when the current request was routed, if request events
+	// have subscribers
+	started time.Time
+
+	// backlog is what the socket did not take of the responses; the
+	// connection waits for writability until it is sent (StateWriting)
+	backlog http.Backlog
+
+	// stalled is when the backlog started, and writeDeadline the time by
+	// which it must be sent (zero: no deadline)
+	stalled       time.Time
+	writeDeadline time.Time
+
+	// sizeChecked is set once the current request's header was checked
+	// against its route's size limits; bodyLimit is its route's
+	// MaxBodyBytes (zero: none)
+	sizeChecked bool
+	bodyLimit   int
+
+	// chunks frames, or spools, the current request's chunked body as it
+	// arrives; chunkEnd is how far into readBuf it has framed (zero: no
+	// chunked body is being framed)
+	chunks   http.ChunkedDecoder
+	chunkEnd int
+
+	// detached is set while an async request holds the connection out of
+	// the poller
+	detached bool
 }
 
 // Reset implements ConnectionPoolable interface
@@ -45,21 +121,43 @@ func (c *Connection) Reset() {
 	c.readBuf = nil
 	c.readOffset = 0
 	c.request = nil
+	c.requestSize = 0
+	c.pipelined = c.pipelined[:0]
+	c.peer = nil
+	c.spill = nil
+	c.spool = nil
 	c.context = nil
 	c.lastActive = time.Time{}
 	c.keepAlive = false
+	c.idleTimeout = 0
+	c.tenant = ""
+	c.draining.Store(false)
 	c.closeAfter = false
+	c.headerDeadline = time.Time{}
+	c.readDeadline = time.Time{}
+	c.sniffed = false
+	c.started = time.Time{}
+	c.backlog.Reset()
+	c.stalled = time.Time{}
+	c.writeDeadline = time.Time{}
+	c.detached = false
+	c.sizeChecked = false
+	c.bodyLimit = 0
+	c.chunkEnd = 0
+	c.chunks.Reset()
 }
 
 // SetFD implements ConnectionPoolable interface
 func (c *Connection) SetFD(fd int) {
 	c.fd = fd
-	c.lastActive = time.Now()
+	c.lastActive = clock.Now()
 }
 
 // Engine is a high-performance zero-allocation HTTP engine with epoll/kqueue
 type Engine struct {
-	router      *router.RadixRouter
+	router      *router.CompiledRouter
+	middleware  *middleware.Pipeline
+	dispatchFn  middleware.HandlerFunc
 	poller      poller.Poller
 	connections map[int]*Connection
 	connMu      sync.RWMutex
@@ -69,25 +167,170 @@ type Engine struct {
 	writeTimeout   time.Duration
 	idleTimeout    time.Duration
 
+	// Recovered handler panics are reported here (both optional)
+	panicHandler PanicHandler
+	observatory  *observability.Observatory
+
+	// Write backpressure is reported here (the observatory's WriteMonitor)
+	writeObserver http.WriteObserver
+
+	// Lifecycle hooks (see OnAccept, OnClose, OnRequest, OnResponse)
+	hooks hooks
+
+	// How often TCP_INFO of open connections is sampled (0: never)
+	tcpInfoInterval time.Duration
+
+	// Deadline of ctx.Context() per request (0: none)
+	requestTimeout time.Duration
+
+	// Slowloris protection: headers must arrive within headerTimeout
+	// and must not exceed maxHeaderBytes
+	headerTimeout  time.Duration
+	maxHeaderBytes int
+	// More header fields are rejected with 431 (0: no limit)
+	maxHeaderCount int
+
+	// Larger Content-Length bodies are rejected with 413 (0: no limit)
+	maxBodyBytes int
+
+	// Reject requests that are not strictly RFC 7230 (see SetStrictParsing)
+	strictParsing bool
+	// How repeated request headers are presented (see SetDuplicateHeaders)
+	duplicateHeaders http.DuplicateHeaders
+
+	// Close every connection after its response
+	noKeepAlive bool
+
+	// Server header of every response ("": none)
+	server string
+
+	// Captures sampled requests and responses (nil: none)
+	dumper *http.Dumper
+
+	// Raise RLIMIT_NOFILE soft limit to the hard limit at startup
+	autoRaiseNoFile bool
+
+	// Keep GOMAXPROCS when a container CPU quota is lower
+	noAutoMaxProcs bool
+
+	// Idle connections are evicted once open connections reach
+	// evictThreshold of fdLimit (RLIMIT_NOFILE, read by Run)
+	evictThreshold float64
+	fdLimit        int
+	evictions      atomic.Uint64
+
+	// Connections refused at maxConnections (see SetMaxConnections)
+	refused atomic.Uint64
+
+	// Answer OPTIONS for routed paths without an OPTIONS handler
+	autoOptions bool
+	// Redirect unknown paths to a matching route (see
+	// SetRedirectTrailingSlash and SetRedirectFixedPath)
+	redirectTrailingSlash bool
+	redirectFixedPath     bool
+
+	// CORS handled by the engine (nil: left to middleware)
+	cors *corsPolicy
+
 	// Fine-grained memory pools
 	contextPool    *pools.SmartPool
 	requestPool    *pools.SmartPool
 	bytePool       *pools.BytePool
 	connectionPool *pools.ConnectionPool
 	workerPool     *pools.WorkerPool // Work-stealing goroutine pool
+
+	// Handler for authority-form CONNECT requests (nil: 404)
+	connectHandler HandlerFunc
+
+	// Custom 404, 405 and 500 responses (nil: plain text)
+	notFound         HandlerFunc
+	methodNotAllowed HandlerFunc
+	errorHandler     ErrorHandler
+
+	// Size limits of the routes, applied once a request header arrives
+	routeSizes sizeLimits
+
+	// Time source for activity stamps and deadlines (coarse by default)
+	clock clock.Clock
+
+	// Options for the listener and accepted sockets
+	sockOpts SocketOptions
+
+	// Request bodies above spillThreshold go to bodyStore (nil: in memory)
+	bodyStore      http.BodyStore
+	spillThreshold int
+
+	// Multipart bytes kept in memory before files spill (0: default)
+	multipartMemory int64
+
+	// Throttling and overload responses by status code (see SetBackoff)
+	backoffs map[int]*http.Backoff
+
+	// Templates ctx.Render executes (nil: none)
+	templates *http.Templates
+
+	// Fingerprinted static assets ctx.Asset resolves (nil: none)
+	assets *http.Assets
+
+	// Proxies whose forwarding headers ctx.ClientIP believes (nil: none)
+	trustedProxies *http.TrustedProxies
+
+	// Protocols recognized next to HTTP on the port (nil: HTTP only)
+	sniff *SniffConfig
+
+	// Topics of the event bus (see SetEventBus)
+	connEvents    *events.Topic[events.Conn]
+	requestEvents *events.Topic[events.Request]
+
+	// Metrics of the engine and registered components (see Stats)
+	stats *stats.Registry
+
+	// Startup report (see BootReport), logged as bootLog
+	boot    atomic.Pointer[BootReport]
+	bootLog BootLogFormat
+
+	// Hangup watcher for requests whose handler uses ctx.Context()
+	hangups poller.Poller
+	watchMu sync.Mutex
+	watched map[int]*http.FDContext
+	watchFn func(*http.FDContext)
 }
 
+// Pool sizes (see BootReport)
+const (
+	connectionPoolCapacity = 10000
+	poolWarmup             = 500 // Context and request objects preallocated
+)
+
 // NewEngine creates a new engine instance
 func NewEngine() *Engine {
 	e := &Engine{
-		router:         router.NewRadixRouter(),
-		connections:    make(map[int]*Connection, 10000),
-		maxConnections: 100000,
-		readTimeout:    10 * time.Second,
-		writeTimeout:   10 * time.Second,
-		idleTimeout:    5 * time.Second, // Short idle timeout for aggressive cleanup
+		router:          router.NewCompiledRouter(),
+		middleware:      middleware.NewPipeline(),
+		connections:     make(map[int]*Connection, 10000),
+		maxConnections:  100000,
+		readTimeout:     10 * time.Second,
+		writeTimeout:    10 * time.Second,
+		idleTimeout:     5 * time.Second, // Short idle timeout for aggressive cleanup
+		headerTimeout:   5 * time.Second,
+		maxHeaderBytes:  8192,
+		maxHeaderCount:  100,
+		autoOptions:     true,
+		evictThreshold:  0.95,
+		tcpInfoInterval: 10 * time.Second,
+		clock:           clock.Default(),
+		sockOpts:        DefaultSocketOptions(),
+		backoffs: map[int]*http.Backoff{
+			429: http.NewBackoff(429, http.BackoffConfig{}),
+			503: http.NewBackoff(503, http.BackoffConfig{}),
+		},
 	}
 
+	// Bind once so the hot path does not allocate a method value
+	e.dispatchFn = e.dispatch
+	e.watchFn = e.watchHangup
+	e.watched = make(map[int]*http.FDContext)
+
 	// Apply GC optimizations for high throughput
 	pools.OptimizeForHighThroughput()
 
@@ -95,7 +338,7 @@ func NewEngine() *Engine {
 	e.bytePool = pools.NewBytePool()
 
 	// Connection pool
-	e.connectionPool = pools.NewConnectionPool(10000, func() any {
+	e.connectionPool = pools.NewConnectionPool(connectionPoolCapacity, func() any {
 		return &Connection{
 			fd:    -1,
 			state: StateReading,
@@ -111,7 +354,7 @@ func NewEngine() *Engine {
 				ctx.Reset(0, nil)
 			}
 		},
-		WarmupSize:    500,  // Increased from 300
+		WarmupSize:    poolWarmup,
 		TargetHitRate: 0.95, // Target 95% hit rate
 	})
 
@@ -128,7 +371,7 @@ func NewEngine() *Engine {
 				req.Body = req.Body[:0]
 			}
 		},
-		WarmupSize:    500,
+		WarmupSize:    poolWarmup,
 		TargetHitRate: 0.95,
 	})
 
@@ -136,108 +379,297 @@ func NewEngine() *Engine {
 	e.contextPool.StartAutoOptimize(30 * time.Second)
 	e.requestPool.StartAutoOptimize(30 * time.Second)
 
-	// Initialize work-stealing worker pool
-	numWorkers := runtime.NumCPU()
+	// Initialize work-stealing worker pool, one worker per CPU the
+	// container's quota allows
+	numWorkers := EffectiveCPUs()
 	e.workerPool = pools.NewWorkerPool(numWorkers)
 
-	log.Printf("📊 Fine-grained pools initialized:")
-	log.Printf("   - Connection pool: 10000 capacity")
-	log.Printf("   - Context pool: 500 warmup, 95%% target")
-	log.Printf("   - Request pool: 500 warmup, 95%% target")
-	log.Printf("   - Byte pool: 4-tier (512/2K/8K/32K)")
-	log.Printf("   - Worker pool: %d workers (work-stealing)", numWorkers)
-	log.Printf("   - GC: Optimized for high throughput (GOGC=300)")
+	e.registerStats()
 
 	return e
 }
 
-// GET registers a GET route
-func (e *Engine) GET(path string, handler HandlerFunc) {
-	e.router.Add("GET", path, func(ctx any) {
+// MaxConnections returns the maximum number of concurrent connections
+func (e *Engine) MaxConnections() int {
+	return e.maxConnections
+}
+
+// SetMaxConnections sets the maximum number of concurrent connections
+// (default 100000). Connections accepted beyond it get the 503 backoff
+// response (see SetBackoff) and are closed.
+func (e *Engine) SetMaxConnections(n int) {
+	e.maxConnections = n
+}
+
+// SetClock sets the time source used for idle tracking and deadlines.
+// The default is the shared coarse clock; tests may pass a clock.Fake.
+func (e *Engine) SetClock(c clock.Clock) {
+	e.clock = c
+}
+
+// SetObservatory records recovered handler panics in o's monitor and
+// write backpressure (queued bytes, stalls) in o.Writes
+func (e *Engine) SetObservatory(o *observability.Observatory) {
+	e.observatory = o
+	e.writeObserver = nil
+	if o != nil {
+		e.writeObserver = o.Writes
+	}
+}
+
+// SetReadTimeout sets how long a client may take to send a complete
+// request once its first byte has arrived. Zero disables the deadline.
+func (e *Engine) SetReadTimeout(d time.Duration) {
+	e.readTimeout = d
+}
+
+// SetWriteTimeout sets how long a response write may stall on a full
+// socket buffer before the connection is dropped. Zero disables it.
+func (e *Engine) SetWriteTimeout(d time.Duration) {
+	e.writeTimeout = d
+}
+
+// SetRequestTimeout sets how long a handler may run before ctx.Context()
+// is canceled with http.ErrRequestTimeout. Zero (the default) disables it.
+func (e *Engine) SetRequestTimeout(d time.Duration) {
+	e.requestTimeout = d
+}
+
+// SetHeaderTimeout sets how long a client may take to send a complete
+// request header block. Zero disables the deadline.
+func (e *Engine) SetHeaderTimeout(d time.Duration) {
+	e.headerTimeout = d
+}
+
+// SetMaxHeaderBytes sets the maximum size of the request line plus headers.
+// Requests exceeding it are rejected with 431. The read buffer grows for
+// header blocks larger than it; zero allows up to 4MB.
+func (e *Engine) SetMaxHeaderBytes(n int) {
+	e.maxHeaderBytes = n
+}
+
+// SetMaxHeaderCount sets the maximum number of header fields in a
+// request (default 100), bounding what the parser keeps per request.
+// Requests with more are rejected with 431. Zero sets no limit.
+func (e *Engine) SetMaxHeaderCount(n int) {
+	e.maxHeaderCount = n
+}
+
+// SetMaxBodyBytes sets the largest request body (Content-Length)
+// accepted. Larger requests are rejected with 413 as soon as their
+// header arrives. Zero (the default) sets no limit, but without a
+// BodyStore bodies are buffered in memory and capped at 4MB.
+func (e *Engine) SetMaxBodyBytes(n int) {
+	e.maxBodyBytes = n
+}
+
+// SetDuplicateHeaders sets how a request header sent more than once is
+// presented to handlers: as a list (http.DuplicateList, the default) or
+// joined into one value (http.DuplicateCombine). A repeated Host, or
+// Content-Length with differing values, gets 400 either way.
+func (e *Engine) SetDuplicateHeaders(policy http.DuplicateHeaders) {
+	e.duplicateHeaders = policy
+}
+
+// SetStrictParsing rejects requests that are not strictly RFC 7230 with
+// 400 before they are framed: bare LF line endings, folded header lines
+// (unfolded otherwise), whitespace before a header colon, conflicting
+// Content-Length and Transfer-Encoding, invalid request targets and so
+// on (see http.CheckStrict). Enable it behind proxies, where the lenient
+// default could frame a request differently than the proxy did.
+func (e *Engine) SetStrictParsing(enabled bool) {
+	e.strictParsing = enabled
+}
+
+// SetTemplates sets the templates handlers render with ctx.Render
+func (e *Engine) SetTemplates(t *http.Templates) {
+	e.templates = t
+}
+
+// ServeAssets serves a's files under a.Prefix() and has ctx.Asset
+// resolve their fingerprinted URLs
+func (e *Engine) ServeAssets(a *http.Assets) {
+	e.assets = a
+	e.GET(a.Prefix()+"/*filepath", a.Handler())
+}
+
+// SetTrustedProxies sets the networks (CIDRs or addresses) of the reverse
+// proxies in front of the engine: ctx.ClientIP takes the client address
+// from X-Forwarded-For or X-Real-IP only on their connections
+func (e *Engine) SetTrustedProxies(cidrs ...string) error {
+	t, err := http.NewTrustedProxies(cidrs...)
+	if err != nil {
+		return err
+	}
+	e.trustedProxies = t
+	return nil
+}
+
+// SetMultipartMemory sets how many bytes of a multipart/form-data body
+// ctx.MultipartForm keeps in memory (default 32MB); file parts beyond it
+// are written to temporary files, removed when the request completes
+func (e *Engine) SetMultipartMemory(n int64) {
+	e.multipartMemory = n
+}
+
+// SetIdleTimeout sets how long a keep-alive connection may wait for its
+// next request (default 5s). Routes override it with the IdleTimeout option.
+func (e *Engine) SetIdleTimeout(d time.Duration) {
+	e.idleTimeout = d
+}
+
+// SetKeepAlive enables or disables keep-alive (enabled by default).
+// Routes override it with the KeepAlive option.
+func (e *Engine) SetKeepAlive(enabled bool) {
+	e.noKeepAlive = !enabled
+}
+
+// SetServerHeader sets the Server header sent with every response, e.g.
+// "fast-server". None is sent by default.
+func (e *Engine) SetServerHeader(name string) {
+	e.server = name
+}
+
+// SetDumper captures a sampled fraction of requests and their responses
+// in d (nil: none). Serve d.Handler() on an admin route to read them.
+func (e *Engine) SetDumper(d *http.Dumper) {
+	e.dumper = d
+}
+
+// SetAutoOptions enables or disables automatic OPTIONS responses.
+// When enabled (the default), an OPTIONS request to a path that has routes
+// but no OPTIONS handler gets 204 with an Allow header listing its methods.
+func (e *Engine) SetAutoOptions(enabled bool) {
+	e.autoOptions = enabled
+}
+
+// Use adds global middleware that wraps every request before routing.
+// A middleware that calls ctx.Abort() stops the chain and skips the route.
+func (e *Engine) Use(handlers ...middleware.HandlerFunc) {
+	for _, h := range handlers {
+		e.middleware.Use(h)
+	}
+}
+
+// Handle registers a route for an arbitrary method, including extension
+// methods such as WebDAV's PROPFIND or MKCOL. The method must be a valid
+// HTTP token and is matched case-sensitively. Options set response
+// defaults such as Compress, CacheControl and ContentType.
+func (e *Engine) Handle(method, path string, handler HandlerFunc, opts ...RouteOption) {
+	if !isMethodToken(method) {
+		panic("invalid HTTP method: " + method)
+	}
+	handler = e.withRouteOptions(method, path, handler, opts)
+	e.router.Add(method, path, func(ctx any) {
 		handler(ctx.(http.Context))
 	})
 }
 
+// GET registers a GET route
+func (e *Engine) GET(path string, handler HandlerFunc, opts ...RouteOption) {
+	e.Handle("GET", path, handler, opts...)
+}
+
 // POST registers a POST route
-func (e *Engine) POST(path string, handler HandlerFunc) {
-	e.router.Add("POST", path, func(ctx any) {
-		handler(ctx.(http.Context))
-	})
+func (e *Engine) POST(path string, handler HandlerFunc, opts ...RouteOption) {
+	e.Handle("POST", path, handler, opts...)
 }
 
 // PUT registers a PUT route
-func (e *Engine) PUT(path string, handler HandlerFunc) {
-	e.router.Add("PUT", path, func(ctx any) {
-		handler(ctx.(http.Context))
-	})
+func (e *Engine) PUT(path string, handler HandlerFunc, opts ...RouteOption) {
+	e.Handle("PUT", path, handler, opts...)
 }
 
 // DELETE registers a DELETE route
-func (e *Engine) DELETE(path string, handler HandlerFunc) {
-	e.router.Add("DELETE", path, func(ctx any) {
-		handler(ctx.(http.Context))
-	})
+func (e *Engine) DELETE(path string, handler HandlerFunc, opts ...RouteOption) {
+	e.Handle("DELETE", path, handler, opts...)
 }
 
 // PATCH registers a PATCH route
-func (e *Engine) PATCH(path string, handler HandlerFunc) {
-	e.router.Add("PATCH", path, func(ctx any) {
-		handler(ctx.(http.Context))
-	})
+func (e *Engine) PATCH(path string, handler HandlerFunc, opts ...RouteOption) {
+	e.Handle("PATCH", path, handler, opts...)
 }
 
 // HEAD registers a HEAD route
-func (e *Engine) HEAD(path string, handler HandlerFunc) {
-	e.router.Add("HEAD", path, func(ctx any) {
-		handler(ctx.(http.Context))
-	})
+func (e *Engine) HEAD(path string, handler HandlerFunc, opts ...RouteOption) {
+	e.Handle("HEAD", path, handler, opts...)
 }
 
 // OPTIONS registers an OPTIONS route
-func (e *Engine) OPTIONS(path string, handler HandlerFunc) {
-	e.router.Add("OPTIONS", path, func(ctx any) {
-		handler(ctx.(http.Context))
-	})
+func (e *Engine) OPTIONS(path string, handler HandlerFunc, opts ...RouteOption) {
+	e.Handle("OPTIONS", path, handler, opts...)
+}
+
+// TRACE registers a TRACE route
+func (e *Engine) TRACE(path string, handler HandlerFunc, opts ...RouteOption) {
+	e.Handle("TRACE", path, handler, opts...)
+}
+
+// CONNECT registers the handler for authority-form CONNECT requests
+// ("CONNECT host:port"). The target is exposed as ctx.Param("authority").
+// Origin-form CONNECT routes can be registered with Handle.
+func (e *Engine) CONNECT(handler HandlerFunc) {
+	e.connectHandler = handler
+}
+
+// isMethodToken reports whether method is a valid RFC 7230 token
+func isMethodToken(method string) bool {
+	if method == "" {
+		return false
+	}
+	for i := 0; i < len(method); i++ {
+		c := method[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
 }
 
 // Run starts the server
 func (e *Engine) Run(addr string) error {
-	laddr, err := net.ResolveTCPAddr("tcp", addr)
+	lfd, closeListener, err := netfd.Listen(addr)
 	if err != nil {
 		return err
 	}
+	defer closeListener()
 
-	ln, err := net.ListenTCP("tcp", laddr)
-	if err != nil {
+	if err := e.applyListenerOptions(lfd); err != nil {
 		return err
 	}
-	defer ln.Close()
 
-	lnFile, err := ln.File()
+	e.poller, err = poller.NewPoller()
 	if err != nil {
 		return err
 	}
-	lfd := int(lnFile.Fd())
+	defer e.poller.Close()
 
-	if err := syscall.SetNonblock(lfd, true); err != nil {
+	if err := e.poller.Add(lfd); err != nil {
 		return err
 	}
 
-	e.poller, err = poller.NewPoller()
+	e.hangups, err = poller.NewHangupPoller()
 	if err != nil {
 		return err
 	}
-	defer e.poller.Close()
+	defer e.hangups.Close()
+	go e.watchHangups()
 
-	if err := e.poller.Add(lfd); err != nil {
-		return err
-	}
+	e.adviseTuning()
+	e.applyMaxProcs()
+	e.fdLimit = fdLimit()
+
+	// Serve from dispatch tables generated from the registered routes
+	e.router.Build()
 
-	log.Printf("🚀 High-Performance Server listening on %s", addr)
-	log.Printf("⚡ Full epoll/kqueue with syscall.Write()")
-	log.Printf("📊 Smart pools initialized with 300 objects warmup")
+	e.boot.Store(e.newBootReport(lfd))
+	e.logBootReport()
 
 	go e.cleanupIdleConnections()
+	go e.sampleTCPInfo()
 
 	for {
 		// Wait up to 100ms (shorter timeout for better responsiveness)
@@ -260,46 +692,61 @@ func (e *Engine) Run(addr string) error {
 // acceptConnections accepts multiple pending connections
 func (e *Engine) acceptConnections(lfd int) {
 	for {
-		nfd, _, err := syscall.Accept(lfd)
+		nfd, peer, err := netfd.Accept(lfd)
 		if err != nil {
 			if err == syscall.EAGAIN || err == syscall.EWOULDBLOCK {
 				return
 			}
+			// Out of descriptors: make room instead of refusing
+			if (err == syscall.EMFILE || err == syscall.ENFILE) &&
+				e.evictThreshold > 0 && e.evictIdle(e.evictBatch()) > 0 {
+				continue
+			}
 			log.Printf("Accept error: %v", err)
 			return
 		}
 
-		if err := syscall.SetNonblock(nfd, true); err != nil {
-			syscall.Close(nfd)
+		if err := netfd.SetNonblock(nfd); err != nil {
+			netfd.Close(nfd)
 			continue
 		}
 
-		// TCP_NODELAY: Disable Nagle's algorithm
-		syscall.SetsockoptInt(nfd, syscall.IPPROTO_TCP, syscall.TCP_NODELAY, 1)
+		// TCP_NODELAY, keepalive and buffer sizes (see SetSocketOptions)
+		e.applyConnOptions(nfd)
 
-		// SO_KEEPALIVE: Enable TCP keepalive
-		syscall.SetsockoptInt(nfd, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 1)
+		if e.atCapacity() {
+			e.refuse(nfd)
+			continue
+		}
 
-		// Configure keepalive timing (macOS: TCP_KEEPALIVE = 0x10)
-		// Wait 30s before first probe
-		syscall.SetsockoptInt(nfd, syscall.IPPROTO_TCP, 0x10, 30)
+		if len(e.hooks.accept) > 0 && !e.runAcceptHooks(nfd, peer) {
+			netfd.Close(nfd)
+			continue
+		}
 
 		conn := e.connectionPool.Get().(*Connection)
 		conn.SetFD(nfd)
+		conn.lastActive = e.clock.Now()
 		conn.state = StateReading
-		conn.readBuf = e.bytePool.Get(8192)
+		conn.readBuf = e.bytePool.Get(readBufSize)
 		conn.readOffset = 0
-		conn.keepAlive = true
+		conn.keepAlive = false
+		conn.peer = peer
 
 		if err := e.poller.Add(nfd); err != nil {
 			e.connectionPool.Put(conn)
-			syscall.Close(nfd)
+			netfd.Close(nfd)
 			continue
 		}
 
 		e.connMu.Lock()
 		e.connections[nfd] = conn
 		e.connMu.Unlock()
+
+		if e.connEvents != nil && e.connEvents.Active() {
+			e.publishConn(nfd, peer)
+		}
+		e.relieveFDPressure(nfd)
 	}
 }
 
@@ -313,19 +760,73 @@ func (e *Engine) handleConnectionEvent(fd int) {
 		return
 	}
 
-	conn.lastActive = time.Now()
+	conn.lastActive = e.clock.Now()
 
 	switch conn.state {
 	case StateReading, StateKeepalive:
 		e.handleRead(conn)
 	case StateWriting:
-		conn.state = StateKeepalive
+		e.handleWrite(conn)
+	}
+}
+
+// awaitWritable parks a connection whose responses the socket did not
+// take whole until the poller reports it writable. Reading stops
+// meanwhile; the cleanup sweep closes the connection if the client takes
+// nothing for longer than the write timeout. Returns false: the
+// connection is no longer served.
+func (e *Engine) awaitWritable(conn *Connection) bool {
+	conn.state = StateWriting
+	conn.stalled = e.clock.Now()
+	if e.writeTimeout > 0 {
+		conn.writeDeadline = conn.stalled.Add(e.writeTimeout)
+	}
+	if conn.detached {
+		if err := e.poller.Add(conn.fd); err != nil {
+			e.closeConnection(conn.fd)
+			return false
+		}
+		conn.detached = false
+	}
+	if err := e.poller.WatchWrite(conn.fd, true); err != nil {
+		e.closeConnection(conn.fd)
+	}
+	return false
+}
+
+// handleWrite sends more of the backlog once the socket is writable. When
+// it is all sent the connection goes back to reading, serving the
+// requests pipelined meanwhile.
+func (e *Engine) handleWrite(conn *Connection) {
+	n, err := conn.backlog.Send(conn.fd)
+	if err != nil {
+		if err != syscall.EAGAIN {
+			e.closeConnection(conn.fd)
+			return
+		}
+		// The deadline bounds a stall: a client reading on gets more time
+		if n > 0 && e.writeTimeout > 0 {
+			conn.writeDeadline = e.clock.Now().Add(e.writeTimeout)
+		}
+		return
+	}
+	if e.writeObserver != nil {
+		e.writeObserver.RecordStall(conn.fd, e.clock.Now().Sub(conn.stalled))
+	}
+	conn.stalled = time.Time{}
+	conn.writeDeadline = time.Time{}
+	if err := e.poller.WatchWrite(conn.fd, false); err != nil {
+		e.closeConnection(conn.fd)
+		return
+	}
+	if e.checkKeepAlive(conn) {
+		e.serveBuffered(conn)
 	}
 }
 
 // handleRead reads and processes HTTP requests
 func (e *Engine) handleRead(conn *Connection) {
-	n, err := syscall.Read(conn.fd, conn.readBuf[conn.readOffset:])
+	n, err := netfd.Read(conn.fd, conn.readBuf[conn.readOffset:])
 	if err != nil {
 		if err == syscall.EAGAIN || err == syscall.EWOULDBLOCK {
 			return
@@ -339,48 +840,613 @@ func (e *Engine) handleRead(conn *Connection) {
 		return
 	}
 
+	// First bytes of a new request start the header and read deadlines
+	if conn.readOffset == 0 {
+		e.startDeadlines(conn)
+	}
+
 	conn.readOffset += n
 
-	req, err := http.ParseRequest(conn.readBuf[:conn.readOffset])
-	if err != nil {
-		if conn.readOffset >= len(conn.readBuf) {
-			e.sendError(conn, 400, "Bad Request")
-			e.closeConnection(conn.fd)
-		}
-		// Partial request, wait for more data
+	// The first bytes of a connection may show another protocol
+	if e.sniff != nil && !conn.sniffed && !e.sniffConn(conn) {
 		return
 	}
 
-	conn.readOffset = 0
-	conn.request = req
-	conn.state = StateProcessing
+	e.serveBuffered(conn)
+}
 
-	e.processRequest(conn)
+// readBufSize is the read buffer of a connection; requests with larger
+// bodies get a larger one until they are served
+const readBufSize = 8192
+
+// maxBufferedRequest bounds the buffer of a request whose body is kept
+// in memory, unless SetMaxBodyBytes allows more. Larger bodies need a
+// BodyStore.
+const maxBufferedRequest = 4 << 20
+
+// growReadBuf gives conn a read buffer of size bytes for a request whose
+// body does not fit, or answers 413 if it is too large to buffer
+func (e *Engine) growReadBuf(conn *Connection, size int) bool {
+	limit := max(maxBufferedRequest, e.maxHeaderBytes)
+	if e.maxBodyBytes > 0 {
+		limit = max(limit, e.maxHeaderBytes+e.maxBodyBytes)
+	}
+	if size > limit {
+		e.flushPipeline(conn)
+		e.sendError(conn, 413, "Request Entity Too Large")
+		e.closeConnection(conn.fd)
+		return false
+	}
+	buf := e.bytePool.Get(size)
+	copy(buf, conn.readBuf[:conn.readOffset])
+	e.bytePool.Put(conn.readBuf)
+	conn.readBuf = buf
+	return true
 }
 
-// processRequest processes a single request
-func (e *Engine) processRequest(conn *Connection) {
-	// For lightweight HTTP handlers, process inline for minimal latency
-	// Worker pool can be enabled for CPU-intensive handlers
-	h, params := e.router.Find(conn.request.Method, conn.request.Path)
+// growHeaderBuf makes room for a header block still arriving, up to the
+// header size limit (SetMaxHeaderBytes, or maxBufferedRequest). A longer
+// one is answered 431.
+func (e *Engine) growHeaderBuf(conn *Connection) bool {
+	limit := e.maxHeaderBytes
+	if limit <= 0 {
+		limit = maxBufferedRequest
+	}
+	if conn.readOffset >= limit {
+		e.flushPipeline(conn)
+		e.sendError(conn, 431, "Request Header Fields Too Large")
+		e.closeConnection(conn.fd)
+		return false
+	}
+	if conn.readOffset < len(conn.readBuf) {
+		return true
+	}
+	return e.growReadBuf(conn, min(2*len(conn.readBuf), limit))
+}
 
-	if h == nil {
-		e.sendError(conn, 404, "Not Found")
-		e.checkKeepAlive(conn)
+// shrinkReadBuf returns a grown read buffer once its request is served
+func (e *Engine) shrinkReadBuf(conn *Connection) {
+	if len(conn.readBuf) <= readBufSize || conn.readOffset > readBufSize {
 		return
 	}
+	buf := e.bytePool.Get(readBufSize)
+	copy(buf, conn.readBuf[:conn.readOffset])
+	e.bytePool.Put(conn.readBuf)
+	conn.readBuf = buf
+}
+
+// checkHeaderLimits answers 431 to a request whose complete header block
+// is larger, or has more fields, than allowed
+func (e *Engine) checkHeaderLimits(conn *Connection, buf []byte) bool {
+	if e.maxHeaderBytes <= 0 && e.maxHeaderCount <= 0 {
+		return true
+	}
+	headerLen, _ := http.RequestFrame(buf)
+	// Lines less the request line and the blank line ending the block
+	fields := bytes.Count(buf[:headerLen], []byte("\n")) - 2
+	if (e.maxHeaderBytes > 0 && headerLen > e.maxHeaderBytes) ||
+		(e.maxHeaderCount > 0 && fields > e.maxHeaderCount) {
+		e.flushPipeline(conn)
+		e.sendError(conn, 431, "Request Header Fields Too Large")
+		e.closeConnection(conn.fd)
+		return false
+	}
+	return true
+}
+
+// requestLength is http.RequestLength, except that a chunked body is
+// framed as it arrives rather than from its start on every read
+func (e *Engine) requestLength(conn *Connection, buf []byte) int {
+	if conn.chunkEnd == 0 {
+		headerLen, bodyLen := http.RequestFrame(buf)
+		if headerLen < 0 {
+			return -1
+		}
+		if bodyLen >= 0 {
+			return headerLen + bodyLen
+		}
+		conn.chunkEnd = headerLen
+	}
+	n, err := conn.chunks.Decode(buf[conn.chunkEnd:], nil)
+	conn.chunkEnd += n
+	switch {
+	case err != nil:
+		return len(buf) // Fails to parse
+	case !conn.chunks.Done():
+		return -1
+	}
+	return conn.chunkEnd
+}
+
+// checkChunkedSize answers 413 once the chunked body decoded so far is
+// larger than SetMaxBodyBytes or its route's MaxBodyBytes
+func (e *Engine) checkChunkedSize(conn *Connection) bool {
+	n := conn.chunks.Size()
+	if (e.maxBodyBytes > 0 && n > e.maxBodyBytes) || (conn.bodyLimit > 0 && n > conn.bodyLimit) {
+		e.flushPipeline(conn)
+		e.sendError(conn, 413, "Request Entity Too Large")
+		e.closeConnection(conn.fd)
+		return false
+	}
+	return true
+}
+
+// checkRouteSizes applies the size limits of the route the request in buf
+// is for once its header block has arrived, before its body is buffered
+// or spooled. A chunked body is checked as it arrives (see
+// checkChunkedSize).
+func (e *Engine) checkRouteSizes(conn *Connection, buf []byte) bool {
+	headerLen, bodyLen := http.RequestFrame(buf)
+	if headerLen < 0 {
+		return true
+	}
+	conn.sizeChecked = true
+	method, path, ok := http.RequestTarget(buf)
+	if !ok {
+		return true // Parsing answers it
+	}
+	l := e.routeSizes.find(method, path)
+	conn.bodyLimit = l.maxBodyBytes
+	code, text := 0, ""
+	switch {
+	case l.maxHeaderBytes > 0 && headerLen > l.maxHeaderBytes:
+		code, text = 431, "Request Header Fields Too Large"
+	case l.maxBodyBytes > 0 && bodyLen > l.maxBodyBytes:
+		code, text = 413, "Request Entity Too Large"
+	default:
+		return true
+	}
+	e.flushPipeline(conn)
+	e.sendError(conn, code, text)
+	e.closeConnection(conn.fd)
+	return false
+}
+
+// startDeadlines starts the header and read deadlines of the next request
+func (e *Engine) startDeadlines(conn *Connection) {
+	now := e.clock.Now()
+	if e.headerTimeout > 0 {
+		conn.headerDeadline = now.Add(e.headerTimeout)
+	}
+	if e.readTimeout > 0 {
+		conn.readDeadline = now.Add(e.readTimeout)
+	}
+}
 
+// serveBuffered processes the complete requests in the read buffer in
+// order (HTTP/1.1 pipelining). Responses to pipelined requests are queued
+// and sent with one write when the batch ends. Returns false if the
+// connection was closed or detached.
+func (e *Engine) serveBuffered(conn *Connection) bool {
+	for conn.readOffset > 0 {
+		var size int
+		if conn.spill != nil {
+			// Body bytes after the header go to the spool
+			if !e.feedSpill(conn) {
+				return false
+			}
+			if !conn.spill.complete() {
+				break
+			}
+			size = conn.spill.headerLen
+		} else {
+			buf := conn.readBuf[:conn.readOffset]
+			size = e.requestLength(conn, buf)
+			// The header block has arrived, if not a chunked body
+			framed := size >= 0 || conn.chunkEnd > 0
+			if framed && e.strictParsing {
+				if err := http.CheckStrict(buf); err != nil {
+					e.flushPipeline(conn)
+					e.sendError(conn, 400, "Bad Request")
+					e.closeConnection(conn.fd)
+					return false
+				}
+			}
+			if framed && !e.checkHeaderLimits(conn, buf) {
+				return false
+			}
+			if e.routeSizes.tree != nil && !conn.sizeChecked && !e.checkRouteSizes(conn, buf) {
+				return false
+			}
+			if conn.chunkEnd > 0 {
+				if !e.checkChunkedSize(conn) {
+					return false
+				}
+				// A chunked body past the threshold, or the buffer, is
+				// decoded into the spool from here on
+				if size < 0 && e.bodyStore != nil &&
+					(conn.chunks.Size() > e.spillThreshold || conn.readOffset >= len(conn.readBuf)) {
+					headerLen, _ := http.RequestFrame(buf)
+					if !e.startSpill(conn, headerLen, -1) {
+						return false
+					}
+					continue
+				}
+			}
+			if size >= 0 && (e.bodyStore != nil || e.maxBodyBytes > 0) {
+				headerLen, bodyLen := http.RequestFrame(buf)
+				if e.maxBodyBytes > 0 && bodyLen > e.maxBodyBytes {
+					e.flushPipeline(conn)
+					e.sendError(conn, 413, "Request Entity Too Large")
+					e.closeConnection(conn.fd)
+					return false
+				}
+				if e.bodyStore != nil && (bodyLen > e.spillThreshold || size > len(conn.readBuf)) {
+					if !e.startSpill(conn, headerLen, bodyLen) {
+						return false
+					}
+					continue
+				}
+			}
+			if size < 0 || size > len(buf) {
+				if size > len(conn.readBuf) {
+					// The body needs a larger buffer
+					if !e.growReadBuf(conn, size) {
+						return false
+					}
+					break
+				} else if conn.readOffset >= len(conn.readBuf) ||
+					(e.maxHeaderBytes > 0 && conn.readOffset >= e.maxHeaderBytes) {
+					headerLen, bodyLen := http.RequestFrame(buf)
+					if headerLen < 0 {
+						// The header block is still arriving
+						if !e.growHeaderBuf(conn) {
+							return false
+						}
+						break
+					}
+					if conn.readOffset < len(conn.readBuf) {
+						break
+					}
+					// A chunked body still arriving needs a larger buffer
+					if bodyLen < 0 {
+						if !e.growReadBuf(conn, 2*len(conn.readBuf)) {
+							return false
+						}
+						break
+					}
+					e.flushPipeline(conn)
+					e.sendError(conn, 400, "Bad Request")
+					e.closeConnection(conn.fd)
+					return false
+				} else {
+					// Partial request, wait for more data
+					break
+				}
+			}
+		}
+
+		parse := http.ParseRequest
+		if conn.spill != nil {
+			parse = http.ParseRequestHeader
+		}
+		req, err := parse(conn.readBuf[:size])
+		if err != nil {
+			e.flushPipeline(conn)
+			e.sendError(conn, 400, "Bad Request")
+			e.closeConnection(conn.fd)
+			return false
+		}
+		// A chunked body's size is only known once decoded
+		if e.maxBodyBytes > 0 && len(req.Body) > e.maxBodyBytes {
+			http.ReleaseRequest(req)
+			e.flushPipeline(conn)
+			e.sendError(conn, 413, "Request Entity Too Large")
+			e.closeConnection(conn.fd)
+			return false
+		}
+		if e.duplicateHeaders == http.DuplicateCombine && len(req.Repeated) > 0 {
+			req.CombineRepeated()
+		}
+		if conn.spill != nil {
+			if conn.spill.chunks != nil {
+				conn.spill.chunks.SetTrailers(req)
+			}
+			conn.spool = conn.spill.body
+			conn.spill = nil
+			req.Spool = conn.spool
+		}
+
+		conn.requestSize = size
+		conn.sizeChecked = false
+		conn.bodyLimit = 0
+		conn.chunkEnd = 0
+		conn.chunks.Reset()
+		conn.headerDeadline = time.Time{}
+		conn.readDeadline = time.Time{}
+		conn.request = req
+		conn.state = StateProcessing
+
+		// Another complete request waiting behind this one?
+		next := conn.readBuf[size:conn.readOffset]
+		nextSize := http.RequestLength(next)
+		more := nextSize >= 0 && nextSize <= len(next)
+
+		if !e.processRequest(conn, more) {
+			return false
+		}
+	}
+
+	if conn.readOffset > 0 && conn.headerDeadline.IsZero() {
+		e.startDeadlines(conn)
+	}
+	return true
+}
+
+// flushPipeline makes a best-effort write of queued pipelined responses
+// before the connection is closed with an error
+func (e *Engine) flushPipeline(conn *Connection) {
+	if len(conn.pipelined) > 0 {
+		netfd.Write(conn.fd, conn.pipelined)
+		conn.pipelined = conn.pipelined[:0]
+	}
+}
+
+// processRequest processes a single request. more reports whether another
+// pipelined request is buffered behind it: its response is then queued
+// and the last request of the batch writes them all. Returns false if the
+// connection was closed or detached.
+func (e *Engine) processRequest(conn *Connection, more bool) bool {
+	// Handlers run inline for minimal latency; ctx.Detach moves
+	// CPU-heavy or blocking work to the worker pool
 	ctx := e.contextPool.Get().(*http.FDContext)
 	ctx.Reset(conn.fd, conn.request)
+	ctx.SetWriteTimeout(e.writeTimeout)
+	ctx.SetWriteObserver(e.writeObserver)
+	ctx.SetWriteBacklog(&conn.backlog)
+	ctx.SetRequestTimeout(e.requestTimeout)
+	ctx.SetDisconnectWatch(e.watchFn)
+	ctx.SetPeer(conn.peer)
+	ctx.SetMultipartMemory(e.multipartMemory)
+	ctx.SetBackoffs(e.backoffs)
+	ctx.SetTemplates(e.templates)
+	ctx.SetTrustedProxies(e.trustedProxies)
+	ctx.SetAssets(e.assets)
+	ctx.SetServer(e.server)
+	ctx.SetDumper(e.dumper, conn.readBuf[:conn.requestSize])
+	ctx.SetReadAhead(conn.readBuf[conn.requestSize:conn.readOffset])
+	if e.requestEvents != nil && e.requestEvents.Active() {
+		conn.started = e.clock.Now()
+	}
+	if e.noKeepAlive || conn.draining.Load() || wantsClose(conn.request) {
+		ctx.SetKeepAlive(false)
+	}
+	batch := more || len(conn.pipelined) > 0
+	if batch {
+		ctx.SetBatch(&conn.pipelined)
+	}
 
-	for k, v := range params {
-		ctx.SetParam(k, v)
+	if e.cors == nil || !e.serveCORS(ctx) {
+		e.execute(conn, ctx)
 	}
 
-	h(ctx)
+	if ctx.IsAsync() {
+		// The deferred response is written directly, after the queue
+		ctx.FlushBatch()
+		ctx.SetBatch(nil)
+		e.detachConnection(conn, ctx)
+		if fn := ctx.Detached(); fn != nil {
+			handle := ctx.Async()
+			if admit := ctx.Admission(); admit != nil {
+				admit(func() { e.offload(conn, handle, fn) })
+			} else {
+				e.offload(conn, handle, fn)
+			}
+		}
+		return false
+	}
 
+	// Handlers that only set a status and headers still get a response;
+	// streams get their final chunk
+	ctx.Finish()
+	e.applyConnSettings(conn, ctx)
+	if batch && (!more || conn.closeAfter || wantsClose(conn.request)) {
+		ctx.FlushBatch()
+	}
+	if len(e.hooks.response) > 0 {
+		e.runRequestHooks(e.hooks.response, ctx)
+	}
+	if !conn.started.IsZero() {
+		e.publishRequest(conn, ctx)
+	}
+	e.endRequest(ctx)
+
+	// A hijacked connection is closed here too: the handler holds its own
+	// descriptor for it
+	writeErr := ctx.WriteErr()
 	e.contextPool.Put(ctx)
-	e.checkKeepAlive(conn)
+	if writeErr != nil {
+		e.closeConnection(conn.fd)
+		return false
+	}
+	if conn.backlog.Pending() {
+		return e.awaitWritable(conn)
+	}
+	return e.checkKeepAlive(conn)
+}
+
+// dispatch routes the request to its handler (final handler of the pipeline)
+func (e *Engine) dispatch(ctx *http.FDContext) {
+	method, path := ctx.Method(), ctx.Path()
+
+	// Authority-form CONNECT targets ("host:port") bypass the router
+	if method == "CONNECT" && (path == "" || path[0] != '/') {
+		if e.connectHandler == nil {
+			e.replyNotFound(ctx)
+			return
+		}
+		ctx.SetParam("authority", path)
+		e.connectHandler(ctx)
+		return
+	}
+
+	m := e.router.Lookup(method, path)
+
+	// HEAD falls back to the GET handler; the context drops the body
+	if m.Handler == nil && method == "HEAD" && m.Allow != "" {
+		if get := e.router.Lookup("GET", path); get.Handler != nil {
+			m = get
+		}
+	}
+
+	if m.Handler == nil && method == "OPTIONS" && e.autoOptions && m.Allow != "" {
+		ctx.SetHeader("Allow", allowHeader(m.Allow))
+		ctx.Status(204)
+		ctx.WriteStatus()
+		return
+	}
+
+	if m.MethodNotAllowed() {
+		e.replyMethodNotAllowed(ctx, allowHeader(m.Allow))
+		return
+	}
+	if m.Handler == nil {
+		if (e.redirectTrailingSlash || e.redirectFixedPath) && e.redirectPath(ctx, method, path) {
+			return
+		}
+		e.replyNotFound(ctx)
+		return
+	}
+
+	for k, v := range m.Params {
+		ctx.SetParam(k, v)
+	}
+
+	m.Handler(ctx)
+}
+
+// detachConnection parks a connection whose handler called ctx.Async().
+// The fd leaves the poller so no further reads can overwrite the request
+// buffer, and the context stays checked out until the handle completes.
+func (e *Engine) detachConnection(conn *Connection, ctx *http.FDContext) {
+	conn.context = ctx
+	conn.detached = true
+	e.poller.Remove(conn.fd)
+
+	ctx.Async().OnComplete(func() {
+		conn.context = nil
+		ctx.Finish()
+		e.applyConnSettings(conn, ctx)
+		if len(e.hooks.response) > 0 {
+			e.runRequestHooks(e.hooks.response, ctx)
+		}
+		if !conn.started.IsZero() {
+			e.publishRequest(conn, ctx)
+		}
+		e.endRequest(ctx)
+		writeErr := ctx.WriteErr()
+		e.contextPool.Put(ctx)
+		if writeErr != nil {
+			e.closeConnection(conn.fd)
+			return
+		}
+		if conn.backlog.Pending() {
+			e.awaitWritable(conn)
+			return
+		}
+		// Serve requests pipelined behind the deferred one before the
+		// connection rejoins the poller
+		if e.checkKeepAlive(conn) && e.serveBuffered(conn) {
+			conn.detached = false
+			if err := e.poller.Add(conn.fd); err != nil {
+				e.closeConnection(conn.fd)
+			}
+		}
+	})
+}
+
+// offload runs a detached handler on the worker pool and completes its
+// handle when it returns. A closed pool runs it inline. Panics are
+// recovered as on the event loop. The request may have been answered
+// meanwhile (e.g. it timed out), leaving nothing to run.
+func (e *Engine) offload(conn *Connection, handle *http.AsyncHandle, fn func(ctx http.Context)) {
+	task := func() {
+		handle.Respond(func(c http.Context) {
+			defer func() {
+				if r := recover(); r != nil {
+					e.recoverPanic(conn, c.(*http.FDContext), r)
+				}
+			}()
+			fn(c)
+		})
+	}
+	if !e.workerPool.Submit(task) {
+		task()
+	}
+}
+
+// watchHangup starts watching a request's connection for peer shutdown
+func (e *Engine) watchHangup(ctx *http.FDContext) {
+	if e.hangups == nil {
+		return
+	}
+	fd := ctx.FD()
+
+	e.watchMu.Lock()
+	defer e.watchMu.Unlock()
+	if err := e.hangups.Add(fd); err == nil {
+		e.watched[fd] = ctx
+	}
+}
+
+// endRequest stops watching the connection and cancels ctx.Context()
+func (e *Engine) endRequest(ctx *http.FDContext) {
+	fd := ctx.FD()
+
+	e.watchMu.Lock()
+	if e.watched[fd] == ctx {
+		delete(e.watched, fd)
+		e.hangups.Remove(fd)
+	}
+	e.watchMu.Unlock()
+
+	ctx.EndRequest()
+}
+
+// watchHangups cancels the contexts of watched requests whose client
+// went away. Probing under watchMu keeps endRequest from recycling a
+// context while it is checked.
+func (e *Engine) watchHangups() {
+	for {
+		fds, err := e.hangups.Wait(100)
+		if err != nil {
+			return
+		}
+
+		e.watchMu.Lock()
+		for _, fd := range fds {
+			if ctx, ok := e.watched[fd]; ok && ctx.Disconnected() {
+				delete(e.watched, fd)
+				e.hangups.Remove(fd)
+			}
+		}
+		e.watchMu.Unlock()
+	}
+}
+
+// allowHeader completes the methods of a route, sorted and
+// comma-separated, into the Allow header value: HEAD is implied by GET and
+// OPTIONS is always allowed
+func allowHeader(allow string) string {
+	methods := strings.Split(allow, ", ")
+	hasGet, hasHead, hasOptions := false, false, false
+	for _, m := range methods {
+		switch m {
+		case "GET":
+			hasGet = true
+		case "HEAD":
+			hasHead = true
+		case "OPTIONS":
+			hasOptions = true
+		}
+	}
+	if hasGet && !hasHead {
+		methods = append(methods, "HEAD")
+	}
+	if !hasOptions {
+		methods = append(methods, "OPTIONS")
+	}
+	return strings.Join(methods, ", ")
 }
 
 // sendError sends an error response
@@ -389,23 +1455,60 @@ func (e *Engine) sendError(conn *Connection, code int, message string) {
 	response = appendInt(response, code)
 	response = append(response, ' ')
 	response = append(response, message...)
-	response = append(response, "\r\n\r\n"...)
+	response = append(response, "\r\nDate: "...)
+	response = clock.AppendDate(response)
+	response = append(response, "\r\n"...)
+	if e.server != "" {
+		response = append(response, "Server: "...)
+		response = append(response, e.server...)
+		response = append(response, "\r\n"...)
+	}
+	response = append(response, "\r\n"...)
 
-	syscall.Write(conn.fd, response)
+	netfd.Write(conn.fd, response)
 }
 
-// checkKeepAlive checks if connection should be kept alive
-func (e *Engine) checkKeepAlive(conn *Connection) {
-	if conn.request.Proto == "HTTP/1.0" || conn.request.Connection == "close" {
+// checkKeepAlive checks if connection should be kept alive.
+// Returns false if the connection was closed.
+func (e *Engine) checkKeepAlive(conn *Connection) bool {
+	e.releaseSpool(conn)
+	if conn.closeAfter || conn.draining.Load() || wantsClose(conn.request) {
 		e.closeConnection(conn.fd)
+		return false
 	} else {
-		// Keep connection alive - reset for next request
+		// Keep connection alive - drop the request from the read buffer,
+		// keeping any pipelined requests behind it
 		conn.state = StateReading
-		conn.readOffset = 0
+		conn.readOffset = copy(conn.readBuf, conn.readBuf[conn.requestSize:conn.readOffset])
+		conn.requestSize = 0
+		e.shrinkReadBuf(conn)
 		http.ReleaseRequest(conn.request)
 		conn.request = nil
-		conn.lastActive = time.Now()
+		conn.keepAlive = true
+		conn.lastActive = e.clock.Now()
+	}
+	return true
+}
+
+// applyConnSettings carries the keep-alive and idle timeout chosen for
+// the response (by the engine or the route) and the tenant over to the
+// connection
+func (e *Engine) applyConnSettings(conn *Connection, ctx *http.FDContext) {
+	if !ctx.KeepAlive() {
+		conn.closeAfter = true
 	}
+	conn.idleTimeout = ctx.IdleTimeout()
+	if t := ctx.Tenant(); t != "" && t != conn.tenant {
+		e.connMu.Lock()
+		conn.tenant = t
+		e.connMu.Unlock()
+	}
+}
+
+// wantsClose reports whether the connection ends after req: HTTP/1.0
+// without Connection: keep-alive, or Connection: close
+func wantsClose(req *http.Request) bool {
+	return !req.KeepAlive()
 }
 
 // closeConnection closes and cleans up a connection
@@ -422,7 +1525,8 @@ func (e *Engine) closeConnection(fd int) {
 		// 1. Remove from poller first (stop receiving events)
 		e.poller.Remove(fd)
 
-		// 2. Clean up pooled objects
+		// 2. Clean up pooled objects and spilled bodies
+		e.releaseSpool(conn)
 		if conn.request != nil {
 			e.requestPool.Put(conn.request)
 			conn.request = nil
@@ -436,8 +1540,19 @@ func (e *Engine) closeConnection(fd int) {
 			conn.readBuf = nil
 		}
 
-		// 3. Close the fd
-		syscall.Close(fd)
+		// 3. Close the fd (hooks and stats first: the number is reused
+		// at once)
+		if len(e.hooks.close) > 0 {
+			e.runCloseHooks(fd)
+		}
+		if e.connEvents != nil && e.connEvents.Active() {
+			e.publishConn(fd, nil)
+		}
+		if e.observatory != nil {
+			e.observatory.Writes.Forget(fd)
+			e.observatory.Tracer.ForgetConn(fd)
+		}
+		netfd.Close(fd)
 
 		// 4. Reset and return connection to pool
 		conn.Reset()
@@ -451,13 +1566,32 @@ func (e *Engine) cleanupIdleConnections() {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		now := time.Now()
+		now := e.clock.Now()
 		var toClose []int
 
 		e.connMu.RLock()
 		for fd, conn := range e.connections {
-			// Close connections that have been idle too long (in any state except processing)
-			if conn.state != StateProcessing && now.Sub(conn.lastActive) > e.idleTimeout {
+			// Close connections that have been idle too long. A stalled write
+			// is bounded by its write deadline instead.
+			idle := e.idleTimeout
+			if conn.idleTimeout > 0 {
+				idle = conn.idleTimeout
+			}
+			if conn.state != StateProcessing && conn.state != StateWriting && now.Sub(conn.lastActive) > idle {
+				toClose = append(toClose, fd)
+				continue
+			}
+			// Close connections trickling in a request past its deadlines
+			if !conn.headerDeadline.IsZero() && now.After(conn.headerDeadline) {
+				toClose = append(toClose, fd)
+				continue
+			}
+			if !conn.readDeadline.IsZero() && now.After(conn.readDeadline) {
+				toClose = append(toClose, fd)
+				continue
+			}
+			// Close connections whose client does not take the response
+			if !conn.writeDeadline.IsZero() && now.After(conn.writeDeadline) {
 				toClose = append(toClose, fd)
 			}
 		}
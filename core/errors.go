@@ -0,0 +1,86 @@
+package core
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// ErrorHandler answers a request that failed with err, e.g. with a
+// branded JSON error. Recovered panics arrive as *PanicError.
+type ErrorHandler func(ctx http.Context, err error)
+
+// PanicError is the error an ErrorHandler gets for a recovered panic
+type PanicError struct {
+	Value any
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// Unwrap returns the panic value if it is an error
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// NotFound sets the handler of requests no route matches, instead of the
+// plain-text 404
+func (e *Engine) NotFound(h HandlerFunc) {
+	e.notFound = h
+}
+
+// MethodNotAllowed sets the handler of requests whose path has routes,
+// but not for their method, instead of the plain-text 405. The Allow
+// header is set before it runs.
+func (e *Engine) MethodNotAllowed(h HandlerFunc) {
+	e.methodNotAllowed = h
+}
+
+// OnError sets the handler of requests that fail in their handler, which
+// are answered with a plain-text 500 without one. It runs after the
+// panic handler (see SetPanicHandler) and only if no response was
+// started; the connection still closes after the response.
+func (e *Engine) OnError(h ErrorHandler) {
+	e.errorHandler = h
+}
+
+// replyNotFound answers a request no route matches
+func (e *Engine) replyNotFound(ctx *http.FDContext) {
+	if e.notFound != nil {
+		e.notFound(ctx)
+		return
+	}
+	ctx.String(404, "Not Found")
+}
+
+// replyMethodNotAllowed answers a request whose method has no route for
+// its path
+func (e *Engine) replyMethodNotAllowed(ctx *http.FDContext, allow string) {
+	ctx.SetHeader("Allow", allow)
+	if e.methodNotAllowed != nil {
+		e.methodNotAllowed(ctx)
+		return
+	}
+	ctx.String(405, "Method Not Allowed")
+}
+
+// replyError answers a failed request with the error handler, falling
+// back to a plain 500 if it panics or writes nothing
+func (e *Engine) replyError(ctx *http.FDContext, err error) {
+	if e.errorHandler != nil {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("⚠️  panic in error handler for %s %s: %v", ctx.Method(), ctx.Path(), r)
+				}
+			}()
+			e.errorHandler(ctx, err)
+		}()
+	}
+	if !ctx.Written() {
+		ctx.String(500, "Internal Server Error")
+	}
+}
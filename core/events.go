@@ -0,0 +1,43 @@
+package core
+
+import (
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/searchktools/fast-server/core/events"
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// SetEventBus makes the engine publish connection events to the bus's
+// events.TopicConn and completed requests to events.TopicRequest. Events
+// are only built while a topic has subscribers. It must be called before
+// Run.
+func (e *Engine) SetEventBus(bus *events.Bus) {
+	e.connEvents = events.NewTopic[events.Conn](bus, events.TopicConn)
+	e.requestEvents = events.NewTopic[events.Request](bus, events.TopicRequest)
+}
+
+// publishConn publishes an accepted (peer set) or closed connection
+func (e *Engine) publishConn(fd int, peer syscall.Sockaddr) {
+	ev := events.Conn{FD: fd, Open: peer != nil, Time: e.clock.Now()}
+	if peer != nil {
+		ev.Remote = http.SockaddrToAddr(peer)
+	}
+	e.connEvents.Publish(ev)
+}
+
+// publishRequest publishes the request conn just completed. Request
+// strings point into the read buffer, so they are copied.
+func (e *Engine) publishRequest(conn *Connection, ctx *http.FDContext) {
+	e.requestEvents.Publish(events.Request{
+		FD:       conn.fd,
+		Method:   strings.Clone(ctx.Method()),
+		Path:     strings.Clone(ctx.Path()),
+		Status:   ctx.StatusCode(),
+		Size:     ctx.ResponseSize(),
+		Tenant:   ctx.Tenant(),
+		Duration: e.clock.Now().Sub(conn.started),
+	})
+	conn.started = time.Time{}
+}
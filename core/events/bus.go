@@ -0,0 +1,231 @@
+// Package events is an in-process publish/subscribe bus of typed topics.
+// It decouples subsystems: the engine publishes connection and request
+// events, observability and the SSE and WebSocket broadcasters
+// subscribe, and applications publish their own events to topics.
+//
+// Publishing never blocks. Each subscriber has a bounded queue drained by
+// its own goroutine; events that do not fit are dropped and counted.
+package events
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuffer is the queue length of a subscriber that sets none
+const DefaultBuffer = 256
+
+// Bus holds named topics
+type Bus struct {
+	mu     sync.Mutex
+	topics map[string]topic
+}
+
+// topic is the untyped view of a Topic
+type topic interface {
+	Stats() TopicStats
+	eventType() reflect.Type
+}
+
+// TopicStats describes a topic
+type TopicStats struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Subscribers int    `json:"subscribers"`
+	Published   uint64 `json:"published"`
+	Dropped     uint64 `json:"dropped"` // Deliveries lost to full subscriber queues
+}
+
+// New creates an empty bus
+func New() *Bus {
+	return &Bus{topics: make(map[string]topic)}
+}
+
+// Stats describes the bus's topics, sorted by name
+func (b *Bus) Stats() []TopicStats {
+	b.mu.Lock()
+	stats := make([]TopicStats, 0, len(b.topics))
+	for _, t := range b.topics {
+		stats = append(stats, t.Stats())
+	}
+	b.mu.Unlock()
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// Topic is a named stream of events of type T
+type Topic[T any] struct {
+	name string
+
+	// subs is replaced on (un)subscribe so Publish reads it without locks
+	mu   sync.Mutex
+	subs atomic.Pointer[[]*Subscription[T]]
+
+	published atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// NewTopic returns the topic of b named name, creating it. Every caller
+// naming a topic must use the same event type; NewTopic panics otherwise.
+func NewTopic[T any](b *Bus, name string) *Topic[T] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if existing, ok := b.topics[name]; ok {
+		t, ok := existing.(*Topic[T])
+		if !ok {
+			panic(fmt.Sprintf("events: topic %q carries %v, not %v",
+				name, existing.eventType(), reflect.TypeFor[T]()))
+		}
+		return t
+	}
+	t := &Topic[T]{name: name}
+	b.topics[name] = t
+	return t
+}
+
+// Name returns the topic's name
+func (t *Topic[T]) Name() string {
+	return t.name
+}
+
+// Active reports whether the topic has subscribers; publishers can skip
+// building events nobody receives
+func (t *Topic[T]) Active() bool {
+	subs := t.subs.Load()
+	return subs != nil && len(*subs) > 0
+}
+
+// Publish queues v for every subscriber and returns how many took it
+func (t *Topic[T]) Publish(v T) int {
+	subs := t.subs.Load()
+	if subs == nil || len(*subs) == 0 {
+		return 0
+	}
+	t.published.Add(1)
+	queued := 0
+	for _, s := range *subs {
+		if s.offer(v) {
+			queued++
+		} else {
+			t.dropped.Add(1)
+		}
+	}
+	return queued
+}
+
+// Subscribe calls fn with the topic's events, in order, on a goroutine
+// of its own. At most buffer events wait for fn (<= 0: DefaultBuffer).
+func (t *Topic[T]) Subscribe(buffer int, fn func(T)) *Subscription[T] {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	s := &Subscription[T]{
+		topic: t,
+		ch:    make(chan T, buffer),
+		done:  make(chan struct{}),
+	}
+	go s.run(fn)
+
+	t.mu.Lock()
+	var subs []*Subscription[T]
+	if old := t.subs.Load(); old != nil {
+		subs = append(subs, *old...)
+	}
+	subs = append(subs, s)
+	t.subs.Store(&subs)
+	t.mu.Unlock()
+	return s
+}
+
+// Stats describes the topic
+func (t *Topic[T]) Stats() TopicStats {
+	n := 0
+	if subs := t.subs.Load(); subs != nil {
+		n = len(*subs)
+	}
+	return TopicStats{
+		Name:        t.name,
+		Type:        reflect.TypeFor[T]().String(),
+		Subscribers: n,
+		Published:   t.published.Load(),
+		Dropped:     t.dropped.Load(),
+	}
+}
+
+func (t *Topic[T]) eventType() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
+// unsubscribe removes s from the topic
+func (t *Topic[T]) unsubscribe(s *Subscription[T]) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	old := t.subs.Load()
+	if old == nil {
+		return
+	}
+	subs := make([]*Subscription[T], 0, len(*old))
+	for _, other := range *old {
+		if other != s {
+			subs = append(subs, other)
+		}
+	}
+	t.subs.Store(&subs)
+}
+
+// Subscription is a subscriber of a topic
+type Subscription[T any] struct {
+	topic *Topic[T]
+	ch    chan T
+	done  chan struct{}
+
+	// mu orders offers against Close, which closes ch
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// offer queues v unless the queue is full or the subscription closed
+func (s *Subscription[T]) offer(v T) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return false
+	}
+	select {
+	case s.ch <- v:
+		return true
+	default:
+		s.dropped.Add(1)
+		return false
+	}
+}
+
+func (s *Subscription[T]) run(fn func(T)) {
+	defer close(s.done)
+	for v := range s.ch {
+		fn(v)
+	}
+}
+
+// Dropped returns how many events did not fit in the queue
+func (s *Subscription[T]) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and waits until fn has handled the events already
+// queued. It must not be called from fn.
+func (s *Subscription[T]) Close() {
+	s.topic.unsubscribe(s)
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+	s.mu.Unlock()
+	<-s.done
+}
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestTopicDeliversInOrder(t *testing.T) {
+	bus := New()
+	topic := NewTopic[int](bus, "numbers")
+	if topic.Active() || topic.Publish(1) != 0 {
+		t.Fatal("Expected a topic without subscribers to take nothing")
+	}
+
+	var mu sync.Mutex
+	var got []int
+	sub := topic.Subscribe(1000, func(v int) {
+		mu.Lock()
+		got = append(got, v)
+		mu.Unlock()
+	})
+	if NewTopic[int](bus, "numbers") != topic || !topic.Active() {
+		t.Fatal("Expected the same, active topic by name")
+	}
+	for i := 0; i < 1000; i++ {
+		topic.Publish(i)
+	}
+	sub.Close()
+
+	if len(got) != 1000 {
+		t.Fatalf("Expected 1000 events, got %d", len(got))
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("Event %d is %d", i, v)
+		}
+	}
+	if topic.Active() || topic.Publish(1) != 0 {
+		t.Error("Expected no subscribers after Close")
+	}
+	if st := bus.Stats(); len(st) != 1 || st[0].Published != 1000 || st[0].Type != "int" {
+		t.Errorf("Unexpected stats %+v", st)
+	}
+}
+
+func TestSubscriptionDropsWhenFull(t *testing.T) {
+	topic := NewTopic[string](New(), "slow")
+	release := make(chan struct{})
+	sub := topic.Subscribe(2, func(string) { <-release })
+
+	// One event is being handled, two wait; the rest are dropped
+	delivered := 0
+	for i := 0; i < 10; i++ {
+		delivered += topic.Publish("x")
+	}
+	close(release)
+	sub.Close()
+
+	if delivered < 2 || delivered > 3 || sub.Dropped() != uint64(10-delivered) {
+		t.Errorf("Delivered %d, dropped %d", delivered, sub.Dropped())
+	}
+	if st := topic.Stats(); st.Dropped != sub.Dropped() {
+		t.Errorf("Topic counted %d drops, subscription %d", st.Dropped, sub.Dropped())
+	}
+}
+
+func TestTopicTypeMismatch(t *testing.T) {
+	bus := New()
+	NewTopic[int](bus, "t")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for a topic of another type")
+		}
+	}()
+	NewTopic[string](bus, "t")
+}
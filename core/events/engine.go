@@ -0,0 +1,31 @@
+package events
+
+import (
+	"net"
+	"time"
+)
+
+// Topics the engine publishes to (see Engine.SetEventBus)
+const (
+	TopicConn    = "engine.conn"    // Conn events
+	TopicRequest = "engine.request" // Request events
+)
+
+// Conn is published when the engine accepts or closes a connection
+type Conn struct {
+	FD     int
+	Open   bool     // false: closed
+	Remote net.Addr // Client address (accepts only)
+	Time   time.Time
+}
+
+// Request is published once a request's response is complete
+type Request struct {
+	FD       int
+	Method   string
+	Path     string
+	Status   int
+	Size     int64 // Response bytes written, headers included
+	Tenant   string
+	Duration time.Duration // From routing to the end of the response
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"log"
+	"slices"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// SetEvictionThreshold sets the share of the RLIMIT_NOFILE soft limit
+// (0-1) at which the engine starts closing idle keep-alive connections,
+// most idle first, to keep accepting new ones. Connections are also
+// evicted when accept fails with EMFILE/ENFILE. Zero disables eviction.
+func (e *Engine) SetEvictionThreshold(share float64) {
+	e.evictThreshold = share
+}
+
+// Evictions returns how many idle connections were closed to free file
+// descriptors
+func (e *Engine) Evictions() uint64 {
+	return e.evictions.Load()
+}
+
+// fdLimit returns the RLIMIT_NOFILE soft limit (0 if unknown)
+func fdLimit() int {
+	cur, _, err := netfd.FileLimit()
+	if err != nil {
+		return 0
+	}
+	return int(cur)
+}
+
+// evictBatch is how many idle connections are closed at a time: 1% of
+// the fd limit, so pressure is relieved without dropping whole swathes
+func (e *Engine) evictBatch() int {
+	return e.fdLimit/100 + 1
+}
+
+// relieveFDPressure evicts idle connections once the descriptor just
+// accepted reaches the eviction threshold. The kernel hands out the lowest
+// free descriptor, so nfd tracks how many are in use, including ones held
+// by files and pollers.
+func (e *Engine) relieveFDPressure(nfd int) {
+	if e.evictThreshold <= 0 || e.fdLimit <= 0 {
+		return
+	}
+	high := int(e.evictThreshold * float64(e.fdLimit))
+	if nfd < high {
+		return
+	}
+	e.evictIdle(nfd - high + e.evictBatch())
+}
+
+// evictIdle closes up to n idle keep-alive connections, least recently
+// active first, and returns how many it closed. Connections that have a
+// request in flight or have not served one yet are never evicted.
+func (e *Engine) evictIdle(n int) int {
+	if n <= 0 {
+		return 0
+	}
+
+	var idle []*Connection
+	e.connMu.RLock()
+	for _, conn := range e.connections {
+		if conn.keepAlive && conn.state == StateReading && conn.readOffset == 0 {
+			idle = append(idle, conn)
+		}
+	}
+	e.connMu.RUnlock()
+
+	slices.SortFunc(idle, func(a, b *Connection) int {
+		return a.lastActive.Compare(b.lastActive)
+	})
+	if len(idle) > n {
+		idle = idle[:n]
+	}
+	for _, conn := range idle {
+		e.closeConnection(conn.fd)
+	}
+
+	if len(idle) > 0 {
+		e.evictions.Add(uint64(len(idle)))
+		log.Printf("⚠️  fd pressure: evicted %d idle connections", len(idle))
+	}
+	return len(idle)
+}
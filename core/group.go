@@ -0,0 +1,124 @@
+package core
+
+import (
+	"github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/middleware"
+	"github.com/searchktools/fast-server/core/router"
+)
+
+// RouterGroup registers routes under a shared path prefix, middleware
+// and route options
+type RouterGroup struct {
+	engine  *Engine
+	group   *router.Group
+	options []RouteOption
+}
+
+// Group creates a route group with the given prefix and middleware.
+// Group middleware runs after global middleware, only for the group's routes.
+// The group is registered with the engine's router, which lists it with
+// Groups.
+func (e *Engine) Group(prefix string, handlers ...middleware.HandlerFunc) *RouterGroup {
+	return &RouterGroup{engine: e, group: e.router.Group(prefix, groupMiddleware(handlers)...)}
+}
+
+// Groups returns the engine's route groups, nested ones included, in the
+// order they were created
+func (e *Engine) Groups() []router.GroupInfo {
+	return e.router.Groups()
+}
+
+// Group creates a nested group inheriting this group's prefix and middleware
+func (g *RouterGroup) Group(prefix string, handlers ...middleware.HandlerFunc) *RouterGroup {
+	return &RouterGroup{
+		engine:  g.engine,
+		group:   g.group.Group(prefix, groupMiddleware(handlers)...),
+		options: append([]RouteOption(nil), g.options...),
+	}
+}
+
+// Use adds middleware to the group. It applies to routes registered afterwards.
+func (g *RouterGroup) Use(handlers ...middleware.HandlerFunc) {
+	g.group.Use(groupMiddleware(handlers)...)
+}
+
+// With adds route options, e.g. IdleTimeout or MaxBodyBytes, to the
+// group's routes registered afterwards. A route's own options win.
+func (g *RouterGroup) With(opts ...RouteOption) *RouterGroup {
+	g.options = append(g.options, opts...)
+	return g
+}
+
+// Handle registers a route for an arbitrary method in the group
+func (g *RouterGroup) Handle(method, path string, handler HandlerFunc, opts ...RouteOption) {
+	if !isMethodToken(method) {
+		panic("invalid HTTP method: " + method)
+	}
+	g.handle(method, path, handler, opts...)
+}
+
+// GET registers a GET route in the group
+func (g *RouterGroup) GET(path string, handler HandlerFunc, opts ...RouteOption) {
+	g.handle("GET", path, handler, opts...)
+}
+
+// POST registers a POST route in the group
+func (g *RouterGroup) POST(path string, handler HandlerFunc, opts ...RouteOption) {
+	g.handle("POST", path, handler, opts...)
+}
+
+// PUT registers a PUT route in the group
+func (g *RouterGroup) PUT(path string, handler HandlerFunc, opts ...RouteOption) {
+	g.handle("PUT", path, handler, opts...)
+}
+
+// DELETE registers a DELETE route in the group
+func (g *RouterGroup) DELETE(path string, handler HandlerFunc, opts ...RouteOption) {
+	g.handle("DELETE", path, handler, opts...)
+}
+
+// PATCH registers a PATCH route in the group
+func (g *RouterGroup) PATCH(path string, handler HandlerFunc, opts ...RouteOption) {
+	g.handle("PATCH", path, handler, opts...)
+}
+
+// HEAD registers a HEAD route in the group
+func (g *RouterGroup) HEAD(path string, handler HandlerFunc, opts ...RouteOption) {
+	g.handle("HEAD", path, handler, opts...)
+}
+
+// OPTIONS registers an OPTIONS route in the group
+func (g *RouterGroup) OPTIONS(path string, handler HandlerFunc, opts ...RouteOption) {
+	g.handle("OPTIONS", path, handler, opts...)
+}
+
+// handle registers a route through the router group, which wraps it in
+// the group's middleware
+func (g *RouterGroup) handle(method, path string, handler HandlerFunc, opts ...RouteOption) {
+	if len(g.options) > 0 {
+		opts = append(append([]RouteOption(nil), g.options...), opts...)
+	}
+	handler = g.engine.withRouteOptions(method, router.JoinPath(g.group.Prefix(), path), handler, opts)
+	g.group.Add(method, path, func(ctx any) {
+		handler(ctx.(http.Context))
+	})
+}
+
+// groupMiddleware adapts engine middleware to the router's: each runs
+// before the rest of the chain and stops it by aborting the request, as
+// in the engine's pipeline
+func groupMiddleware(handlers []middleware.HandlerFunc) []router.Middleware {
+	mw := make([]router.Middleware, len(handlers))
+	for i, h := range handlers {
+		mw[i] = func(next router.HandlerFunc) router.HandlerFunc {
+			return func(ctx any) {
+				fc := ctx.(*http.FDContext)
+				h(fc)
+				if !fc.IsAborted() {
+					next(ctx)
+				}
+			}
+		}
+	}
+	return mw
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// AcceptHook is called for each accepted connection with the client's
+// address. Returning false closes the connection right away.
+type AcceptHook func(fd int, remote net.Addr) bool
+
+// CloseHook is called when a connection is closed, before its fd is
+// released
+type CloseHook func(fd int)
+
+// RequestHook is called with the context of a request, see OnRequest and
+// OnResponse
+type RequestHook func(ctx *http.FDContext)
+
+// hooks holds the lifecycle hooks. The engine only checks slice lengths
+// on the hot path, so unused hooks cost nothing.
+type hooks struct {
+	accept   []AcceptHook
+	close    []CloseHook
+	request  []RequestHook
+	response []RequestHook
+}
+
+// OnAccept registers a hook for accepted connections, e.g. to tag or
+// limit clients. Hooks run on the event loop in registration order and
+// must be registered before Run.
+func (e *Engine) OnAccept(h AcceptHook) {
+	e.hooks.accept = append(e.hooks.accept, h)
+}
+
+// OnClose registers a hook for closed connections. It may run on the
+// idle-cleanup goroutine as well as the event loop.
+func (e *Engine) OnClose(h CloseHook) {
+	e.hooks.close = append(e.hooks.close, h)
+}
+
+// OnRequest registers a hook called before the middleware chain runs for
+// each request. Must be registered before Run.
+func (e *Engine) OnRequest(h RequestHook) {
+	e.hooks.request = append(e.hooks.request, h)
+}
+
+// OnResponse registers a hook called once a request's response is
+// complete, including deferred ones (ctx.Async, Offload). It may run on a
+// worker goroutine. Must be registered before Run.
+func (e *Engine) OnResponse(h RequestHook) {
+	e.hooks.response = append(e.hooks.response, h)
+}
+
+// runAcceptHooks reports whether all accept hooks admit the connection
+func (e *Engine) runAcceptHooks(fd int, peer syscall.Sockaddr) bool {
+	remote := http.SockaddrToAddr(peer)
+	for _, h := range e.hooks.accept {
+		if !h(fd, remote) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Engine) runCloseHooks(fd int) {
+	for _, h := range e.hooks.close {
+		h(fd)
+	}
+}
+
+func (e *Engine) runRequestHooks(hs []RequestHook, ctx *http.FDContext) {
+	for _, h := range hs {
+		h(ctx)
+	}
+}
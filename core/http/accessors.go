@@ -0,0 +1,109 @@
+package http
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Typed accessors for path parameters and query values. They parse in
+// place, allocating only for malformed values.
+
+// ParamInt returns the path parameter key as an int
+func (c *FDContext) ParamInt(key string) (int, error) {
+	return paramInt(key, c.Param(key))
+}
+
+// ParamInt64 returns the path parameter key as an int64
+func (c *FDContext) ParamInt64(key string) (int64, error) {
+	return paramInt64(key, c.Param(key))
+}
+
+// QueryInt returns the query value key as an int, or def if it is
+// missing or not a number
+func (c *FDContext) QueryInt(key string, def int) int {
+	return queryInt(c.Query(key), def)
+}
+
+// QueryInt64 returns the query value key as an int64, or def if it is
+// missing or not a number
+func (c *FDContext) QueryInt64(key string, def int64) int64 {
+	return queryInt64(c.Query(key), def)
+}
+
+// QueryBool returns the query value key as a bool (1, t, true, 0, f,
+// false in any case, as strconv.ParseBool), or def if it is missing or
+// not a bool
+func (c *FDContext) QueryBool(key string, def bool) bool {
+	return queryBool(c.Query(key), def)
+}
+
+// ParamInt returns the path parameter key as an int
+func (c *StandardContext) ParamInt(key string) (int, error) {
+	return paramInt(key, c.Param(key))
+}
+
+// ParamInt64 returns the path parameter key as an int64
+func (c *StandardContext) ParamInt64(key string) (int64, error) {
+	return paramInt64(key, c.Param(key))
+}
+
+// QueryInt returns the query value key as an int, or def
+func (c *StandardContext) QueryInt(key string, def int) int {
+	return queryInt(c.Query(key), def)
+}
+
+// QueryInt64 returns the query value key as an int64, or def
+func (c *StandardContext) QueryInt64(key string, def int64) int64 {
+	return queryInt64(c.Query(key), def)
+}
+
+// QueryBool returns the query value key as a bool, or def
+func (c *StandardContext) QueryBool(key string, def bool) bool {
+	return queryBool(c.Query(key), def)
+}
+
+func paramInt(key, s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("param %q: %w", key, err)
+	}
+	return n, nil
+}
+
+func paramInt64(key, s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("param %q: %w", key, err)
+	}
+	return n, nil
+}
+
+func queryInt(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return def
+}
+
+func queryInt64(s string, def int64) int64 {
+	if s == "" {
+		return def
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	return def
+}
+
+func queryBool(s string, def bool) bool {
+	if s == "" {
+		return def
+	}
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	return def
+}
@@ -0,0 +1,170 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// immutableCacheControl is sent with fingerprinted assets: their URL
+// changes with their content, so clients may keep them for good
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// AssetConfig configures fingerprinted static assets (see NewAssets)
+type AssetConfig struct {
+	// Dir holds the asset files
+	Dir string
+
+	// Prefix is the URL path the assets are served under. Default: "/static".
+	Prefix string
+}
+
+// Assets serves the files of a directory under content-addressed URLs
+// for cache busting without a bundler: each file is hashed once, when
+// the Assets is created, and app.css is served as app.<hash>.css with
+// immutable cache headers. Pages link to ctx.Asset("app.css"), or
+// {{asset "app.css"}} in templates (see FuncMap).
+type Assets struct {
+	dir    string
+	prefix string
+
+	// Logical name ("css/app.css") to fingerprinted URL, and
+	// fingerprinted name to logical name
+	urls  map[string]string
+	files map[string]string
+}
+
+// NewAssets hashes the files in cfg.Dir
+func NewAssets(cfg AssetConfig) (*Assets, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "/static"
+	}
+	a := &Assets{
+		dir:    cfg.Dir,
+		prefix: "/" + strings.Trim(cfg.Prefix, "/"),
+		urls:   make(map[string]string),
+		files:  make(map[string]string),
+	}
+
+	err := filepath.WalkDir(cfg.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(cfg.Dir, p)
+		if err != nil {
+			return err
+		}
+		sum, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(rel)
+		ext := path.Ext(name)
+		hashed := strings.TrimSuffix(name, ext) + "." + sum + ext
+		a.urls[name] = a.prefix + "/" + hashed
+		a.files[hashed] = name
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// hashFile returns the first 12 hex digits of the SHA-256 of a file
+func hashFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+// Prefix returns the URL path the assets are served under
+func (a *Assets) Prefix() string {
+	return a.prefix
+}
+
+// URL returns the fingerprinted URL of the asset name ("css/app.css"),
+// or its plain URL if it is not one of the files
+func (a *Assets) URL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if u, ok := a.urls[name]; ok {
+		return u
+	}
+	return a.prefix + "/" + name
+}
+
+// Manifest returns the fingerprinted URL of every asset by logical name
+func (a *Assets) Manifest() map[string]string {
+	m := make(map[string]string, len(a.urls))
+	for name, u := range a.urls {
+		m[name] = u
+	}
+	return m
+}
+
+// WriteManifest writes the manifest as JSON, e.g. at build time for a
+// CDN upload or a frontend that resolves URLs itself
+func (a *Assets) WriteManifest(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a.urls)
+}
+
+// FuncMap returns the template functions resolving asset URLs, for
+// TemplateConfig.Funcs: {{asset "css/app.css"}}
+func (a *Assets) FuncMap() map[string]any {
+	return map[string]any{"asset": a.URL}
+}
+
+// Handler returns the handler serving the assets, for a route of
+// Prefix()+"/*filepath". Fingerprinted names are cached for good; plain
+// names are served as well, revalidated on every use.
+func (a *Assets) Handler() func(ctx Context) {
+	return func(ctx Context) {
+		fc, ok := ctx.(*FDContext)
+		if !ok {
+			ctx.String(404, "File not found")
+			return
+		}
+		name := strings.TrimPrefix(fc.Param("filepath"), "/")
+		if logical, ok := a.files[name]; ok {
+			fc.SetHeader("Cache-Control", immutableCacheControl)
+			name = logical
+		} else if _, ok := a.urls[name]; ok {
+			fc.SetHeader("Cache-Control", "no-cache")
+		} else {
+			fc.String(404, "File not found")
+			return
+		}
+		fc.ServeFile(filepath.Join(a.dir, filepath.FromSlash(name)))
+	}
+}
+
+// SetAssets sets the assets Asset resolves names with
+func (c *FDContext) SetAssets(a *Assets) {
+	c.assets = a
+}
+
+// Asset returns the fingerprinted URL of the asset name, or name if the
+// engine serves no assets
+func (c *FDContext) Asset(name string) string {
+	if c.assets == nil {
+		return name
+	}
+	return c.assets.URL(name)
+}
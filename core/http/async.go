@@ -0,0 +1,115 @@
+package http
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrAsyncCompleted is returned when an async handle is completed twice
+var ErrAsyncCompleted = errors.New("async response already completed")
+
+// AsyncHandle is a request detached from the synchronous processing path.
+//
+// While a handle is pending the engine keeps the context, request and
+// connection checked out: they are not recycled and the connection does
+// not move to keep-alive. Exactly one of Respond or Fail must be called.
+type AsyncHandle struct {
+	mu     sync.Mutex
+	ctx    Context
+	done   bool
+	onDone []func()
+
+	// responding is set while Respond runs fn; rearmed records that fn
+	// called Async again, e.g. an offloaded handler going asynchronous,
+	// which leaves the handle pending for a later Respond or Fail
+	responding atomic.Bool
+	rearmed    atomic.Bool
+}
+
+func newAsyncHandle(ctx Context) *AsyncHandle {
+	return &AsyncHandle{ctx: ctx}
+}
+
+// Respond writes the deferred response using fn and completes the handle
+func (h *AsyncHandle) Respond(fn func(ctx Context)) error {
+	h.mu.Lock()
+	if h.done {
+		h.mu.Unlock()
+		return ErrAsyncCompleted
+	}
+	h.responding.Store(true)
+	fn(h.ctx)
+	h.responding.Store(false)
+	if h.rearmed.Swap(false) {
+		h.mu.Unlock()
+		return nil
+	}
+	return h.complete()
+}
+
+// rearm keeps the handle pending past the Respond running, if any
+func (h *AsyncHandle) rearm() {
+	if h.responding.Load() {
+		h.rearmed.Store(true)
+	}
+}
+
+// Fail sends an error response and completes the handle
+func (h *AsyncHandle) Fail(code int, message string) error {
+	h.mu.Lock()
+	if h.done {
+		h.mu.Unlock()
+		return ErrAsyncCompleted
+	}
+	h.ctx.Error(code, message)
+	return h.complete()
+}
+
+// claim takes the handle if it is pending and no Respond is running, for
+// the caller to write a response and complete it
+func (h *AsyncHandle) claim() bool {
+	if !h.mu.TryLock() {
+		return false
+	}
+	if h.done {
+		h.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// Done reports whether the handle has been completed
+func (h *AsyncHandle) Done() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.done
+}
+
+// OnComplete registers fn to run once the handle completes; functions
+// run in the order they were registered. If the handle is already
+// complete, fn runs immediately.
+func (h *AsyncHandle) OnComplete(fn func()) {
+	h.mu.Lock()
+	if h.done {
+		h.mu.Unlock()
+		fn()
+		return
+	}
+	h.onDone = append(h.onDone, fn)
+	h.mu.Unlock()
+}
+
+// complete marks the handle done and runs the completion hook.
+// Must be called with h.mu held; releases it.
+func (h *AsyncHandle) complete() error {
+	h.done = true
+	onDone := h.onDone
+	h.onDone = nil
+	h.mu.Unlock()
+
+	for _, fn := range onDone {
+		fn()
+	}
+	return nil
+}
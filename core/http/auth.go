@@ -0,0 +1,147 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// BasicAuth returns the username and password of an Authorization header
+// using the Basic scheme (RFC 7617). They are decoded into a buffer the
+// context reuses, so they are valid until the request ends and must be
+// copied to be kept.
+func (c *FDContext) BasicAuth() (username, password string, ok bool) {
+	return parseBasicAuth(c.Header("Authorization"), &c.authBuf)
+}
+
+// BasicAuth returns the credentials of a Basic Authorization header
+func (c *StandardContext) BasicAuth() (username, password string, ok bool) {
+	var buf []byte
+	return parseBasicAuth(c.Header("Authorization"), &buf)
+}
+
+// parseBasicAuth decodes "Basic base64(user:pass)" into *buf
+func parseBasicAuth(auth string, buf *[]byte) (username, password string, ok bool) {
+	const prefix = "Basic "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	src := unsafeBytes(strings.TrimSpace(auth[len(prefix):]))
+
+	n := base64.StdEncoding.DecodedLen(len(src))
+	if cap(*buf) < n {
+		*buf = make([]byte, n)
+	}
+	dst := (*buf)[:n]
+	n, err := base64.StdEncoding.Decode(dst, src)
+	if err != nil {
+		return "", "", false
+	}
+	dst = dst[:n]
+
+	i := bytes.IndexByte(dst, ':')
+	if i < 0 {
+		return "", "", false
+	}
+	return unsafeString(dst[:i]), unsafeString(dst[i+1:]), true
+}
+
+// BearerToken returns the token of an Authorization header using the
+// Bearer scheme (RFC 6750)
+func (c *FDContext) BearerToken() (string, bool) {
+	return parseBearer(c.Header("Authorization"))
+}
+
+// Unauthorized sends 401 with a Bearer challenge for realm. If the request
+// carried a token, the challenge says it was invalid (invalid_token).
+func (c *FDContext) Unauthorized(realm string) {
+	_, hasToken := c.BearerToken()
+	c.SetHeader("WWW-Authenticate", bearerChallenge(realm, hasToken))
+	c.Error(401, "Unauthorized")
+}
+
+// Forbidden sends 403. If the request carried a bearer token, a challenge
+// says it lacks the scope the resource needs (insufficient_scope).
+func (c *FDContext) Forbidden() {
+	if _, ok := c.BearerToken(); ok {
+		c.SetHeader("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+	}
+	c.Error(403, "Forbidden")
+}
+
+// BearerToken returns the token of a Bearer Authorization header
+func (c *StandardContext) BearerToken() (string, bool) {
+	return parseBearer(c.Header("Authorization"))
+}
+
+// Unauthorized sends 401 with a Bearer challenge for realm
+func (c *StandardContext) Unauthorized(realm string) {
+	_, hasToken := c.BearerToken()
+	c.sendChallenge(401, "Unauthorized", bearerChallenge(realm, hasToken))
+}
+
+// Forbidden sends 403, with an insufficient_scope challenge if the request
+// carried a bearer token
+func (c *StandardContext) Forbidden() {
+	challenge := ""
+	if _, ok := c.BearerToken(); ok {
+		challenge = `Bearer error="insufficient_scope"`
+	}
+	c.sendChallenge(403, "Forbidden", challenge)
+}
+
+// sendChallenge sends an Error response with a WWW-Authenticate header
+func (c *StandardContext) sendChallenge(code int, message, challenge string) {
+	data, _ := json.Marshal(map[string]any{"code": code, "message": message})
+	w := c.ResponseWriter()
+	w.WriteHeaderLine(code)
+	if challenge != "" {
+		w.WriteHeader("WWW-Authenticate", challenge)
+	}
+	w.WriteHeader("Content-Type", "application/json")
+	w.WriteHeader("Content-Length", strconv.Itoa(len(data)))
+	w.WriteBodyChunk(data)
+}
+
+// parseBearer returns the token of "Bearer <token>"
+func parseBearer(auth string) (string, bool) {
+	const prefix = "Bearer "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(auth[len(prefix):])
+	if token == "" || strings.ContainsAny(token, " \t") {
+		return "", false
+	}
+	return token, true
+}
+
+// bearerChallenge formats a Bearer WWW-Authenticate value
+func bearerChallenge(realm string, invalidToken bool) string {
+	var b strings.Builder
+	b.WriteString("Bearer")
+	sep := " "
+	if realm != "" {
+		b.WriteString(` realm="`)
+		for _, r := range realm {
+			switch {
+			case r < 0x20 || r == 0x7f:
+				// Dropped: CR or LF would cost the whole header
+			case r == '"' || r == '\\':
+				b.WriteByte('\\')
+				b.WriteRune(r)
+			default:
+				b.WriteRune(r)
+			}
+		}
+		b.WriteByte('"')
+		sep = ", "
+	}
+	if invalidToken {
+		b.WriteString(sep)
+		b.WriteString(`error="invalid_token"`)
+	}
+	return b.String()
+}
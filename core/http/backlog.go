@@ -0,0 +1,151 @@
+package http
+
+import (
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/searchktools/fast-server/core/netfd"
+	"github.com/searchktools/fast-server/core/sendfile"
+)
+
+// maxBacklog bounds the unsent bytes kept for a connection; writes past
+// it wait for the socket, up to the write timeout
+const maxBacklog = 4 << 20
+
+// Backlog holds what a connection's socket did not take of its responses:
+// bytes, then the rest of a file sent with sendfile. The engine sends it
+// once the connection is writable, so writes on the event loop do not
+// wait for a slow client (see SetWriteBacklog).
+type Backlog struct {
+	buf []byte
+	off int
+
+	file        *os.File
+	fileRelease func()
+	fileOff     int64
+	fileLeft    int
+}
+
+// Pending reports whether anything is waiting to be sent
+func (b *Backlog) Pending() bool {
+	return b != nil && (b.off < len(b.buf) || b.fileLeft > 0)
+}
+
+// Send writes as much of the backlog as the socket takes. It returns
+// EAGAIN while bytes remain.
+func (b *Backlog) Send(fd int) (int, error) {
+	sent := 0
+	for b.off < len(b.buf) {
+		n, err := netfd.Write(fd, b.buf[b.off:])
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			if err == syscall.EWOULDBLOCK {
+				err = syscall.EAGAIN
+			}
+			return sent, err
+		}
+		b.off += n
+		sent += n
+	}
+	for b.fileLeft > 0 {
+		n, err := sendfile.Send(fd, b.file, b.fileOff, b.fileLeft)
+		b.fileOff += int64(n)
+		b.fileLeft -= n
+		sent += n
+		if err != nil {
+			return sent, err
+		}
+		if b.fileLeft > 0 {
+			// The file shrank since its Content-Length was sent
+			return sent, io.ErrUnexpectedEOF
+		}
+	}
+	b.Reset()
+	return sent, nil
+}
+
+// Reset drops the backlog, keeping a small buffer for reuse, and
+// releases its file
+func (b *Backlog) Reset() {
+	if cap(b.buf) > 64<<10 {
+		b.buf = nil
+	}
+	b.buf = b.buf[:0]
+	b.off = 0
+	if b.fileRelease != nil {
+		b.fileRelease()
+		b.fileRelease = nil
+	}
+	b.file = nil
+	b.fileOff = 0
+	b.fileLeft = 0
+}
+
+// queue appends bufs to the backlog unless that would exceed maxBacklog.
+// Nothing queues behind a file.
+func (b *Backlog) queue(bufs ...[]byte) bool {
+	if b.fileLeft > 0 {
+		return false
+	}
+	n := len(b.buf) - b.off
+	for _, buf := range bufs {
+		n += len(buf)
+	}
+	if n > maxBacklog {
+		return false
+	}
+	for _, buf := range bufs {
+		b.buf = append(b.buf, buf...)
+	}
+	return true
+}
+
+// SetWriteBacklog makes writes the socket would block keep their unsent
+// bytes in b and return, for the engine to send once the connection is
+// writable. Later writes queue behind them. A nil b (or a full backlog)
+// waits for the socket instead, up to the write timeout. Called by the
+// engine.
+func (c *FDContext) SetWriteBacklog(b *Backlog) {
+	c.backlog = b
+}
+
+// deferWrite queues bufs, which the socket did not take, in the backlog
+func (c *FDContext) deferWrite(bufs ...[]byte) bool {
+	if c.backlog == nil || !c.backlog.queue(bufs...) {
+		return false
+	}
+	for _, buf := range bufs {
+		c.bytesSent += int64(len(buf))
+	}
+	return true
+}
+
+// deferFile leaves count bytes of file, from offset, to the backlog,
+// which calls release once they are sent or dropped
+func (c *FDContext) deferFile(file *os.File, release func(), offset int64, count int) bool {
+	if c.backlog == nil || c.backlog.fileLeft > 0 {
+		return false
+	}
+	c.backlog.file = file
+	c.backlog.fileRelease = release
+	c.backlog.fileOff = offset
+	c.backlog.fileLeft = count
+	c.bytesSent += int64(count)
+	return true
+}
+
+// drainBacklog sends the backlog before a write that cannot queue behind
+// it, waiting for the socket up to the write timeout
+func (c *FDContext) drainBacklog() error {
+	var stall writeStall
+	for c.backlog.Pending() {
+		if _, err := c.backlog.Send(c.fd); err != nil && !c.retryWrite(err, &stall) {
+			return c.writeErr
+		}
+	}
+	c.endStall(&stall)
+	return nil
+}
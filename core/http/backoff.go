@@ -0,0 +1,165 @@
+package http
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/searchktools/fast-server/core/clock"
+)
+
+// RetryAfterPlaceholder in a BackoffConfig body is replaced with the
+// seconds the client should wait
+const RetryAfterPlaceholder = "{retry_after}"
+
+// BackoffConfig describes a throttling (429) or overload (503) response
+type BackoffConfig struct {
+	// ContentType of Body. Default: application/json for the default
+	// body, text/plain otherwise.
+	ContentType string
+
+	// Body is sent with RetryAfterPlaceholder replaced. Default:
+	// {"code":429,"message":"Too Many Requests","retry_after":N}, the
+	// shape of Error's body.
+	Body string
+
+	// Headers are added to the response
+	Headers map[string]string
+
+	// RetryAfter is the wait suggested when the caller gives none.
+	// Default: 1s.
+	RetryAfter time.Duration
+}
+
+// Backoff is a throttling or overload response prepared ahead of time.
+// It tells clients when to retry with Retry-After and RateLimit-Reset,
+// in seconds.
+type Backoff struct {
+	code        int
+	contentType string
+	headers     [][2]string // sorted by name
+
+	// body is split around the placeholder (hasWait: it is present)
+	before, after []byte
+	hasWait       bool
+
+	retryAfter time.Duration
+}
+
+// NewBackoff prepares the response with status code described by cfg
+func NewBackoff(code int, cfg BackoffConfig) *Backoff {
+	b := &Backoff{code: code, contentType: cfg.ContentType, retryAfter: cfg.RetryAfter}
+	if b.retryAfter <= 0 {
+		b.retryAfter = time.Second
+	}
+
+	body := cfg.Body
+	if body == "" {
+		body = `{"code":` + strconv.Itoa(code) + `,"message":` + strconv.Quote(statusText(code)) +
+			`,"retry_after":` + RetryAfterPlaceholder + `}`
+		if b.contentType == "" {
+			b.contentType = "application/json"
+		}
+	}
+	if b.contentType == "" {
+		b.contentType = "text/plain; charset=utf-8"
+	}
+	before, after, found := bytes.Cut([]byte(body), []byte(RetryAfterPlaceholder))
+	b.before, b.after, b.hasWait = before, after, found
+
+	for k, v := range cfg.Headers {
+		b.headers = append(b.headers, [2]string{k, v})
+	}
+	sort.Slice(b.headers, func(i, j int) bool { return b.headers[i][0] < b.headers[j][0] })
+	return b
+}
+
+// defaultBackoffs are used for codes the engine has no template for
+var defaultBackoffs = map[int]*Backoff{
+	429: NewBackoff(429, BackoffConfig{}),
+	503: NewBackoff(503, BackoffConfig{}),
+}
+
+// Code returns the status code of the response
+func (b *Backoff) Code() int {
+	return b.code
+}
+
+// seconds returns retryAfter, or the default wait, in whole seconds
+func (b *Backoff) seconds(retryAfter time.Duration) int {
+	if retryAfter <= 0 {
+		retryAfter = b.retryAfter
+	}
+	return int((retryAfter + time.Second - 1) / time.Second)
+}
+
+// appendBody appends the body telling the client to wait secs seconds
+func (b *Backoff) appendBody(dst []byte, secs int) []byte {
+	dst = append(dst, b.before...)
+	if b.hasWait {
+		dst = strconv.AppendInt(dst, int64(secs), 10)
+		dst = append(dst, b.after...)
+	}
+	return dst
+}
+
+// AppendResponse appends the complete response, closing the connection,
+// for connections refused before a request is read
+func (b *Backoff) AppendResponse(dst []byte, retryAfter time.Duration) []byte {
+	secs := b.seconds(retryAfter)
+	var bodyBuf [256]byte
+	body := b.appendBody(bodyBuf[:0], secs)
+
+	dst = append(dst, "HTTP/1.1 "...)
+	dst = appendInt(dst, b.code)
+	dst = append(dst, ' ')
+	dst = append(dst, statusText(b.code)...)
+	dst = append(dst, "\r\nDate: "...)
+	dst = clock.AppendDate(dst)
+	dst = append(dst, "\r\nRetry-After: "...)
+	dst = appendInt(dst, secs)
+	dst = append(dst, "\r\nRateLimit-Reset: "...)
+	dst = appendInt(dst, secs)
+	dst = append(dst, "\r\n"...)
+	for _, h := range b.headers {
+		dst = appendHeader(dst, h[0], h[1])
+	}
+	dst = appendHeader(dst, "Content-Type", b.contentType)
+	dst = append(dst, "Content-Length: "...)
+	dst = appendInt(dst, len(body))
+	dst = append(dst, "\r\nConnection: close\r\n\r\n"...)
+	return append(dst, body...)
+}
+
+// SetBackoffs sets the engine's throttling and overload responses by
+// status code (see Backoff)
+func (c *FDContext) SetBackoffs(backoffs map[int]*Backoff) {
+	c.backoffs = backoffs
+}
+
+// Backoff sends the engine's response for code, 429 or 503 unless the
+// engine has templates for others, asking the client to retry after
+// retryAfter (0: the template's default). Headers set on the context,
+// e.g. CORS headers, are kept.
+func (c *FDContext) Backoff(code int, retryAfter time.Duration) error {
+	b := c.backoffs[code]
+	if b == nil {
+		if b = defaultBackoffs[code]; b == nil {
+			b = NewBackoff(code, BackoffConfig{})
+		}
+	}
+
+	secs := b.seconds(retryAfter)
+	wait := strconv.Itoa(secs)
+	c.SetHeader("Retry-After", wait)
+	c.SetHeader("RateLimit-Reset", wait)
+	for _, h := range b.headers {
+		c.SetHeader(h[0], h[1])
+	}
+
+	var bodyBuf [256]byte
+	body := b.appendBody(bodyBuf[:0], secs)
+	c.startResponse(b.code, b.contentType, len(body))
+	return c.writeBody(body)
+}
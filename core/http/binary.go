@@ -0,0 +1,84 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"sync"
+
+	"github.com/searchktools/fast-server/core/msgpack"
+)
+
+var (
+	// ErrNotProtoMessage is returned by Bind for protobuf bodies when the
+	// target is not a proto.Message
+	ErrNotProtoMessage = errors.New("bind: protobuf body needs a proto.Message target")
+
+	// ErrProtobufDisabled is returned by Bind for protobuf bodies in
+	// builds with the fastserver_noprotobuf tag
+	ErrProtobufDisabled = errors.New("bind: protobuf support not built in (fastserver_noprotobuf)")
+)
+
+// binaryBufPool holds encode buffers of Msgpack and Protobuf
+var binaryBufPool = sync.Pool{New: func() any { return new([]byte) }}
+
+// maxPooledBuffer is the largest encode buffer kept for reuse
+const maxPooledBuffer = 64 << 10
+
+// Msgpack sends v encoded as MessagePack
+func (c *FDContext) Msgpack(code int, v any) {
+	buf := binaryBufPool.Get().(*[]byte)
+	data, err := msgpack.Append((*buf)[:0], v)
+	if err != nil {
+		binaryBufPool.Put(buf)
+		c.Error(500, "Failed to marshal MessagePack")
+		return
+	}
+
+	c.respond(code, c.implicitType("application/msgpack"), data)
+	putBinaryBuf(buf, data)
+}
+
+// putBinaryBuf returns an encode buffer, grown to data, to the pool
+func putBinaryBuf(buf *[]byte, data []byte) {
+	if cap(data) <= maxPooledBuffer {
+		*buf = data[:0]
+		binaryBufPool.Put(buf)
+	}
+}
+
+// BindMsgpack decodes a MessagePack request body into v
+func (c *FDContext) BindMsgpack(v any) error {
+	body, err := c.bodyBytes()
+	if err != nil {
+		return err
+	}
+	return msgpack.Unmarshal(body, v)
+}
+
+// bodyBytes returns the request body, reading it back if it was spilled
+// to a BodyStore
+func (c *FDContext) bodyBytes() ([]byte, error) {
+	if c.request.Spool == nil {
+		return c.request.Body, nil
+	}
+	body, err := c.BodyReader()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// bindBinary decodes MessagePack and protobuf bodies for Bind; ok is
+// false for other content types
+func (c *FDContext) bindBinary(v any) (ok bool, err error) {
+	mediaType, _, _ := mime.ParseMediaType(c.request.ContentType)
+	switch mediaType {
+	case "application/msgpack", "application/x-msgpack", "application/vnd.msgpack":
+		return true, c.BindMsgpack(v)
+	case "application/protobuf", "application/x-protobuf", "application/vnd.google.protobuf":
+		return true, c.bindProtobuf(v)
+	}
+	return false, nil
+}
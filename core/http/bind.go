@@ -0,0 +1,62 @@
+package http
+
+import (
+	"net/textproto"
+	"net/url"
+)
+
+var (
+	queryBinding  = &binding{tags: []string{"query", "form", "json"}, label: "query parameter", split: true}
+	headerBinding = &binding{tags: []string{"header"}, label: "header", split: true, fold: true}
+)
+
+// BindQuery sets the fields of the struct v points to from the query
+// string. A field is filled from the parameter named by its query tag,
+// else its form or json tag, else its name; values are converted as by
+// BindForm. Slice fields take a comma-separated list (?ids=1,2,3).
+func (c *FDContext) BindQuery(v any) error {
+	values := make(url.Values, c.request.QueryLen())
+	for k, val := range c.request.QueryParams() {
+		if unescaped, err := url.QueryUnescape(k); err == nil {
+			k = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(val); err == nil {
+			val = unescaped
+		}
+		values[k] = []string{val}
+	}
+	return bindValues(values, v, queryBinding)
+}
+
+// BindHeader sets the fields of the struct v points to from the request
+// headers. A field is filled from the header named by its header tag
+// (matched case-insensitively), else its name; values are converted as
+// by BindForm. Slice fields take a comma-separated list.
+//
+//	var h struct {
+//		Tenant  string        `header:"X-Tenant"`
+//		Timeout time.Duration `header:"X-Timeout"`
+//	}
+func (c *FDContext) BindHeader(v any) error {
+	r := c.request
+	values := make(url.Values, 6+len(r.ExtraHeaders))
+	for k, val := range map[string]string{
+		"Content-Type":   r.ContentType,
+		"Content-Length": r.ContentLength,
+		"User-Agent":     r.UserAgent,
+		"Accept":         r.Accept,
+		"Host":           r.Host,
+		"Connection":     r.Connection,
+	} {
+		if val != "" {
+			values[k] = []string{val}
+		}
+	}
+	for k, val := range r.ExtraHeaders {
+		values[textproto.CanonicalMIMEHeaderKey(k)] = []string{val}
+	}
+	for k, vals := range r.Repeated {
+		values[k] = vals
+	}
+	return bindValues(values, v, headerBinding)
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// BodyStore holds request bodies too large to keep in memory, e.g. on
+// disk or in an object store
+type BodyStore interface {
+	// Create starts storing a body of size bytes (-1: unknown, for a
+	// chunked body)
+	Create(size int64) (SpooledBody, error)
+}
+
+// SpooledBody is a request body being written to, then read from, a
+// BodyStore
+type SpooledBody interface {
+	io.Writer
+
+	// Open returns a reader over the stored body; each call starts over
+	Open() (io.ReadCloser, error)
+
+	// Remove releases the stored body
+	Remove() error
+}
+
+// DiskStore spills request bodies to temporary files
+type DiskStore struct {
+	Dir string // Directory for the files; "" uses os.TempDir()
+}
+
+// NewDiskStore creates a DiskStore writing to dir
+func NewDiskStore(dir string) *DiskStore {
+	return &DiskStore{Dir: dir}
+}
+
+// Create implements BodyStore
+func (s *DiskStore) Create(size int64) (SpooledBody, error) {
+	f, err := os.CreateTemp(s.Dir, "fast-server-body-*")
+	if err != nil {
+		return nil, err
+	}
+	return &diskBody{file: f}, nil
+}
+
+type diskBody struct {
+	file *os.File
+}
+
+func (b *diskBody) Write(p []byte) (int, error) {
+	return b.file.Write(p)
+}
+
+func (b *diskBody) Open() (io.ReadCloser, error) {
+	return os.Open(b.file.Name())
+}
+
+func (b *diskBody) Remove() error {
+	b.file.Close()
+	return os.Remove(b.file.Name())
+}
+
+// BodyReader returns a reader over the request body, including a body
+// spilled to a BodyStore
+func (c *FDContext) BodyReader() (io.ReadCloser, error) {
+	if c.request.Spool != nil {
+		return c.request.Spool.Open()
+	}
+	return io.NopCloser(bytes.NewReader(c.request.Body)), nil
+}
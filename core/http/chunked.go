@@ -0,0 +1,233 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// maxChunkSizeDigits bounds a chunk-size line so the size cannot overflow
+const maxChunkSizeDigits = 15
+
+// decodeChunked frames the chunked body (RFC 7230 4.1) at the start of
+// data: the chunks, the terminal chunk and the trailer section. It
+// returns the encoded length, the trailer fields (without the final empty
+// line) and, if decode is set, appends the decoded body to dst. Chunk
+// extensions are ignored. It returns ErrIncomplete while
+// the body has not fully arrived and ErrInvalidRequest if it is malformed.
+// The engine's framing and the parser both use it, so they cannot
+// disagree about where the body ends.
+func decodeChunked(dst, data []byte, decode bool) (body, trailer []byte, n int, err error) {
+	body = dst
+	for {
+		line, rest, ok := cutLine(data[n:])
+		if !ok {
+			return body, nil, 0, ErrIncomplete
+		}
+		if i := bytes.IndexByte(line, ';'); i >= 0 {
+			line = line[:i] // Extensions
+		}
+		size, ok := parseChunkSize(bytes.TrimRight(line, " \t"))
+		if !ok {
+			return body, nil, 0, ErrInvalidRequest
+		}
+		n = len(data) - len(rest)
+
+		if size == 0 {
+			break
+		}
+		if len(rest) < size {
+			return body, nil, 0, ErrIncomplete
+		}
+		if decode {
+			body = append(body, rest[:size]...)
+		}
+		n += size
+
+		// CRLF after the chunk data
+		end, rest, ok := cutLine(data[n:])
+		if !ok {
+			if len(data[n:]) < 2 {
+				return body, nil, 0, ErrIncomplete
+			}
+			return body, nil, 0, ErrInvalidRequest
+		}
+		if len(end) != 0 {
+			return body, nil, 0, ErrInvalidRequest
+		}
+		n = len(data) - len(rest)
+	}
+
+	// Trailer section, ended by an empty line
+	start := n
+	for {
+		line, rest, ok := cutLine(data[n:])
+		if !ok {
+			return body, nil, 0, ErrIncomplete
+		}
+		if len(line) == 0 {
+			return body, data[start:n], len(data) - len(rest), nil
+		}
+		n = len(data) - len(rest)
+	}
+}
+
+// maxTrailerBytes bounds the trailer section a ChunkedDecoder keeps
+const maxTrailerBytes = 64 << 10
+
+// ChunkedDecoder decodes a chunked body arriving over several reads, so
+// each read only decodes the new bytes. It frames the body as
+// decodeChunked does.
+type ChunkedDecoder struct {
+	state   chunkState
+	left    int // Data bytes of the current chunk still to come
+	size    int
+	trailer []byte
+}
+
+type chunkState uint8
+
+const (
+	chunkSize chunkState = iota
+	chunkData
+	chunkEnd // The CRLF after a chunk's data
+	chunkTrailer
+	chunkDone
+)
+
+// Decode decodes the chunked body at the start of data, which follows
+// what earlier calls consumed, writing the decoded bytes to w (nil:
+// framing only). It returns how many bytes it consumed; a line cut short
+// is left for the next call. It returns ErrInvalidRequest if the body is
+// malformed or its trailer too large, and w's errors.
+func (d *ChunkedDecoder) Decode(data []byte, w io.Writer) (int, error) {
+	n := 0
+	for d.state != chunkDone && n < len(data) {
+		switch d.state {
+		case chunkSize:
+			line, rest, ok := cutLine(data[n:])
+			if !ok {
+				return n, nil
+			}
+			if i := bytes.IndexByte(line, ';'); i >= 0 {
+				line = line[:i] // Extensions
+			}
+			size, ok := parseChunkSize(bytes.TrimRight(line, " \t"))
+			if !ok {
+				return n, ErrInvalidRequest
+			}
+			n = len(data) - len(rest)
+			d.left = size
+			d.state = chunkData
+			if size == 0 {
+				d.state = chunkTrailer
+			}
+		case chunkData:
+			k := min(d.left, len(data)-n)
+			if w != nil {
+				if _, err := w.Write(data[n : n+k]); err != nil {
+					return n, err
+				}
+			}
+			n += k
+			d.left -= k
+			d.size += k
+			if d.left == 0 {
+				d.state = chunkEnd
+			}
+		case chunkEnd:
+			line, rest, ok := cutLine(data[n:])
+			if !ok {
+				if len(data[n:]) < 2 {
+					return n, nil
+				}
+				return n, ErrInvalidRequest
+			}
+			if len(line) != 0 {
+				return n, ErrInvalidRequest
+			}
+			n = len(data) - len(rest)
+			d.state = chunkSize
+		case chunkTrailer:
+			line, rest, ok := cutLine(data[n:])
+			if !ok {
+				return n, nil
+			}
+			n = len(data) - len(rest)
+			if len(line) == 0 {
+				d.state = chunkDone
+				break
+			}
+			if len(d.trailer)+len(line) >= maxTrailerBytes {
+				return n, ErrInvalidRequest
+			}
+			d.trailer = append(append(d.trailer, line...), '\n')
+		}
+	}
+	return n, nil
+}
+
+// Done reports whether the whole body, trailer included, was decoded
+func (d *ChunkedDecoder) Done() bool {
+	return d.state == chunkDone
+}
+
+// Size returns the number of body bytes decoded so far
+func (d *ChunkedDecoder) Size() int {
+	return d.size
+}
+
+// SetTrailers fills req.Trailers from the decoded trailer fields
+func (d *ChunkedDecoder) SetTrailers(req *Request) {
+	parseTrailer(req, d.trailer)
+}
+
+// Reset prepares the decoder for another body
+func (d *ChunkedDecoder) Reset() {
+	*d = ChunkedDecoder{trailer: d.trailer[:0]}
+}
+
+// cutLine splits data after its first line, which ends with CRLF or LF
+func cutLine(data []byte) (line, rest []byte, ok bool) {
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		return nil, data, false
+	}
+	line = data[:i]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, data[i+1:], true
+}
+
+// parseChunkSize parses a hexadecimal chunk size
+func parseChunkSize(b []byte) (int, bool) {
+	if len(b) == 0 || len(b) > maxChunkSizeDigits {
+		return 0, false
+	}
+	n := 0
+	for _, ch := range b {
+		var d byte
+		switch {
+		case '0' <= ch && ch <= '9':
+			d = ch - '0'
+		case 'a' <= ch && ch <= 'f':
+			d = ch - 'a' + 10
+		case 'A' <= ch && ch <= 'F':
+			d = ch - 'A' + 10
+		default:
+			return 0, false
+		}
+		n = n<<4 | int(d)
+	}
+	return n, true
+}
+
+// isChunked reports whether a Transfer-Encoding value ends with chunked,
+// the only framing of a request body it allows
+func isChunked(te string) bool {
+	if i := strings.LastIndexByte(te, ','); i >= 0 {
+		te = te[i+1:]
+	}
+	return strings.EqualFold(strings.TrimSpace(te), "chunked")
+}
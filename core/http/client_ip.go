@@ -0,0 +1,123 @@
+package http
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"syscall"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// TrustedProxies are the networks of the reverse proxies and load
+// balancers whose X-Forwarded-For and X-Real-IP headers ClientIP believes
+type TrustedProxies struct {
+	prefixes []netip.Prefix
+}
+
+// NewTrustedProxies parses CIDRs ("10.0.0.0/8") and single addresses
+func NewTrustedProxies(cidrs ...string) (*TrustedProxies, error) {
+	t := &TrustedProxies{}
+	for _, s := range cidrs {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", s, err)
+			}
+			t.prefixes = append(t.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", s, err)
+		}
+		t.prefixes = append(t.prefixes, prefix.Masked())
+	}
+	return t, nil
+}
+
+// Contains reports whether addr is a trusted proxy
+func (t *TrustedProxies) Contains(addr netip.Addr) bool {
+	if t == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range t.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTrustedProxies sets the proxies whose forwarding headers ClientIP
+// believes (nil: none)
+func (c *FDContext) SetTrustedProxies(t *TrustedProxies) {
+	c.trustedProxies = t
+}
+
+// ClientIP returns the client's IP address. If the connection comes from
+// a trusted proxy, it is taken from X-Forwarded-For, skipping trusted
+// proxies from the right since clients can prepend any address, or else
+// from X-Real-IP. Otherwise, or without valid headers, it is the peer's
+// address. It returns "" if the peer is unknown (e.g. a Unix socket).
+func (c *FDContext) ClientIP() string {
+	peer, ok := c.peerAddr()
+	if !ok {
+		return ""
+	}
+	if !c.trustedProxies.Contains(peer) {
+		return peer.String()
+	}
+
+	if xff := c.Header("X-Forwarded-For"); xff != "" {
+		client := peer
+		for xff != "" {
+			hop := xff
+			if i := strings.LastIndexByte(xff, ','); i >= 0 {
+				hop, xff = xff[i+1:], xff[:i]
+			} else {
+				xff = ""
+			}
+			addr, err := netip.ParseAddr(strings.TrimSpace(hop))
+			if err != nil {
+				break
+			}
+			client = addr.Unmap()
+			if !c.trustedProxies.Contains(client) {
+				break
+			}
+		}
+		if client != peer {
+			return client.String()
+		}
+	}
+	if realIP := c.Header("X-Real-IP"); realIP != "" {
+		if addr, err := netip.ParseAddr(strings.TrimSpace(realIP)); err == nil {
+			return addr.Unmap().String()
+		}
+	}
+	return peer.String()
+}
+
+// peerAddr returns the peer's IP address
+func (c *FDContext) peerAddr() (netip.Addr, bool) {
+	sa := c.peer
+	if sa == nil {
+		var err error
+		if sa, err = netfd.Getpeername(c.fd); err != nil {
+			return netip.Addr{}, false
+		}
+	}
+	switch sa := sa.(type) {
+	case *syscall.SockaddrInet4:
+		return netip.AddrFrom4(sa.Addr), true
+	case *syscall.SockaddrInet6:
+		return netip.AddrFrom16(sa.Addr).Unmap(), true
+	}
+	return netip.Addr{}, false
+}
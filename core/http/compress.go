@@ -0,0 +1,206 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressMinBytes is the smallest body worth compressing by default
+const compressMinBytes = 1024
+
+// Compressor is a reusable streaming encoder, e.g. *gzip.Writer. Reset
+// starts a new stream to w; Close ends it.
+type Compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// Compression configures response compression (see SetCompression)
+type Compression struct {
+	// Encodings are the content codings to use, in order of preference
+	// when the client ranks them equally. Default: br and zstd if
+	// registered, then gzip and deflate.
+	Encodings []string
+
+	// MinBytes is the smallest body compressed. Default: 1KB.
+	MinBytes int
+}
+
+// defaultCompression is used by routes with the Compress option
+var defaultCompression = &Compression{}
+
+// defaultEncodings is the preference order when Encodings is empty
+var defaultEncodings = []string{"br", "zstd", "gzip", "deflate"}
+
+// compressors holds a pool of encoders per content coding
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]*sync.Pool{}
+)
+
+func init() {
+	RegisterCompressor("gzip", func() Compressor { return gzip.NewWriter(nil) })
+	RegisterCompressor("deflate", func() Compressor { return zlib.NewWriter(nil) })
+}
+
+// RegisterCompressor adds or replaces the encoder of a content coding,
+// e.g. "br" with a Brotli package:
+//
+//	http.RegisterCompressor("br", func() http.Compressor {
+//		return brotli.NewWriterLevel(nil, 5)
+//	})
+//
+// Encoders are pooled and reused. Register them before serving.
+func RegisterCompressor(encoding string, newCompressor func() Compressor) {
+	compressorsMu.Lock()
+	compressors[encoding] = &sync.Pool{New: func() any { return newCompressor() }}
+	compressorsMu.Unlock()
+}
+
+// SetCompression compresses this request's complete responses (String,
+// JSON, Data, Bytes, Writer bodies that fit its buffer) with the coding
+// the client prefers among cfg's. nil leaves it to the route's Compress
+// option. Streamed responses are not compressed.
+func (c *FDContext) SetCompression(cfg *Compression) {
+	c.compression = cfg
+}
+
+// compressionConfig returns the compression settings of this response
+func (c *FDContext) compressionConfig() *Compression {
+	if c.compression != nil {
+		return c.compression
+	}
+	if c.defaults != nil && c.defaults.Compress {
+		return defaultCompression
+	}
+	return nil
+}
+
+var compressBufPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// compressBody compresses body if this response should be and returns
+// the body to send; release returns the buffer once it is sent
+func (c *FDContext) compressBody(contentType string, body []byte) (out []byte, release func()) {
+	cfg := c.compressionConfig()
+	if cfg == nil {
+		return body, nil
+	}
+	c.SetHeader("Vary", "Accept-Encoding")
+
+	minBytes := cfg.MinBytes
+	if minBytes <= 0 {
+		minBytes = compressMinBytes
+	}
+	if len(body) < minBytes || c.responseHeaders["Content-Encoding"] != "" || !compressible(contentType) {
+		return body, nil
+	}
+	encoding, pool := cfg.negotiate(c.Header("Accept-Encoding"))
+	if pool == nil {
+		return body, nil
+	}
+
+	buf := compressBufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	zw := pool.Get().(Compressor)
+	zw.Reset(buf)
+	_, err := zw.Write(body)
+	if err == nil {
+		err = zw.Close()
+	}
+	pool.Put(zw)
+	if err != nil || buf.Len() >= len(body) {
+		compressBufPool.Put(buf)
+		return body, nil
+	}
+	c.SetHeader("Content-Encoding", encoding)
+	if etag := c.responseHeaders["Etag"]; etag != "" {
+		c.responseHeaders["Etag"] = encodedETag(etag, encoding)
+	}
+	return buf.Bytes(), func() { compressBufPool.Put(buf) }
+}
+
+// negotiate picks the coding the client ranks highest by q-value among
+// the configured ones, preferring earlier ones on ties
+func (cfg *Compression) negotiate(accept string) (string, *sync.Pool) {
+	if accept == "" {
+		return "", nil
+	}
+	encodings := cfg.Encodings
+	if len(encodings) == 0 {
+		encodings = defaultEncodings
+	}
+
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	var best string
+	var bestPool *sync.Pool
+	bestQ := 0.0
+	for _, enc := range encodings {
+		pool := compressors[enc]
+		if pool == nil {
+			continue
+		}
+		if q := acceptQ(accept, enc); q > bestQ {
+			best, bestPool, bestQ = enc, pool, q
+		}
+	}
+	return best, bestPool
+}
+
+// acceptQ returns the q-value Accept-Encoding gives encoding, directly or
+// through "*" (0: not acceptable)
+func acceptQ(accept, encoding string) float64 {
+	wildcard := -1.0
+	for accept != "" {
+		var part string
+		part, accept, _ = strings.Cut(accept, ",")
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(name)
+
+		q := 1.0
+		for params != "" {
+			var param string
+			param, params, _ = strings.Cut(params, ";")
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(strings.TrimSpace(k), "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		if strings.EqualFold(name, encoding) {
+			return q
+		}
+		if name == "*" {
+			wildcard = q
+		}
+	}
+	return max(wildcard, 0)
+}
+
+// compressible reports whether a body of contentType is worth
+// compressing: media that is compressed already is not
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	switch {
+	case mediaType == "image/svg+xml":
+		return true
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"):
+		return false
+	}
+	switch mediaType {
+	case "application/gzip", "application/zip", "application/zstd",
+		"application/x-7z-compressed", "application/x-bzip2", "font/woff2":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package http
+
+import "time"
+
+// SetKeepAlive sets whether the connection stays open for another request
+// after this response. Disabling it sends Connection: close.
+func (c *FDContext) SetKeepAlive(enabled bool) {
+	c.noKeepAlive = !enabled
+}
+
+// KeepAlive reports whether the connection stays open after this response
+func (c *FDContext) KeepAlive() bool {
+	return !c.noKeepAlive
+}
+
+// SetIdleTimeout sets how long the connection may wait for its next
+// request after this response, overriding the engine's idle timeout.
+// Zero keeps the engine's.
+func (c *FDContext) SetIdleTimeout(d time.Duration) {
+	c.idleTimeout = d
+}
+
+// IdleTimeout returns the idle timeout set by SetIdleTimeout (0: unset)
+func (c *FDContext) IdleTimeout() time.Duration {
+	return c.idleTimeout
+}
+
+// SetTenant labels the connection with the tenant it serves, e.g. from an
+// API key, so operators can list and drain a tenant's connections (see
+// Engine.Drain). The label stays on the connection for later requests.
+func (c *FDContext) SetTenant(tenant string) {
+	c.tenant = tenant
+}
+
+// Tenant returns the tenant set by SetTenant for this request
+func (c *FDContext) Tenant() string {
+	return c.tenant
+}
@@ -1,12 +1,15 @@
 package http
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"net"
 	"os"
-	"path/filepath"
 	"sync"
-	"syscall"
+
+	"github.com/searchktools/fast-server/core/netfd"
+	"github.com/searchktools/fast-server/core/sendfile"
 )
 
 // Context defines the HTTP request context interface
@@ -17,23 +20,54 @@ type Context interface {
 	Param(key string) string
 	Query(key string) string
 	Header(key string) string
+	BasicAuth() (username, password string, ok bool)
+	BearerToken() (string, bool)
 	Body() []byte
 	SetParam(key, value string)
 
+	// Typed accessors (see FDContext.QueryInt)
+	ParamInt(key string) (int, error)
+	ParamInt64(key string) (int64, error)
+	QueryInt(key string, def int) int
+	QueryInt64(key string, def int64) int64
+	QueryBool(key string, def bool) bool
+
 	// Response methods
 	String(code int, s string)
 	JSON(code int, v any)
 	Bytes(code int, data []byte)
 	Data(code int, contentType string, data []byte)
+	NoContent(code int) error
 	Error(code int, message string)
+	Unauthorized(realm string)
+	Forbidden()
 	Success(data any)
 	ServeFile(filePath string) error
+	// ResponseWriter writes the status, headers and body piecewise
+	ResponseWriter() ResponseWriter
 
 	// Binding
 	Bind(v any) error
 
 	// Connection access
 	Conn() net.Conn
+	RemoteAddr() net.Addr
+	LocalAddr() net.Addr
+
+	// Protocol and TLS state (see FDContext.Scheme)
+	Proto() string
+	IsTLS() bool
+	TLSState() *tls.ConnectionState
+	Scheme() string
+
+	// Context is canceled when the client disconnects or the request ends
+	Context() context.Context
+
+	// Async detaches the request for a deferred response
+	Async() *AsyncHandle
+
+	// Detach runs fn off the event loop and sends its response when it returns
+	Detach(fn func(ctx Context))
 }
 
 // StandardContext is the standard context implementation
@@ -51,6 +85,12 @@ type StandardContext struct {
 
 	// Pre-allocated response buffer
 	responseBuf []byte
+
+	// Pending deferred response (nil unless Async was called)
+	async *AsyncHandle
+
+	// Request context (nil: context.Background())
+	ctx context.Context
 }
 
 var contextPool = sync.Pool{
@@ -83,6 +123,8 @@ func ReleaseContext(ctx Context) {
 	if stdCtx, ok := ctx.(*StandardContext); ok {
 		stdCtx.request = nil
 		stdCtx.conn = nil
+		stdCtx.async = nil
+		stdCtx.ctx = nil
 		stdCtx.paramCount = 0
 		if stdCtx.paramMapOverflow != nil {
 			for k := range stdCtx.paramMapOverflow {
@@ -139,35 +181,59 @@ func (c *StandardContext) Conn() net.Conn {
 	return c.conn
 }
 
+// RemoteAddr returns the client's address
+func (c *StandardContext) RemoteAddr() net.Addr {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.RemoteAddr()
+}
+
+// LocalAddr returns the address the client connected to
+func (c *StandardContext) LocalAddr() net.Addr {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.LocalAddr()
+}
+
+// Async detaches the request for a deferred response.
+// The caller must not release the context until the handle completes.
+func (c *StandardContext) Async() *AsyncHandle {
+	if c.async == nil {
+		c.async = newAsyncHandle(c)
+	}
+	return c.async
+}
+
+// Context returns the request's context: the one set with SetContext, or
+// context.Background()
+func (c *StandardContext) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// SetContext sets the context returned by Context, e.g. from net/http
+func (c *StandardContext) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+// Detach runs fn inline: a StandardContext already has its own goroutine
+func (c *StandardContext) Detach(fn func(ctx Context)) {
+	fn(c)
+}
+
 // Query gets a query parameter
 func (c *StandardContext) Query(key string) string {
-	if c.request.Query == nil {
-		return ""
-	}
-	return c.request.Query[key]
+	return c.request.QueryValue(key)
 }
 
-// Header gets a request header (prioritizes predefined fields)
+// Header returns the first value of a request header, matching the key
+// case-insensitively
 func (c *StandardContext) Header(key string) string {
-	switch key {
-	case "Content-Type":
-		return c.request.ContentType
-	case "Content-Length":
-		return c.request.ContentLength
-	case "User-Agent":
-		return c.request.UserAgent
-	case "Accept":
-		return c.request.Accept
-	case "Host":
-		return c.request.Host
-	case "Connection":
-		return c.request.Connection
-	default:
-		if c.request.ExtraHeaders != nil {
-			return c.request.ExtraHeaders[key]
-		}
-		return ""
-	}
+	return c.request.Header(key)
 }
 
 // Body returns the request body
@@ -175,6 +241,11 @@ func (c *StandardContext) Body() []byte {
 	return c.request.Body
 }
 
+// isHead reports whether the response must omit its body
+func (c *StandardContext) isHead() bool {
+	return c.request != nil && c.request.Method == "HEAD"
+}
+
 // Bind binds JSON to a struct
 func (c *StandardContext) Bind(v any) error {
 	return json.Unmarshal(c.request.Body, v)
@@ -191,11 +262,30 @@ func (c *StandardContext) String(code int, s string) {
 	c.responseBuf = append(c.responseBuf, "\r\nContent-Type: text/plain\r\nContent-Length: "...)
 	c.responseBuf = appendInt(c.responseBuf, len(s))
 	c.responseBuf = append(c.responseBuf, "\r\n\r\n"...)
-	c.responseBuf = append(c.responseBuf, s...)
+	if !c.isHead() {
+		c.responseBuf = append(c.responseBuf, s...)
+	}
 
 	c.conn.Write(c.responseBuf)
 }
 
+// NoContent sends a response without a body
+func (c *StandardContext) NoContent(code int) error {
+	c.responseBuf = c.responseBuf[:0]
+
+	c.responseBuf = append(c.responseBuf, "HTTP/1.1 "...)
+	c.responseBuf = appendInt(c.responseBuf, code)
+	c.responseBuf = append(c.responseBuf, ' ')
+	c.responseBuf = append(c.responseBuf, statusText(code)...)
+	if code >= 200 && code != 204 && code != 304 {
+		c.responseBuf = append(c.responseBuf, "\r\nContent-Length: 0"...)
+	}
+	c.responseBuf = append(c.responseBuf, "\r\n\r\n"...)
+
+	_, err := c.conn.Write(c.responseBuf)
+	return err
+}
+
 // JSON sends a JSON response
 func (c *StandardContext) JSON(code int, v any) {
 	data, err := json.Marshal(v)
@@ -213,7 +303,9 @@ func (c *StandardContext) JSON(code int, v any) {
 	c.responseBuf = append(c.responseBuf, "\r\nContent-Type: application/json\r\nContent-Length: "...)
 	c.responseBuf = appendInt(c.responseBuf, len(data))
 	c.responseBuf = append(c.responseBuf, "\r\n\r\n"...)
-	c.responseBuf = append(c.responseBuf, data...)
+	if !c.isHead() {
+		c.responseBuf = append(c.responseBuf, data...)
+	}
 
 	c.conn.Write(c.responseBuf)
 }
@@ -229,7 +321,9 @@ func (c *StandardContext) Bytes(code int, data []byte) {
 	c.responseBuf = append(c.responseBuf, "\r\nContent-Type: application/octet-stream\r\nContent-Length: "...)
 	c.responseBuf = appendInt(c.responseBuf, len(data))
 	c.responseBuf = append(c.responseBuf, "\r\n\r\n"...)
-	c.responseBuf = append(c.responseBuf, data...)
+	if !c.isHead() {
+		c.responseBuf = append(c.responseBuf, data...)
+	}
 
 	c.conn.Write(c.responseBuf)
 }
@@ -247,7 +341,9 @@ func (c *StandardContext) Data(code int, contentType string, data []byte) {
 	c.responseBuf = append(c.responseBuf, "\r\nContent-Length: "...)
 	c.responseBuf = appendInt(c.responseBuf, len(data))
 	c.responseBuf = append(c.responseBuf, "\r\n\r\n"...)
-	c.responseBuf = append(c.responseBuf, data...)
+	if !c.isHead() {
+		c.responseBuf = append(c.responseBuf, data...)
+	}
 
 	c.conn.Write(c.responseBuf)
 }
@@ -291,7 +387,7 @@ func (c *StandardContext) ServeFile(filePath string) error {
 	size := stat.Size()
 
 	// Get content type
-	contentType := getContentType(filePath)
+	contentType := sendfile.GetContentType(filePath)
 
 	// Write response headers
 	c.responseBuf = c.responseBuf[:0]
@@ -303,6 +399,9 @@ func (c *StandardContext) ServeFile(filePath string) error {
 
 	// Write headers first
 	c.conn.Write(c.responseBuf)
+	if c.isHead() {
+		return nil
+	}
 
 	// Use sendfile for zero-copy file transfer
 	// Get raw file descriptor from connection
@@ -311,11 +410,10 @@ func (c *StandardContext) ServeFile(filePath string) error {
 		if err == nil {
 			defer connFile.Close()
 			connFd := int(connFile.Fd())
-			fileFd := int(file.Fd())
 
 			// Zero-copy sendfile
 			offset := int64(0)
-			_, err := sendfileImpl(connFd, fileFd, &offset, int(size))
+			_, err := netfd.Sendfile(connFd, file, &offset, int(size))
 			return err
 		}
 	}
@@ -331,36 +429,6 @@ func getFileInfo(path string) (*os.File, error) {
 	return os.Open(path)
 }
 
-func getContentType(filename string) string {
-	ext := filepath.Ext(filename)
-	switch ext {
-	case ".html", ".htm":
-		return "text/html"
-	case ".css":
-		return "text/css"
-	case ".js":
-		return "application/javascript"
-	case ".json":
-		return "application/json"
-	case ".png":
-		return "image/png"
-	case ".jpg", ".jpeg":
-		return "image/jpeg"
-	case ".gif":
-		return "image/gif"
-	case ".svg":
-		return "image/svg+xml"
-	case ".txt":
-		return "text/plain"
-	default:
-		return "application/octet-stream"
-	}
-}
-
-func sendfileImpl(outFd, inFd int, offset *int64, count int) (int, error) {
-	return syscall.Sendfile(outFd, inFd, offset, count)
-}
-
 func copyFileData(src *os.File, dst net.Conn, buffer []byte) (int64, error) {
 	return 0, nil // Simplified - would use io.CopyBuffer
 }
@@ -399,20 +467,10 @@ func appendInt(b []byte, i int) []byte {
 	return b
 }
 
-// statusText returns the HTTP status text for the given code
-func statusText(code int) string {
-	switch code {
-	case 200:
-		return "OK"
-	case 201:
-		return "Created"
-	case 400:
-		return "Bad Request"
-	case 404:
-		return "Not Found"
-	case 500:
-		return "Internal Server Error"
-	default:
-		return "Unknown"
-	}
+// appendHeader appends a "Key: value" header line to a byte slice
+func appendHeader(b []byte, key, value string) []byte {
+	b = append(b, key...)
+	b = append(b, ": "...)
+	b = append(b, value...)
+	return append(b, "\r\n"...)
 }
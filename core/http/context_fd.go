@@ -1,18 +1,37 @@
 package http
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime/multipart"
 	"net"
+	nethttp "net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
+
+	"github.com/searchktools/fast-server/core/clock"
+	"github.com/searchktools/fast-server/core/netfd"
+	"github.com/searchktools/fast-server/core/sendfile"
 )
 
+// ErrWriteTimeout is returned when a response write stalls past the write timeout
+var ErrWriteTimeout = errors.New("response write timed out")
+
 // FDContext is a file-descriptor based context for epoll/kqueue
 type FDContext struct {
-	// File descriptor for syscall.Write
+	// File descriptor for netfd.Write
 	fd int
 
-	// Request
-	request *Request
+	// Request, copied out of the pooled buffers once retained (see Retain)
+	request  *Request
+	retained bool
 
 	// Parameters (fixed array for performance)
 	paramKeys        [4]string
@@ -25,8 +44,126 @@ type FDContext struct {
 
 	// Response state
 	responseHeaders map[string]string
+	responseCookies []string
 	statusCode      int
 	aborted         bool
+	written         bool
+
+	// Pending deferred response (nil unless Async was called), also
+	// published for the deadline timer
+	async    *AsyncHandle
+	asyncRef atomic.Pointer[AsyncHandle]
+	// Handler to run on the worker pool (nil unless Detach was called)
+	detached func(ctx Context)
+	admit    func(start func())
+	// Streamed response in progress (see Stream and SSEvent), and whether
+	// its body goes out unframed under a Content-Length
+	streaming bool
+	rawBody   bool
+	// Response trailers, names and values alternating (see SetTrailer)
+	trailers []string
+
+	// Queue for pipelined responses (nil writes directly)
+	batch *[]byte
+	// Unsent bytes left for the engine (nil: writes wait, see
+	// SetWriteBacklog)
+	backlog *Backlog
+	// Held response state (see Hold)
+	holdBuf  []byte
+	holdMark int
+
+	// Captured response (non-nil while recording, see Record)
+	recording bool
+	recorded  *RecordedResponse
+
+	// Peer address from accept, converted on first use (see RemoteAddr)
+	peer       syscall.Sockaddr
+	remoteAddr net.Addr
+	// Proxies whose forwarding headers ClientIP believes
+	trustedProxies *TrustedProxies
+
+	// Route response defaults (nil unless the route declares options)
+	defaults *ResponseDefaults
+	// Response compression of this request (see SetCompression)
+	compression *Compression
+	// Automatic ETag of this request (see SetAutoETag)
+	etagMode    ETagMode
+	etagModeSet bool
+
+	// Reusable HTML builder (see HTML)
+	html HTML
+	// Buffered body writer (see Writer) and low-level writer (see
+	// ResponseWriter)
+	body bodyWriter
+	rw   fdResponseWriter
+
+	// Parsed urlencoded form (see Form)
+	form       url.Values
+	formErr    error
+	formParsed bool
+
+	// Parsed multipart form and its memory threshold (see MultipartForm)
+	multipart       *multipart.Form
+	multipartErr    error
+	multipartParsed bool
+	multipartMemory int64
+
+	// Engine's 429/503 responses (see Backoff)
+	backoffs map[int]*Backoff
+
+	// Templates executed by Render
+	templates *Templates
+	// Fingerprinted assets resolved by Asset
+	assets *Assets
+	// Server header value of every response ("": none)
+	server string
+
+	// Request cookies, parsed on first use (see Cookies)
+	cookies       []*nethttp.Cookie
+	cookiesParsed bool
+	// Decoded Basic credentials (see BasicAuth)
+	authBuf []byte
+
+	// Connection taken over by the handler, and the bytes read past the
+	// request (see Hijack)
+	hijacked  bool
+	readAhead []byte
+
+	// Connection settings after this response (see SetKeepAlive)
+	noKeepAlive bool
+	idleTimeout time.Duration
+	tenant      string
+
+	// Client disconnect tracking (see Context and Disconnected)
+	disconnected atomic.Bool
+	reqCtx       context.Context
+	cancel       context.CancelCauseFunc
+	watch        func(*FDContext)
+	deadline     time.Time
+
+	// Enforced deadline (see SetDeadline): its timer, whether it passed
+	// and whether the timeout reply is being written
+	enforced     *deadlineTimer
+	timedOut     atomic.Bool
+	timeoutReply bool
+
+	// Write timeout and the first error hit while writing the response
+	writeTimeout time.Duration
+	writeErr     error
+	// Bytes of the response written or queued (see ResponseSize)
+	bytesSent int64
+
+	// Receives queue depths and write stalls (nil: not reported)
+	writeObserver WriteObserver
+
+	// Request/response dump (see SetDumper): the raw request, and the
+	// response captured so far with the end of its headers
+	dumper        *Dumper
+	rawRequest    []byte
+	dumping       bool
+	dumpOut       []byte
+	dumpHead      int
+	dumpTruncated bool
 }
 
 // NewFDContext creates a new FD-based context
@@ -40,6 +177,17 @@ func NewFDContext(fd int, req *Request) *FDContext {
 	}
 }
 
+// FD returns the connection's file descriptor
+func (c *FDContext) FD() int {
+	return c.fd
+}
+
+// Request returns the underlying parsed request.
+// It is only valid until the handler returns.
+func (c *FDContext) Request() *Request {
+	return c.request
+}
+
 // Request information methods
 func (c *FDContext) Method() string {
 	return c.request.Method
@@ -64,39 +212,41 @@ func (c *FDContext) Param(key string) string {
 }
 
 func (c *FDContext) Query(key string) string {
-	if c.request.Query == nil {
-		return ""
-	}
-	return c.request.Query[key]
+	return c.request.QueryValue(key)
 }
 
+// Header returns the first value of a request header, matching the key
+// case-insensitively
 func (c *FDContext) Header(key string) string {
-	// Check predefined headers first
-	switch key {
-	case "Content-Type":
-		return c.request.ContentType
-	case "Content-Length":
-		return c.request.ContentLength
-	case "User-Agent":
-		return c.request.UserAgent
-	case "Accept":
-		return c.request.Accept
-	case "Host":
-		return c.request.Host
-	case "Connection":
-		return c.request.Connection
-	}
-	// Check extra headers
-	if c.request.ExtraHeaders != nil {
-		return c.request.ExtraHeaders[key]
-	}
-	return ""
+	return c.request.Header(key)
+}
+
+// HeaderValues returns every value of a request header sent more than
+// once, such as several Accept lines
+func (c *FDContext) HeaderValues(key string) []string {
+	return c.request.HeaderValues(key)
 }
 
+// Body returns the in-memory request body. Bodies spilled to a BodyStore
+// are read with BodyReader. The slice is reused after the request (see
+// BodyCopy).
 func (c *FDContext) Body() []byte {
 	return c.request.Body
 }
 
+// isHead reports whether the response must omit its body
+func (c *FDContext) isHead() bool {
+	return c.request != nil && c.request.Method == "HEAD"
+}
+
+// bodyAllowed reports whether the body may follow the headers written by
+// startResponse: not for HEAD requests or bodiless status codes. A HEAD
+// response keeps the Content-Length of the body it omits.
+func (c *FDContext) bodyAllowed() bool {
+	code := c.statusCode
+	return !c.isHead() && code >= 200 && code != 204 && code != 304
+}
+
 func (c *FDContext) SetParam(key, value string) {
 	if c.paramCount < 4 {
 		c.paramKeys[c.paramCount] = key
@@ -112,54 +262,150 @@ func (c *FDContext) SetParam(key, value string) {
 
 // writeResponse writes the response buffer to the file descriptor
 func (c *FDContext) writeResponse() error {
-	// Write all data (handle partial writes)
+	if c.batch != nil {
+		return c.queueResponse()
+	}
+	return c.writeAll(c.responseBuf, netfd.Write)
+}
+
+// writeAll writes buf with write, handling partial writes
+func (c *FDContext) writeAll(buf []byte, write func(int, []byte) (int, error)) error {
+	if err := c.checkDeadline(); err != nil {
+		return err
+	}
+	if c.dumping {
+		c.captureDump(buf)
+	}
+	return c.writeBuf(buf, write)
+}
+
+// writeBuf is writeAll regardless of the deadline
+func (c *FDContext) writeBuf(buf []byte, write func(int, []byte) (int, error)) error {
+	c.written = true
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+
+	if c.backlog.Pending() {
+		if c.deferWrite(buf) {
+			return nil
+		}
+		if err := c.drainBacklog(); err != nil {
+			return err
+		}
+	}
+
 	written := 0
-	for written < len(c.responseBuf) {
-		n, err := syscall.Write(c.fd, c.responseBuf[written:])
+	var stall writeStall
+	for written < len(buf) {
+		n, err := write(c.fd, buf[written:])
 		if err != nil {
-			if err == syscall.EAGAIN || err == syscall.EWOULDBLOCK {
-				// Would block - in production, should return to event loop
-				// For now, retry immediately (simplified)
+			if isAgain(err) && stall.start.IsZero() && c.deferWrite(buf[written:]) {
+				return nil
+			}
+			if c.retryWrite(err, &stall) {
 				continue
 			}
-			return err
+			return c.writeErr
 		}
 		written += n
+		c.bytesSent += int64(n)
 	}
+	c.endStall(&stall)
 	return nil
 }
 
-// String sends a plain text response
-func (c *FDContext) String(code int, s string) {
-	c.responseBuf = c.responseBuf[:0]
-
-	// Status line
-	c.responseBuf = append(c.responseBuf, "HTTP/1.1 "...)
-	c.responseBuf = appendInt(c.responseBuf, code)
-	c.responseBuf = append(c.responseBuf, ' ')
-	c.responseBuf = append(c.responseBuf, statusText(code)...)
-	c.responseBuf = append(c.responseBuf, "\r\n"...)
+// writeStall tracks a write blocked on a full socket buffer
+type writeStall struct {
+	start    time.Time
+	deadline time.Time
+}
 
-	// Headers
-	c.responseBuf = append(c.responseBuf, "Content-Type: text/plain\r\n"...)
-	c.responseBuf = append(c.responseBuf, "Content-Length: "...)
-	c.responseBuf = appendInt(c.responseBuf, len(s))
-	c.responseBuf = append(c.responseBuf, "\r\n\r\n"...)
+// isAgain reports whether a write failed because the socket buffer is full
+func isAgain(err error) bool {
+	return err == syscall.EAGAIN || err == syscall.EWOULDBLOCK
+}
 
-	// Body
-	c.responseBuf = append(c.responseBuf, s...)
+// retryWrite reports whether a failed write should be retried: on EAGAIN
+// it waits for the socket to become writable, until the write timeout
+// expires. Otherwise it records writeErr.
+func (c *FDContext) retryWrite(err error, stall *writeStall) bool {
+	if !isAgain(err) {
+		if err == syscall.EPIPE || err == syscall.ECONNRESET {
+			c.markDisconnected()
+		}
+		c.writeErr = err
+		c.endStall(stall)
+		return false
+	}
+	if stall.start.IsZero() {
+		stall.start = time.Now()
+		if c.writeTimeout > 0 {
+			stall.deadline = stall.start.Add(c.writeTimeout)
+		}
+	} else if !stall.deadline.IsZero() && time.Now().After(stall.deadline) {
+		c.writeErr = ErrWriteTimeout
+		c.endStall(stall)
+		return false
+	}
+	wait := time.Duration(-1)
+	if !stall.deadline.IsZero() {
+		wait = max(time.Until(stall.deadline), 0)
+	}
+	netfd.WaitWritable(c.fd, wait)
+	return true
+}
 
-	// Write to socket
-	c.writeResponse()
+// endStall reports how long a write was blocked, if it was
+func (c *FDContext) endStall(stall *writeStall) {
+	if stall.start.IsZero() || c.writeObserver == nil {
+		return
+	}
+	c.writeObserver.RecordStall(c.fd, time.Since(stall.start))
 }
 
-// JSON sends a JSON response
-func (c *FDContext) JSON(code int, v any) {
-	data, err := json.Marshal(v)
-	if err != nil {
-		c.Error(500, "Failed to marshal JSON")
+// SetServer sets the Server header sent with every response ("": none).
+// Values containing CR or LF are dropped.
+func (c *FDContext) SetServer(name string) {
+	if strings.ContainsAny(name, "\r\n") {
 		return
 	}
+	c.server = name
+}
+
+// SetWriteTimeout sets how long a response write may stall before failing
+func (c *FDContext) SetWriteTimeout(d time.Duration) {
+	c.writeTimeout = d
+}
+
+// WriteObserver is told how many bytes of queued responses are written at
+// once and how long writes were blocked on a full socket buffer (a slow
+// or stuck client)
+type WriteObserver interface {
+	RecordQueue(fd, n int)
+	RecordStall(fd int, d time.Duration)
+}
+
+// SetWriteObserver sets where write backpressure is reported; nil
+// disables it
+func (c *FDContext) SetWriteObserver(o WriteObserver) {
+	c.writeObserver = o
+}
+
+// WriteErr returns the first error hit while writing the response, if any
+func (c *FDContext) WriteErr() error {
+	return c.writeErr
+}
+
+// startResponse writes the status line and headers into responseBuf.
+// A code of 0 uses the status set by Status. Headers set with SetHeader
+// (including Content-Type) and cookies set with SetCookie are included;
+// Content-Length is always computed from the body.
+func (c *FDContext) startResponse(code int, contentType string, contentLength int) {
+	if code == 0 {
+		code = c.statusCode
+	}
+	c.statusCode = code
 
 	c.responseBuf = c.responseBuf[:0]
 
@@ -170,67 +416,137 @@ func (c *FDContext) JSON(code int, v any) {
 	c.responseBuf = append(c.responseBuf, statusText(code)...)
 	c.responseBuf = append(c.responseBuf, "\r\n"...)
 
-	// Headers
-	c.responseBuf = append(c.responseBuf, "Content-Type: application/json\r\n"...)
-	c.responseBuf = append(c.responseBuf, "Content-Length: "...)
-	c.responseBuf = appendInt(c.responseBuf, len(data))
-	c.responseBuf = append(c.responseBuf, "\r\n\r\n"...)
+	// Date from the coarse clock's cached value
+	c.responseBuf = append(c.responseBuf, "Date: "...)
+	c.responseBuf = clock.AppendDate(c.responseBuf)
+	c.responseBuf = append(c.responseBuf, "\r\n"...)
+	if c.server != "" {
+		c.responseBuf = appendHeader(c.responseBuf, "Server", c.server)
+	}
 
-	// Body
-	c.responseBuf = append(c.responseBuf, data...)
+	// Custom headers
+	for k, v := range c.responseHeaders {
+		if k == "Content-Length" {
+			continue
+		}
+		if k == "Content-Type" {
+			contentType = v
+			continue
+		}
+		c.responseBuf = appendHeader(c.responseBuf, k, v)
+	}
+	if c.defaults != nil && c.defaults.CacheControl != "" && c.responseHeaders["Cache-Control"] == "" {
+		c.responseBuf = appendHeader(c.responseBuf, "Cache-Control", c.defaults.CacheControl)
+	}
+	for _, cookie := range c.responseCookies {
+		c.responseBuf = appendHeader(c.responseBuf, "Set-Cookie", cookie)
+	}
+	// An HTTP/1.0 body without a length is delimited by closing the
+	// connection; a kept-alive HTTP/1.0 connection must say so
+	if contentLength < 0 && c.request.Proto == "HTTP/1.0" && c.bodyAllowed() {
+		c.noKeepAlive = true
+	}
+	c.responseBuf = c.appendConnection(c.responseBuf)
+
+	if contentType != "" {
+		c.responseBuf = appendHeader(c.responseBuf, "Content-Type", contentType)
+	}
 
-	// Write to socket
-	c.writeResponse()
+	// 1xx, 204 and 304 responses carry no body and no Content-Length.
+	// A negative length streams the body (chunked for HTTP/1.1).
+	if code >= 200 && code != 204 && code != 304 {
+		if contentLength >= 0 {
+			c.responseBuf = append(c.responseBuf, "Content-Length: "...)
+			c.responseBuf = appendInt(c.responseBuf, contentLength)
+			c.responseBuf = append(c.responseBuf, "\r\n"...)
+		} else if c.request.Proto != "HTTP/1.0" {
+			c.responseBuf = append(c.responseBuf, "Transfer-Encoding: chunked\r\n"...)
+		}
+	}
+	c.responseBuf = append(c.responseBuf, "\r\n"...)
 }
 
-// Bytes sends a raw bytes response
-func (c *FDContext) Bytes(code int, data []byte) {
-	c.responseBuf = c.responseBuf[:0]
+// appendConnection appends the Connection header the response needs:
+// close when the connection ends after it, keep-alive when an HTTP/1.0
+// connection stays open, none otherwise
+func (c *FDContext) appendConnection(b []byte) []byte {
+	switch {
+	case c.noKeepAlive:
+		return append(b, "Connection: close\r\n"...)
+	case c.request != nil && c.request.Proto == "HTTP/1.0":
+		return append(b, "Connection: keep-alive\r\n"...)
+	}
+	return b
+}
 
-	// Status line
-	c.responseBuf = append(c.responseBuf, "HTTP/1.1 "...)
-	c.responseBuf = appendInt(c.responseBuf, code)
-	c.responseBuf = append(c.responseBuf, ' ')
-	c.responseBuf = append(c.responseBuf, statusText(code)...)
-	c.responseBuf = append(c.responseBuf, "\r\n"...)
+// WriteStatus sends the status set by Status and the response headers with
+// an empty body. The engine calls it when a handler (or an aborting
+// middleware) returns without writing a response.
+func (c *FDContext) WriteStatus() {
+	c.NoContent(c.statusCode)
+}
 
-	// Headers
-	c.responseBuf = append(c.responseBuf, "Content-Type: application/octet-stream\r\n"...)
-	c.responseBuf = append(c.responseBuf, "Content-Length: "...)
-	c.responseBuf = appendInt(c.responseBuf, len(data))
-	c.responseBuf = append(c.responseBuf, "\r\n\r\n"...)
+// NoContent sends a response without a body, such as 204 for an ack or
+// 200 for a health check. Unless response headers or cookies are set, it
+// is written from a precomputed status line with only the Date added.
+func (c *FDContext) NoContent(code int) error {
+	line := statusLine(code)
+	if line == nil || len(c.responseHeaders) > 0 || len(c.responseCookies) > 0 ||
+		(c.defaults != nil && c.defaults.CacheControl != "") {
+		c.startResponse(code, "", 0)
+		return c.writeResponse()
+	}
 
-	// Body
-	c.responseBuf = append(c.responseBuf, data...)
+	c.statusCode = code
+	b := append(c.responseBuf[:0], line...)
+	b = clock.AppendDate(b)
+	b = append(b, "\r\n"...)
+	if c.server != "" {
+		b = appendHeader(b, "Server", c.server)
+	}
+	b = c.appendConnection(b)
+	if code >= 200 && code != 204 && code != 304 {
+		b = append(b, "Content-Length: 0\r\n"...)
+	}
+	c.responseBuf = append(b, "\r\n"...)
+	return c.writeResponse()
+}
 
-	// Write to socket
-	c.writeResponse()
+// Written reports whether a response has been written
+func (c *FDContext) Written() bool {
+	return c.written
 }
 
-// Data sends a response with custom content type
-func (c *FDContext) Data(code int, contentType string, data []byte) {
-	c.responseBuf = c.responseBuf[:0]
+// String sends a plain text response
+func (c *FDContext) String(code int, s string) {
+	c.respond(code, c.implicitType("text/plain"), unsafeBytes(s))
+}
 
-	// Status line
-	c.responseBuf = append(c.responseBuf, "HTTP/1.1 "...)
-	c.responseBuf = appendInt(c.responseBuf, code)
-	c.responseBuf = append(c.responseBuf, ' ')
-	c.responseBuf = append(c.responseBuf, statusText(code)...)
-	c.responseBuf = append(c.responseBuf, "\r\n"...)
+// JSON sends a JSON response
+func (c *FDContext) JSON(code int, v any) {
+	// Encoded into a pooled buffer rather than a new slice per response;
+	// respond copies it into responseBuf (or writes it out) before it is
+	// returned to the pool
+	s := jsonStreamPool.Get().(*jsonStreamState)
+	defer releaseJSONState(s)
+	if err := s.enc.Encode(v); err != nil {
+		c.Error(500, "Failed to marshal JSON")
+		return
+	}
+	data := s.buf.Bytes()
+	data = data[:len(data)-1] // Encode's trailing newline
 
-	// Headers
-	c.responseBuf = append(c.responseBuf, "Content-Type: "...)
-	c.responseBuf = append(c.responseBuf, contentType...)
-	c.responseBuf = append(c.responseBuf, "\r\n"...)
-	c.responseBuf = append(c.responseBuf, "Content-Length: "...)
-	c.responseBuf = appendInt(c.responseBuf, len(data))
-	c.responseBuf = append(c.responseBuf, "\r\n\r\n"...)
+	c.respond(code, c.implicitType("application/json"), data)
+}
 
-	// Body
-	c.responseBuf = append(c.responseBuf, data...)
+// Bytes sends a raw bytes response
+func (c *FDContext) Bytes(code int, data []byte) {
+	c.respond(code, c.implicitType("application/octet-stream"), data)
+}
 
-	// Write to socket
-	c.writeResponse()
+// Data sends a response with custom content type
+func (c *FDContext) Data(code int, contentType string, data []byte) {
+	c.respond(code, contentType, data)
 }
 
 // Error sends an error response
@@ -250,18 +566,96 @@ func (c *FDContext) Success(data any) {
 	})
 }
 
-// ServeFile serves a file using sendfile (zero-copy)
+// ServeFile serves a file using sendfile (zero-copy). The header is sent
+// with MSG_MORE where supported so the kernel coalesces it with the body.
+// It sends Last-Modified (and an ETag, see SetAutoETag) and answers 304
+// when the request's If-None-Match or If-Modified-Since match.
 func (c *FDContext) ServeFile(filePath string) error {
-	// TODO: Implement sendfile integration
-	return nil
+	info, err := os.Stat(filePath)
+	if err != nil {
+		c.String(404, "File not found")
+		return err
+	}
+	if info.IsDir() {
+		c.String(404, "File not found")
+		return fs.ErrNotExist
+	}
+
+	if c.responseHeaders["Last-Modified"] == "" {
+		c.SetHeader("Last-Modified", info.ModTime().UTC().Format(nethttp.TimeFormat))
+	}
+	if mode := c.autoETag(); mode != ETagNone && c.responseHeaders["Etag"] == "" {
+		c.SetHeader("ETag", fileETag(info.ModTime(), info.Size(), mode))
+	}
+	if (c.request.Method == "GET" || c.request.Method == "HEAD") &&
+		c.notModified(c.responseHeaders["Etag"], c.responseHeaders["Last-Modified"]) {
+		c.writeNotModified()
+		return nil
+	}
+
+	size := int(info.Size())
+	if c.isHead() || size == 0 {
+		c.startResponse(200, sendfile.GetContentType(filePath), size)
+		return c.writeResponse()
+	}
+
+	// Opened before the headers go out, for a 404 if it was removed
+	file, release, err := sendfile.Open(filePath, info)
+	if err != nil {
+		c.String(404, "File not found")
+		return err
+	}
+	c.startResponse(200, sendfile.GetContentType(filePath), size)
+	if err := c.FlushBatch(); err != nil {
+		release()
+		return err
+	}
+	if err := c.writeAll(c.responseBuf, writeMore); err != nil {
+		release()
+		return err
+	}
+	return c.sendFile(file, release, size)
 }
 
-// Bind binds request body to a struct (not implemented for FD context)
-func (c *FDContext) Bind(v any) error {
-	return json.Unmarshal(c.request.Body, v)
+// sendFile sends size bytes of file after the headers and releases it.
+// What the socket does not take is left to the backlog, which then holds
+// the file, or waited for up to the write timeout.
+func (c *FDContext) sendFile(file *os.File, release func(), size int) error {
+	var stall writeStall
+	sent := 0
+	for sent < size {
+		// Headers still in the backlog go first
+		if c.backlog.Pending() {
+			if c.deferFile(file, release, int64(sent), size-sent) {
+				return nil
+			}
+			if err := c.drainBacklog(); err != nil {
+				return err
+			}
+		}
+		n, err := sendfile.Send(c.fd, file, int64(sent), size-sent)
+		sent += n
+		c.bytesSent += int64(n)
+		if err == nil {
+			break
+		}
+		if isAgain(err) && stall.start.IsZero() && c.deferFile(file, release, int64(sent), size-sent) {
+			return nil
+		}
+		if !c.retryWrite(err, &stall) {
+			release()
+			return c.writeErr
+		}
+	}
+	c.endStall(&stall)
+	release()
+	if sent < size {
+		c.writeErr = io.ErrUnexpectedEOF
+	}
+	return c.writeErr
 }
 
-// Conn returns nil (FD context doesn't use net.Conn)
+// Conn returns nil: FD contexts have no net.Conn (see Hijack)
 func (c *FDContext) Conn() net.Conn {
 	return nil
 }
@@ -271,21 +665,119 @@ func (c *FDContext) GetHeader(key string) string {
 	return c.Header(key)
 }
 
-// SetHeader sets a response header
+// SetHeader sets a response header, replacing any previous value.
+// Setting Content-Type overrides the default of the response method.
+// Keys or values containing CR or LF are dropped.
 func (c *FDContext) SetHeader(key, value string) {
-	// Headers are set inline in response buffer
-	// For now, store in a map (can be optimized later)
+	if strings.ContainsAny(key, "\r\n") || strings.ContainsAny(value, "\r\n") {
+		return
+	}
 	if c.responseHeaders == nil {
 		c.responseHeaders = make(map[string]string, 8)
 	}
-	c.responseHeaders[key] = value
+	c.responseHeaders[textproto.CanonicalMIMEHeaderKey(key)] = value
 }
 
-// Status sets the response status code
+// AddHeader adds a value to a response header. Values of a repeated
+// header are sent as one comma-separated list, which means the same
+// (RFC 9110); Set-Cookie values, which cannot be combined, are sent as
+// separate headers.
+func (c *FDContext) AddHeader(key, value string) {
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	prev, ok := c.responseHeaders[key]
+	switch {
+	case strings.ContainsAny(key, "\r\n") || strings.ContainsAny(value, "\r\n"):
+	case key == "Set-Cookie":
+		c.responseCookies = append(c.responseCookies, value)
+	case ok:
+		c.responseHeaders[key] = prev + ", " + value
+	default:
+		c.SetHeader(key, value)
+	}
+}
+
+// SetCookie adds a Set-Cookie header to the response. Invalid cookies are dropped.
+func (c *FDContext) SetCookie(cookie *nethttp.Cookie) {
+	if v := cookie.String(); v != "" {
+		c.responseCookies = append(c.responseCookies, v)
+	}
+}
+
+// Status sets the response status code used by WriteStatus and by response
+// methods called with code 0
 func (c *FDContext) Status(code int) {
 	c.statusCode = code
 }
 
+// StatusCode returns the response status: the one sent, or the one set by
+// Status before the response is written
+func (c *FDContext) StatusCode() int {
+	return c.statusCode
+}
+
+// ResponseStatus returns the status of the response sent, or 0 before
+// the response is written. Logging and metrics middleware read it after
+// the handler returns.
+func (c *FDContext) ResponseStatus() int {
+	if !c.written {
+		return 0
+	}
+	return c.statusCode
+}
+
+// ResponseSize returns how many bytes of the response, headers included,
+// have been written to the connection (or queued behind pipelined
+// responses)
+func (c *FDContext) ResponseSize() int64 {
+	return c.bytesSent
+}
+
+// Async detaches the request from the synchronous processing path.
+// The engine keeps the context and connection alive until the returned
+// handle is completed with Respond or Fail. A handler running inside
+// Respond, e.g. an offloaded one, may call Async to respond later.
+func (c *FDContext) Async() *AsyncHandle {
+	if c.async == nil {
+		c.async = newAsyncHandle(c)
+		c.asyncRef.Store(c.async)
+	} else {
+		c.async.rearm()
+	}
+	return c.async
+}
+
+// IsAsync reports whether the handler detached the request
+func (c *FDContext) IsAsync() bool {
+	return c.async != nil
+}
+
+// Detach hands fn to the engine's worker pool so CPU-heavy or blocking
+// work does not stall the event loop. The connection is parked as with
+// Async, and the response fn writes is sent once it returns.
+func (c *FDContext) Detach(fn func(ctx Context)) {
+	c.Async()
+	c.detached = fn
+}
+
+// Detached returns the handler passed to Detach, if any
+func (c *FDContext) Detached() func(ctx Context) {
+	return c.detached
+}
+
+// DetachAfter is Detach for a handler that must wait its turn: once the
+// request is parked the engine calls admit, which calls start when fn
+// may run, from any goroutine, or completes the request itself (e.g.
+// rejecting it through Async). admit must not block.
+func (c *FDContext) DetachAfter(admit func(start func()), fn func(ctx Context)) {
+	c.Detach(fn)
+	c.admit = admit
+}
+
+// Admission returns the admit function passed to DetachAfter, if any
+func (c *FDContext) Admission() func(start func()) {
+	return c.admit
+}
+
 // IsAborted returns whether the request has been aborted
 func (c *FDContext) IsAborted() bool {
 	return c.aborted
@@ -300,6 +792,7 @@ func (c *FDContext) Abort() {
 func (c *FDContext) Reset(fd int, req *Request) {
 	c.fd = fd
 	c.request = req
+	c.retained = false
 
 	// Reset params without freeing memory
 	c.paramCount = 0
@@ -317,8 +810,61 @@ func (c *FDContext) Reset(fd int, req *Request) {
 		}
 	}
 
+	c.responseCookies = c.responseCookies[:0]
+
 	// Keep slice capacity, just reset length
 	c.responseBuf = c.responseBuf[:0]
 	c.statusCode = 200
 	c.aborted = false
+	c.written = false
+	c.stopDeadline()
+	c.timedOut.Store(false)
+	c.timeoutReply = false
+	c.async = nil
+	c.asyncRef.Store(nil)
+	c.detached = nil
+	c.admit = nil
+	c.batch = nil
+	c.backlog = nil
+	c.streaming = false
+	c.rawBody = false
+	c.trailers = c.trailers[:0]
+	c.body.buf = c.body.buf[:0]
+	c.rw = fdResponseWriter{}
+	c.form = nil
+	c.formErr = nil
+	c.formParsed = false
+	c.releaseMultipart()
+	c.multipartMemory = 0
+	c.backoffs = nil
+	c.templates = nil
+	c.assets = nil
+	c.server = ""
+	clear(c.cookies)
+	c.cookies = c.cookies[:0]
+	c.cookiesParsed = false
+	c.hijacked = false
+	c.readAhead = nil
+	c.holdBuf = c.holdBuf[:0]
+	c.recording = false
+	c.recorded = nil
+	c.peer = nil
+	c.remoteAddr = nil
+	c.trustedProxies = nil
+	c.defaults = nil
+	c.compression = nil
+	c.etagMode = ETagNone
+	c.etagModeSet = false
+	c.noKeepAlive = false
+	c.idleTimeout = 0
+	c.tenant = ""
+	c.writeErr = nil
+	c.bytesSent = 0
+	c.disconnected.Store(false)
+	c.reqCtx = nil
+	c.cancel = nil
+	c.deadline = time.Time{}
+	c.dumper = nil
+	c.rawRequest = nil
+	c.dumping = false
 }
@@ -1,9 +1,40 @@
 package http
 
 import (
+	"compress/gzip"
+	"compress/zlib"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime/multipart"
+	"net"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/searchktools/fast-server/core/msgpack"
+	"github.com/searchktools/fast-server/core/netfd"
 )
 
+// newSocketPair 创建一对已连接的套接字，用于捕获响应输出
+func newSocketPair(t *testing.T) (int, func() string) {
+	t.Helper()
+	client, err := NewRawClient()
+	if err != nil {
+		t.Fatalf("socketpair: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client.FD, client.Response
+}
+
 // TestFDContextBasic 测试基本功能
 func TestFDContextBasic(t *testing.T) {
 	req := &Request{
@@ -59,7 +90,8 @@ func TestFDContextHeaders(t *testing.T) {
 		UserAgent:   "TestAgent/1.0",
 	}
 
-	ctx := NewFDContext(1, req)
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, req)
 
 	// 读取预定义头部
 	if ctx.Header("Content-Type") != "application/json" {
@@ -70,10 +102,22 @@ func TestFDContextHeaders(t *testing.T) {
 		t.Errorf("Expected User-Agent=TestAgent/1.0, got %s", ctx.GetHeader("User-Agent"))
 	}
 
-	// 设置响应头
-	ctx.SetHeader("X-Custom", "test-value")
+	// 设置响应头，Content-Type 覆盖默认值
+	ctx.SetHeader("x-custom", "test-value")
+	ctx.SetHeader("Content-Type", "text/html")
+	ctx.SetHeader("X-Injected", "a\r\nX-Evil: 1")
+	ctx.String(200, "<p>ok</p>")
 
-	// 注意：目前无法读取响应头，这里只测试不崩溃
+	out := read()
+	if !strings.Contains(out, "X-Custom: test-value\r\n") {
+		t.Errorf("Expected custom header in response, got %q", out)
+	}
+	if !strings.Contains(out, "Content-Type: text/html\r\n") || strings.Contains(out, "text/plain") {
+		t.Errorf("Expected Content-Type override, got %q", out)
+	}
+	if strings.Contains(out, "X-Evil") {
+		t.Errorf("Expected header with CRLF to be dropped, got %q", out)
+	}
 }
 
 // TestFDContextAbort 测试终止功能
@@ -103,12 +147,157 @@ func TestFDContextStatus(t *testing.T) {
 		Path:   "/",
 	}
 
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, req)
+
+	// 未写入响应时，WriteStatus 发送 Status 设置的状态码
+	ctx.Status(204)
+	ctx.SetHeader("Allow", "GET, OPTIONS")
+	if ctx.Written() {
+		t.Error("New context should not be written")
+	}
+	ctx.WriteStatus()
+
+	out := read()
+	if !strings.HasPrefix(out, "HTTP/1.1 204 No Content\r\nDate: ") ||
+		!strings.HasSuffix(out, "\r\nAllow: GET, OPTIONS\r\n\r\n") ||
+		strings.Contains(out, "Content-Length") {
+		t.Errorf("Unexpected 204 response %q", out)
+	}
+	if !ctx.Written() {
+		t.Error("Context should be written after WriteStatus")
+	}
+
+	// 状态码为 0 时使用 Status 设置的值
+	ctx.Reset(fd, req)
+	ctx.Status(429)
+	ctx.String(0, "slow down")
+	if out := read(); !strings.HasPrefix(out, "HTTP/1.1 429 Too Many Requests\r\n") {
+		t.Errorf("Expected 429 status line, got %q", out)
+	}
+}
+
+// TestFDContextSetCookie 测试 Set-Cookie 响应头
+func TestFDContextSetCookie(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+
+	ctx.SetCookie(&nethttp.Cookie{Name: "session", Value: "abc", Path: "/", HttpOnly: true})
+	ctx.SetCookie(&nethttp.Cookie{Name: "theme", Value: "dark"})
+	ctx.SetCookie(&nethttp.Cookie{Name: "csrf", Value: "x", Secure: true, SameSite: nethttp.SameSiteStrictMode})
+	ctx.String(200, "ok")
+
+	out := read()
+	if !strings.Contains(out, "Set-Cookie: session=abc; Path=/; HttpOnly\r\n") {
+		t.Errorf("Expected session cookie, got %q", out)
+	}
+	if !strings.Contains(out, "Set-Cookie: theme=dark\r\n") {
+		t.Errorf("Expected theme cookie, got %q", out)
+	}
+	if !strings.Contains(out, "Set-Cookie: csrf=x; Secure; SameSite=Strict\r\n") {
+		t.Errorf("Expected csrf cookie, got %q", out)
+	}
+
+	// 读取请求 Cookie
+	req := &Request{Method: "GET", Path: "/"}
+	req.SetHeader("Cookie", "session=abc; theme=dark")
+	ctx.Reset(fd, req)
+	if cookie, err := ctx.Cookie("theme"); err != nil || cookie.Value != "dark" {
+		t.Errorf("Expected theme=dark, got %v (%v)", cookie, err)
+	}
+	if _, err := ctx.Cookie("missing"); err != nethttp.ErrNoCookie {
+		t.Errorf("Expected ErrNoCookie, got %v", err)
+	}
+	if n := len(ctx.Cookies()); n != 2 {
+		t.Errorf("Expected 2 cookies, got %d", n)
+	}
+
+	// Reset 清除 Cookie
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	ctx.String(200, "ok")
+	if out := read(); strings.Contains(out, "Set-Cookie") {
+		t.Errorf("Expected cookies cleared after reset, got %q", out)
+	}
+}
+
+// TestFDContextCookieScan 测试请求 Cookie 的惰性解析
+func TestFDContextCookieScan(t *testing.T) {
+	req := &Request{Method: "GET", Path: "/"}
+	req.SetHeader("Cookie", `a=1; bad name=x; quoted="q v";=empty; session=abc;theme=dark`)
+	ctx := NewFDContext(1, req)
+
+	if v, ok := ctx.CookieValue("session"); !ok || v != "abc" {
+		t.Errorf("Expected session=abc, got %q %v", v, ok)
+	}
+	if v, _ := ctx.CookieValue("quoted"); v != "q v" {
+		t.Errorf("Expected the quotes stripped, got %q", v)
+	}
+	if _, ok := ctx.CookieValue("bad name"); ok {
+		t.Error("Expected the malformed pair to be skipped")
+	}
+	if allocs := testing.AllocsPerRun(100, func() { ctx.CookieValue("theme") }); allocs != 0 {
+		t.Errorf("Expected no allocations, got %.0f", allocs)
+	}
+
+	var names []string
+	for _, c := range ctx.Cookies() {
+		names = append(names, c.Name+"="+c.Value)
+	}
+	if strings.Join(names, ",") != "a=1,quoted=q v,session=abc,theme=dark" {
+		t.Errorf("Unexpected cookies %v", names)
+	}
+	if c, err := ctx.Cookie("theme"); err != nil || c != ctx.Cookies()[3] {
+		t.Error("Cookie should use the parsed cookies")
+	}
+
+	ctx.Reset(1, &Request{Method: "GET", Path: "/"})
+	if len(ctx.Cookies()) != 0 {
+		t.Error("Reset should clear the parsed cookies")
+	}
+}
+
+// TestFDContextHeaderCase 测试大小写无关的请求头与重复请求头
+func TestFDContextHeaderCase(t *testing.T) {
+	raw := "GET / HTTP/1.1\r\nhost: example.com\r\ncontent-type: text/plain\r\nAccept: text/html\r\n" +
+		"Accept: application/json\r\nx-trace-id: abc\r\n\r\n"
+	req, err := ParseRequest([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
 	ctx := NewFDContext(1, req)
 
-	// 默认状态码应该是 200
-	// 注意：目前无法直接读取状态码，这里只测试不崩溃
-	ctx.Status(404)
-	ctx.Status(200)
+	for key, want := range map[string]string{
+		"content-type": "text/plain",
+		"Content-Type": "text/plain",
+		"HOST":         "example.com",
+		"X-Trace-Id":   "abc",
+		"x-trace-id":   "abc",
+		"Accept":       "text/html",
+	} {
+		if got := ctx.Header(key); got != want {
+			t.Errorf("Header(%q) = %q, want %q", key, got, want)
+		}
+	}
+	if got := ctx.HeaderValues("accept"); strings.Join(got, "|") != "text/html|application/json" {
+		t.Errorf("Expected both Accept values, got %v", got)
+	}
+	if got := ctx.HeaderValues("X-Trace-Id"); len(got) != 1 || got[0] != "abc" {
+		t.Errorf("Expected one value, got %v", got)
+	}
+
+	// 响应头多值
+	fd, read := newSocketPair(t)
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	ctx.AddHeader("vary", "Accept")
+	ctx.AddHeader("Vary", "Origin")
+	ctx.AddHeader("Set-Cookie", "a=1")
+	ctx.AddHeader("Set-Cookie", "b=2")
+	ctx.String(200, "ok")
+	out := read()
+	if !strings.Contains(out, "Vary: Accept, Origin\r\n") ||
+		!strings.Contains(out, "Set-Cookie: a=1\r\nSet-Cookie: b=2\r\n") {
+		t.Errorf("Unexpected headers %q", out)
+	}
 }
 
 // TestFDContextReset 测试重置功能
@@ -167,6 +356,34 @@ func TestFDContextJSON(t *testing.T) {
 	ctx.JSON(200, data)
 }
 
+// TestFDContextJSONBody 测试 JSON 响应体与 json.Marshal 一致且不额外分配
+func TestFDContextJSONBody(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+
+	v := struct {
+		Message string `json:"message"`
+		Count   int    `json:"count"`
+	}{"<hello>", 123}
+	want, _ := json.Marshal(v)
+	ctx.JSON(200, v)
+	if out := read(); !strings.HasSuffix(out, "\r\n\r\n"+string(want)) {
+		t.Errorf("Expected body %s, got %q", want, out)
+	}
+
+	if raceEnabled {
+		t.Skip("allocations are not counted under the race detector")
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+		ctx.JSON(200, &v)
+		read()
+	})
+	if allocs > 2 { // The Request and the string read
+		t.Errorf("Expected the body to be encoded without allocating, got %.0f allocs", allocs)
+	}
+}
+
 // TestFDContextString 测试字符串响应
 func TestFDContextString(t *testing.T) {
 	req := &Request{
@@ -180,6 +397,497 @@ func TestFDContextString(t *testing.T) {
 	ctx.String(200, "Hello, World!")
 }
 
+// TestFDContextTypedAccessors 测试带默认值的类型化参数读取
+func TestFDContextTypedAccessors(t *testing.T) {
+	ctx := NewFDContext(1, &Request{Method: "GET", Path: "/users/42", Query: map[string]string{
+		"page": "3", "size": "x", "verbose": "true", "big": "9007199254740993",
+	}})
+	ctx.SetParam("id", "42")
+	ctx.SetParam("slug", "abc")
+
+	if n, err := ctx.ParamInt("id"); err != nil || n != 42 {
+		t.Errorf("ParamInt = %d, %v", n, err)
+	}
+	if _, err := ctx.ParamInt64("slug"); err == nil || !strings.Contains(err.Error(), `"slug"`) {
+		t.Errorf("Expected an error naming the param, got %v", err)
+	}
+	if ctx.QueryInt("page", 1) != 3 || ctx.QueryInt("size", 20) != 20 || ctx.QueryInt("missing", 7) != 7 {
+		t.Error("QueryInt should fall back to the default for missing or malformed values")
+	}
+	if ctx.QueryInt64("big", 0) != 9007199254740993 {
+		t.Error("QueryInt64 should parse 64-bit values")
+	}
+	if !ctx.QueryBool("verbose", false) || !ctx.QueryBool("missing", true) {
+		t.Error("QueryBool should parse bools and fall back to the default")
+	}
+
+	if n := testing.AllocsPerRun(100, func() {
+		ctx.QueryInt("page", 1)
+		ctx.QueryBool("missing", false)
+		ctx.ParamInt("id")
+	}); n != 0 {
+		t.Errorf("Expected no allocations, got %v", n)
+	}
+}
+
+// TestFDContextScheme 测试协议与代理转发的 scheme
+func TestFDContextScheme(t *testing.T) {
+	proxies, _ := NewTrustedProxies("10.0.0.0/8")
+	scheme := func(peer [4]byte, headers map[string]string) string {
+		ctx := NewFDContext(-1, &Request{Method: "GET", Path: "/", Proto: "HTTP/1.1", Host: "example.com", ExtraHeaders: headers})
+		ctx.SetPeer(&syscall.SockaddrInet4{Addr: peer})
+		ctx.SetTrustedProxies(proxies)
+		if ctx.Proto() != "HTTP/1.1" || ctx.IsTLS() || ctx.TLSState() != nil {
+			t.Error("Expected a plaintext HTTP/1.1 request")
+		}
+		return ctx.BaseURL()
+	}
+
+	proxy, untrusted := [4]byte{10, 0, 0, 1}, [4]byte{203, 0, 113, 9}
+	for _, tc := range []struct {
+		peer    [4]byte
+		headers map[string]string
+		want    string
+	}{
+		{proxy, nil, "http://example.com"},
+		{proxy, map[string]string{"X-Forwarded-Proto": "HTTPS"}, "https://example.com"},
+		{proxy, map[string]string{"X-Forwarded-Proto": "https, http", "X-Forwarded-Host": "app.example"}, "https://app.example"},
+		{proxy, map[string]string{"Forwarded": `for=1.2.3.4;proto="https", proto=http`}, "https://example.com"},
+		{proxy, map[string]string{"X-Forwarded-Proto": "gopher"}, "http://example.com"},
+		{untrusted, map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil"}, "http://example.com"},
+	} {
+		if got := scheme(tc.peer, tc.headers); got != tc.want {
+			t.Errorf("peer %v, headers %v: got %q, want %q", tc.peer, tc.headers, got, tc.want)
+		}
+	}
+}
+
+// TestFDContextDump 测试请求/响应抓取、截断与脱敏
+func TestFDContextDump(t *testing.T) {
+	fd, read := newSocketPair(t)
+	d := NewDumper(DumpConfig{MaxBody: 8, Capacity: 2})
+
+	raw := "POST /login?token=abc HTTP/1.1\r\nHost: example.com\r\nAuthorization: Bearer xyz\r\n\r\npassword=hunter2&user=alice"
+	ctx := NewFDContext(fd, &Request{Method: "POST", Path: "/login"})
+	ctx.SetDumper(d, []byte(raw))
+	if ctx.Dumping() {
+		t.Fatal("Rate 0 should not sample the request")
+	}
+	if !ctx.DumpRequest() {
+		t.Fatal("Expected DumpRequest to pick the request")
+	}
+	ctx.SetHeader("Set-Cookie", "sid=1")
+	ctx.String(401, "invalid credentials")
+	read()
+	ctx.EndRequest()
+
+	// 未抽样且未强制的请求不记录
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	ctx.SetDumper(d, []byte("GET / HTTP/1.1\r\n\r\n"))
+	ctx.String(200, "ok")
+	read()
+	ctx.EndRequest()
+
+	dumps := d.Dumps()
+	if len(dumps) != 1 {
+		t.Fatalf("Expected 1 dump, got %d", len(dumps))
+	}
+	dump := dumps[0]
+	if dump.Method != "POST" || dump.Path != "/login" || dump.Status != 401 {
+		t.Errorf("Unexpected dump %+v", dump)
+	}
+	for _, secret := range []string{"abc", "xyz", "hunter2", "sid=1"} {
+		if strings.Contains(dump.Request+dump.Response, secret) {
+			t.Errorf("Expected %q to be redacted: %q / %q", secret, dump.Request, dump.Response)
+		}
+	}
+	if !strings.HasPrefix(dump.Response, "HTTP/1.1 401 Unauthorized\r\n") ||
+		!strings.HasSuffix(dump.Response, "\r\n\r\ninvalid ") || !dump.ResponseTruncated {
+		t.Errorf("Expected the response body cut at 8 bytes, got %q", dump.Response)
+	}
+	if !dump.RequestTruncated || !strings.Contains(dump.Request, "Host: example.com\r\n") {
+		t.Errorf("Expected the request body cut at 8 bytes, got %q", dump.Request)
+	}
+
+	// 容量满时丢弃最旧的记录
+	d.SetRate(1)
+	for _, path := range []string{"/a", "/b", "/c"} {
+		ctx.Reset(fd, &Request{Method: "GET", Path: path})
+		ctx.SetDumper(d, []byte("GET "+path+" HTTP/1.1\r\n\r\n"))
+		ctx.String(200, "ok")
+		read()
+		ctx.EndRequest()
+	}
+	if dumps := d.Dumps(); len(dumps) != 2 || dumps[0].Path != "/c" || dumps[1].Path != "/b" {
+		t.Errorf("Expected the 2 newest dumps, got %+v", dumps)
+	}
+}
+
+// TestFDContextBasicAuth 测试 Basic 认证头解析
+func TestFDContextBasicAuth(t *testing.T) {
+	for _, tc := range []struct {
+		header, user, pass string
+		ok                 bool
+	}{
+		{"Basic YWxpY2U6b3BlbjpzZXNhbWU=", "alice", "open:sesame", true},
+		{"basic Ym9iOg==", "bob", "", true},
+		{"Bearer YWxpY2U6cHc=", "", "", false},
+		{"Basic bm9jb2xvbg==", "", "", false}, // 缺少冒号
+		{"Basic !!!", "", "", false},
+		{"", "", "", false},
+	} {
+		ctx := NewFDContext(-1, &Request{Method: "GET", Path: "/", ExtraHeaders: map[string]string{"Authorization": tc.header}})
+		user, pass, ok := ctx.BasicAuth()
+		if user != tc.user || pass != tc.pass || ok != tc.ok {
+			t.Errorf("%q: got (%q, %q, %v), want (%q, %q, %v)", tc.header, user, pass, ok, tc.user, tc.pass, tc.ok)
+		}
+	}
+
+	ctx := NewFDContext(-1, &Request{Method: "GET", Path: "/", ExtraHeaders: map[string]string{"Authorization": "Basic YWxpY2U6c2VjcmV0"}})
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, _, ok := ctx.BasicAuth(); !ok {
+			t.Fatal("Expected credentials")
+		}
+	})
+	if allocs > 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+// TestFDContextBearerAuth 测试 Bearer 令牌提取与 401/403 质询头
+func TestFDContextBearerAuth(t *testing.T) {
+	for _, tc := range []struct {
+		header, token string
+		ok            bool
+	}{
+		{"Bearer abc.def-ghi", "abc.def-ghi", true},
+		{"bearer  tok ", "tok", true},
+		{"Bearer ", "", false},
+		{"Bearer a b", "", false},
+		{"Basic YWxpY2U6cHc=", "", false},
+	} {
+		ctx := NewFDContext(-1, &Request{Method: "GET", Path: "/", ExtraHeaders: map[string]string{"Authorization": tc.header}})
+		if token, ok := ctx.BearerToken(); token != tc.token || ok != tc.ok {
+			t.Errorf("%q: got (%q, %v), want (%q, %v)", tc.header, token, ok, tc.token, tc.ok)
+		}
+	}
+
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+	ctx.Unauthorized(`api "v1"`)
+	if out := read(); !strings.HasPrefix(out, "HTTP/1.1 401 Unauthorized\r\n") ||
+		!strings.Contains(out, "Www-Authenticate: Bearer realm=\"api \\\"v1\\\"\"\r\n") {
+		t.Errorf("Unexpected 401 response %q", out)
+	}
+
+	// 携带了令牌：401 标明 invalid_token，403 标明 insufficient_scope
+	headers := map[string]string{"Authorization": "Bearer expired"}
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/", ExtraHeaders: headers})
+	ctx.Unauthorized("api")
+	if out := read(); !strings.Contains(out, "Www-Authenticate: Bearer realm=\"api\", error=\"invalid_token\"\r\n") {
+		t.Errorf("Expected an invalid_token challenge, got %q", out)
+	}
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/", ExtraHeaders: headers})
+	ctx.Forbidden()
+	if out := read(); !strings.HasPrefix(out, "HTTP/1.1 403 Forbidden\r\n") ||
+		!strings.Contains(out, "Www-Authenticate: Bearer error=\"insufficient_scope\"\r\n") {
+		t.Errorf("Expected an insufficient_scope challenge, got %q", out)
+	}
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	ctx.Forbidden()
+	if out := read(); strings.Contains(out, "WWW-Authenticate") {
+		t.Errorf("Expected no challenge without a token, got %q", out)
+	}
+}
+
+// TestFDContextResponseSize 测试响应状态码与写出字节数统计
+func TestFDContextResponseSize(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+	ctx.Status(201)
+	if ctx.ResponseStatus() != 0 || ctx.ResponseSize() != 0 {
+		t.Error("Expected no status or size before the response is written")
+	}
+	ctx.String(202, "accepted")
+	out := read()
+	if ctx.ResponseStatus() != 202 || ctx.ResponseSize() != int64(len(out)) {
+		t.Errorf("Expected 202 and %d bytes, got %d and %d", len(out), ctx.ResponseStatus(), ctx.ResponseSize())
+	}
+
+	// 流水线：入队时计数，刷出时不重复计数
+	var batch []byte
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/a"})
+	ctx.SetBatch(&batch)
+	ctx.String(200, "a")
+	queued := int64(len(batch))
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/b"})
+	ctx.SetBatch(&batch)
+	ctx.String(200, "bb")
+	ctx.FlushBatch()
+	out = read()
+	if ctx.ResponseSize() != int64(len(out))-queued {
+		t.Errorf("Expected %d bytes for the second response, got %d", int64(len(out))-queued, ctx.ResponseSize())
+	}
+}
+
+// TestFDContextClientIP 测试可信代理下的客户端 IP 解析
+func TestFDContextClientIP(t *testing.T) {
+	proxies, err := NewTrustedProxies("10.0.0.0/8", "192.168.1.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("Expected an invalid CIDR to fail")
+	}
+
+	clientIP := func(peer [4]byte, headers map[string]string) string {
+		ctx := NewFDContext(-1, &Request{Method: "GET", Path: "/", ExtraHeaders: headers})
+		ctx.SetPeer(&syscall.SockaddrInet4{Addr: peer})
+		ctx.SetTrustedProxies(proxies)
+		return ctx.ClientIP()
+	}
+
+	untrusted := [4]byte{203, 0, 113, 9}
+	proxy := [4]byte{10, 1, 2, 3}
+	cases := []struct {
+		peer    [4]byte
+		headers map[string]string
+		want    string
+	}{
+		{untrusted, map[string]string{"X-Forwarded-For": "1.2.3.4"}, "203.0.113.9"},
+		{proxy, nil, "10.1.2.3"},
+		{proxy, map[string]string{"X-Forwarded-For": "1.2.3.4"}, "1.2.3.4"},
+		// A spoofed first entry is skipped: the rightmost untrusted hop wins
+		{proxy, map[string]string{"X-Forwarded-For": "6.6.6.6, 1.2.3.4, 10.9.9.9"}, "1.2.3.4"},
+		{proxy, map[string]string{"x-forwarded-for": "1.2.3.4"}, "1.2.3.4"},
+		{proxy, map[string]string{"X-Real-IP": "5.6.7.8"}, "5.6.7.8"},
+		{proxy, map[string]string{"X-Forwarded-For": "garbage"}, "10.1.2.3"},
+		{[4]byte{192, 168, 1, 5}, map[string]string{"X-Forwarded-For": "::ffff:1.2.3.4"}, "1.2.3.4"},
+	}
+	for _, tc := range cases {
+		if got := clientIP(tc.peer, tc.headers); got != tc.want {
+			t.Errorf("peer %v, headers %v: got %q, want %q", tc.peer, tc.headers, got, tc.want)
+		}
+	}
+}
+
+// TestAssets 测试静态资源指纹与清单
+func TestAssets(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "css"), 0o755)
+	os.WriteFile(filepath.Join(dir, "css", "app.css"), []byte("body{}"), 0o644)
+
+	a, err := NewAssets(AssetConfig{Dir: dir, Prefix: "/assets/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := a.URL("/css/app.css")
+	if u != "/assets/css/app.7c98040a5416.css" {
+		t.Errorf("Expected a fingerprinted URL, got %q", u)
+	}
+	if a.URL("missing.js") != "/assets/missing.js" || a.Manifest()["css/app.css"] != u {
+		t.Errorf("Unexpected manifest %v", a.Manifest())
+	}
+	if fn, ok := a.FuncMap()["asset"].(func(string) string); !ok || fn("css/app.css") != u {
+		t.Error("FuncMap should resolve asset URLs")
+	}
+
+	ctx := NewFDContext(1, &Request{Method: "GET", Path: "/"})
+	if ctx.Asset("css/app.css") != "css/app.css" {
+		t.Error("Without assets, Asset should return the name")
+	}
+	ctx.SetAssets(a)
+	if ctx.Asset("css/app.css") != u {
+		t.Error("Asset should resolve through the engine's assets")
+	}
+}
+
+// TestFDContextAsync 测试异步响应
+func TestFDContextAsync(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+
+	if ctx.IsAsync() {
+		t.Fatal("New context should not be async")
+	}
+
+	handle := ctx.Async()
+	if !ctx.IsAsync() || ctx.Async() != handle {
+		t.Fatal("Async should return the same pending handle")
+	}
+
+	completed := false
+	handle.OnComplete(func() { completed = true })
+
+	if err := handle.Respond(func(c Context) { c.String(200, "done") }); err != nil {
+		t.Fatalf("Respond failed: %v", err)
+	}
+	if !completed || !handle.Done() {
+		t.Error("Completion hook should run after Respond")
+	}
+	if out := read(); !strings.HasSuffix(out, "done") {
+		t.Errorf("Expected deferred body, got %q", out)
+	}
+
+	if err := handle.Fail(500, "late"); err != ErrAsyncCompleted {
+		t.Errorf("Expected ErrAsyncCompleted, got %v", err)
+	}
+
+	ctx.Reset(fd, &Request{})
+	if ctx.IsAsync() {
+		t.Error("Reset should clear the async handle")
+	}
+}
+
+// TestFDContextDetach 测试将处理函数移交工作池
+func TestFDContextDetach(t *testing.T) {
+	ctx := NewFDContext(1, &Request{Method: "GET", Path: "/"})
+	if ctx.Detached() != nil {
+		t.Fatal("New context should have no detached handler")
+	}
+
+	ran := false
+	ctx.Detach(func(c Context) { ran = true })
+	if !ctx.IsAsync() || ctx.Detached() == nil {
+		t.Fatal("Detach should park the request and keep the handler")
+	}
+	if ran {
+		t.Error("Detach should not run the handler itself")
+	}
+
+	ctx.Reset(1, &Request{})
+	if ctx.Detached() != nil || ctx.IsAsync() {
+		t.Error("Reset should clear the detached handler")
+	}
+}
+
+// TestFDContextAsyncRearm 测试在 Respond 中再次调用 Async 延后完成
+func TestFDContextAsyncRearm(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+
+	var order []string
+	handle := ctx.Async()
+	handle.OnComplete(func() { order = append(order, "first") })
+	handle.OnComplete(func() { order = append(order, "second") })
+
+	// An offloaded handler going asynchronous
+	handle.Respond(func(c Context) { c.Async() })
+	if handle.Done() || len(order) > 0 {
+		t.Fatal("Respond should leave a rearmed handle pending")
+	}
+
+	if err := handle.Respond(func(c Context) { c.String(200, "later") }); err != nil {
+		t.Fatalf("Respond failed: %v", err)
+	}
+	if !handle.Done() || strings.Join(order, ",") != "first,second" {
+		t.Errorf("Completion hooks should run in order, got %v", order)
+	}
+	if out := read(); !strings.HasSuffix(out, "later") {
+		t.Errorf("Expected deferred body, got %q", out)
+	}
+}
+
+// TestStatusText 测试状态行文本与自定义文本
+func TestStatusText(t *testing.T) {
+	for code, want := range map[int]string{
+		204: "No Content",
+		418: "I'm a teapot",
+		503: "Service Unavailable",
+		599: "status code 599",
+	} {
+		if got := StatusText(code); got != want {
+			t.Errorf("StatusText(%d) = %q, want %q", code, got, want)
+		}
+	}
+
+	RegisterStatusText(499, "Client Closed Request")
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+	ctx.String(499, "")
+	if out := read(); !strings.HasPrefix(out, "HTTP/1.1 499 Client Closed Request\r\n") {
+		t.Errorf("Expected the registered text, got %q", out)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for text with CRLF")
+		}
+	}()
+	RegisterStatusText(299, "Injected\r\nX-Evil: 1")
+}
+
+// TestFDContextServerHeader 测试 Date 与 Server 响应头
+func TestFDContextServerHeader(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+	ctx.SetServer("fast-server")
+	ctx.String(200, "ok")
+	out := read()
+	if !strings.Contains(out, "\r\nServer: fast-server\r\n") || !strings.Contains(out, "\r\nDate: ") {
+		t.Errorf("Expected Date and Server headers, got %q", out)
+	}
+
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	ctx.SetServer("evil\r\nX-Injected: 1")
+	ctx.String(200, "ok")
+	if out := read(); strings.Contains(out, "Server:") {
+		t.Errorf("Expected no Server header, got %q", out)
+	}
+}
+
+// TestFDContextNoContent 测试无响应体的快速路径
+func TestFDContextNoContent(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "POST", Path: "/ack"})
+	ctx.NoContent(204)
+	out := read()
+	if !strings.HasPrefix(out, "HTTP/1.1 204 No Content\r\nDate: ") || !strings.HasSuffix(out, " GMT\r\n\r\n") {
+		t.Errorf("Unexpected 204 response %q", out)
+	}
+
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/health"})
+	ctx.SetKeepAlive(false)
+	ctx.NoContent(200)
+	if out := read(); !strings.HasSuffix(out, "\r\nConnection: close\r\nContent-Length: 0\r\n\r\n") {
+		t.Errorf("Expected an empty 200 closing the connection, got %q", out)
+	}
+
+	// 有响应头时走完整路径
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	ctx.SetHeader("X-Ack", "1")
+	ctx.Status(202)
+	ctx.WriteStatus()
+	if out := read(); !strings.HasPrefix(out, "HTTP/1.1 202 Accepted\r\n") || !strings.Contains(out, "X-Ack: 1\r\n") {
+		t.Errorf("Expected 202 with the header, got %q", out)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		ctx.Reset(fd, nil)
+		ctx.NoContent(204)
+	})
+	read()
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %.0f", allocs)
+	}
+}
+
+// TestFDContextHeadOmitsBody 测试 HEAD 请求不返回响应体
+func TestFDContextHeadOmitsBody(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "HEAD", Path: "/"})
+
+	ctx.String(200, "Hello, World!")
+
+	out := read()
+	if !strings.Contains(out, "Content-Length: 13\r\n") {
+		t.Errorf("Expected Content-Length of the omitted body, got %q", out)
+	}
+	if !strings.HasSuffix(out, "\r\n\r\n") {
+		t.Errorf("Expected no body for HEAD, got %q", out)
+	}
+}
+
 // BenchmarkFDContextSetParam 参数设置基准测试
 func BenchmarkFDContextSetParam(b *testing.B) {
 	req := &Request{
@@ -231,3 +939,1534 @@ func BenchmarkFDContextJSON(b *testing.B) {
 		ctx.Reset(1, req)
 	}
 }
+
+// readAll 读取直到获得 n 个字节或没有更多数据
+func readAll(read func() string, n int) string {
+	var sb strings.Builder
+	for sb.Len() < n {
+		chunk := read()
+		if chunk == "" {
+			break
+		}
+		sb.WriteString(chunk)
+	}
+	return sb.String()
+}
+
+// TestFDContextLargeBodyWritev 测试大响应体通过 writev 发送
+func TestFDContextLargeBodyWritev(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+
+	body := strings.Repeat("x", writevThreshold+100)
+	ctx.String(200, body)
+
+	out := readAll(read, len(body)+100)
+	if !strings.Contains(out, "Content-Length: 16484\r\n") {
+		t.Errorf("Expected Content-Length header, got %q", out[:min(len(out), 200)])
+	}
+	if !strings.HasSuffix(out, "\r\n\r\n"+body) {
+		t.Error("Expected header followed by the full body")
+	}
+	if len(ctx.responseBuf) > writevThreshold {
+		t.Errorf("Large body should not be copied into the header buffer (%d bytes)", len(ctx.responseBuf))
+	}
+}
+
+// TestFDContextServeFile 测试 sendfile 文件响应
+func TestFDContextServeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.html")
+	content := "<h1>" + strings.Repeat("hello ", 1000) + "</h1>"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+	if err := ctx.ServeFile(path); err != nil {
+		t.Fatalf("ServeFile: %v", err)
+	}
+
+	out := readAll(read, len(content)+100)
+	if !strings.Contains(out, "Content-Type: text/html; charset=utf-8\r\n") {
+		t.Errorf("Expected text/html, got %q", out[:min(len(out), 200)])
+	}
+	if !strings.HasSuffix(out, "\r\n\r\n"+content) {
+		t.Error("Expected header followed by the file content")
+	}
+
+	// 替换后的文件不从缓存的描述符发送
+	replaced := filepath.Join(t.TempDir(), "index.html")
+	if err := os.WriteFile(replaced, []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(replaced, path); err != nil {
+		t.Fatal(err)
+	}
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	if err := ctx.ServeFile(path); err != nil {
+		t.Fatalf("ServeFile after replace: %v", err)
+	}
+	if out := read(); !strings.HasSuffix(out, "\r\n\r\nnew") {
+		t.Errorf("Expected the new content, got %q", out)
+	}
+
+	// 不存在的文件返回 404
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	if err := ctx.ServeFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+	if out := read(); !strings.HasPrefix(out, "HTTP/1.1 404 Not Found\r\n") {
+		t.Errorf("Expected 404, got %q", out)
+	}
+}
+
+// TestFDContextAttachment 测试文件下载的 Content-Disposition
+func TestFDContextAttachment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.csv")
+	if err := os.WriteFile(path, []byte("id,name\n1,a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+	if err := ctx.Attachment(path, ""); err != nil {
+		t.Fatalf("Attachment: %v", err)
+	}
+	out := read()
+	if !strings.Contains(out, "Content-Disposition: attachment; filename=\"export.csv\"\r\n") ||
+		!strings.Contains(out, "Content-Type: text/csv") || !strings.HasSuffix(out, "1,a\n") {
+		t.Errorf("Unexpected download response %q", out)
+	}
+
+	// 非 ASCII 文件名使用 filename*
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	ctx.Inline(path, "报表 \"Q1\".csv")
+	want := `inline; filename="__ \"Q1\".csv"; filename*=UTF-8''%E6%8A%A5%E8%A1%A8%20%22Q1%22.csv`
+	if out := read(); !strings.Contains(out, "Content-Disposition: "+want+"\r\n") {
+		t.Errorf("Expected %s, got %q", want, out)
+	}
+
+	// 文件不存在时 404 不带 Content-Disposition
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	if err := ctx.Attachment(path+".missing", "x.csv"); err == nil {
+		t.Error("Expected error for missing file")
+	}
+	if out := read(); !strings.HasPrefix(out, "HTTP/1.1 404") || strings.Contains(out, "Content-Disposition") {
+		t.Errorf("Expected a plain 404, got %q", out)
+	}
+}
+
+// TestFDContextSpliceFrom 测试从上游连接转发字节
+func TestFDContextSpliceFrom(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	payload := "HTTP/1.1 200 OK\r\nContent-Length: 100000\r\n\r\n" + strings.Repeat("p", 100000)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte(payload))
+		conn.Close()
+	}()
+
+	upstream, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+
+	n, err := ctx.SpliceFrom(upstream.(*net.TCPConn), -1)
+	if err != nil {
+		t.Fatalf("SpliceFrom: %v", err)
+	}
+	if n != int64(len(payload)) {
+		t.Errorf("Expected %d bytes relayed, got %d", len(payload), n)
+	}
+	if !ctx.Written() {
+		t.Error("Context should be written after SpliceFrom")
+	}
+	if out := readAll(read, len(payload)); out != payload {
+		t.Errorf("Relayed bytes differ (got %d bytes)", len(out))
+	}
+}
+
+// TestFDContextResponseDefaults 测试路由级响应默认值（压缩、缓存、内容类型）
+func TestFDContextResponseDefaults(t *testing.T) {
+	fd, read := newSocketPair(t)
+	req := &Request{Method: "GET", Path: "/", ExtraHeaders: map[string]string{"Accept-Encoding": "br, gzip"}}
+	ctx := NewFDContext(fd, req)
+	ctx.SetResponseDefaults(&ResponseDefaults{
+		Compress:     true,
+		CacheControl: "public, max-age=60",
+		ContentType:  "text/csv",
+	})
+
+	body := strings.Repeat("a,b,c\n", 500)
+	ctx.String(200, body)
+
+	out := readAll(read, 1)
+	header, compressed, _ := strings.Cut(out, "\r\n\r\n")
+	for _, want := range []string{"Content-Encoding: gzip", "Vary: Accept-Encoding", "Cache-Control: public, max-age=60", "Content-Type: text/csv"} {
+		if !strings.Contains(header, want+"\r\n") {
+			t.Errorf("Expected %q in %q", want, header)
+		}
+	}
+	zr, err := gzip.NewReader(strings.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	plain, _ := io.ReadAll(zr)
+	if string(plain) != body {
+		t.Error("Decompressed body differs")
+	}
+
+	// 客户端不接受 gzip，处理器自定义 Cache-Control
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	ctx.SetResponseDefaults(&ResponseDefaults{Compress: true, CacheControl: "public, max-age=60"})
+	ctx.SetHeader("Cache-Control", "no-store")
+	ctx.String(200, body)
+
+	out = readAll(read, len(body))
+	if strings.Contains(out, "Content-Encoding") || !strings.HasSuffix(out, body) {
+		t.Error("Expected uncompressed body without Accept-Encoding")
+	}
+	if !strings.Contains(out, "Cache-Control: no-store\r\n") || strings.Contains(out, "max-age") {
+		t.Errorf("Expected handler Cache-Control to win, got %q", out[:strings.Index(out, "\r\n\r\n")])
+	}
+}
+
+// TestFDContextCompression 测试按 Accept-Encoding 协商的响应压缩
+func TestFDContextCompression(t *testing.T) {
+	fd, read := newSocketPair(t)
+	RegisterCompressor("x-test", func() Compressor { return gzip.NewWriter(nil) })
+	body := strings.Repeat("compress me ", 200)
+
+	tests := []struct {
+		accept      string
+		contentType string
+		cfg         *Compression
+		encoding    string
+	}{
+		{"gzip;q=0.5, deflate", "text/plain", &Compression{}, "deflate"},
+		{"gzip, deflate", "text/plain", &Compression{}, "gzip"},
+		{"*;q=0.1, gzip;q=0", "text/plain", &Compression{}, "deflate"},
+		{"identity", "text/plain", &Compression{}, ""},
+		{"x-test, gzip", "text/plain", &Compression{Encodings: []string{"x-test", "gzip"}}, "x-test"},
+		{"gzip", "text/plain", &Compression{MinBytes: 10000}, ""},
+		{"gzip", "image/png", &Compression{}, ""},
+		{"gzip", "text/plain", nil, ""},
+	}
+	for _, tt := range tests {
+		ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/", ExtraHeaders: map[string]string{"Accept-Encoding": tt.accept}})
+		ctx.SetCompression(tt.cfg)
+		ctx.Data(200, tt.contentType, []byte(body))
+
+		out := readAll(read, 1)
+		header, payload, _ := strings.Cut(out, "\r\n\r\n")
+		if tt.encoding == "" {
+			if strings.Contains(header, "Content-Encoding") {
+				t.Errorf("%q %s: expected no compression, got %q", tt.accept, tt.contentType, header)
+			}
+			continue
+		}
+		if !strings.Contains(header, "Content-Encoding: "+tt.encoding+"\r\n") {
+			t.Errorf("%q: expected %s, got %q", tt.accept, tt.encoding, header)
+			continue
+		}
+		var zr io.Reader
+		if tt.encoding == "deflate" {
+			zr, _ = zlib.NewReader(strings.NewReader(payload))
+		} else {
+			zr, _ = gzip.NewReader(strings.NewReader(payload))
+		}
+		if plain, _ := io.ReadAll(zr); string(plain) != body {
+			t.Errorf("%q: decompressed body differs", tt.accept)
+		}
+	}
+}
+
+// TestFDContextETag 测试自动 ETag 与条件请求
+func TestFDContextETag(t *testing.T) {
+	fd, read := newSocketPair(t)
+	body := strings.Repeat("versioned ", 200)
+	get := func(headers map[string]string, prepare func(ctx *FDContext)) string {
+		ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/doc", ExtraHeaders: headers})
+		prepare(ctx)
+		return readAll(read, 1)
+	}
+	etagOf := func(out string) string {
+		_, rest, _ := strings.Cut(out, "Etag: ")
+		tag, _, _ := strings.Cut(rest, "\r\n")
+		return tag
+	}
+
+	strong := func(ctx *FDContext) {
+		ctx.SetAutoETag(ETagStrong)
+		ctx.String(200, body)
+	}
+	tag := etagOf(get(nil, strong))
+	if !strings.HasPrefix(tag, `"`) {
+		t.Fatalf("Expected a strong ETag, got %q", tag)
+	}
+	if out := get(map[string]string{"If-None-Match": `"other", ` + tag}, strong); !strings.HasPrefix(out, "HTTP/1.1 304") || strings.Contains(out, "versioned") {
+		t.Errorf("Expected 304 for a matching If-None-Match, got %q", out)
+	}
+
+	// 压缩后的强 ETag 区分编码，但仍与未压缩的版本匹配
+	compressed := func(ctx *FDContext) {
+		ctx.SetCompression(&Compression{})
+		strong(ctx)
+	}
+	gzTag := etagOf(get(map[string]string{"Accept-Encoding": "gzip"}, compressed))
+	if gzTag != tag[:len(tag)-1]+`-gzip"` {
+		t.Errorf("Expected %q to name the gzip representation of %q", gzTag, tag)
+	}
+	if out := get(map[string]string{"Accept-Encoding": "gzip", "If-None-Match": gzTag}, compressed); !strings.HasPrefix(out, "HTTP/1.1 304") {
+		t.Errorf("Expected 304 for the encoded tag, got %q", out)
+	}
+
+	weak := func(ctx *FDContext) {
+		ctx.SetAutoETag(ETagWeak)
+		ctx.String(200, body)
+	}
+	if wtag := etagOf(get(nil, weak)); wtag != "W/"+tag {
+		t.Errorf("Expected weak tag W/%s, got %q", tag, wtag)
+	}
+
+	// 处理器设置的 Last-Modified
+	lastModified := func(ctx *FDContext) {
+		ctx.SetHeader("Last-Modified", "Mon, 05 Oct 2026 10:00:00 GMT")
+		ctx.String(200, body)
+	}
+	if out := get(map[string]string{"If-Modified-Since": "Mon, 05 Oct 2026 10:00:00 GMT"}, lastModified); !strings.HasPrefix(out, "HTTP/1.1 304") {
+		t.Errorf("Expected 304 when not modified since, got %q", out)
+	}
+	if out := get(map[string]string{"If-Modified-Since": "Sun, 04 Oct 2026 10:00:00 GMT"}, lastModified); !strings.HasPrefix(out, "HTTP/1.1 200") {
+		t.Errorf("Expected 200 when modified since, got %q", out[:20])
+	}
+
+	// ServeFile 使用修改时间与大小
+	path := filepath.Join(t.TempDir(), "asset.txt")
+	os.WriteFile(path, []byte("asset"), 0o644)
+	file := func(ctx *FDContext) {
+		ctx.SetAutoETag(ETagStrong)
+		ctx.ServeFile(path)
+	}
+	out := get(nil, file)
+	if !strings.Contains(out, "Last-Modified: ") || !strings.HasSuffix(out, "asset") {
+		t.Fatalf("Expected Last-Modified and the file, got %q", out)
+	}
+	if out := get(map[string]string{"If-None-Match": etagOf(out)}, file); !strings.HasPrefix(out, "HTTP/1.1 304") || strings.Contains(out, "Content-Length") {
+		t.Errorf("Expected a bodiless 304 for the file, got %q", out)
+	}
+}
+
+// TestFDContextDisconnected 测试客户端断开检测
+func TestFDContextDisconnected(t *testing.T) {
+	fd, peer, err := netfd.Pair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer netfd.Close(fd)
+
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/export"})
+	reqCtx := ctx.Context()
+
+	// 未读的请求数据不算断开
+	netfd.Write(peer, []byte("pipelined"))
+	if ctx.Disconnected() {
+		t.Fatal("Connected client reported as disconnected")
+	}
+
+	netfd.Close(peer)
+	netfd.Read(fd, make([]byte, 16))
+	if !ctx.Disconnected() {
+		t.Fatal("Expected disconnect after peer close")
+	}
+	select {
+	case <-reqCtx.Done():
+	default:
+		t.Fatal("Context should be canceled on disconnect")
+	}
+	if cause := context.Cause(reqCtx); !errors.Is(cause, ErrClientDisconnected) {
+		t.Errorf("Expected ErrClientDisconnected cause, got %v", cause)
+	}
+
+	// EndRequest 取消新请求的 Context
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	reqCtx = ctx.Context()
+	ctx.EndRequest()
+	if !errors.Is(context.Cause(reqCtx), context.Canceled) {
+		t.Errorf("Expected context.Canceled after EndRequest, got %v", context.Cause(reqCtx))
+	}
+}
+
+// TestRequestLength 测试流水线请求的分帧
+func TestRequestLength(t *testing.T) {
+	tests := []struct {
+		data string
+		want int
+	}{
+		{"GET / HTTP/1.1\r\nHost: x", -1},
+		{"GET / HTTP/1.1\r\nHost: x\r\n\r\nGET /b HTTP/1.1\r\n\r\n", 27},
+		{"POST / HTTP/1.1\r\ncontent-length: 5\r\n\r\nhelloGET", 43},
+		{"POST / HTTP/1.1\r\nContent-Length: 10\r\n\r\nhello", 49},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello", -1},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\nGET", 62},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n", 51},
+	}
+	for _, tt := range tests {
+		if got := RequestLength([]byte(tt.data)); got != tt.want {
+			t.Errorf("RequestLength(%q) = %d, want %d", tt.data, got, tt.want)
+		}
+	}
+
+	req, err := ParseRequest([]byte("POST / HTTP/1.1\r\nContent-Length: 5\r\n\r\nhello"))
+	if err != nil || req.HeaderSize != 38 {
+		t.Errorf("Expected HeaderSize 38, got %v (%v)", req, err)
+	}
+}
+
+// TestChunkedDecoder 测试分多次到达的分块请求体被增量解码，结果与一次性解码一致
+func TestChunkedDecoder(t *testing.T) {
+	body := "5;ext=1\r\nhello\r\n6\r\n world\r\n0\r\nX-Sum: abc\r\n\r\nGET"
+	for _, step := range []int{1, 2, 3, 7, len(body)} {
+		var d ChunkedDecoder
+		var out bytes.Buffer
+		pending := []byte(nil)
+		for i := 0; i < len(body) && !d.Done(); i += step {
+			pending = append(pending, body[i:min(i+step, len(body))]...)
+			n, err := d.Decode(pending, &out)
+			if err != nil {
+				t.Fatalf("step %d: %v", step, err)
+			}
+			pending = pending[n:]
+		}
+		req := AcquireRequest()
+		d.SetTrailers(req)
+		if !d.Done() || out.String() != "hello world" || d.Size() != 11 || req.Trailer("X-Sum") != "abc" {
+			t.Errorf("step %d: done %v, body %q, size %d, trailer %q", step, d.Done(), out.String(), d.Size(), req.Trailer("X-Sum"))
+		}
+		ReleaseRequest(req)
+	}
+
+	for _, bad := range []string{"zz\r\n", "5\r\nhelloXY", "1000000000000000\r\n"} {
+		var d ChunkedDecoder
+		if _, err := d.Decode([]byte(bad), nil); err != ErrInvalidRequest {
+			t.Errorf("Decode(%q) = %v, want ErrInvalidRequest", bad, err)
+		}
+	}
+}
+
+// TestRequestFrameMixedLineEndings 测试头部在第一个空行处结束，无论以 CRLF 还是 LF 结尾，
+// 不会把后面的流水线请求并入前一个请求
+func TestRequestFrameMixedLineEndings(t *testing.T) {
+	tests := []struct {
+		data       string
+		headerLen  int
+		secondPath string
+	}{
+		{"GET /a HTTP/1.1\nHost: a\n\nPOST /b HTTP/1.1\r\nHost: b\r\nContent-Length: 5\r\n\r\nhello", 25, "/b"},
+		{"GET /a HTTP/1.1\r\nHost: a\r\n\r\nPOST /b HTTP/1.1\nHost: b\nContent-Length: 5\n\nhello", 28, "/b"},
+		{"GET /a HTTP/1.1\r\nHost: a\n\r\nPOST /b HTTP/1.1\r\nContent-Length: 5\r\n\r\nhello", 27, "/b"},
+	}
+	for _, tt := range tests {
+		data := []byte(tt.data)
+		headerLen, bodyLen := RequestFrame(data)
+		if headerLen != tt.headerLen || bodyLen != 0 {
+			t.Errorf("RequestFrame(%q) = (%d, %d), want (%d, 0)", tt.data, headerLen, bodyLen, tt.headerLen)
+			continue
+		}
+		if n := RequestLength(data); n != tt.headerLen {
+			t.Errorf("RequestLength(%q) = %d, want %d", tt.data, n, tt.headerLen)
+		}
+		first, err := ParseRequest(data)
+		if err != nil || first.Path != "/a" || first.HeaderSize != tt.headerLen || len(first.Body) != 0 {
+			t.Errorf("First request of %q: %+v (%v)", tt.data, first, err)
+			continue
+		}
+		second, err := ParseRequest(data[tt.headerLen:])
+		if err != nil || second.Method != "POST" || second.Path != tt.secondPath || string(second.Body) != "hello" {
+			t.Errorf("Second request of %q: %+v (%v)", tt.data, second, err)
+		}
+	}
+}
+
+// TestParseRequestIncomplete 测试请求体未收全时返回 ErrIncomplete
+func TestParseRequestIncomplete(t *testing.T) {
+	for _, data := range []string{
+		"POST / HTTP/1.1",
+		"POST / HTTP/1.1\r\nContent-Length: 5\r\n",
+		"POST / HTTP/1.1\r\nContent-Length: 10\r\n\r\nhello",
+	} {
+		if _, err := ParseRequest([]byte(data)); err != ErrIncomplete {
+			t.Errorf("ParseRequest(%q): expected ErrIncomplete, got %v", data, err)
+		}
+	}
+
+	// 只取 Content-Length 长度的请求体，后续流水线字节不计入
+	req, err := ParseRequest([]byte("POST / HTTP/1.1\r\nContent-Length: 5\r\n\r\nhelloGET / HTTP/1.1\r\n\r\n"))
+	if err != nil || string(req.Body) != "hello" {
+		t.Errorf("Expected body %q, got %v (%v)", "hello", req, err)
+	}
+
+	// 仅解析头部时不等待请求体
+	req, err = ParseRequestHeader([]byte("POST / HTTP/1.1\r\nContent-Length: 100\r\n\r\n"))
+	if err != nil || req.ContentLength != "100" || len(req.Body) != 0 {
+		t.Errorf("Expected the header alone to parse, got %v (%v)", req, err)
+	}
+
+	// 仅以 LF 分隔的请求头同样被解析
+	req, err = ParseRequest([]byte("POST / HTTP/1.1\nContent-Length: 2\n\nhi"))
+	if err != nil || string(req.Body) != "hi" {
+		t.Errorf("Expected body %q, got %v (%v)", "hi", req, err)
+	}
+}
+
+// TestParseRequestChunked 测试分块请求体的解码
+func TestParseRequestChunked(t *testing.T) {
+	head := "POST /upload HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n"
+	tests := []struct {
+		body string
+		want string
+		err  error
+	}{
+		{"5\r\nhello\r\n7;ext=1\r\n, world\r\n0\r\n\r\n", "hello, world", nil},
+		{"A\r\n0123456789\r\n0\r\nX-Checksum: abc\r\n\r\n", "0123456789", nil},
+		{"5\nhello\n0\n\n", "hello", nil},
+		{"0\r\n\r\n", "", nil},
+		{"5\r\nhel", "", ErrIncomplete},
+		{"5\r\nhello\r\n0\r\n", "", ErrIncomplete},
+		{"5\r\nhelloXX\r\n0\r\n\r\n", "", ErrInvalidRequest},
+		{"-1\r\n\r\n", "", ErrInvalidRequest},
+		{"fffffffffffffffff\r\n", "", ErrInvalidRequest},
+	}
+	for _, tt := range tests {
+		req, err := ParseRequest([]byte(head + tt.body))
+		if err != tt.err {
+			t.Errorf("%q: expected error %v, got %v", tt.body, tt.err, err)
+			continue
+		}
+		if err == nil && string(req.Body) != tt.want {
+			t.Errorf("%q: expected body %q, got %q", tt.body, tt.want, req.Body)
+		}
+	}
+
+	// 非 chunked 结尾的 Transfer-Encoding 无法分帧
+	if _, err := ParseRequest([]byte("POST / HTTP/1.1\r\nTransfer-Encoding: gzip\r\n\r\nabc")); err != ErrInvalidRequest {
+		t.Errorf("Expected ErrInvalidRequest, got %v", err)
+	}
+}
+
+// TestTrailers 测试 chunked 请求的 trailer 解析与响应 trailer
+func TestTrailers(t *testing.T) {
+	data := "POST /rpc HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"5\r\nhello\r\n0\r\nx-checksum: abc\r\nGrpc-Status:  0 \r\n\r\n"
+	req, err := ParseRequest([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseRequest: %v", err)
+	}
+	if req.Trailer("X-Checksum") != "abc" || req.Trailer("grpc-status") != "0" {
+		t.Errorf("Expected trailers, got %v", req.Trailers)
+	}
+	// trailer 不会混入请求头
+	if req.Header("X-Checksum") != "" || string(req.Body) != "hello" {
+		t.Errorf("Expected the trailer apart from headers and body %q", req.Body)
+	}
+
+	// 小 Writer 响应带 trailer 时改为 chunked 发送
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "POST", Path: "/rpc", Proto: "HTTP/1.1"})
+	ctx.SetTrailer("grpc-status", "1")
+	io.WriteString(ctx.Writer(), "reply")
+	ctx.SetTrailer("Grpc-Status", "0")
+	ctx.SetTrailer("Grpc-Message", "ok")
+	ctx.SetTrailer("X-Bad", "a\r\nb")
+	ctx.Finish()
+	out := read()
+	if !strings.Contains(out, "Trailer: Grpc-Status, Grpc-Message\r\n") || !strings.Contains(out, "Transfer-Encoding: chunked\r\n") {
+		t.Errorf("Expected a declared trailer and a chunked body, got %q", out)
+	}
+	if !strings.HasSuffix(out, "5\r\nreply\r\n0\r\nGrpc-Status: 0\r\nGrpc-Message: ok\r\n\r\n") {
+		t.Errorf("Expected the trailers after the last chunk, got %q", out)
+	}
+
+	// HTTP/1.0 无法携带 trailer
+	fd, read = newSocketPair(t)
+	ctx = NewFDContext(fd, &Request{Method: "GET", Path: "/rows", Proto: "HTTP/1.0"})
+	ctx.SetTrailer("X-Count", "1")
+	ctx.Stream(func(w io.Writer) bool {
+		io.WriteString(w, "row\n")
+		return false
+	})
+	ctx.Finish()
+	if out := read(); strings.Contains(out, "X-Count: 1\r\n") || !strings.HasSuffix(out, "row\n") {
+		t.Errorf("Expected no trailers for HTTP/1.0, got %q", out)
+	}
+}
+
+// TestFDContextRetain 测试请求数据在缓冲区复用后仍可安全保留
+func TestFDContextRetain(t *testing.T) {
+	buf := []byte("POST /users/42?tab=posts HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\n\r\nhello")
+	req, err := ParseRequest(buf)
+	if err != nil {
+		t.Fatalf("ParseRequest: %v", err)
+	}
+	ctx := NewFDContext(1, req)
+	ctx.SetParam("id", req.Path[len("/users/"):])
+
+	body := ctx.BodyCopy()
+	retained := ctx.Retain()
+	if ctx.Retain() != retained || ctx.Request() != retained {
+		t.Error("Expected Retain to copy the request once")
+	}
+	path, id, tab := ctx.Path(), ctx.Param("id"), ctx.Query("tab")
+
+	// 模拟读缓冲区与请求对象被下一个请求复用
+	ReleaseRequest(req)
+	copy(buf, bytes.Repeat([]byte("x"), len(buf)))
+	next, _ := ParseRequest([]byte("POST / HTTP/1.1\r\nContent-Length: 5\r\n\r\nworld"))
+	defer ReleaseRequest(next)
+
+	if path != "/users/42" || id != "42" || tab != "posts" || ctx.Method() != "POST" {
+		t.Errorf("Expected retained values, got path %q id %q tab %q", path, id, tab)
+	}
+	if string(body) != "hello" || string(ctx.Body()) != "hello" {
+		t.Errorf("Expected the body to survive, got %q and %q", body, ctx.Body())
+	}
+}
+
+// TestDuplicateHeaders 测试重复请求头的合并与敏感头部的拒绝
+func TestDuplicateHeaders(t *testing.T) {
+	raw := "GET / HTTP/1.1\r\nHost: x\r\nAccept: text/html\r\nCookie: a=1\r\naccept: application/json\r\n" +
+		"Cookie: b=2\r\nContent-Length: 0\r\nContent-Length: 0\r\n\r\n"
+	req, err := ParseRequest([]byte(raw))
+	if err != nil {
+		t.Fatalf("ParseRequest: %v", err)
+	}
+	req.CombineRepeated()
+	if got := req.Header("Accept"); got != "text/html, application/json" {
+		t.Errorf("Expected the Accept values joined, got %q", got)
+	}
+	if got := req.Header("Cookie"); got != "a=1; b=2" {
+		t.Errorf("Expected the Cookie values joined with ;, got %q", got)
+	}
+	if got := req.HeaderValues("Accept"); len(got) != 1 || len(req.Repeated) != 0 {
+		t.Errorf("Expected one combined value, got %v", got)
+	}
+
+	// 重复的 Host 与不一致的 Content-Length 无法确定代理用了哪个
+	for _, raw := range []string{
+		"GET / HTTP/1.1\r\nHost: a\r\nhost: b\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 3\r\nContent-Length: 5\r\n\r\nhello",
+	} {
+		if _, err := ParseRequest([]byte(raw)); err != ErrInvalidRequest {
+			t.Errorf("%q: expected ErrInvalidRequest, got %v", raw, err)
+		}
+	}
+}
+
+// TestHTTP10 测试 HTTP/1.0 语义：无头部请求、keep-alive 协商与无 chunked 响应
+func TestHTTP10(t *testing.T) {
+	// 老式探针不带任何头部
+	for _, data := range []string{"GET /health HTTP/1.0\r\n\r\n", "GET /health HTTP/1.0\n\n"} {
+		req, err := ParseRequest([]byte(data))
+		if err != nil || req.Path != "/health" || req.HeaderSize != len(data) {
+			t.Errorf("%q: expected a request without headers, got %v", data, err)
+		}
+	}
+
+	tests := []struct {
+		proto, connection string
+		keepAlive         bool
+	}{
+		{"HTTP/1.1", "", true},
+		{"HTTP/1.1", "Close", false},
+		{"HTTP/1.1", "Upgrade, close", false},
+		{"HTTP/1.0", "", false},
+		{"HTTP/1.0", "Keep-Alive", true},
+		{"HTTP/1.0", "close", false},
+	}
+	for _, tt := range tests {
+		req := &Request{Proto: tt.proto, Connection: tt.connection}
+		if req.KeepAlive() != tt.keepAlive {
+			t.Errorf("%s Connection %q: expected keep-alive %v", tt.proto, tt.connection, tt.keepAlive)
+		}
+	}
+
+	// 保持连接的 HTTP/1.0 响应需声明 keep-alive
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/", Proto: "HTTP/1.0", Connection: "keep-alive"})
+	ctx.String(200, "ok")
+	if out := read(); !strings.Contains(out, "Connection: keep-alive\r\n") || !strings.Contains(out, "Content-Length: 2\r\n") {
+		t.Errorf("Expected Connection: keep-alive, got %q", out)
+	}
+
+	// 无长度的流式响应不能分块，只能以关闭连接结束
+	fd, read = newSocketPair(t)
+	ctx = NewFDContext(fd, &Request{Method: "GET", Path: "/", Proto: "HTTP/1.0", Connection: "keep-alive"})
+	ctx.Stream(func(w io.Writer) bool {
+		io.WriteString(w, "row\n")
+		return false
+	})
+	ctx.Finish()
+	out := read()
+	if strings.Contains(out, "chunked") || !strings.Contains(out, "Connection: close\r\n") || !strings.HasSuffix(out, "\r\n\r\nrow\n") {
+		t.Errorf("Expected an unframed body closing the connection, got %q", out)
+	}
+	if ctx.KeepAlive() {
+		t.Error("Expected keep-alive off after an unframed body")
+	}
+}
+
+// TestParseQuery 测试查询参数的零分配存储与溢出
+func TestParseQuery(t *testing.T) {
+	req, err := ParseRequest([]byte("GET /s?q=fast&page=2&q=server&flag&&x=a=b HTTP/1.1\r\nHost: x\r\n\r\n"))
+	if err != nil {
+		t.Fatalf("ParseRequest: %v", err)
+	}
+	if req.Path != "/s" || req.QueryValue("q") != "server" || req.QueryValue("page") != "2" || req.QueryValue("x") != "a=b" {
+		t.Errorf("Unexpected query: path %q q=%q page=%q x=%q", req.Path, req.QueryValue("q"), req.QueryValue("page"), req.QueryValue("x"))
+	}
+	if req.RawQuery != "q=fast&page=2&q=server&flag&&x=a=b" {
+		t.Errorf("Unexpected raw query %q", req.RawQuery)
+	}
+	if req.QueryLen() != 4 || req.QueryValue("flag") != "" || req.Query != nil {
+		t.Errorf("Expected 4 parameters in the fixed array, got %d (overflow %v)", req.QueryLen(), req.Query)
+	}
+
+	// 短查询串不分配内存
+	path := "/s?a=1&b=2&c=3&d=4&e=5&f=6&g=7&h=8"
+	if n := testing.AllocsPerRun(100, func() {
+		req.Reset()
+		parseQuery(req, path, 2)
+	}); n != 0 {
+		t.Errorf("Expected 0 allocations, got %v", n)
+	}
+
+	// 超出固定数组的参数放入 Query
+	req.Reset()
+	parseQuery(req, path+"&i=9&j=10&a=0", 2)
+	if req.QueryLen() != 10 || len(req.Query) != 2 || req.QueryValue("j") != "10" || req.QueryValue("a") != "0" {
+		t.Errorf("Expected 2 parameters in the overflow map, got %v", req.Query)
+	}
+	n := 0
+	for range req.QueryParams() {
+		n++
+	}
+	clone := req.Clone()
+	ReleaseRequest(req)
+	if n != 10 || clone.QueryValue("h") != "8" || clone.QueryValue("i") != "9" {
+		t.Errorf("Expected 10 parameters, got %d (clone h=%q)", n, clone.QueryValue("h"))
+	}
+}
+
+// TestParseRequestObsFold 测试折叠头部（obs-fold）的展开
+func TestParseRequestObsFold(t *testing.T) {
+	data := "GET / HTTP/1.1\r\n \r\nHost: x\r\nX-Long: a,\r\n  b,\r\n\tc\r\nAccept: */*\r\n\r\n"
+	req, err := ParseRequest([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseRequest: %v", err)
+	}
+	if v := req.Header("X-Long"); v != "a, b, c" {
+		t.Errorf("Expected the folded value unfolded, got %q", v)
+	}
+	if req.Host != "x" || req.Accept != "*/*" || len(req.ExtraHeaders) != 1 {
+		t.Errorf("Expected no garbage headers, got %v", req.ExtraHeaders)
+	}
+
+	// 折叠的分帧头部会被拒绝，RequestFrame 不会把续行当成头部
+	for _, data := range []string{
+		"POST / HTTP/1.1\r\nContent-Length:\r\n 5\r\n\r\nhello",
+		"POST / HTTP/1.1\r\nTransfer-Encoding: gzip,\r\n chunked\r\n\r\n0\r\n\r\n",
+	} {
+		if _, err := ParseRequest([]byte(data)); err != ErrInvalidRequest {
+			t.Errorf("%q: expected ErrInvalidRequest, got %v", data, err)
+		}
+	}
+	if _, bodyLen := RequestFrame([]byte("POST / HTTP/1.1\r\nX-A: b\r\n Content-Length: 5\r\n\r\n")); bodyLen != 0 {
+		t.Errorf("Expected a continuation line to be ignored, got body length %d", bodyLen)
+	}
+}
+
+// TestCheckStrict 测试严格模式下的请求校验（防请求走私）
+func TestCheckStrict(t *testing.T) {
+	tests := []struct {
+		data string
+		ok   bool
+	}{
+		{"GET /a?b=c HTTP/1.1\r\nHost: x\r\n\r\n", true},
+		{"GET / HTTP/1.0\r\n\r\n", true},
+		{"POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\nContent-Length: 5\r\n\r\nhello", true},
+		{"POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: gzip, chunked\r\n\r\n", true},
+		{"GET / HTTP/1.1\nHost: x\n\n", false},
+		{"GET / HTTP/1.1\r\nHost: x\nX-A: b\r\n\r\n", false},
+		{"GET / HTTP/1.1\r\nHost : x\r\n\r\n", false},
+		{"GET / HTTP/1.1\r\nHost: x\r\nX-A: b\r\n c\r\n\r\n", false},
+		{"POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\nTransfer-Encoding: chunked\r\n\r\n", false},
+		{"POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\nContent-Length: 6\r\n\r\n", false},
+		{"POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 5, 5\r\n\r\n", false},
+		{"POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked, gzip\r\n\r\n", false},
+		{"GET /a b HTTP/1.1\r\nHost: x\r\n\r\n", false},
+		{"GET /\x7f HTTP/1.1\r\nHost: x\r\n\r\n", false},
+		{"GET / HTTP/1.1\r\n\r\n", false},
+		{"GET / HTTP/1.1\r\nHost: x\r\nHost: y\r\n\r\n", false},
+		{"GET / HTTP/2.0\r\nHost: x\r\n\r\n", false},
+		{"G(T / HTTP/1.1\r\nHost: x\r\n\r\n", false},
+	}
+	for _, tt := range tests {
+		err := CheckStrict([]byte(tt.data))
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckStrict(%q) = %v, want ok=%v", tt.data, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("Expected %v to wrap ErrInvalidRequest", err)
+		}
+	}
+}
+
+// TestFDContextBatch 测试流水线响应合并写出
+func TestFDContextBatch(t *testing.T) {
+	fd, read := newSocketPair(t)
+	var queue []byte
+
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/a"})
+	ctx.SetBatch(&queue)
+	ctx.String(200, "first")
+	if out := read(); out != "" {
+		t.Fatalf("Batched response should be queued, got %q", out)
+	}
+
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/b"})
+	ctx.SetBatch(&queue)
+	ctx.String(200, "second")
+	if err := ctx.FlushBatch(); err != nil {
+		t.Fatalf("FlushBatch failed: %v", err)
+	}
+
+	out := read()
+	first, second := strings.Index(out, "first"), strings.Index(out, "second")
+	if first < 0 || second < first || strings.Count(out, "HTTP/1.1 200") != 2 {
+		t.Errorf("Expected both responses in order, got %q", out)
+	}
+	if len(queue) != 0 {
+		t.Error("FlushBatch should empty the queue")
+	}
+}
+
+// TestFDContextRemoteAddr 测试客户端地址
+func TestFDContextRemoteAddr(t *testing.T) {
+	fd, _ := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+
+	ctx.SetPeer(&syscall.SockaddrInet4{Addr: [4]byte{10, 0, 0, 1}, Port: 5000})
+	if addr := ctx.RemoteAddr(); addr == nil || addr.String() != "10.0.0.1:5000" {
+		t.Errorf("Expected 10.0.0.1:5000, got %v", addr)
+	}
+
+	ctx.SetPeer(&syscall.SockaddrInet6{Addr: [16]byte{15: 1}, Port: 443})
+	if addr := ctx.RemoteAddr(); addr == nil || addr.String() != "[::1]:443" {
+		t.Errorf("Expected [::1]:443, got %v", addr)
+	}
+
+	// Without an accept address the socket is asked
+	ctx.Reset(fd, &Request{})
+	if addr := ctx.RemoteAddr(); addr == nil || addr.Network() != "unix" {
+		t.Errorf("Expected unix peer address, got %v", addr)
+	}
+	if addr := ctx.LocalAddr(); addr == nil || addr.Network() != "unix" {
+		t.Errorf("Expected unix local address, got %v", addr)
+	}
+}
+
+// TestFDContextBodyReader 测试溢出到磁盘的请求体
+func TestFDContextBodyReader(t *testing.T) {
+	store := NewDiskStore(t.TempDir())
+	body, err := store.Create(11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Write([]byte("hello "))
+	body.Write([]byte("world"))
+
+	ctx := NewFDContext(1, &Request{Method: "POST", Path: "/upload", Spool: body})
+	r, err := ctx.BodyReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "hello world" || len(ctx.Body()) != 0 {
+		t.Errorf("Expected spilled body, got %q", data)
+	}
+
+	if err := body.Remove(); err != nil {
+		t.Errorf("Remove failed: %v", err)
+	}
+	if _, err := body.Open(); err == nil {
+		t.Error("Removed body should not open")
+	}
+}
+
+// TestFDContextRequestTimeout 测试请求超时取消 Context
+func TestFDContextRequestTimeout(t *testing.T) {
+	ctx := NewFDContext(1, &Request{Method: "GET", Path: "/report"})
+	ctx.SetRequestTimeout(20 * time.Millisecond)
+
+	reqCtx := ctx.Context()
+	if _, ok := reqCtx.Deadline(); !ok {
+		t.Fatal("Context should carry the request deadline")
+	}
+	select {
+	case <-reqCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Context should be canceled after the request timeout")
+	}
+	if cause := context.Cause(reqCtx); !errors.Is(cause, ErrRequestTimeout) {
+		t.Errorf("Expected ErrRequestTimeout cause, got %v", cause)
+	}
+	ctx.EndRequest()
+
+	// 无超时时没有截止时间
+	ctx.Reset(1, &Request{Method: "GET", Path: "/"})
+	if _, ok := ctx.Context().Deadline(); ok {
+		t.Error("Reset should clear the request deadline")
+	}
+	ctx.EndRequest()
+}
+
+// TestFDContextDeadline 测试截止时间到达后以 504 应答
+func TestFDContextDeadline(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/"})
+
+	// 挂起的请求由计时器应答
+	completed := make(chan struct{})
+	handle := ctx.Async()
+	handle.OnComplete(func() { close(completed) })
+	ctx.SetDeadline(time.Now().Add(20 * time.Millisecond))
+	select {
+	case <-completed:
+	case <-time.After(time.Second):
+		t.Fatal("Deadline should complete a parked request")
+	}
+	if !ctx.TimedOut() || !errors.Is(context.Cause(ctx.Context()), ErrRequestTimeout) {
+		t.Error("Context should be canceled at the deadline")
+	}
+	if out := read(); !strings.Contains(out, "504") || !strings.HasSuffix(out, "Gateway Timeout") {
+		t.Errorf("Expected 504, got %q", out)
+	}
+	if err := handle.Respond(func(c Context) { c.String(200, "late") }); err != ErrAsyncCompleted {
+		t.Errorf("Expected ErrAsyncCompleted, got %v", err)
+	}
+	ctx.EndRequest()
+
+	// 处理函数超时后的写入被丢弃，返回时应答 504
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	ctx.SetDeadline(time.Now().Add(-time.Millisecond))
+	for !ctx.TimedOut() {
+		time.Sleep(time.Millisecond)
+	}
+	ctx.String(200, "late")
+	ctx.Finish()
+	if out := read(); !strings.Contains(out, "504") || strings.Contains(out, "late") {
+		t.Errorf("Expected only the 504, got %q", out)
+	}
+	ctx.EndRequest()
+
+	// Reset 清除截止时间
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/"})
+	if _, ok := ctx.Deadline(); ok || ctx.TimedOut() {
+		t.Error("Reset should clear the deadline")
+	}
+}
+
+// TestFDContextSSEvent 测试 SSE 事件流
+func TestFDContextSSEvent(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/events", Proto: "HTTP/1.1"})
+
+	ctx.SSEvent("update", map[string]int{"n": 1})
+	ctx.SSEvent("", "a\nb")
+	ctx.Finish()
+
+	out := read()
+	for _, want := range []string{
+		"Content-Type: text/event-stream\r\n",
+		"Transfer-Encoding: chunked\r\n",
+		"event: update\ndata: {\"n\":1}\n\n",
+		"data: a\ndata: b\n\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in %q", want, out)
+		}
+	}
+	if !strings.HasSuffix(out, "\r\n0\r\n\r\n") {
+		t.Errorf("Stream should end with the last chunk, got %q", out)
+	}
+}
+
+// TestFDContextStream 测试分块流式响应
+func TestFDContextStream(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/rows", Proto: "HTTP/1.1"})
+
+	n := 0
+	gone := ctx.Stream(func(w io.Writer) bool {
+		n++
+		io.WriteString(w, "row\n")
+		return n < 3
+	})
+	ctx.Finish()
+	if gone || n != 3 {
+		t.Fatalf("Expected 3 steps without disconnect, got %d (gone=%v)", n, gone)
+	}
+
+	out := read()
+	if strings.Count(out, "4\r\nrow\n\r\n") != 3 || !strings.HasSuffix(out, "0\r\n\r\n") {
+		t.Errorf("Expected 3 chunks and the last chunk, got %q", out)
+	}
+	if err := ctx.SSEvent("late", "x"); err != ErrResponseWritten {
+		t.Errorf("Expected ErrResponseWritten after the stream ended, got %v", err)
+	}
+}
+
+// TestFDContextWriter 测试响应体 Writer：小响应带 Content-Length，大响应分块
+func TestFDContextWriter(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/report", Proto: "HTTP/1.1"})
+	ctx.SetHeader("Content-Type", "text/csv")
+	fmt.Fprintf(ctx.Writer(), "id,name\n%d,%s\n", 1, "a")
+	ctx.Finish()
+	if out := read(); !strings.Contains(out, "Content-Length: 12\r\n") || !strings.HasSuffix(out, "id,name\n1,a\n") {
+		t.Errorf("Expected a small body with Content-Length, got %q", out)
+	}
+
+	fd, read = newSocketPair(t)
+	ctx = NewFDContext(fd, &Request{Method: "GET", Path: "/report", Proto: "HTTP/1.1"})
+	w := ctx.Writer()
+	row := strings.Repeat("x", 1023) + "\n"
+	for i := 0; i < 100; i++ {
+		if _, err := io.WriteString(w, row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx.Finish()
+	out := readAll(read, 1<<20)
+	head, body, _ := strings.Cut(out, "\r\n\r\n")
+	if !strings.Contains(head, "Transfer-Encoding: chunked") || strings.Contains(head, "Content-Length") {
+		t.Fatalf("Expected a chunked response, got %q", head)
+	}
+	if !strings.HasSuffix(body, "\r\n0\r\n\r\n") || strings.Count(body, row) != 100 {
+		t.Errorf("Expected 100 rows and the last chunk, got %d rows", strings.Count(body, row))
+	}
+	if _, err := w.Write([]byte("late")); err != ErrResponseWritten {
+		t.Errorf("Expected ErrResponseWritten after Finish, got %v", err)
+	}
+}
+
+// TestFDContextResponseWriter 测试底层响应写入接口
+func TestFDContextResponseWriter(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/fb", Proto: "HTTP/1.1"})
+	w := ctx.ResponseWriter()
+	w.WriteHeaderLine(201)
+	w.WriteHeader("Content-Type", "application/x-flatbuffers")
+	w.WriteHeader("Content-Length", "10")
+	if err := w.WriteBodyChunk([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteBodyChunk([]byte("!")); err != ErrBodyLength {
+		t.Errorf("Expected ErrBodyLength past the declared length, got %v", err)
+	}
+	ctx.Finish()
+	out := read()
+	if !strings.HasPrefix(out, "HTTP/1.1 201") || !strings.Contains(out, "Content-Length: 10\r\n") ||
+		!strings.Contains(out, "Content-Type: application/x-flatbuffers\r\n") || !strings.HasSuffix(out, "\r\n\r\nhelloworld") {
+		t.Errorf("Expected the declared body as written, got %q", out)
+	}
+	if !ctx.KeepAlive() {
+		t.Error("A complete body should keep the connection")
+	}
+
+	// Short of the declared length: the connection must not be reused
+	fd, read = newSocketPair(t)
+	ctx = NewFDContext(fd, &Request{Method: "GET", Path: "/fb", Proto: "HTTP/1.1"})
+	w = ctx.ResponseWriter()
+	w.WriteHeader("Content-Length", "10")
+	w.WriteBodyChunk([]byte("short"))
+	ctx.Finish()
+	read()
+	if ctx.KeepAlive() {
+		t.Error("A short body should close the connection")
+	}
+
+	// Without a length it buffers like Writer
+	fd, read = newSocketPair(t)
+	ctx = NewFDContext(fd, &Request{Method: "GET", Path: "/txt", Proto: "HTTP/1.1"})
+	w = ctx.ResponseWriter()
+	w.WriteHeader("Content-Type", "text/plain")
+	w.WriteBodyChunk([]byte("a,"))
+	w.WriteBodyChunk([]byte("b"))
+	ctx.Finish()
+	if out := read(); !strings.Contains(out, "Content-Length: 3\r\n") || !strings.HasSuffix(out, "a,b") {
+		t.Errorf("Expected a buffered body, got %q", out)
+	}
+}
+
+// TestFDContextFlush 测试 Flush 立即发送已缓冲的响应体
+func TestFDContextFlush(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/progress", Proto: "HTTP/1.1"})
+	io.WriteString(ctx.Writer(), "10%")
+	if err := ctx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if out := read(); !strings.Contains(out, "Transfer-Encoding: chunked") || !strings.HasSuffix(out, "3\r\n10%\r\n") {
+		t.Fatalf("Flush should send the head and the buffered chunk, got %q", out)
+	}
+	ctx.Finish()
+	if out := read(); out != "0\r\n\r\n" {
+		t.Errorf("Expected the last chunk, got %q", out)
+	}
+}
+
+// TestFDContextWritePreflight 测试预先生成的 CORS 预检响应
+func TestFDContextWritePreflight(t *testing.T) {
+	fd, read := newSocketPair(t)
+	p := NewPreflight("DELETE, GET", "Content-Type", 10*time.Minute)
+
+	ctx := NewFDContext(fd, &Request{Method: "OPTIONS", Path: "/items/1", Proto: "HTTP/1.1"})
+	if err := ctx.WritePreflight(p, "https://app.example"); err != nil {
+		t.Fatal(err)
+	}
+	out := read()
+	for _, want := range []string{
+		"HTTP/1.1 204 No Content\r\n",
+		"Access-Control-Allow-Origin: https://app.example\r\nVary: Origin\r\n",
+		"Access-Control-Allow-Methods: DELETE, GET\r\n",
+		"Access-Control-Max-Age: 600\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in %q", want, out)
+		}
+	}
+	if !strings.HasSuffix(out, "\r\n\r\n") || strings.Contains(out, "Content-Length") {
+		t.Errorf("Expected a bodiless 204, got %q", out)
+	}
+
+	ctx.Reset(fd, &Request{Method: "OPTIONS", Path: "/items/1", Proto: "HTTP/1.1"})
+	ctx.SetKeepAlive(false)
+	ctx.WritePreflight(p, "*")
+	if out := read(); strings.Contains(out, "Vary") || !strings.Contains(out, "Connection: close") {
+		t.Errorf("Expected a wildcard preflight closing the connection, got %q", out)
+	}
+}
+
+// TestFDContextBackoff 测试 429/503 退避响应模板
+func TestFDContextBackoff(t *testing.T) {
+	fd, read := newSocketPair(t)
+
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/api", Proto: "HTTP/1.1"})
+	ctx.Backoff(429, 1500*time.Millisecond)
+	out := read()
+	for _, want := range []string{
+		"HTTP/1.1 429 Too Many Requests\r\n",
+		"Retry-After: 2\r\n",
+		"Ratelimit-Reset: 2\r\n", // canonicalized by SetHeader
+		"Content-Type: application/json\r\n",
+		`{"code":429,"message":"Too Many Requests","retry_after":2}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in %q", want, out)
+		}
+	}
+
+	backoffs := map[int]*Backoff{503: NewBackoff(503, BackoffConfig{
+		Body:       "overloaded, retry in " + RetryAfterPlaceholder + "s",
+		Headers:    map[string]string{"X-Overload": "queue"},
+		RetryAfter: 30 * time.Second,
+	})}
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/api", Proto: "HTTP/1.1"})
+	ctx.SetBackoffs(backoffs)
+	ctx.Backoff(503, 0)
+	out = read()
+	for _, want := range []string{
+		"HTTP/1.1 503 Service Unavailable\r\n",
+		"Retry-After: 30\r\n",
+		"X-Overload: queue\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\noverloaded, retry in 30s",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in %q", want, out)
+		}
+	}
+
+	raw := string(backoffs[503].AppendResponse(nil, 5*time.Second))
+	if !strings.Contains(raw, "Retry-After: 5\r\n") || !strings.Contains(raw, "Connection: close\r\n") ||
+		!strings.HasSuffix(raw, "retry in 5s") {
+		t.Errorf("Unexpected refusal %q", raw)
+	}
+}
+
+// TestFDContextXML 测试 XML 响应与绑定
+func TestFDContextXML(t *testing.T) {
+	type item struct {
+		XMLName struct{} `xml:"item"`
+		ID      int      `xml:"id,attr"`
+		Name    string   `xml:"name"`
+	}
+
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{
+		Method:      "POST",
+		Path:        "/items",
+		Proto:       "HTTP/1.1",
+		ContentType: "text/xml; charset=utf-8",
+		Body:        []byte(`<item id="7"><name>bolt</name></item>`),
+	})
+	var in item
+	if err := ctx.Bind(&in); err != nil || in.ID != 7 || in.Name != "bolt" {
+		t.Fatalf("Bind = %+v, %v", in, err)
+	}
+
+	ctx.XML(201, in)
+	out := read()
+	if !strings.Contains(out, "Content-Type: application/xml; charset=utf-8\r\n") ||
+		!strings.HasSuffix(out, "\r\n\r\n"+`<?xml version="1.0" encoding="UTF-8"?>`+"\n"+`<item id="7"><name>bolt</name></item>`) {
+		t.Errorf("Unexpected response %q", out)
+	}
+}
+
+// TestFDContextMsgpack 测试 MessagePack 响应与绑定
+func TestFDContextMsgpack(t *testing.T) {
+	body, _ := msgpack.Marshal(map[string]any{"name": "bolt", "qty": 3})
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{
+		Method:      "POST",
+		Path:        "/items",
+		Proto:       "HTTP/1.1",
+		ContentType: "application/msgpack",
+		Body:        body,
+	})
+	var in struct {
+		Name string `json:"name"`
+		Qty  int    `json:"qty"`
+	}
+	if err := ctx.Bind(&in); err != nil || in.Name != "bolt" || in.Qty != 3 {
+		t.Fatalf("Bind = %+v, %v", in, err)
+	}
+	ctx.Msgpack(200, in)
+	out := read()
+	if !strings.Contains(out, "Content-Type: application/msgpack\r\n") ||
+		!strings.HasSuffix(out, "\r\n\r\n\x82\xa4name\xa4bolt\xa3qty\x03") {
+		t.Errorf("Unexpected response %q", out)
+	}
+}
+
+// TestFDContextHijack 测试接管连接
+func TestFDContextHijack(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/tunnel", Proto: "HTTP/1.1"})
+	ctx.SetReadAhead([]byte("PING"))
+	ctx.Status(101)
+	ctx.SetHeader("Upgrade", "echo")
+	ctx.WriteStatus()
+
+	conn, err := ctx.Hijack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if out := read(); !strings.HasPrefix(out, "HTTP/1.1 101") {
+		t.Fatalf("Expected the 101 response first, got %q", out)
+	}
+
+	buf := make([]byte, 16)
+	if n, _ := conn.Read(buf); string(buf[:n]) != "PING" {
+		t.Errorf("Expected the read-ahead bytes, got %q", buf[:n])
+	}
+	ctx.String(200, "ignored")
+	ctx.Finish()
+	if ctx.WriteErr() != ErrHijacked || read() != "" {
+		t.Error("The context should write nothing after Hijack")
+	}
+
+	// The engine closes its descriptor; the hijacked conn stays usable
+	netfd.Close(fd)
+	conn.Write([]byte("PONG"))
+	if out := read(); out != "PONG" {
+		t.Errorf("Expected PONG on the hijacked conn, got %q", out)
+	}
+}
+
+// TestFDContextForm 测试 urlencoded 表单解析与绑定
+func TestFDContextForm(t *testing.T) {
+	req := &Request{
+		Method:      "POST",
+		Path:        "/signup",
+		ContentType: "application/x-www-form-urlencoded; charset=utf-8",
+		Body:        []byte("name=Ada+Lovelace&age=36&tags=math&tags=poetry&agree=on&note=a%26b"),
+	}
+	ctx := NewFDContext(-1, req)
+
+	if got := ctx.PostForm("name"); got != "Ada Lovelace" {
+		t.Errorf("Expected decoded name, got %q", got)
+	}
+	if got := ctx.PostFormArray("tags"); len(got) != 2 || got[1] != "poetry" {
+		t.Errorf("Expected both tags, got %v", got)
+	}
+	if m := ctx.PostFormMap(); m["note"] != "a&b" || m["tags"] != "math" || len(m) != 5 {
+		t.Errorf("Unexpected form map %v", m)
+	}
+
+	var form struct {
+		Name  string   `form:"name"`
+		Age   int      `json:"age"`
+		Tags  []string `form:"tags"`
+		Agree bool     `form:"agree"`
+		Skip  string   `form:"-"`
+	}
+	if err := ctx.Bind(&form); err != nil {
+		t.Fatal(err)
+	}
+	if form.Name != "Ada Lovelace" || form.Age != 36 || len(form.Tags) != 2 || !form.Agree {
+		t.Errorf("Unexpected bound form %+v", form)
+	}
+
+	var bad struct {
+		Age  uint8 `form:"age"`
+		Name int   `form:"name"`
+	}
+	if err := ctx.BindForm(&bad); err == nil || !strings.Contains(err.Error(), `"name"`) {
+		t.Errorf("Expected an error naming the field, got %v", err)
+	}
+
+	ctx.Reset(-1, &Request{Method: "POST", ContentType: "application/json", Body: []byte(`{"age":7}`)})
+	if ctx.PostForm("age") != "" {
+		t.Error("JSON bodies have no form fields")
+	}
+	if err := ctx.Bind(&form); err != nil || form.Age != 7 {
+		t.Errorf("Bind should decode JSON bodies, got %d (%v)", form.Age, err)
+	}
+}
+
+// TestFDContextBindQueryHeader 测试查询参数与请求头绑定
+func TestFDContextBindQueryHeader(t *testing.T) {
+	req := &Request{Method: "GET", Path: "/search", UserAgent: "probe/1.0"}
+	req.Query = map[string]string{"q": "fast+server%21", "page": "3", "ids": "4,5,6", "since": "2024-01-02T03:04:05Z"}
+	req.SetHeader("x-tenant", "acme")
+	req.SetHeader("X-Timeout", "1.5s")
+	req.SetHeader("X-Features", "a, b")
+	ctx := NewFDContext(-1, req)
+
+	var q struct {
+		Q     string    `query:"q"`
+		Page  int       `json:"page"`
+		IDs   []int     `query:"ids"`
+		Since time.Time `query:"since"`
+		Limit *int      `query:"limit"`
+	}
+	if err := ctx.BindQuery(&q); err != nil {
+		t.Fatal(err)
+	}
+	if q.Q != "fast server!" || q.Page != 3 || len(q.IDs) != 3 || q.IDs[2] != 6 ||
+		q.Since.Year() != 2024 || q.Limit != nil {
+		t.Errorf("Unexpected bound query %+v", q)
+	}
+
+	var h struct {
+		Tenant   string        `header:"X-Tenant"`
+		Timeout  time.Duration `header:"x-timeout"`
+		Features []string      `header:"X-Features"`
+		Agent    string        `header:"User-Agent"`
+	}
+	if err := ctx.BindHeader(&h); err != nil {
+		t.Fatal(err)
+	}
+	if h.Tenant != "acme" || h.Timeout != 1500*time.Millisecond || len(h.Features) != 2 ||
+		h.Features[1] != "b" || h.Agent != "probe/1.0" {
+		t.Errorf("Unexpected bound headers %+v", h)
+	}
+
+	var bad struct {
+		Page bool `query:"page"`
+	}
+	if err := ctx.BindQuery(&bad); err == nil || !strings.Contains(err.Error(), `query parameter "page"`) {
+		t.Errorf("Expected an error naming the parameter, got %v", err)
+	}
+}
+
+// TestFDContextMultipart 测试 multipart 表单与文件上传
+func TestFDContextMultipart(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "report")
+	fw, _ := mw.CreateFormFile("upload", "data.bin")
+	fw.Write(bytes.Repeat([]byte("x"), 4096))
+	mw.Close()
+
+	req := &Request{Method: "POST", Path: "/upload", ContentType: mw.FormDataContentType(), Body: body.Bytes()}
+	ctx := NewFDContext(-1, req)
+	ctx.SetMultipartMemory(1024) // The file spills to disk
+
+	if got := ctx.PostForm("title"); got != "report" {
+		t.Errorf("Expected the title field, got %q", got)
+	}
+	var form struct {
+		Title string `form:"title"`
+	}
+	if err := ctx.Bind(&form); err != nil || form.Title != "report" {
+		t.Errorf("Bind should fill multipart fields, got %+v (%v)", form, err)
+	}
+
+	fh, err := ctx.FormFile("upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if fh.Filename != "data.bin" || len(data) != 4096 {
+		t.Errorf("Unexpected file %q of %d bytes", fh.Filename, len(data))
+	}
+	osFile, spilled := f.(*os.File)
+	if !spilled {
+		t.Fatal("Expected the file to spill to a temporary file")
+	}
+	if _, err := ctx.FormFile("missing"); err != nethttp.ErrMissingFile {
+		t.Errorf("Expected ErrMissingFile, got %v", err)
+	}
+
+	ctx.Finish()
+	if _, err := os.Stat(osFile.Name()); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be removed, got %v", err)
+	}
+
+	ctx.Reset(-1, &Request{Method: "POST", ContentType: "application/json"})
+	if _, err := ctx.MultipartForm(); err != ErrNotMultipart {
+		t.Errorf("Expected ErrNotMultipart, got %v", err)
+	}
+}
+
+// TestFDContextJSONStream 测试JSON数组流式编码
+func TestFDContextJSONStream(t *testing.T) {
+	rows := func(n int) iter.Seq[any] {
+		return func(yield func(any) bool) {
+			for i := 0; i < n; i++ {
+				if !yield(map[string]int{"id": i}) {
+					return
+				}
+			}
+		}
+	}
+
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/rows", Proto: "HTTP/1.1"})
+	if err := ctx.JSONStream(200, rows(2)); err != nil {
+		t.Fatal(err)
+	}
+	ctx.Finish()
+	if out := read(); !strings.Contains(out, "Content-Length: 19") || !strings.HasSuffix(out, `[{"id":0},{"id":1}]`) {
+		t.Errorf("Expected a small array with Content-Length, got %q", out)
+	}
+
+	fd, read = newSocketPair(t)
+	ctx = NewFDContext(fd, &Request{Method: "GET", Path: "/rows", Proto: "HTTP/1.0"})
+	if err := ctx.JSONStream(200, rows(4000)); err != nil {
+		t.Fatal(err)
+	}
+	ctx.Finish()
+	_, body, _ := strings.Cut(read(), "\r\n\r\n")
+	var got []map[string]int
+	if err := json.Unmarshal([]byte(body), &got); err != nil || len(got) != 4000 || got[3999]["id"] != 3999 {
+		t.Errorf("Expected 4000 streamed rows, got %d (%v)", len(got), err)
+	}
+}
+
+// TestFDContextKeepAlive 测试关闭 keep-alive 时发送 Connection: close
+func TestFDContextKeepAlive(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/poll"})
+	ctx.SetKeepAlive(false)
+	ctx.SetIdleTimeout(time.Minute)
+	ctx.String(200, "ok")
+
+	if out := read(); !strings.Contains(out, "Connection: close\r\n") {
+		t.Errorf("Expected Connection: close, got %q", out)
+	}
+	if ctx.KeepAlive() || ctx.IdleTimeout() != time.Minute {
+		t.Errorf("Unexpected settings: keep-alive %v, idle %v", ctx.KeepAlive(), ctx.IdleTimeout())
+	}
+
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/poll"})
+	if !ctx.KeepAlive() || ctx.IdleTimeout() != 0 {
+		t.Error("Reset should restore the engine defaults")
+	}
+}
+
+// TestFDContextHTML 测试 HTML 构建与转义
+func TestFDContextHTML(t *testing.T) {
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/status"})
+
+	h := ctx.HTML()
+	h.Open("ul").Attr("class", `a"b`)
+	h.Open("li").Text("<script>&").Close("li")
+	h.Open("br").Int(42)
+	h.Close("ul").Send(200)
+
+	out := read()
+	if !strings.Contains(out, "Content-Type: text/html; charset=utf-8\r\n") {
+		t.Errorf("Expected text/html, got %q", out)
+	}
+	want := `<ul class="a&#34;b"><li>&lt;script&gt;&amp;</li><br>42</ul>`
+	if !strings.HasSuffix(out, want) {
+		t.Errorf("Expected body %q, got %q", want, out)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		ctx.HTML().Open("p").Attr("id", "x").Text("a < b").Close("p")
+	})
+	if allocs > 0 {
+		t.Errorf("Expected no allocations building HTML, got %v", allocs)
+	}
+}
+
+// TestServeRaw 测试 ServeRaw 返回完整的原始响应，包括超过套接字缓冲区的响应体
+func TestServeRaw(t *testing.T) {
+	body := strings.Repeat("0123456789abcdef", 64*1024)
+	out, err := ServeRaw(&Request{Method: "GET", Path: "/big"}, func(ctx Context) {
+		ctx.String(200, body)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "HTTP/1.1 200") || !strings.HasSuffix(out, "\r\n\r\n"+body) {
+		t.Errorf("Expected the whole %d-byte response, got %d bytes", len(body), len(out))
+	}
+
+	// 处理函数未写入时按引擎的方式补发状态行
+	out, _ = ServeRaw(&Request{Method: "GET", Path: "/"}, func(ctx Context) {
+		ctx.(*FDContext).Status(204)
+	})
+	if !strings.HasPrefix(out, "HTTP/1.1 204") {
+		t.Errorf("Expected the status sent by Finish, got %q", out)
+	}
+}
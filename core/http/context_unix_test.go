@@ -0,0 +1,107 @@
+//go:build !windows
+
+package http
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// 以下测试依赖真实套接字的发送缓冲区写满；Windows 上模拟的连接不会写满
+
+// TestFDContextWriteBacklog 测试套接字写满时未发送的字节留给引擎发送
+func TestFDContextWriteBacklog(t *testing.T) {
+	fd, read := newSocketPair(t)
+	netfd.SetNonblock(fd)
+	netfd.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_SNDBUF, 4096)
+
+	body := strings.Repeat("x", 256*1024)
+	var backlog Backlog
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/big"})
+	ctx.SetWriteBacklog(&backlog)
+	ctx.SetWriteTimeout(time.Millisecond)
+
+	// 不等待客户端读取，剩余字节留在 backlog 中
+	ctx.String(200, body)
+	if err := ctx.WriteErr(); err != nil {
+		t.Fatalf("String: %v", err)
+	}
+	if !backlog.Pending() {
+		t.Fatal("Expected the unsent body in the backlog")
+	}
+	// 后续写入排在 backlog 之后
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/next"})
+	ctx.SetWriteBacklog(&backlog)
+	ctx.String(200, "next")
+
+	var out strings.Builder
+	for backlog.Pending() {
+		if _, err := backlog.Send(fd); err != nil && err != syscall.EAGAIN {
+			t.Fatalf("Send: %v", err)
+		}
+		out.WriteString(read())
+	}
+	for s := read(); s != ""; s = read() {
+		out.WriteString(s)
+	}
+	got := out.String()
+	if !strings.Contains(got, body) || !strings.HasSuffix(got, "\r\n\r\nnext") {
+		t.Errorf("Expected the body followed by the next response, got %d bytes ending %q", len(got), got[max(len(got)-16, 0):])
+	}
+
+	// 没有 backlog 时等待套接字，直到写超时
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/big"})
+	ctx.SetWriteTimeout(20 * time.Millisecond)
+	ctx.String(200, body)
+	if err := ctx.WriteErr(); !errors.Is(err, ErrWriteTimeout) {
+		t.Errorf("Expected ErrWriteTimeout without a backlog, got %v", err)
+	}
+}
+
+// TestFDContextServeFileBacklog 测试 sendfile 写满套接字时文件剩余部分留给引擎发送
+func TestFDContextServeFileBacklog(t *testing.T) {
+	fd, read := newSocketPair(t)
+	netfd.SetNonblock(fd)
+	netfd.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_SNDBUF, 4096)
+
+	content := strings.Repeat("0123456789abcdef", 32*1024)
+	path := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var backlog Backlog
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/big.bin"})
+	ctx.SetWriteBacklog(&backlog)
+	start := time.Now()
+	if err := ctx.ServeFile(path); err != nil {
+		t.Fatalf("ServeFile: %v", err)
+	}
+	if time.Since(start) > time.Second || !backlog.Pending() {
+		t.Fatal("Expected ServeFile to return with the rest of the file in the backlog")
+	}
+	if got := ctx.ResponseSize(); got < int64(len(content)) {
+		t.Errorf("Expected the whole file counted as sent, got %d", got)
+	}
+
+	var out strings.Builder
+	for backlog.Pending() {
+		if _, err := backlog.Send(fd); err != nil && err != syscall.EAGAIN {
+			t.Fatalf("Send: %v", err)
+		}
+		out.WriteString(read())
+	}
+	for s := read(); s != ""; s = read() {
+		out.WriteString(s)
+	}
+	if _, body, _ := strings.Cut(out.String(), "\r\n\r\n"); body != content {
+		t.Errorf("Expected the file body (%d bytes), got %d bytes", len(content), len(body))
+	}
+}
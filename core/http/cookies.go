@@ -0,0 +1,100 @@
+package http
+
+import (
+	nethttp "net/http"
+	"strings"
+)
+
+// CookieValue returns the value of the named request cookie without
+// allocating: it is scanned for in the Cookie header, and the value
+// shares its memory. Malformed pairs are skipped.
+func (c *FDContext) CookieValue(name string) (string, bool) {
+	value, found := "", false
+	scanCookies(c.Header("Cookie"), func(n, v string) bool {
+		if n == name {
+			value, found = v, true
+			return false
+		}
+		return true
+	})
+	return value, found
+}
+
+// Cookie returns the named request cookie, or nethttp.ErrNoCookie
+func (c *FDContext) Cookie(name string) (*nethttp.Cookie, error) {
+	if c.cookiesParsed {
+		for _, cookie := range c.cookies {
+			if cookie.Name == name {
+				return cookie, nil
+			}
+		}
+		return nil, nethttp.ErrNoCookie
+	}
+	value, ok := c.CookieValue(name)
+	if !ok {
+		return nil, nethttp.ErrNoCookie
+	}
+	return &nethttp.Cookie{Name: name, Value: value}, nil
+}
+
+// Cookies returns the request cookies, parsed on first use. Malformed
+// pairs are skipped.
+func (c *FDContext) Cookies() []*nethttp.Cookie {
+	if !c.cookiesParsed {
+		c.cookiesParsed = true
+		scanCookies(c.Header("Cookie"), func(name, value string) bool {
+			c.cookies = append(c.cookies, &nethttp.Cookie{Name: name, Value: value})
+			return true
+		})
+	}
+	return c.cookies
+}
+
+// scanCookies calls fn with the name and value of each pair of a Cookie
+// header, until fn returns false
+func scanCookies(header string, fn func(name, value string) bool) {
+	for header != "" {
+		var pair string
+		pair, header, _ = strings.Cut(header, ";")
+		pair = strings.TrimSpace(pair)
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || !validCookieName(name) {
+			continue
+		}
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		if !validCookieValue(value) {
+			continue
+		}
+		if !fn(name, value) {
+			return
+		}
+	}
+}
+
+// validCookieName reports whether name is a token (RFC 6265)
+func validCookieName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		if ch <= ' ' || ch >= 0x7f || strings.IndexByte(`()<>@,;:\"/[]?={}`, ch) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// validCookieValue reports whether value holds only cookie-octets,
+// leniently allowing spaces and commas as browsers send them
+func validCookieValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		if ch < ' ' || ch >= 0x7f || ch == '"' || ch == ';' || ch == '\\' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// deadlineTimer enforces a request's deadline (see SetDeadline)
+type deadlineTimer struct {
+	c     *FDContext
+	timer *time.Timer
+
+	mu      sync.Mutex
+	stopped bool
+	cancel  context.CancelCauseFunc // Context's, if created before SetDeadline
+}
+
+// SetDeadline sets when the request times out. Context is canceled then
+// with ErrRequestTimeout, and the response is enforced: the client gets
+// 504 Gateway Timeout and whatever the handler writes afterwards is
+// dropped. A parked request (Async, or waiting for the worker pool) is
+// answered when the deadline passes; a handler still running is answered
+// when it returns, so it should watch Context. A response already under
+// way is cut off and the connection closed. The zero time removes the
+// deadline.
+func (c *FDContext) SetDeadline(t time.Time) {
+	c.stopDeadline()
+	c.deadline = t
+	if t.IsZero() {
+		return
+	}
+
+	d := &deadlineTimer{c: c, cancel: c.cancel}
+	c.enforced = d
+	d.timer = time.AfterFunc(time.Until(t), d.fire)
+}
+
+// Deadline returns when the request times out, if it has a deadline
+func (c *FDContext) Deadline() (time.Time, bool) {
+	return c.deadline, !c.deadline.IsZero()
+}
+
+// TimedOut reports whether the deadline set with SetDeadline has passed
+func (c *FDContext) TimedOut() bool {
+	return c.timedOut.Load()
+}
+
+// stopDeadline stops enforcing the deadline; a timer firing concurrently
+// has finished once it returns
+func (c *FDContext) stopDeadline() {
+	d := c.enforced
+	if d == nil {
+		return
+	}
+	c.enforced = nil
+	d.timer.Stop()
+	d.mu.Lock()
+	d.stopped = true
+	d.mu.Unlock()
+}
+
+// fire marks the request timed out and answers it if it is parked
+func (d *deadlineTimer) fire() {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	c := d.c
+	c.timedOut.Store(true)
+	if d.cancel != nil {
+		d.cancel(ErrRequestTimeout)
+	}
+	// A parked request is answered now. Holding the handle keeps the
+	// request from completing (and the context from being reused) until
+	// the reply is written.
+	h := c.asyncRef.Load()
+	claimed := h != nil && h.claim()
+	d.mu.Unlock()
+
+	if claimed {
+		c.replyTimeout()
+		h.complete()
+	}
+}
+
+// checkDeadline returns an error if the response may not be written
+// because the deadline passed. A response already under way is cut off.
+func (c *FDContext) checkDeadline() error {
+	if !c.timedOut.Load() || c.timeoutReply {
+		return nil
+	}
+	if c.written && c.writeErr == nil {
+		c.writeErr = ErrRequestTimeout
+	}
+	return ErrRequestTimeout
+}
+
+// replyTimeout answers a timed-out request that has not been answered
+func (c *FDContext) replyTimeout() {
+	if c.written {
+		return
+	}
+	clear(c.responseHeaders)
+	c.responseCookies = c.responseCookies[:0]
+	c.timeoutReply = true
+	c.String(504, "Gateway Timeout")
+}
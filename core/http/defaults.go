@@ -0,0 +1,43 @@
+package http
+
+// ResponseDefaults are per-route response settings applied by the
+// response methods, so handlers need not set the same headers each time
+type ResponseDefaults struct {
+	Compress     bool   // Compress bodies for clients that accept it
+	CacheControl string // Cache-Control unless the handler sets one
+	ContentType  string // Replaces the implicit type of String, JSON and Bytes
+	ETag         ETagMode
+}
+
+// SetResponseDefaults applies route defaults to this request's response
+func (c *FDContext) SetResponseDefaults(d *ResponseDefaults) {
+	c.defaults = d
+}
+
+// implicitType returns the route's content type, or fallback
+func (c *FDContext) implicitType(fallback string) string {
+	if c.defaults != nil && c.defaults.ContentType != "" {
+		return c.defaults.ContentType
+	}
+	return fallback
+}
+
+// respond writes a complete response, or 304 if the request's
+// validators match it, compressing the body if the request or route asks
+// for it and the client accepts a coding. The body is left out where
+// bodyAllowed says so, e.g. for HEAD.
+func (c *FDContext) respond(code int, contentType string, body []byte) {
+	if c.recording {
+		c.record(code, contentType, body)
+	}
+	if c.conditional(code, body) {
+		return
+	}
+	body, release := c.compressBody(contentType, body)
+	if release != nil {
+		defer release()
+	}
+
+	c.startResponse(code, contentType, len(body))
+	c.writeBody(body)
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+var (
+	// ErrClientDisconnected is the cancellation cause of Context when the
+	// client closes or resets the connection
+	ErrClientDisconnected = errors.New("client disconnected")
+
+	// ErrRequestTimeout is the cancellation cause of Context when the
+	// request outlives the engine's request timeout
+	ErrRequestTimeout = errors.New("request timed out")
+)
+
+// Context returns a context canceled when the client disconnects
+// (cause ErrClientDisconnected), when the request timeout expires
+// (cause ErrRequestTimeout) or when the request completes. Handlers doing
+// database or RPC work should pass it on so doomed requests are abandoned.
+func (c *FDContext) Context() context.Context {
+	if c.reqCtx == nil {
+		c.reqCtx, c.cancel = context.WithCancelCause(context.Background())
+		if !c.deadline.IsZero() {
+			var stop context.CancelFunc
+			c.reqCtx, stop = context.WithDeadlineCause(c.reqCtx, c.deadline, ErrRequestTimeout)
+			cancel := c.cancel
+			c.cancel = func(cause error) {
+				cancel(cause)
+				stop()
+			}
+		}
+		if c.disconnected.Load() {
+			c.cancel(ErrClientDisconnected)
+		} else if c.watch != nil {
+			c.watch(c)
+		}
+	}
+	return c.reqCtx
+}
+
+// Disconnected reports whether the client has closed or reset the
+// connection. It peeks at the socket without consuming request data.
+func (c *FDContext) Disconnected() bool {
+	if c.disconnected.Load() {
+		return true
+	}
+
+	if netfd.PeerClosed(c.fd) {
+		c.markDisconnected()
+		return true
+	}
+	return false
+}
+
+// SetRequestTimeout sets how long the request may run before Context is
+// canceled with ErrRequestTimeout, counted from now. Zero disables it.
+func (c *FDContext) SetRequestTimeout(d time.Duration) {
+	c.deadline = time.Time{}
+	if d > 0 {
+		c.deadline = time.Now().Add(d)
+	}
+}
+
+// SetDisconnectWatch sets the hook called when a handler first asks for
+// Context, so the engine can watch the connection for hangups
+func (c *FDContext) SetDisconnectWatch(watch func(*FDContext)) {
+	c.watch = watch
+}
+
+// EndRequest cancels Context once the response is complete, and stores
+// the request's dump if it is dumped. Called by the engine; handlers should not call it.
+func (c *FDContext) EndRequest() {
+	c.stopDeadline()
+	if c.dumping {
+		c.finishDump()
+	}
+	if c.cancel != nil {
+		c.cancel(context.Canceled)
+	}
+}
+
+// markDisconnected records the hangup and cancels Context
+func (c *FDContext) markDisconnected() {
+	c.disconnected.Store(true)
+	if c.cancel != nil {
+		c.cancel(ErrClientDisconnected)
+	}
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Attachment serves a file as a download (Content-Disposition:
+// attachment) that the browser saves as name, or as the file's base name
+// if name is empty. The file goes out with sendfile, like ServeFile.
+func (c *FDContext) Attachment(filePath, name string) error {
+	return c.serveDisposition("attachment", filePath, name)
+}
+
+// Inline serves a file for the browser to display (Content-Disposition:
+// inline), e.g. a PDF report, named name if the user saves it
+func (c *FDContext) Inline(filePath, name string) error {
+	return c.serveDisposition("inline", filePath, name)
+}
+
+// serveDisposition serves a file with a Content-Disposition header
+func (c *FDContext) serveDisposition(disposition, filePath, name string) error {
+	// Checked first so that a 404 goes out without the header
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		c.String(404, "File not found")
+		if err == nil {
+			err = fs.ErrNotExist
+		}
+		return err
+	}
+
+	if name == "" {
+		name = filepath.Base(filePath)
+	}
+	c.SetHeader("Content-Disposition", contentDisposition(disposition, name))
+	return c.ServeFile(filePath)
+}
+
+// contentDisposition formats a Content-Disposition value (RFC 6266). A
+// name with non-ASCII characters is sent as UTF-8 in filename*, with an
+// ASCII approximation in filename for old clients.
+func contentDisposition(disposition, name string) string {
+	var b strings.Builder
+	b.WriteString(disposition)
+	b.WriteString(`; filename="`)
+	ascii := true
+	for _, r := range name {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			b.WriteByte('_') // Controls, CR and LF included
+		case r >= 0x80:
+			ascii = false
+			b.WriteByte('_')
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	if ascii {
+		return b.String()
+	}
+
+	b.WriteString("; filename*=UTF-8''")
+	const hex = "0123456789ABCDEF"
+	for i := 0; i < len(name); i++ {
+		if ch := name[i]; isAttrChar(ch) {
+			b.WriteByte(ch)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(hex[ch>>4])
+			b.WriteByte(hex[ch&0xf])
+		}
+	}
+	return b.String()
+}
+
+// isAttrChar reports whether ch may appear unencoded in an RFC 8187
+// extended value
+func isAttrChar(ch byte) bool {
+	switch {
+	case 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', '0' <= ch && ch <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", ch) >= 0
+}
@@ -0,0 +1,279 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/searchktools/fast-server/core/redact"
+)
+
+// DumpConfig configures request/response dumps (see NewDumper)
+type DumpConfig struct {
+	// Rate is the fraction of requests dumped, from 0 to 1. Handlers pick
+	// more with ctx.DumpRequest. Default: 0.
+	Rate float64
+
+	// MaxBody is how many bytes of each body are kept. Default: 1024.
+	MaxBody int
+
+	// Capacity is how many dumps are kept; the oldest go first.
+	// Default: 100.
+	Capacity int
+
+	// Redactor masks sensitive headers and body fields.
+	// Default: redact.New(redact.DefaultConfig()).
+	Redactor *redact.Redactor
+}
+
+// Dump is a captured request and its response, as they went over the
+// wire: the headers and the first MaxBody bytes of the body
+type Dump struct {
+	Time              time.Time `json:"time"`
+	Method            string    `json:"method"`
+	Path              string    `json:"path"`
+	Status            int       `json:"status"`
+	Request           string    `json:"request"`
+	Response          string    `json:"response"`
+	RequestTruncated  bool      `json:"request_truncated,omitempty"`
+	ResponseTruncated bool      `json:"response_truncated,omitempty"`
+}
+
+// Dumper keeps the raw requests and responses of a sampled fraction of
+// traffic, for debugging client integrations in production. Files sent
+// with sendfile and spliced bodies are not captured, only their headers.
+type Dumper struct {
+	rate     atomic.Uint64 // math.Float64bits
+	maxBody  int
+	redactor *redact.Redactor
+
+	mu    sync.Mutex
+	ring  []Dump
+	next  int
+	count int
+}
+
+// NewDumper creates a dumper; the engine uses it once set with
+// Engine.SetDumper
+func NewDumper(cfg DumpConfig) *Dumper {
+	if cfg.MaxBody <= 0 {
+		cfg.MaxBody = 1024
+	}
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = 100
+	}
+	if cfg.Redactor == nil {
+		cfg.Redactor = redact.New(redact.DefaultConfig())
+	}
+	d := &Dumper{
+		maxBody:  cfg.MaxBody,
+		redactor: cfg.Redactor,
+		ring:     make([]Dump, cfg.Capacity),
+	}
+	d.SetRate(cfg.Rate)
+	return d
+}
+
+// SetRate changes the sampled fraction, e.g. to turn dumps on while a
+// client issue is being investigated
+func (d *Dumper) SetRate(rate float64) {
+	d.rate.Store(math.Float64bits(min(max(rate, 0), 1)))
+}
+
+// Rate returns the sampled fraction
+func (d *Dumper) Rate() float64 {
+	return math.Float64frombits(d.rate.Load())
+}
+
+// sample reports whether a request is picked
+func (d *Dumper) sample() bool {
+	rate := d.Rate()
+	return rate >= 1 || rate > 0 && rand.Float64() < rate
+}
+
+// Dumps returns the kept dumps, newest first
+func (d *Dumper) Dumps() []Dump {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dumps := make([]Dump, 0, d.count)
+	for i := 1; i <= d.count; i++ {
+		dumps = append(dumps, d.ring[(d.next-i+len(d.ring))%len(d.ring)])
+	}
+	return dumps
+}
+
+// Clear drops the kept dumps
+func (d *Dumper) Clear() {
+	d.mu.Lock()
+	clear(d.ring)
+	d.next, d.count = 0, 0
+	d.mu.Unlock()
+}
+
+// add keeps a dump, dropping the oldest if full
+func (d *Dumper) add(dump Dump) {
+	d.mu.Lock()
+	d.ring[d.next] = dump
+	d.next = (d.next + 1) % len(d.ring)
+	d.count = min(d.count+1, len(d.ring))
+	d.mu.Unlock()
+}
+
+// Handler returns a handler serving the dumps as JSON, or as plain text
+// for ?format=text. It belongs behind an admin-only route: redaction
+// covers the usual credentials, not everything a client may send.
+func (d *Dumper) Handler() func(ctx Context) {
+	return func(ctx Context) {
+		dumps := d.Dumps()
+		if ctx.Query("format") != "text" {
+			data, err := json.Marshal(dumps)
+			if err != nil {
+				ctx.Error(500, err.Error())
+				return
+			}
+			ctx.Data(200, "application/json", data)
+			return
+		}
+
+		var b strings.Builder
+		for _, dump := range dumps {
+			b.WriteString("=== ")
+			b.WriteString(dump.Time.Format(time.RFC3339Nano))
+			b.WriteString(" ")
+			b.WriteString(dump.Method)
+			b.WriteString(" ")
+			b.WriteString(dump.Path)
+			b.WriteString("\n--- request\n")
+			b.WriteString(dump.Request)
+			b.WriteString("\n--- response\n")
+			b.WriteString(dump.Response)
+			b.WriteString("\n\n")
+		}
+		ctx.Data(200, "text/plain; charset=utf-8", []byte(b.String()))
+	}
+}
+
+// redactMessage masks the sensitive headers and body fields of a raw
+// HTTP message and returns it as a string
+func (d *Dumper) redactMessage(msg []byte) string {
+	head, body, _ := bytes.Cut(msg, []byte("\r\n\r\n"))
+	var b strings.Builder
+	b.Grow(len(msg))
+	for i, line := range strings.Split(string(head), "\r\n") {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if i == 0 || !ok {
+			// Request or status line: the query may carry tokens
+			b.WriteString(d.redactor.String(line))
+			continue
+		}
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(d.redactor.String(d.redactor.Header(name, strings.TrimSpace(value))))
+	}
+	if len(msg) > len(head) {
+		b.WriteString("\r\n\r\n")
+		b.WriteString(d.redactor.String(string(body)))
+	}
+	return b.String()
+}
+
+// SetDumper sets the dumper the request may be captured in and the raw
+// request bytes, and samples the request. Called by the engine; raw must
+// stay valid until EndRequest.
+func (c *FDContext) SetDumper(d *Dumper, raw []byte) {
+	c.dumper = d
+	c.rawRequest = raw
+	if d != nil && d.sample() {
+		c.startDump()
+	}
+}
+
+// DumpRequest captures this request and its response in the engine's
+// dumper regardless of the sampling rate, e.g. for a client picked by a
+// debug header. It reports whether the request is dumped: not without a
+// dumper, or once the response has been written.
+func (c *FDContext) DumpRequest() bool {
+	if c.dumper == nil || (c.written && !c.dumping) {
+		return false
+	}
+	c.startDump()
+	return true
+}
+
+// Dumping reports whether this request is being dumped
+func (c *FDContext) Dumping() bool {
+	return c.dumping
+}
+
+// startDump starts capturing the response
+func (c *FDContext) startDump() {
+	if c.dumping {
+		return
+	}
+	c.dumping = true
+	c.dumpOut = c.dumpOut[:0]
+	c.dumpHead = -1
+	c.dumpTruncated = false
+}
+
+// captureDump records written response bytes: all of the headers, then
+// up to MaxBody bytes of the body
+func (c *FDContext) captureDump(p []byte) {
+	if c.dumpHead < 0 {
+		start := max(len(c.dumpOut)-3, 0)
+		c.dumpOut = append(c.dumpOut, p...)
+		i := bytes.Index(c.dumpOut[start:], []byte("\r\n\r\n"))
+		if i < 0 {
+			return
+		}
+		c.dumpHead = start + i + 4
+		p = nil
+	}
+
+	room := c.dumpHead + c.dumper.maxBody - len(c.dumpOut)
+	if len(p) > room {
+		p = p[:max(room, 0)]
+		c.dumpTruncated = true
+	}
+	c.dumpOut = append(c.dumpOut, p...)
+	if len(c.dumpOut) > c.dumpHead+c.dumper.maxBody {
+		c.dumpOut = c.dumpOut[:c.dumpHead+c.dumper.maxBody]
+		c.dumpTruncated = true
+	}
+}
+
+// finishDump stores the captured request and response
+func (c *FDContext) finishDump() {
+	c.dumping = false
+	d := c.dumper
+
+	raw := c.rawRequest
+	reqTruncated := false
+	if head := bytes.Index(raw, []byte("\r\n\r\n")); head >= 0 && len(raw) > head+4+d.maxBody {
+		raw = raw[:head+4+d.maxBody]
+		reqTruncated = true
+	}
+
+	dump := Dump{
+		Time:              time.Now(),
+		Status:            c.statusCode,
+		Request:           d.redactMessage(raw),
+		Response:          d.redactMessage(c.dumpOut),
+		RequestTruncated:  reqTruncated,
+		ResponseTruncated: c.dumpTruncated,
+	}
+	if c.request != nil {
+		// Copied: they point into the connection's read buffer
+		dump.Method = strings.Clone(c.request.Method)
+		dump.Path = strings.Clone(c.request.Path)
+	}
+	d.add(dump)
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"hash/fnv"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ETagMode selects automatic ETags for 200 responses to GET and HEAD
+type ETagMode uint8
+
+const (
+	ETagNone   ETagMode = iota // No automatic ETag
+	ETagStrong                 // "tag": the representation is byte-identical
+	ETagWeak                   // W/"tag": the content is equivalent
+)
+
+// SetAutoETag sets the automatic ETag of this request's response,
+// overriding the route's ETag option. Complete bodies are tagged with a
+// hash of their content, ServeFile responses with the file's modification
+// time and size. Requests whose If-None-Match matches get 304.
+func (c *FDContext) SetAutoETag(mode ETagMode) {
+	c.etagMode = mode
+	c.etagModeSet = true
+}
+
+// autoETag returns the automatic ETag mode of this response
+func (c *FDContext) autoETag() ETagMode {
+	if c.etagModeSet {
+		return c.etagMode
+	}
+	if c.defaults != nil {
+		return c.defaults.ETag
+	}
+	return ETagNone
+}
+
+// conditional tags a complete response and answers 304 if the request's
+// validators match it. It reports whether the 304 was sent.
+func (c *FDContext) conditional(code int, body []byte) bool {
+	if code != 200 || (c.request.Method != "GET" && c.request.Method != "HEAD") {
+		return false
+	}
+	etag := c.responseHeaders["Etag"]
+	if mode := c.autoETag(); etag == "" && mode != ETagNone {
+		h := fnv.New64a()
+		h.Write(body)
+		etag = formatETag(strconv.FormatUint(h.Sum64(), 16), mode)
+		c.SetHeader("ETag", etag)
+	}
+	if !c.notModified(etag, c.responseHeaders["Last-Modified"]) {
+		return false
+	}
+	c.writeNotModified()
+	return true
+}
+
+// notModified evaluates If-None-Match, or else If-Modified-Since, against
+// the response's validators
+func (c *FDContext) notModified(etag, lastModified string) bool {
+	if inm := c.Header("If-None-Match"); inm != "" {
+		return etag != "" && etagMatch(inm, etag)
+	}
+	ims := c.Header("If-Modified-Since")
+	if ims == "" || lastModified == "" {
+		return false
+	}
+	since, err := nethttp.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := nethttp.ParseTime(lastModified)
+	return err == nil && !modified.After(since)
+}
+
+// writeNotModified sends 304 with the response's headers and no body
+func (c *FDContext) writeNotModified() {
+	if c.compressionConfig() != nil {
+		c.SetHeader("Vary", "Accept-Encoding")
+	}
+	c.startResponse(304, "", 0)
+	c.writeResponse()
+}
+
+// fileETag returns the ETag of a file version
+func fileETag(modTime time.Time, size int64, mode ETagMode) string {
+	return formatETag(strconv.FormatInt(modTime.UnixNano(), 16)+"-"+strconv.FormatInt(size, 16), mode)
+}
+
+func formatETag(opaque string, mode ETagMode) string {
+	if mode == ETagWeak {
+		return `W/"` + opaque + `"`
+	}
+	return `"` + opaque + `"`
+}
+
+// encodedETag returns the strong ETag of the response encoded with
+// encoding: a strong tag names exactly one representation
+func encodedETag(etag, encoding string) string {
+	if strings.HasPrefix(etag, "W/") || len(etag) < 2 || etag[len(etag)-1] != '"' {
+		return etag
+	}
+	return etag[:len(etag)-1] + "-" + encoding + `"`
+}
+
+// etagMatch reports whether the If-None-Match list matches etag by weak
+// comparison, also accepting the tag of an encoded representation of it
+// (see encodedETag)
+func etagMatch(list, etag string) bool {
+	opaque := strings.TrimPrefix(etag, "W/")
+	for list != "" {
+		var tag string
+		tag, list, _ = strings.Cut(list, ",")
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		tag = strings.TrimPrefix(tag, "W/")
+		if tag == opaque {
+			return true
+		}
+		if len(tag) > len(opaque) && len(opaque) >= 2 &&
+			strings.HasPrefix(tag, opaque[:len(opaque)-1]+"-") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,270 @@
+package http
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formMaxBytes bounds a form body read back from a BodyStore
+const formMaxBytes = 10 << 20
+
+// PostForm returns the first value of a field of an
+// application/x-www-form-urlencoded or multipart/form-data body, or "" if
+// there is none
+func (c *FDContext) PostForm(key string) string {
+	form, _ := c.Form()
+	return form.Get(key)
+}
+
+// PostFormArray returns all values of a form field, e.g. checkboxes
+func (c *FDContext) PostFormArray(key string) []string {
+	form, _ := c.Form()
+	return form[key]
+}
+
+// PostFormMap returns the first value of every form field
+func (c *FDContext) PostFormMap() map[string]string {
+	form, _ := c.Form()
+	m := make(map[string]string, len(form))
+	for k, v := range form {
+		if len(v) > 0 {
+			m[k] = v[0]
+		}
+	}
+	return m
+}
+
+// Form parses an application/x-www-form-urlencoded body once and returns
+// its fields; for a multipart/form-data body it returns the non-file
+// fields of MultipartForm. Other bodies have none. On a malformed body it
+// returns the fields parsed before the error.
+func (c *FDContext) Form() (url.Values, error) {
+	if c.formParsed {
+		return c.form, c.formErr
+	}
+	c.formParsed = true
+	c.form = url.Values{}
+	if _, ok := c.multipartBoundary(); ok {
+		mf, err := c.MultipartForm()
+		if mf != nil {
+			c.form = url.Values(mf.Value)
+		}
+		c.formErr = err
+		return c.form, err
+	}
+	if !c.isFormBody() {
+		return c.form, nil
+	}
+
+	body := c.request.Body
+	if c.request.Spool != nil {
+		r, err := c.BodyReader()
+		if err != nil {
+			c.formErr = err
+			return c.form, err
+		}
+		body, err = io.ReadAll(io.LimitReader(r, formMaxBytes+1))
+		r.Close()
+		if err == nil && len(body) > formMaxBytes {
+			err = errors.New("form body too large")
+		}
+		if err != nil {
+			c.formErr = err
+			return c.form, err
+		}
+	}
+
+	c.form, c.formErr = url.ParseQuery(string(body))
+	return c.form, c.formErr
+}
+
+// isFormBody reports whether the request carries a urlencoded form
+func (c *FDContext) isFormBody() bool {
+	mediaType, _, _ := mime.ParseMediaType(c.request.ContentType)
+	return mediaType == "application/x-www-form-urlencoded"
+}
+
+// Bind decodes the request body into v according to its Content-Type:
+// the fields of a urlencoded or multipart form (see BindForm), XML (see
+// BindXML), MessagePack or protobuf (see BindMsgpack, BindProtobuf), JSON
+// otherwise
+func (c *FDContext) Bind(v any) error {
+	if _, ok := c.multipartBoundary(); ok || c.isFormBody() {
+		return c.BindForm(v)
+	}
+	if c.isXMLBody() {
+		return c.BindXML(v)
+	}
+	if ok, err := c.bindBinary(v); ok {
+		return err
+	}
+	return json.Unmarshal(c.request.Body, v)
+}
+
+// BindForm sets the fields of the struct v points to from the form. A
+// field is filled from the form field named by its form tag, else its
+// json tag, else its name; "-" skips it. Strings, bools ("on" counts as
+// true), numbers, durations, encoding.TextUnmarshaler types, slices of
+// them and embedded structs are supported.
+func (c *FDContext) BindForm(v any) error {
+	form, err := c.Form()
+	if err != nil {
+		return err
+	}
+	return bindValues(form, v, formBinding)
+}
+
+// binding describes a source of values for struct fields
+type binding struct {
+	tags  []string // Tags naming a field's value, in order of precedence
+	label string   // Describes a value in errors
+	split bool     // A single comma-separated value fills a slice field
+	fold  bool     // Names are header names, matched case-insensitively
+}
+
+var formBinding = &binding{tags: []string{"form", "json"}, label: "form field"}
+
+// bindValues sets the fields of the struct v points to from values
+func bindValues(values url.Values, v any, b *binding) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("bind: target must be a non-nil pointer to a struct")
+	}
+	return bindStruct(values, rv.Elem(), b)
+}
+
+func bindStruct(values url.Values, rv reflect.Value, b *binding) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if field.Anonymous && fv.Kind() == reflect.Struct {
+			if err := bindStruct(values, fv, b); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		name := b.fieldName(field)
+		if name == "-" {
+			continue
+		}
+		key := name
+		if b.fold {
+			key = textproto.CanonicalMIMEHeaderKey(name)
+		}
+		vals, ok := values[key]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+
+		if fv.Kind() == reflect.Slice && !isTextUnmarshaler(fv) {
+			if b.split && len(vals) == 1 {
+				vals = strings.Split(vals[0], ",")
+				for j := range vals {
+					vals[j] = strings.TrimSpace(vals[j])
+				}
+			}
+			slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+			for j, s := range vals {
+				if err := setValue(slice.Index(j), s); err != nil {
+					return fmt.Errorf("%s %q: %w", b.label, name, err)
+				}
+			}
+			fv.Set(slice)
+			continue
+		}
+		if err := setValue(fv, vals[0]); err != nil {
+			return fmt.Errorf("%s %q: %w", b.label, name, err)
+		}
+	}
+	return nil
+}
+
+// fieldName returns the name of a struct field's value
+func (b *binding) fieldName(field reflect.StructField) string {
+	for _, tag := range b.tags {
+		if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// isTextUnmarshaler reports whether v parses itself from text
+func isTextUnmarshaler(v reflect.Value) bool {
+	return reflect.PointerTo(v.Type()).Implements(textUnmarshalerType)
+}
+
+// setValue parses s into v: strings, bools, numbers, time.Duration and
+// encoding.TextUnmarshaler types (e.g. time.Time as RFC 3339)
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if isTextUnmarshaler(v) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == reflect.TypeFor[time.Duration]() {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		if s == "on" {
+			v.SetBool(true)
+			return nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"errors"
+	"net"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+var (
+	// ErrHijacked is returned by writes after the connection was hijacked
+	ErrHijacked = errors.New("connection hijacked")
+
+	// errHijackStream is returned by Hijack during a streamed response
+	errHijackStream = errors.New("cannot hijack a streamed response")
+)
+
+// Flush sends what is buffered now rather than when the handler returns:
+// responses queued behind pipelined requests and the body written to
+// Writer. If no complete response was written, this commits to a streamed
+// (chunked) response, sending the status and headers first.
+func (c *FDContext) Flush() error {
+	if c.written && !c.streaming {
+		return c.FlushBatch()
+	}
+	if len(c.body.buf) > 0 {
+		return c.body.flush()
+	}
+	return c.startStream(c.implicitType("application/octet-stream"))
+}
+
+// Hijack takes the connection over from the engine, for tunnels (see
+// Engine.CONNECT) or protocols switched to after a 101 response. What was
+// written so far is sent first, and the returned conn reads what the
+// client sent past the request before reading the socket. The engine
+// writes nothing more and lets go of the connection when the handler
+// returns; closing the conn is up to the caller.
+func (c *FDContext) Hijack() (net.Conn, error) {
+	if c.hijacked {
+		return nil, ErrHijacked
+	}
+	if c.streaming {
+		return nil, errHijackStream
+	}
+	c.body.finish()
+	if err := c.FlushBatch(); err != nil {
+		return nil, err
+	}
+	if err := c.drainBacklog(); err != nil {
+		return nil, err
+	}
+	if c.writeErr != nil {
+		return nil, c.writeErr
+	}
+
+	conn, err := netfd.Conn(c.fd)
+	if err != nil {
+		return nil, err
+	}
+	c.hijacked = true
+	c.written = true
+	c.writeErr = ErrHijacked
+	if len(c.readAhead) > 0 {
+		conn = &readAheadConn{Conn: conn, buf: append([]byte(nil), c.readAhead...)}
+	}
+	return conn, nil
+}
+
+// Hijacked reports whether the handler took the connection over
+func (c *FDContext) Hijacked() bool {
+	return c.hijacked
+}
+
+// SetReadAhead sets the bytes read from the connection past this request,
+// handed to Hijack. Called by the engine.
+func (c *FDContext) SetReadAhead(b []byte) {
+	c.readAhead = b
+}
+
+// readAheadConn returns data read ahead by the engine before reading conn
+type readAheadConn struct {
+	net.Conn
+	buf []byte
+}
+
+func (c *readAheadConn) Read(p []byte) (int, error) {
+	if len(c.buf) > 0 {
+		n := copy(p, c.buf)
+		c.buf = c.buf[n:]
+		return n, nil
+	}
+	return c.Conn.Read(p)
+}
@@ -0,0 +1,134 @@
+package http
+
+import "strconv"
+
+// htmlMaxRetained is the largest HTML buffer a context keeps for reuse
+const htmlMaxRetained = 64 * 1024
+
+// HTML builds a small HTML response in a buffer owned by the context, so
+// rendering status pages or admin snippets allocates nothing once the
+// buffer has grown. Text and attribute values are escaped; Raw is not.
+//
+//	h := ctx.HTML()
+//	h.Open("ul").Attr("class", "jobs")
+//	for _, j := range jobs {
+//		h.Open("li").Text(j.Name).Close("li")
+//	}
+//	h.Close("ul").Send(200)
+type HTML struct {
+	c   *FDContext
+	buf []byte
+	tag bool // inside an open start tag, before its ">"
+}
+
+// HTML returns the context's HTML builder, emptied
+func (c *FDContext) HTML() *HTML {
+	h := &c.html
+	h.c = c
+	h.buf = h.buf[:0]
+	h.tag = false
+	return h
+}
+
+// Open starts an element; Attr may follow
+func (h *HTML) Open(tag string) *HTML {
+	h.endTag()
+	h.buf = append(h.buf, '<')
+	h.buf = append(h.buf, tag...)
+	h.tag = true
+	return h
+}
+
+// Attr adds an attribute to the element just opened, escaping its value.
+// Attribute names must be trusted.
+func (h *HTML) Attr(name, value string) *HTML {
+	if !h.tag {
+		return h
+	}
+	h.buf = append(h.buf, ' ')
+	h.buf = append(h.buf, name...)
+	h.buf = append(h.buf, `="`...)
+	h.buf = AppendEscaped(h.buf, value)
+	h.buf = append(h.buf, '"')
+	return h
+}
+
+// Close ends an element
+func (h *HTML) Close(tag string) *HTML {
+	h.endTag()
+	h.buf = append(h.buf, "</"...)
+	h.buf = append(h.buf, tag...)
+	h.buf = append(h.buf, '>')
+	return h
+}
+
+// Text appends escaped text
+func (h *HTML) Text(s string) *HTML {
+	h.endTag()
+	h.buf = AppendEscaped(h.buf, s)
+	return h
+}
+
+// Int appends a number
+func (h *HTML) Int(n int64) *HTML {
+	h.endTag()
+	h.buf = strconv.AppendInt(h.buf, n, 10)
+	return h
+}
+
+// Raw appends trusted markup unescaped, e.g. a prebuilt fragment
+func (h *HTML) Raw(s string) *HTML {
+	h.endTag()
+	h.buf = append(h.buf, s...)
+	return h
+}
+
+// Bytes returns the markup built so far. It is valid until the context
+// is reused.
+func (h *HTML) Bytes() []byte {
+	h.endTag()
+	return h.buf
+}
+
+// Send sends the markup as a text/html response
+func (h *HTML) Send(code int) {
+	h.c.respond(code, h.c.implicitType("text/html; charset=utf-8"), h.Bytes())
+	if cap(h.buf) > htmlMaxRetained {
+		h.buf = nil
+	}
+}
+
+// endTag closes an open start tag
+func (h *HTML) endTag() {
+	if h.tag {
+		h.buf = append(h.buf, '>')
+		h.tag = false
+	}
+}
+
+// AppendEscaped appends s to dst with &, <, >, " and ' escaped, so it is
+// safe as HTML text or as a quoted attribute value
+func AppendEscaped(dst []byte, s string) []byte {
+	last := 0
+	for i := 0; i < len(s); i++ {
+		var esc string
+		switch s[i] {
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		case '"':
+			esc = "&#34;"
+		case '\'':
+			esc = "&#39;"
+		default:
+			continue
+		}
+		dst = append(dst, s[last:i]...)
+		dst = append(dst, esc...)
+		last = i + 1
+	}
+	return append(dst, s[last:]...)
+}
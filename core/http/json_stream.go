@@ -0,0 +1,100 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"iter"
+	"sync"
+)
+
+// jsonStreamChunk is how much encoded output JSONStream collects before
+// sending it as one chunk
+const jsonStreamChunk = 32 * 1024
+
+// jsonStreamState is the encoder state JSON and JSONStream reuse across
+// requests
+type jsonStreamState struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var jsonStreamPool = sync.Pool{
+	New: func() any {
+		s := &jsonStreamState{}
+		s.enc = json.NewEncoder(&s.buf)
+		return s
+	},
+}
+
+// releaseJSONState returns s to the pool, unless an oversized value grew
+// its buffer
+func releaseJSONState(s *jsonStreamState) {
+	if s.buf.Cap() <= 4*jsonStreamChunk {
+		s.buf.Reset()
+		jsonStreamPool.Put(s)
+	}
+}
+
+// JSONStream sends the elements of items as a JSON array, encoding them
+// one at a time instead of marshaling the whole slice first. Output is
+// sent in chunks of about 32KB; an array that fits in the first chunk is
+// sent as a regular response with Content-Length.
+//
+// If an element fails to encode before anything was sent, the client
+// gets a 500. After that the status is out, so the stream is cut short
+// and the error returned. Iteration stops early when the client
+// disconnects.
+func (c *FDContext) JSONStream(code int, items iter.Seq[any]) error {
+	if c.written {
+		return ErrResponseWritten
+	}
+
+	s := jsonStreamPool.Get().(*jsonStreamState)
+	defer releaseJSONState(s)
+
+	contentType := c.implicitType("application/json")
+	c.statusCode = code
+	s.buf.WriteByte('[')
+
+	var err error
+	first := true
+	for v := range items {
+		if !first {
+			s.buf.WriteByte(',')
+		}
+		first = false
+
+		if err = s.enc.Encode(v); err != nil {
+			break
+		}
+		// Drop the newline Encode appends
+		s.buf.Truncate(s.buf.Len() - 1)
+
+		if s.buf.Len() >= jsonStreamChunk {
+			if err = c.startStream(contentType); err != nil {
+				return err
+			}
+			if err = c.writeChunk(s.buf.Bytes()); err != nil {
+				return err
+			}
+			s.buf.Reset()
+			if c.Disconnected() {
+				return ErrClientDisconnected
+			}
+		}
+	}
+
+	if err != nil {
+		if !c.streaming {
+			c.Error(500, "Failed to marshal JSON")
+		}
+		return err
+	}
+
+	s.buf.WriteByte(']')
+	if !c.streaming {
+		c.respond(code, contentType, s.buf.Bytes())
+		return c.writeErr
+	}
+	return c.writeChunk(s.buf.Bytes())
+}
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"errors"
+	"mime"
+	"mime/multipart"
+	nethttp "net/http"
+)
+
+// DefaultMultipartMemory is how much of a multipart body is kept in
+// memory before file parts spill to temporary files
+const DefaultMultipartMemory = 32 << 20
+
+// ErrNotMultipart is returned by MultipartForm for other bodies
+var ErrNotMultipart = errors.New("request body is not multipart/form-data")
+
+// SetMultipartMemory sets how many bytes of a multipart body are kept in
+// memory (0: DefaultMultipartMemory); file parts beyond it are written to
+// temporary files in os.TempDir()
+func (c *FDContext) SetMultipartMemory(n int64) {
+	c.multipartMemory = n
+}
+
+// MultipartForm parses a multipart/form-data body once and returns its
+// fields and files. The body is parsed as a stream, from memory or from
+// the BodyStore it was spilled to, and the temporary files are removed
+// when the request completes.
+func (c *FDContext) MultipartForm() (*multipart.Form, error) {
+	if c.multipartParsed {
+		return c.multipart, c.multipartErr
+	}
+	c.multipartParsed = true
+
+	boundary, ok := c.multipartBoundary()
+	if !ok {
+		c.multipartErr = ErrNotMultipart
+		return nil, c.multipartErr
+	}
+	body, err := c.BodyReader()
+	if err != nil {
+		c.multipartErr = err
+		return nil, err
+	}
+	defer body.Close()
+
+	maxMemory := c.multipartMemory
+	if maxMemory <= 0 {
+		maxMemory = DefaultMultipartMemory
+	}
+	c.multipart, c.multipartErr = multipart.NewReader(body, boundary).ReadForm(maxMemory)
+	return c.multipart, c.multipartErr
+}
+
+// FormFile returns the first file uploaded in the multipart field name.
+// Open the returned header to read the file.
+func (c *FDContext) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if files := form.File[name]; len(files) > 0 {
+		return files[0], nil
+	}
+	return nil, nethttp.ErrMissingFile
+}
+
+// multipartBoundary returns the boundary of a multipart/form-data body
+func (c *FDContext) multipartBoundary() (string, bool) {
+	mediaType, params, err := mime.ParseMediaType(c.request.ContentType)
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return "", false
+	}
+	return params["boundary"], true
+}
+
+// releaseMultipart removes the temporary files of a parsed multipart form
+func (c *FDContext) releaseMultipart() {
+	if c.multipart != nil {
+		c.multipart.RemoveAll()
+	}
+	c.multipart = nil
+	c.multipartErr = nil
+	c.multipartParsed = false
+}
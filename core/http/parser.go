@@ -3,6 +3,9 @@ package http
 import (
 	"bytes"
 	"errors"
+	"net/textproto"
+	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -14,21 +17,37 @@ func unsafeString(b []byte) string {
 
 var (
 	ErrInvalidRequest = errors.New("invalid HTTP request")
+	// ErrIncomplete is returned while the request has not fully arrived:
+	// its header block or its Content-Length body is cut short
+	ErrIncomplete = errors.New("incomplete HTTP request")
 )
 
-// ParseRequest is a zero-allocation HTTP parser
+// ParseRequest is a zero-allocation HTTP parser. It returns ErrIncomplete
+// until the header block and Content-Length bytes of body are in data;
+// bytes past the body (a pipelined request) are not part of it.
 func ParseRequest(data []byte) (*Request, error) {
+	return parseRequest(data, true)
+}
+
+// ParseRequestHeader parses the request line and header block only, for
+// a body that is read separately (e.g. spooled to a BodyStore)
+func ParseRequestHeader(data []byte) (*Request, error) {
+	return parseRequest(data, false)
+}
+
+// parseRequest parses a request, with its body if withBody is set
+func parseRequest(data []byte, withBody bool) (*Request, error) {
 	req := AcquireRequest()
 
 	// Parse request line
 	lineEnd := bytes.IndexByte(data, '\n')
 	if lineEnd == -1 {
 		ReleaseRequest(req)
-		return nil, ErrInvalidRequest
+		return nil, ErrIncomplete
 	}
 
 	line := data[:lineEnd]
-	if line[len(line)-1] == '\r' {
+	if len(line) > 0 && line[len(line)-1] == '\r' {
 		line = line[:len(line)-1]
 	}
 
@@ -55,24 +74,64 @@ func ParseRequest(data []byte) (*Request, error) {
 	req.Proto = unsafeString(line[sp2+1:])
 
 	// Parse query parameters
-	if idx := bytes.IndexByte([]byte(req.Path), '?'); idx != -1 {
+	if idx := strings.IndexByte(req.Path, '?'); idx != -1 {
 		req.Path, _ = parseQuery(req, req.Path, idx)
 	}
 
 	// Parse headers
+	size := len(data)
 	data = data[lineEnd+1:]
-	headerEnd := bytes.Index(data, []byte("\r\n\r\n"))
-	if headerEnd == -1 {
-		headerEnd = bytes.Index(data, []byte("\n\n"))
-		if headerEnd == -1 {
+	fields, headerLen := headerEnd(data)
+	if headerLen == -1 {
+		ReleaseRequest(req)
+		return nil, ErrIncomplete
+	}
+	block := data[:fields]
+	if hasObsFold(block) {
+		var ok bool
+		if block, ok = unfoldHeaders(block); !ok {
 			ReleaseRequest(req)
 			return nil, ErrInvalidRequest
 		}
-		data = data[headerEnd+2:]
+	}
+	if err := parseHeaders(req, block); err != nil {
+		ReleaseRequest(req)
+		return nil, err
+	}
+	data = data[headerLen:]
+	req.HeaderSize = size - len(data)
+	if !withBody {
+		return req, nil
+	}
+
+	// Frame the body as RequestLength does: a Transfer-Encoding body must
+	// be chunked, and both kinds of body must have fully arrived
+	if te, ok := req.ExtraHeaders["Transfer-Encoding"]; ok {
+		if !isChunked(te) {
+			ReleaseRequest(req)
+			return nil, ErrInvalidRequest
+		}
+		body, trailer, _, err := decodeChunked(req.Body[:0], data, true)
+		if err != nil {
+			ReleaseRequest(req)
+			return nil, err
+		}
+		req.Body = body
+		parseTrailer(req, trailer)
+		return req, nil
+	}
+	if req.ContentLength != "" {
+		n, err := strconv.Atoi(req.ContentLength)
+		if err != nil || n < 0 {
+			n = 0
+		}
+		if len(data) < n {
+			ReleaseRequest(req)
+			return nil, ErrIncomplete
+		}
+		data = data[:n]
 	} else {
-		headerData := data[:headerEnd]
-		parseHeaders(req, headerData)
-		data = data[headerEnd+4:]
+		data = nil
 	}
 
 	// Parse request body
@@ -83,8 +142,170 @@ func ParseRequest(data []byte) (*Request, error) {
 	return req, nil
 }
 
-// parseHeaders parses HTTP headers
-func parseHeaders(req *Request, data []byte) {
+// RequestLength returns the size of the first request in data: the header
+// block plus a Content-Length body, which may extend past data when the
+// body has not fully arrived, or a chunked body. It returns -1 while the
+// header block or a chunked body is incomplete. A malformed chunked body
+// takes the rest of data, which then fails to parse.
+func RequestLength(data []byte) int {
+	headerLen, bodyLen := RequestFrame(data)
+	switch {
+	case headerLen < 0:
+		return -1
+	case bodyLen < 0:
+		_, _, n, err := decodeChunked(nil, data[headerLen:], false)
+		if err == ErrIncomplete {
+			return -1
+		}
+		if err != nil {
+			return len(data)
+		}
+		return headerLen + n
+	}
+	return headerLen + bodyLen
+}
+
+// RequestTarget returns the method and path, without the query, of the
+// request line at the start of data. The strings point into data.
+func RequestTarget(data []byte) (method, path string, ok bool) {
+	line, _, ok := cutLine(data)
+	if !ok {
+		return "", "", false
+	}
+	sp1 := bytes.IndexByte(line, ' ')
+	if sp1 == -1 {
+		return "", "", false
+	}
+	sp2 := bytes.IndexByte(line[sp1+1:], ' ')
+	if sp2 == -1 {
+		return "", "", false
+	}
+	target := line[sp1+1 : sp1+1+sp2]
+	if i := bytes.IndexByte(target, '?'); i != -1 {
+		target = target[:i]
+	}
+	return unsafeString(line[:sp1]), unsafeString(target), true
+}
+
+// RequestFrame returns the header block size of the first request in data
+// and its Content-Length. headerLen is -1 while the header block is
+// incomplete; bodyLen is -1 for a Transfer-Encoding body.
+func RequestFrame(data []byte) (headerLen, bodyLen int) {
+	// Skip the request line
+	i := bytes.IndexByte(data, '\n')
+	if i == -1 {
+		return -1, 0
+	}
+	fields, n := headerEnd(data[i+1:])
+	if n == -1 {
+		return -1, 0
+	}
+	headerLen = i + 1 + n
+	lines := data[i+1 : i+1+fields]
+
+	for len(lines) > 0 {
+		line := lines
+		if i := bytes.IndexByte(lines, '\n'); i != -1 {
+			line, lines = lines[:i], lines[i+1:]
+		} else {
+			lines = nil
+		}
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			continue // Folded continuation (see unfoldHeaders)
+		}
+
+		colon := bytes.IndexByte(line, ':')
+		if colon <= 0 {
+			continue
+		}
+		key := bytes.TrimSpace(line[:colon])
+		switch {
+		case bytes.EqualFold(key, []byte("Content-Length")):
+			if n, err := strconv.Atoi(string(bytes.TrimSpace(line[colon+1:]))); err == nil && n > 0 {
+				bodyLen = n
+			}
+		case bytes.EqualFold(key, []byte("Transfer-Encoding")):
+			return headerLen, -1
+		}
+	}
+	return headerLen, bodyLen
+}
+
+// headerEnd finds the empty line ending the header fields at the start of
+// data, which like the other lines may end with CRLF or LF. It returns
+// the size of the fields, without the line break before the empty line,
+// and the size up to the end of the empty line, or -1 while it has not
+// arrived. Bytes after the first empty line belong to the body or the
+// next request, so the search stops there.
+func headerEnd(data []byte) (fields, size int) {
+	for pos := 0; ; {
+		i := bytes.IndexByte(data[pos:], '\n')
+		if i == -1 {
+			return -1, -1
+		}
+		if i == 0 || (i == 1 && data[pos] == '\r') {
+			fields = pos
+			if fields > 0 {
+				fields-- // The LF ending the last field
+				if fields > 0 && data[fields-1] == '\r' {
+					fields--
+				}
+			}
+			return fields, pos + i + 1
+		}
+		pos += i + 1
+	}
+}
+
+// hasObsFold reports whether a header block has obsolete line folding: a
+// line starting with a space or tab
+func hasObsFold(block []byte) bool {
+	return len(block) > 0 && (block[0] == ' ' || block[0] == '\t') ||
+		bytes.Contains(block, []byte("\n ")) || bytes.Contains(block, []byte("\n\t"))
+}
+
+// unfoldHeaders returns a copy of a header block with each obsolete line
+// folding (RFC 7230 3.2.4) replaced by a space, so a folded value is read
+// whole rather than as a garbage header. Whitespace-led lines before the
+// first field are dropped. It reports false for a folded Content-Length
+// or Transfer-Encoding, which RequestFrame reads unfolded: the request is
+// rejected rather than framed two different ways.
+func unfoldHeaders(block []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(block))
+	first := true
+	for len(block) > 0 {
+		line, rest, ok := cutLine(block)
+		if !ok {
+			line, rest = block, nil
+		}
+		block = rest
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			if first {
+				continue
+			}
+			prev := out[bytes.LastIndexByte(out, '\n')+1:]
+			name, _, _ := bytes.Cut(prev, []byte(":"))
+			name = bytes.TrimSpace(name)
+			if bytes.EqualFold(name, []byte("Content-Length")) || bytes.EqualFold(name, []byte("Transfer-Encoding")) {
+				return nil, false
+			}
+			out = append(bytes.TrimRight(out, " \t"), ' ')
+			out = append(out, bytes.TrimLeft(line, " \t")...)
+			continue
+		}
+		if !first {
+			out = append(out, '\n')
+		}
+		out = append(out, line...)
+		first = false
+	}
+	return out, true
+}
+
+// parseHeaders parses HTTP headers. A second Host, or a second
+// Content-Length with another value, is rejected with ErrInvalidRequest
+// (RFC 9112 3.2 and 6.3): which one a proxy used is unknown.
+func parseHeaders(req *Request, data []byte) error {
 	for len(data) > 0 {
 		lineEnd := bytes.IndexByte(data, '\n')
 		if lineEnd == -1 {
@@ -105,7 +326,10 @@ func parseHeaders(req *Request, data []byte) {
 		if colon > 0 {
 			key := string(bytes.TrimSpace(line[:colon]))
 			value := string(bytes.TrimSpace(line[colon+1:]))
-			req.SetHeader(key, value)
+			if conflictingHeader(req, key, value) {
+				return ErrInvalidRequest
+			}
+			req.AddHeader(key, value)
 		}
 
 		if lineEnd == len(data) {
@@ -113,26 +337,56 @@ func parseHeaders(req *Request, data []byte) {
 		}
 		data = data[lineEnd+1:]
 	}
+	return nil
 }
 
-// parseQuery parses query parameters
-func parseQuery(req *Request, path string, idx int) (string, error) {
-	queryStr := path[idx+1:]
-	path = path[:idx]
+// conflictingHeader reports whether key repeats a header that must be
+// sent once
+func conflictingHeader(req *Request, key, value string) bool {
+	switch {
+	case strings.EqualFold(key, "Host"):
+		return req.Host != ""
+	case strings.EqualFold(key, "Content-Length"):
+		return req.ContentLength != "" && req.ContentLength != value
+	}
+	return false
+}
 
-	if req.Query == nil {
-		req.Query = make(map[string]string)
+// parseTrailer fills req.Trailers from the trailer fields of a chunked
+// body. They are kept apart from the headers, so a trailer cannot change
+// how the request was framed or routed.
+func parseTrailer(req *Request, data []byte) {
+	for len(data) > 0 {
+		line, rest, _ := cutLine(data)
+		data = rest
+		colon := bytes.IndexByte(line, ':')
+		if colon <= 0 {
+			continue
+		}
+		if req.Trailers == nil {
+			req.Trailers = make(map[string]string)
+		}
+		key := textproto.CanonicalMIMEHeaderKey(string(bytes.TrimSpace(line[:colon])))
+		req.Trailers[key] = string(bytes.TrimSpace(line[colon+1:]))
 	}
+}
+
+// parseQuery stores the query parameters of path and returns the path
+// without its query string. Keys and values point into the request data,
+// like the path, so a short query allocates nothing.
+func parseQuery(req *Request, path string, idx int) (string, error) {
+	query := path[idx+1:]
+	path = path[:idx]
+	req.RawQuery = query
 
-	// Simple query parameter parsing
-	pairs := bytes.Split([]byte(queryStr), []byte("&"))
-	for _, pair := range pairs {
-		kv := bytes.SplitN(pair, []byte("="), 2)
-		if len(kv) == 2 {
-			req.Query[string(kv[0])] = string(kv[1])
-		} else if len(kv) == 1 {
-			req.Query[string(kv[0])] = ""
+	for query != "" {
+		var pair string
+		pair, query, _ = strings.Cut(query, "&")
+		if pair == "" {
+			continue
 		}
+		key, value, _ := strings.Cut(pair, "=")
+		req.SetQuery(key, value)
 	}
 
 	return path, nil
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"net"
+	"strconv"
+	"syscall"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// SetPeer records the client address returned by accept, so RemoteAddr
+// needs no syscall
+func (c *FDContext) SetPeer(sa syscall.Sockaddr) {
+	c.peer = sa
+	c.remoteAddr = nil
+}
+
+// RemoteAddr returns the client's address, or nil if it is unknown
+func (c *FDContext) RemoteAddr() net.Addr {
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	sa := c.peer
+	if sa == nil {
+		var err error
+		if sa, err = netfd.Getpeername(c.fd); err != nil {
+			return nil
+		}
+	}
+	c.remoteAddr = SockaddrToAddr(sa)
+	return c.remoteAddr
+}
+
+// LocalAddr returns the address the client connected to, or nil if it is
+// unknown
+func (c *FDContext) LocalAddr() net.Addr {
+	sa, err := netfd.Getsockname(c.fd)
+	if err != nil {
+		return nil
+	}
+	return SockaddrToAddr(sa)
+}
+
+// SockaddrToAddr converts a socket address to a net.Addr
+func SockaddrToAddr(sa syscall.Sockaddr) net.Addr {
+	switch sa := sa.(type) {
+	case *syscall.SockaddrInet4:
+		return &net.TCPAddr{IP: net.IPv4(sa.Addr[0], sa.Addr[1], sa.Addr[2], sa.Addr[3]), Port: sa.Port}
+	case *syscall.SockaddrInet6:
+		addr := &net.TCPAddr{IP: append(net.IP(nil), sa.Addr[:]...), Port: sa.Port}
+		if sa.ZoneId != 0 {
+			addr.Zone = strconv.Itoa(int(sa.ZoneId))
+		}
+		return addr
+	case *syscall.SockaddrUnix:
+		return &net.UnixAddr{Name: sa.Name, Net: "unix"}
+	}
+	return nil
+}
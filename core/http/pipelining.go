@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"io"
 	"net"
+
+	"github.com/searchktools/fast-server/core/netfd"
 )
 
 // PipelineHandler handles HTTP/1.1 pipelining
@@ -11,6 +13,9 @@ type PipelineHandler struct {
 	conn    net.Conn
 	reader  *bufio.Reader
 	maxSize int // Max pipeline queue size
+
+	// Bytes read past the last complete request
+	pending []byte
 }
 
 // NewPipelineHandler creates a new pipeline handler
@@ -26,38 +31,39 @@ func NewPipelineHandler(conn net.Conn, maxSize int) *PipelineHandler {
 	}
 }
 
-// ReadRequests reads multiple pipelined requests
+// ReadRequests reads multiple pipelined requests. It blocks until at
+// least one request, body included, has fully arrived.
 func (ph *PipelineHandler) ReadRequests() ([]*Request, error) {
 	requests := make([]*Request, 0, ph.maxSize)
 	buf := make([]byte, 4096)
 
-	for i := 0; i < ph.maxSize; i++ {
-		// Try to read next request
-		n, err := ph.reader.Read(buf)
-		if err != nil {
-			if err == io.EOF && len(requests) > 0 {
-				// End of current batch
-				break
+	for len(requests) < ph.maxSize {
+		// Parse the complete requests already read
+		size := RequestLength(ph.pending)
+		if size >= 0 && size <= len(ph.pending) {
+			// Each request gets its own copy: its strings point into it
+			req, err := ParseRequest(append([]byte(nil), ph.pending[:size]...))
+			if err != nil {
+				return requests, err
 			}
-			return requests, err
+			requests = append(requests, req)
+			ph.pending = ph.pending[size:]
+			continue
 		}
 
-		if n == 0 {
+		// Wait for more only if no request is ready
+		if len(requests) > 0 && ph.reader.Buffered() == 0 {
 			break
 		}
-
-		// Parse request
-		req, err := ParseRequest(buf[:n])
+		n, err := ph.reader.Read(buf)
 		if err != nil {
+			if err == io.EOF && len(requests) > 0 {
+				// End of current batch
+				break
+			}
 			return requests, err
 		}
-
-		requests = append(requests, req)
-
-		// If no more data immediately available, break
-		if ph.reader.Buffered() == 0 {
-			break
-		}
+		ph.pending = append(ph.pending, buf[:n]...)
 	}
 
 	return requests, nil
@@ -144,3 +150,46 @@ func HandlePipelinedConnection(conn net.Conn, config PipelineConfig, handler fun
 		}
 	}
 }
+
+// SetBatch queues this request's response in buf instead of writing it,
+// so the engine can send the responses to pipelined requests with one
+// write. Streamed responses (large bodies, files, splice) flush the queue
+// first. A nil buf writes directly.
+func (c *FDContext) SetBatch(buf *[]byte) {
+	c.batch = buf
+}
+
+// queueResponse appends the response buffer to the batch
+func (c *FDContext) queueResponse() error {
+	if err := c.checkDeadline(); err != nil {
+		return err
+	}
+	c.written = true
+	if c.dumping {
+		c.captureDump(c.responseBuf)
+	}
+	if c.writeErr == nil {
+		*c.batch = append(*c.batch, c.responseBuf...)
+		c.bytesSent += int64(len(c.responseBuf))
+	}
+	return c.writeErr
+}
+
+// FlushBatch writes the queued responses. Streamed responses call it
+// first so pipelined responses stay in order.
+func (c *FDContext) FlushBatch() error {
+	if c.batch == nil || len(*c.batch) == 0 {
+		return c.writeErr
+	}
+	pending := *c.batch
+	*c.batch = pending[:0]
+	if c.writeObserver != nil {
+		c.writeObserver.RecordQueue(c.fd, len(pending))
+	}
+	// Earlier requests' responses go out even if this one timed out. The
+	// bytes were counted when they were queued.
+	sent := c.bytesSent
+	err := c.writeBuf(pending, netfd.Write)
+	c.bytesSent = sent
+	return err
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/searchktools/fast-server/core/clock"
+)
+
+// Preflight is a CORS preflight response prepared ahead of time, so the
+// engine can answer preflights without running handlers or middleware
+type Preflight struct {
+	// headers follow Access-Control-Allow-Origin
+	headers []byte
+}
+
+// NewPreflight prepares the 204 answer to preflights of a route allowing
+// methods and request headers; a positive maxAge lets browsers cache it
+func NewPreflight(methods, headers string, maxAge time.Duration) *Preflight {
+	var b []byte
+	b = appendHeader(b, "Access-Control-Allow-Methods", methods)
+	b = appendHeader(b, "Access-Control-Allow-Headers", headers)
+	if maxAge > 0 {
+		b = appendHeader(b, "Access-Control-Max-Age", strconv.Itoa(int(maxAge/time.Second)))
+	}
+	return &Preflight{headers: b}
+}
+
+// WritePreflight sends p for a request from origin ("*" for any)
+func (c *FDContext) WritePreflight(p *Preflight, origin string) error {
+	c.statusCode = 204
+	b := append(c.responseBuf[:0], "HTTP/1.1 204 No Content\r\nDate: "...)
+	b = clock.AppendDate(b)
+	b = append(b, "\r\n"...)
+	if c.server != "" {
+		b = appendHeader(b, "Server", c.server)
+	}
+	b = append(b, "Access-Control-Allow-Origin: "...)
+	b = append(b, origin...)
+	b = append(b, "\r\n"...)
+	if origin != "*" {
+		b = append(b, "Vary: Origin\r\n"...)
+	}
+	b = append(b, p.headers...)
+	b = c.appendConnection(b)
+	c.responseBuf = append(b, "\r\n"...)
+	return c.writeResponse()
+}
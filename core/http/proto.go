@@ -0,0 +1,121 @@
+package http
+
+import (
+	"crypto/tls"
+	"strings"
+)
+
+// Proto returns the request's protocol version, e.g. "HTTP/1.1"
+func (c *FDContext) Proto() string {
+	return c.request.Proto
+}
+
+// IsTLS reports whether the connection is TLS. It is always false: the
+// engine reads plaintext sockets, and TLS is terminated in front of it
+// (an alpn.Mux or a proxy). Use Scheme to tell HTTPS requests apart.
+func (c *FDContext) IsTLS() bool {
+	return false
+}
+
+// TLSState returns nil: see IsTLS
+func (c *FDContext) TLSState() *tls.ConnectionState {
+	return nil
+}
+
+// Scheme returns "https" or "http": the scheme the client used. On
+// connections from trusted proxies (see SetTrustedProxies) it is taken
+// from X-Forwarded-Proto or the proto of a Forwarded header.
+func (c *FDContext) Scheme() string {
+	if c.fromTrustedProxy() {
+		if proto := forwardedProto(c.Header("X-Forwarded-Proto"), c.Header("Forwarded")); proto != "" {
+			return proto
+		}
+	}
+	return "http"
+}
+
+// Host returns the host the client addressed: the Host header, or
+// X-Forwarded-Host on connections from trusted proxies
+func (c *FDContext) Host() string {
+	if c.fromTrustedProxy() {
+		if host := c.Header("X-Forwarded-Host"); host != "" {
+			host, _, _ = strings.Cut(host, ",")
+			return strings.TrimSpace(host)
+		}
+	}
+	return c.request.Host
+}
+
+// BaseURL returns the scheme and host the client used, such as
+// "https://example.com", for building absolute URLs
+func (c *FDContext) BaseURL() string {
+	return c.Scheme() + "://" + c.Host()
+}
+
+// fromTrustedProxy reports whether the connection comes from a trusted
+// proxy
+func (c *FDContext) fromTrustedProxy() bool {
+	if c.trustedProxies == nil {
+		return false
+	}
+	peer, ok := c.peerAddr()
+	return ok && c.trustedProxies.Contains(peer)
+}
+
+// forwardedProto returns "https" or "http" from the first hop of
+// X-Forwarded-Proto, or else of a Forwarded header (RFC 7239), or "" if
+// neither names one
+func forwardedProto(xfp, forwarded string) string {
+	if xfp != "" {
+		xfp, _, _ = strings.Cut(xfp, ",")
+		return normalizeScheme(strings.TrimSpace(xfp))
+	}
+	first, _, _ := strings.Cut(forwarded, ",")
+	for _, pair := range strings.Split(first, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(key, "proto") {
+			return normalizeScheme(strings.Trim(value, `"`))
+		}
+	}
+	return ""
+}
+
+// normalizeScheme returns "https" or "http" for s, or "" for anything else
+func normalizeScheme(s string) string {
+	switch {
+	case strings.EqualFold(s, "https"):
+		return "https"
+	case strings.EqualFold(s, "http"):
+		return "http"
+	}
+	return ""
+}
+
+// Proto returns the request's protocol version, e.g. "HTTP/1.1"
+func (c *StandardContext) Proto() string {
+	return c.request.Proto
+}
+
+// IsTLS reports whether the connection is TLS, e.g. from an alpn.Mux
+// listener
+func (c *StandardContext) IsTLS() bool {
+	return c.TLSState() != nil
+}
+
+// TLSState returns the state of a TLS connection, or nil
+func (c *StandardContext) TLSState() *tls.ConnectionState {
+	conn, ok := c.conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	state := conn.ConnectionState()
+	return &state
+}
+
+// Scheme returns "https" on TLS connections, "http" otherwise
+func (c *StandardContext) Scheme() string {
+	if c.IsTLS() {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,37 @@
+//go:build !fastserver_noprotobuf
+
+package http
+
+import "google.golang.org/protobuf/proto"
+
+// Protobuf sends m encoded as Protocol Buffers
+func (c *FDContext) Protobuf(code int, m proto.Message) {
+	buf := binaryBufPool.Get().(*[]byte)
+	data, err := proto.MarshalOptions{}.MarshalAppend((*buf)[:0], m)
+	if err != nil {
+		binaryBufPool.Put(buf)
+		c.Error(500, "Failed to marshal protobuf")
+		return
+	}
+
+	c.respond(code, c.implicitType("application/x-protobuf"), data)
+	putBinaryBuf(buf, data)
+}
+
+// BindProtobuf decodes a Protocol Buffers request body into m
+func (c *FDContext) BindProtobuf(m proto.Message) error {
+	body, err := c.bodyBytes()
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(body, m)
+}
+
+// bindProtobuf is Bind for protobuf bodies
+func (c *FDContext) bindProtobuf(v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	return c.BindProtobuf(m)
+}
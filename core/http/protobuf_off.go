@@ -0,0 +1,8 @@
+//go:build fastserver_noprotobuf
+
+package http
+
+// bindProtobuf is Bind for protobuf bodies, which this build cannot decode
+func (c *FDContext) bindProtobuf(v any) error {
+	return ErrProtobufDisabled
+}
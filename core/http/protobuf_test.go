@@ -0,0 +1,37 @@
+//go:build !fastserver_noprotobuf
+
+package http
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// TestFDContextProtobuf 测试 Protobuf 响应与绑定
+func TestFDContextProtobuf(t *testing.T) {
+	pb, _ := proto.Marshal(wrapperspb.String("bolt"))
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{
+		Method:      "POST",
+		Path:        "/items",
+		Proto:       "HTTP/1.1",
+		ContentType: "application/x-protobuf",
+		Body:        pb,
+	})
+	var msg wrapperspb.StringValue
+	if err := ctx.Bind(&msg); err != nil || msg.Value != "bolt" {
+		t.Fatalf("Bind = %v, %v", msg.Value, err)
+	}
+	var in struct{ Name string }
+	if err := ctx.Bind(&in); err != ErrNotProtoMessage {
+		t.Errorf("Expected ErrNotProtoMessage, got %v", err)
+	}
+	ctx.Protobuf(200, &msg)
+	if out := read(); !strings.Contains(out, "Content-Type: application/x-protobuf\r\n") ||
+		!strings.HasSuffix(out, "\r\n\r\n"+string(pb)) {
+		t.Errorf("Unexpected response %q", out)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package http
+
+// raceEnabled reports whether the race detector is on; it makes
+// allocations tests cannot count on
+const raceEnabled = true
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"strings"
+	"syscall"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// RawClient is the client end of a connected socket pair, for tests of
+// handlers and middleware: a context writes its response to FD and
+// Response returns the raw bytes that arrived. It is not safe for
+// concurrent use.
+type RawClient struct {
+	FD   int
+	peer int
+	eof  bool
+}
+
+// NewRawClient opens a socket pair. Writes to FD block once the pair's
+// buffer is full; responses larger than that need ServeRaw, which reads
+// while the handler writes.
+func NewRawClient() (*RawClient, error) {
+	fd, peer, err := netfd.Pair()
+	if err != nil {
+		return nil, err
+	}
+	if err := netfd.SetNonblock(peer); err != nil {
+		netfd.Close(fd)
+		netfd.Close(peer)
+		return nil, err
+	}
+	return &RawClient{FD: fd, peer: peer}, nil
+}
+
+// Response returns everything written to FD since the last call
+func (c *RawClient) Response() string {
+	var out strings.Builder
+	buf := make([]byte, 64*1024)
+	for !c.eof {
+		n, err := netfd.Read(c.peer, buf)
+		if n > 0 {
+			out.Write(buf[:n])
+			continue
+		}
+		if err == syscall.EINTR {
+			continue
+		}
+		// EAGAIN: nothing more for now; otherwise the stream has ended
+		c.eof = err != syscall.EAGAIN
+		break
+	}
+	return out.String()
+}
+
+// Close closes both ends
+func (c *RawClient) Close() {
+	netfd.Close(c.FD)
+	netfd.Close(c.peer)
+}
+
+// ServeRaw runs handler on a context for req, finishes the response as
+// the engine does and returns the raw bytes written: status line, headers
+// and the whole body
+func ServeRaw(req *Request, handler func(Context)) (string, error) {
+	c, err := NewRawClient()
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	// Read while the handler writes, so a response of any size gets through
+	out := make(chan string, 1)
+	go func() {
+		var sb strings.Builder
+		for !c.eof {
+			if err := netfd.WaitReadable(c.peer, -1); err != nil {
+				break
+			}
+			sb.WriteString(c.Response())
+		}
+		out <- sb.String()
+	}()
+
+	ctx := NewFDContext(c.FD, req)
+	handler(ctx)
+	ctx.Finish()
+	netfd.Shutdown(c.FD)
+	return <-out, nil
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"maps"
+	"strconv"
+)
+
+// RecordedResponse is a response captured by Record, e.g. for a cache.
+// The body is stored uncompressed; Replay applies the route's defaults.
+type RecordedResponse struct {
+	Status      int
+	ContentType string
+	Header      map[string]string
+	Cookies     []string
+	Body        []byte
+}
+
+// Size returns the approximate memory held by the response
+func (r *RecordedResponse) Size() int {
+	n := len(r.ContentType) + len(r.Body)
+	for k, v := range r.Header {
+		n += len(k) + len(v)
+	}
+	for _, c := range r.Cookies {
+		n += len(c)
+	}
+	return n
+}
+
+// Record captures the next response written by String, JSON, Bytes, Data
+// or Error. Status-only and streamed responses are not captured.
+func (c *FDContext) Record() {
+	c.recording = true
+	c.recorded = nil
+}
+
+// Recorded returns the captured response, or nil if none was written
+func (c *FDContext) Recorded() *RecordedResponse {
+	return c.recorded
+}
+
+// record captures a response passed to respond
+func (c *FDContext) record(code int, contentType string, body []byte) {
+	if code == 0 {
+		code = c.statusCode
+	}
+	r := &RecordedResponse{
+		Status:      code,
+		ContentType: contentType,
+		Header:      maps.Clone(c.responseHeaders),
+		Body:        append([]byte(nil), body...),
+	}
+	if len(c.responseCookies) > 0 {
+		r.Cookies = append([]string(nil), c.responseCookies...)
+	}
+	c.recorded = r
+}
+
+// Replay writes a recorded response, with age (seconds) sent as the Age
+// header when non-negative. Headers the handler already set win.
+func (c *FDContext) Replay(r *RecordedResponse, age int) {
+	for k, v := range r.Header {
+		if _, ok := c.responseHeaders[k]; !ok {
+			c.SetHeader(k, v)
+		}
+	}
+	c.responseCookies = append(c.responseCookies, r.Cookies...)
+	if age >= 0 {
+		c.SetHeader("Age", strconv.Itoa(age))
+	}
+	c.respond(r.Status, r.ContentType, r.Body)
+}
+
+// Hold queues the response instead of sending it, so the caller can
+// inspect it and then drop it with Discard or send it with Release.
+func (c *FDContext) Hold() {
+	if c.batch == nil {
+		c.holdBuf = c.holdBuf[:0]
+		c.batch = &c.holdBuf
+	}
+	c.holdMark = len(*c.batch)
+}
+
+// Discard drops a held response so another can be written in its place.
+// It reports false if the response was streamed and has already been sent.
+func (c *FDContext) Discard() bool {
+	if c.written && len(*c.batch) <= c.holdMark {
+		c.endHold()
+		return false
+	}
+	*c.batch = (*c.batch)[:c.holdMark]
+	c.endHold()
+
+	clear(c.responseHeaders)
+	c.responseCookies = c.responseCookies[:0]
+	c.statusCode = 200
+	c.written = false
+	c.writeErr = nil
+	c.recorded = nil
+	return true
+}
+
+// Release sends a held response
+func (c *FDContext) Release() error {
+	if c.batch == &c.holdBuf {
+		err := c.FlushBatch()
+		c.endHold()
+		return err
+	}
+	c.endHold()
+	return c.writeErr
+}
+
+// endHold stops holding; a queue owned by the engine stays in place
+func (c *FDContext) endHold() {
+	if c.batch == &c.holdBuf {
+		c.batch = nil
+	}
+	c.holdMark = 0
+}
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+)
+
+// ErrNoTemplates is returned by Render when no registry is set
+var ErrNoTemplates = errors.New("no templates registered")
+
+var templateBufPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// SetTemplates sets the registry Render uses
+func (c *FDContext) SetTemplates(t *Templates) {
+	c.templates = t
+}
+
+// Render executes the template named name with data and sends the
+// result as text/html. The page is rendered before anything is sent, so a
+// failing template is answered with 500 and its error returned.
+func (c *FDContext) Render(code int, name string, data any) error {
+	if c.templates == nil {
+		c.Error(500, "Internal Server Error")
+		return ErrNoTemplates
+	}
+
+	buf := templateBufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer templateBufPool.Put(buf)
+	if err := c.templates.Execute(buf, name, data); err != nil {
+		c.Error(500, "Internal Server Error")
+		return err
+	}
+	c.Data(code, "text/html; charset=utf-8", buf.Bytes())
+	return nil
+}
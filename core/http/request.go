@@ -1,6 +1,11 @@
 package http
 
-import "sync"
+import (
+	"iter"
+	"net/textproto"
+	"strings"
+	"sync"
+)
 
 // Request is a zero-allocation HTTP request structure
 type Request struct {
@@ -19,11 +24,32 @@ type Request struct {
 	// Extra headers (allocated only when needed)
 	ExtraHeaders map[string]string
 
-	// Query parameters
-	Query map[string]string
+	// Every value of headers sent more than once, by canonical key. The
+	// first value is also in its field or ExtraHeaders.
+	Repeated map[string][]string
+
+	// Query parameters: the first maxQueryParams in a fixed array
+	// (zero-allocation), the rest in Query. Read them with QueryValue and
+	// QueryParams; parsed keys and values point into the request data.
+	queryKeys   [maxQueryParams]string
+	queryValues [maxQueryParams]string
+	queryCount  int
+	Query       map[string]string
+	// RawQuery is the query string, without the '?'
+	RawQuery string
 
 	// Request body
 	Body []byte
+
+	// Trailers are the trailer fields sent after a chunked body, by
+	// canonical key
+	Trailers map[string]string
+
+	// Spool holds a body spilled to a BodyStore (Body is then empty)
+	Spool SpooledBody
+
+	// HeaderSize is the size of the request line plus the header block
+	HeaderSize int
 }
 
 var requestPool = sync.Pool{
@@ -56,15 +82,73 @@ func (r *Request) Reset() {
 			delete(r.ExtraHeaders, k)
 		}
 	}
+	clear(r.Repeated)
 
+	clear(r.queryKeys[:r.queryCount])
+	clear(r.queryValues[:r.queryCount])
+	r.queryCount = 0
+	r.RawQuery = ""
 	if r.Query != nil {
 		for k := range r.Query {
 			delete(r.Query, k)
 		}
 	}
 
+	clear(r.Trailers)
+
 	// Keep slice capacity, just reset length
 	r.Body = r.Body[:0]
+	r.Spool = nil
+	r.HeaderSize = 0
+}
+
+// Clone returns a deep copy of r that stays valid after r is released
+// (the parser's strings point into the connection's read buffer)
+func (r *Request) Clone() *Request {
+	c := &Request{
+		Method:        strings.Clone(r.Method),
+		Path:          strings.Clone(r.Path),
+		RawQuery:      strings.Clone(r.RawQuery),
+		Proto:         strings.Clone(r.Proto),
+		ContentType:   r.ContentType,
+		ContentLength: r.ContentLength,
+		UserAgent:     r.UserAgent,
+		Accept:        r.Accept,
+		Host:          r.Host,
+		Connection:    r.Connection,
+		Body:          append([]byte(nil), r.Body...),
+		HeaderSize:    r.HeaderSize,
+	}
+	if len(r.ExtraHeaders) > 0 {
+		c.ExtraHeaders = make(map[string]string, len(r.ExtraHeaders))
+		for k, v := range r.ExtraHeaders {
+			c.ExtraHeaders[k] = v
+		}
+	}
+	if len(r.Repeated) > 0 {
+		c.Repeated = make(map[string][]string, len(r.Repeated))
+		for k, v := range r.Repeated {
+			c.Repeated[k] = append([]string(nil), v...)
+		}
+	}
+	for i := 0; i < r.queryCount; i++ {
+		c.queryKeys[i] = strings.Clone(r.queryKeys[i])
+		c.queryValues[i] = strings.Clone(r.queryValues[i])
+	}
+	c.queryCount = r.queryCount
+	if len(r.Query) > 0 {
+		c.Query = make(map[string]string, len(r.Query))
+		for k, v := range r.Query {
+			c.Query[strings.Clone(k)] = strings.Clone(v)
+		}
+	}
+	if len(r.Trailers) > 0 {
+		c.Trailers = make(map[string]string, len(r.Trailers))
+		for k, v := range r.Trailers {
+			c.Trailers[k] = v
+		}
+	}
+	return c
 }
 
 func ReleaseRequest(req *Request) {
@@ -72,8 +156,217 @@ func ReleaseRequest(req *Request) {
 	requestPool.Put(req)
 }
 
-// SetHeader sets a header (prioritizes predefined fields)
+// SetHeader sets a header (prioritizes predefined fields), replacing any
+// previous values. The key is canonicalized ("content-type" is stored as
+// Content-Type).
 func (r *Request) SetHeader(key, value string) {
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	delete(r.Repeated, key)
+	if !r.setField(key, value) {
+		if r.ExtraHeaders == nil {
+			r.ExtraHeaders = make(map[string]string)
+		}
+		r.ExtraHeaders[key] = value
+	}
+}
+
+// AddHeader adds a value to a header. Header keeps returning the first
+// value; HeaderValues returns all of them.
+func (r *Request) AddHeader(key, value string) {
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	first, ok := r.lookup(key)
+	if !ok {
+		r.SetHeader(key, value)
+		return
+	}
+	if r.Repeated == nil {
+		r.Repeated = make(map[string][]string)
+	}
+	values := r.Repeated[key]
+	if len(values) == 0 {
+		values = append(values, first)
+	}
+	r.Repeated[key] = append(values, value)
+}
+
+// DuplicateHeaders is how a header sent more than once is presented to
+// handlers (see Engine.SetDuplicateHeaders). A repeated Host, or
+// Content-Length with differing values, is rejected whatever the policy.
+type DuplicateHeaders int
+
+const (
+	// DuplicateList keeps every value: Header returns the first and
+	// HeaderValues all of them (default)
+	DuplicateList DuplicateHeaders = iota
+	// DuplicateCombine joins the values into one (RFC 9110 5.3), so
+	// Header returns them all: comma-separated, or "; "-separated for
+	// Cookie
+	DuplicateCombine
+)
+
+// CombineRepeated joins the values of each repeated header into one, as
+// DuplicateCombine does
+func (r *Request) CombineRepeated() {
+	for key, values := range r.Repeated {
+		sep := ", "
+		if key == "Cookie" {
+			sep = "; "
+		}
+		r.SetHeader(key, strings.Join(values, sep)) // Drops the list
+	}
+}
+
+// Header returns the first value of a request header. The key is
+// matched case-insensitively.
+func (r *Request) Header(key string) string {
+	if v, ok := r.lookup(key); ok {
+		return v
+	}
+	// Not in canonical form, or ExtraHeaders filled directly: compare
+	// without case, without allocating
+	for _, name := range predefinedHeaders {
+		if strings.EqualFold(name, key) {
+			v, _ := r.lookup(name)
+			return v
+		}
+	}
+	for k, v := range r.ExtraHeaders {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// HeaderValues returns every value of a request header, in the order
+// they were sent. The key is matched case-insensitively.
+func (r *Request) HeaderValues(key string) []string {
+	if values, ok := r.Repeated[textproto.CanonicalMIMEHeaderKey(key)]; ok {
+		return values
+	}
+	if v := r.Header(key); v != "" {
+		return []string{v}
+	}
+	return nil
+}
+
+// KeepAlive reports whether the client asked to keep the connection open
+// after the response: by default for HTTP/1.1 unless Connection lists
+// close, and for HTTP/1.0 only if it lists keep-alive
+func (r *Request) KeepAlive() bool {
+	if r.Proto == "HTTP/1.0" {
+		return hasToken(r.Connection, "keep-alive")
+	}
+	return !hasToken(r.Connection, "close")
+}
+
+// hasToken reports whether a comma-separated header value lists token,
+// without case
+func hasToken(list, token string) bool {
+	for list != "" {
+		var item string
+		item, list, _ = strings.Cut(list, ",")
+		if strings.EqualFold(strings.TrimSpace(item), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxQueryParams is how many query parameters a Request stores without
+// allocating
+const maxQueryParams = 8
+
+// QueryValue returns a query parameter, "" if it is absent. The value is
+// not unescaped.
+func (r *Request) QueryValue(key string) string {
+	for i := 0; i < r.queryCount; i++ {
+		if r.queryKeys[i] == key {
+			return r.queryValues[i]
+		}
+	}
+	if r.Query != nil {
+		return r.Query[key]
+	}
+	return ""
+}
+
+// SetQuery sets a query parameter, replacing any previous value
+func (r *Request) SetQuery(key, value string) {
+	for i := 0; i < r.queryCount; i++ {
+		if r.queryKeys[i] == key {
+			r.queryValues[i] = value
+			return
+		}
+	}
+	if _, ok := r.Query[key]; !ok && r.queryCount < maxQueryParams {
+		r.queryKeys[r.queryCount] = key
+		r.queryValues[r.queryCount] = value
+		r.queryCount++
+		return
+	}
+	if r.Query == nil {
+		r.Query = make(map[string]string)
+	}
+	r.Query[key] = value
+}
+
+// QueryParams iterates over the query parameters: those that fit in the
+// fixed array in the order they were sent, then the rest
+func (r *Request) QueryParams() iter.Seq2[string, string] {
+	return func(yield func(key, value string) bool) {
+		for i := 0; i < r.queryCount; i++ {
+			if !yield(r.queryKeys[i], r.queryValues[i]) {
+				return
+			}
+		}
+		for k, v := range r.Query {
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// QueryLen returns the number of query parameters
+func (r *Request) QueryLen() int {
+	return r.queryCount + len(r.Query)
+}
+
+// Trailer returns a trailer field of a chunked body. The key is matched
+// case-insensitively.
+func (r *Request) Trailer(key string) string {
+	if v, ok := r.Trailers[key]; ok {
+		return v
+	}
+	return r.Trailers[textproto.CanonicalMIMEHeaderKey(key)]
+}
+
+// predefinedHeaders are the headers with a field of their own
+var predefinedHeaders = [...]string{"Content-Type", "Content-Length", "User-Agent", "Accept", "Host", "Connection"}
+
+// lookup returns a header by its canonical key
+func (r *Request) lookup(key string) (string, bool) {
+	switch key {
+	case "Content-Type":
+		return r.ContentType, r.ContentType != ""
+	case "Content-Length":
+		return r.ContentLength, r.ContentLength != ""
+	case "User-Agent":
+		return r.UserAgent, r.UserAgent != ""
+	case "Accept":
+		return r.Accept, r.Accept != ""
+	case "Host":
+		return r.Host, r.Host != ""
+	case "Connection":
+		return r.Connection, r.Connection != ""
+	}
+	v, ok := r.ExtraHeaders[key]
+	return v, ok
+}
+
+// setField sets a predefined header field, reporting false for other keys
+func (r *Request) setField(key, value string) bool {
 	switch key {
 	case "Content-Type":
 		r.ContentType = value
@@ -88,9 +381,7 @@ func (r *Request) SetHeader(key, value string) {
 	case "Connection":
 		r.Connection = value
 	default:
-		if r.ExtraHeaders == nil {
-			r.ExtraHeaders = make(map[string]string)
-		}
-		r.ExtraHeaders[key] = value
+		return false
 	}
+	return true
 }
@@ -0,0 +1,195 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// ErrBodyLength is returned when a body outgrows the Content-Length
+// declared through a ResponseWriter
+var ErrBodyLength = errors.New("response body exceeds declared Content-Length")
+
+// ResponseWriter is the low-level response interface for serializers that
+// write responses themselves (FlatBuffers, Cap'n Proto, custom text
+// protocols) instead of handing a finished []byte to Bytes or Data.
+//
+// The status and headers are sent with the first body chunk, or when the
+// handler returns. Declaring Content-Length sends the body as written;
+// otherwise small bodies get a Content-Length and larger ones are chunked,
+// as with Writer.
+type ResponseWriter interface {
+	io.Writer
+
+	// WriteHeaderLine sets the status line's code
+	WriteHeaderLine(code int)
+	// WriteHeader sets a response header
+	WriteHeader(key, value string)
+	// WriteBodyChunk sends p as part of the body
+	WriteBodyChunk(p []byte) error
+}
+
+// ResponseWriter returns the low-level writer of the response
+func (c *FDContext) ResponseWriter() ResponseWriter {
+	c.rw.c = c
+	return &c.rw
+}
+
+// fdResponseWriter is the ResponseWriter of an FDContext
+type fdResponseWriter struct {
+	c *FDContext
+	// Declared Content-Length and the bytes of it still to send
+	sized     bool
+	length    int
+	remaining int
+}
+
+func (w *fdResponseWriter) WriteHeaderLine(code int) {
+	w.c.Status(code)
+}
+
+func (w *fdResponseWriter) WriteHeader(key, value string) {
+	if !strings.EqualFold(key, "Content-Length") {
+		w.c.SetHeader(key, value)
+		return
+	}
+	if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+		w.sized, w.length, w.remaining = true, n, n
+	}
+}
+
+func (w *fdResponseWriter) Write(p []byte) (int, error) {
+	if err := w.WriteBodyChunk(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *fdResponseWriter) WriteBodyChunk(p []byte) error {
+	c := w.c
+	if !w.sized {
+		_, err := c.Writer().Write(p)
+		return err
+	}
+
+	if c.Disconnected() {
+		return ErrClientDisconnected
+	}
+	if len(p) > w.remaining {
+		return ErrBodyLength
+	}
+	if !c.streaming {
+		if err := c.startSized(c.implicitType("application/octet-stream"), w.length); err != nil {
+			return err
+		}
+	}
+	w.remaining -= len(p)
+	return c.writeChunk(p)
+}
+
+// finish keeps the connection from being reused if less body was sent
+// than declared, since the client would read the next response as body
+func (w *fdResponseWriter) finish() {
+	if !w.sized {
+		return
+	}
+	c := w.c
+	if !c.written {
+		// Nothing sent: the head still declares the length
+		c.startSized(c.implicitType("application/octet-stream"), w.length)
+	}
+	if w.remaining > 0 && c.bodyAllowed() {
+		c.noKeepAlive = true
+	}
+}
+
+// startSized sends the head of a streamed response of known length, whose
+// body is written as is
+func (c *FDContext) startSized(contentType string, length int) error {
+	if c.written {
+		return ErrResponseWritten
+	}
+	if err := c.FlushBatch(); err != nil {
+		return err
+	}
+
+	c.startResponse(0, contentType, length)
+	c.streaming = true
+	c.rawBody = true
+	return c.writeAll(c.responseBuf, netfd.Write)
+}
+
+// ResponseWriter returns the low-level writer of the response. Without a
+// declared Content-Length the body is delimited by closing the connection.
+func (c *StandardContext) ResponseWriter() ResponseWriter {
+	return &connResponseWriter{c: c, code: 200, length: -1}
+}
+
+// connResponseWriter is the ResponseWriter of a StandardContext
+type connResponseWriter struct {
+	c       *StandardContext
+	code    int
+	headers []string
+	length  int
+	sent    bool
+}
+
+func (w *connResponseWriter) WriteHeaderLine(code int) {
+	w.code = code
+}
+
+func (w *connResponseWriter) WriteHeader(key, value string) {
+	if strings.ContainsAny(key, "\r\n") || strings.ContainsAny(value, "\r\n") {
+		return
+	}
+	if strings.EqualFold(key, "Content-Length") {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			w.length = n
+		}
+		return
+	}
+	w.headers = append(w.headers, key, value)
+}
+
+func (w *connResponseWriter) Write(p []byte) (int, error) {
+	if err := w.WriteBodyChunk(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *connResponseWriter) WriteBodyChunk(p []byte) error {
+	c := w.c
+	if !w.sent {
+		w.sent = true
+		buf := c.responseBuf[:0]
+		buf = append(buf, "HTTP/1.1 "...)
+		buf = appendInt(buf, w.code)
+		buf = append(buf, ' ')
+		buf = append(buf, statusText(w.code)...)
+		buf = append(buf, "\r\n"...)
+		for i := 0; i < len(w.headers); i += 2 {
+			buf = appendHeader(buf, w.headers[i], w.headers[i+1])
+		}
+		if w.length >= 0 {
+			buf = append(buf, "Content-Length: "...)
+			buf = appendInt(buf, w.length)
+			buf = append(buf, "\r\n"...)
+		} else {
+			buf = append(buf, "Connection: close\r\n"...)
+		}
+		buf = append(buf, "\r\n"...)
+		c.responseBuf = buf
+		if _, err := c.conn.Write(buf); err != nil {
+			return err
+		}
+	}
+	if len(p) == 0 || c.isHead() {
+		return nil
+	}
+	_, err := c.conn.Write(p)
+	return err
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"bytes"
+	"strings"
+)
+
+// A request's data lives in buffers that are reused once the handler
+// returns (or, for Async and Detach, once the response is complete):
+//
+//   - Method, Path, Proto, query values and route parameters point into
+//     the connection's read buffer, which takes the next request on the
+//     connection or goes back to the engine's byte pool
+//   - Body belongs to the pooled Request and takes another request's body
+//   - the FDContext itself is pooled
+//
+// Header values are copies and stay valid. Anything else a handler keeps
+// longer (in a cache, a goroutine, a batch) must be copied: strings.Clone
+// for one value, BodyCopy for the body, Retain for all of it.
+
+// BodyCopy returns a copy of the in-memory request body, which the handler
+// may keep after it returns
+func (c *FDContext) BodyCopy() []byte {
+	return bytes.Clone(c.request.Body)
+}
+
+// Retain copies the request out of the read buffer and the request pool,
+// so that what the context returns from then on (Method, Path, Param,
+// Query, Body, Request) may be kept after the handler returns. Values read
+// before the call still point into the buffers. The context itself is
+// still reused: keep the values or the returned Request, not the context.
+// A body spilled to a BodyStore is removed after the request all the
+// same.
+func (c *FDContext) Retain() *Request {
+	if c.retained {
+		return c.request
+	}
+	req := c.request.Clone()
+	req.Spool = c.request.Spool
+	c.request = req
+	for i := 0; i < c.paramCount && i < 4; i++ {
+		c.paramKeys[i] = strings.Clone(c.paramKeys[i])
+		c.paramValues[i] = strings.Clone(c.paramValues[i])
+	}
+	for k, v := range c.paramMapOverflow {
+		c.paramMapOverflow[k] = strings.Clone(v)
+	}
+	c.retained = true
+	return req
+}
@@ -0,0 +1,80 @@
+//go:build linux
+
+package http
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// spliceChunk is the most moved through the pipe per splice call
+const spliceChunk = 64 * 1024
+
+// SpliceFrom relays up to n bytes (n < 0: until EOF) from upstream to the
+// client with splice(2) through a pipe, so the data never enters user
+// space. Bytes are relayed as-is: the handler either writes its own status
+// line and headers first or relays an upstream's complete HTTP response.
+func (c *FDContext) SpliceFrom(upstream syscall.Conn, n int64) (int64, error) {
+	if err := c.FlushBatch(); err != nil {
+		return 0, err
+	}
+	if err := c.drainBacklog(); err != nil {
+		return 0, err
+	}
+	c.written = true
+
+	rc, err := upstream.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var p [2]int
+	if err := unix.Pipe2(p[:], unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+		return 0, err
+	}
+	defer unix.Close(p[0])
+	defer unix.Close(p[1])
+
+	var total int64
+	for n < 0 || total < n {
+		chunk := spliceChunk
+		if n >= 0 && n-total < int64(chunk) {
+			chunk = int(n - total)
+		}
+
+		// Upstream -> pipe, waiting on the Go poller while upstream is empty
+		var moved int64
+		var serr error
+		err := rc.Read(func(fd uintptr) bool {
+			moved, serr = unix.Splice(int(fd), nil, p[1], nil, chunk, unix.SPLICE_F_MOVE|unix.SPLICE_F_NONBLOCK)
+			return serr != unix.EAGAIN
+		})
+		if err == nil {
+			err = serr
+		}
+		if err != nil {
+			return total, err
+		}
+		if moved == 0 {
+			break // upstream EOF
+		}
+
+		// Pipe -> client
+		var stall writeStall
+		for moved > 0 {
+			w, err := unix.Splice(p[0], nil, c.fd, nil, int(moved), unix.SPLICE_F_MOVE|unix.SPLICE_F_NONBLOCK)
+			if err != nil {
+				if c.retryWrite(err, &stall) {
+					continue
+				}
+				return total, c.writeErr
+			}
+			moved -= w
+			total += w
+			c.bytesSent += w
+		}
+		c.endStall(&stall)
+	}
+	return total, nil
+}
@@ -0,0 +1,45 @@
+//go:build !linux
+
+package http
+
+import (
+	"io"
+	"syscall"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// SpliceFrom relays up to n bytes (n < 0: until EOF) from upstream to the
+// client. Platforms without splice(2) copy through a user-space buffer.
+func (c *FDContext) SpliceFrom(upstream syscall.Conn, n int64) (int64, error) {
+	if err := c.FlushBatch(); err != nil {
+		return 0, err
+	}
+	c.written = true
+
+	r, ok := upstream.(io.Reader)
+	if !ok {
+		return 0, syscall.EINVAL
+	}
+	if n >= 0 {
+		r = io.LimitReader(r, n)
+	}
+
+	buf := make([]byte, 64*1024)
+	var total int64
+	for {
+		nr, err := r.Read(buf)
+		if nr > 0 {
+			if werr := c.writeAll(buf[:nr], netfd.Write); werr != nil {
+				return total, werr
+			}
+			total += int64(nr)
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
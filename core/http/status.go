@@ -0,0 +1,98 @@
+package http
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Status texts registered with RegisterStatusText, copied on write so
+// responses read them without locking
+var (
+	customStatusText   atomic.Pointer[map[int]string]
+	customStatusTextMu sync.Mutex
+)
+
+// statusLines holds "HTTP/1.1 <code> <text>\r\nDate: " of the IANA
+// codes, the start of NoContent's responses
+var statusLines = func() (lines [600][]byte) {
+	for code := 100; code < len(lines); code++ {
+		if text := nethttp.StatusText(code); text != "" {
+			lines[code] = []byte("HTTP/1.1 " + strconv.Itoa(code) + " " + text + "\r\nDate: ")
+		}
+	}
+	return lines
+}()
+
+// statusLine returns the precomputed start of a response with code, or
+// nil if there is none or its text was replaced with RegisterStatusText
+func statusLine(code int) []byte {
+	if code < 0 || code >= len(statusLines) {
+		return nil
+	}
+	if custom := customStatusText.Load(); custom != nil {
+		if _, ok := (*custom)[code]; ok {
+			return nil
+		}
+	}
+	return statusLines[code]
+}
+
+// RegisterStatusText sets the reason phrase sent with code, for codes
+// outside the IANA registry (499 Client Closed Request) or to replace a
+// standard one. It panics if code is not a three-digit status or text
+// contains CR or LF.
+func RegisterStatusText(code int, text string) {
+	if code < 100 || code > 999 {
+		panic(fmt.Sprintf("http: invalid status code %d", code))
+	}
+	if strings.ContainsAny(text, "\r\n") {
+		panic(fmt.Sprintf("http: invalid status text %q", text))
+	}
+
+	customStatusTextMu.Lock()
+	defer customStatusTextMu.Unlock()
+	texts := make(map[int]string)
+	if old := customStatusText.Load(); old != nil {
+		for c, t := range *old {
+			texts[c] = t
+		}
+	}
+	texts[code] = text
+	customStatusText.Store(&texts)
+}
+
+// StatusText returns the reason phrase sent with code: the registered
+// text, the IANA one, or "status code <code>" for unregistered codes
+func StatusText(code int) string {
+	return statusText(code)
+}
+
+// statusText returns the HTTP status text for the given code
+func statusText(code int) string {
+	if custom := customStatusText.Load(); custom != nil {
+		if text, ok := (*custom)[code]; ok {
+			return text
+		}
+	}
+	switch code {
+	case 200:
+		return "OK"
+	case 201:
+		return "Created"
+	case 400:
+		return "Bad Request"
+	case 404:
+		return "Not Found"
+	case 500:
+		return "Internal Server Error"
+	default:
+		if text := nethttp.StatusText(code); text != "" {
+			return text
+		}
+		return "status code " + strconv.Itoa(code)
+	}
+}
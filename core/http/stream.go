@@ -0,0 +1,170 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// ErrResponseWritten is returned when a stream is started after a
+// complete response was already written
+var ErrResponseWritten = errors.New("response already written")
+
+var (
+	crlf      = []byte("\r\n")
+	lastChunk = []byte("0\r\n\r\n")
+)
+
+// Stream sends a streamed response without registering with an SSE
+// Broker. step is called repeatedly with a writer for the body, and what
+// each call writes is sent to the client as one chunk. Streaming stops
+// when step returns false or the client disconnects; Stream reports
+// whether the client went away. Status and headers set beforehand are sent
+// first. Long-lived streams should run off the event loop (Offload or
+// Detach).
+func (c *FDContext) Stream(step func(w io.Writer) bool) bool {
+	if err := c.startStream(c.implicitType("")); err != nil {
+		return c.writeErr != nil
+	}
+
+	w := &streamWriter{c: c}
+	for {
+		if c.Disconnected() {
+			return true
+		}
+		more := step(w)
+		if err := c.writeChunk(w.buf); err != nil {
+			return true
+		}
+		w.buf = w.buf[:0]
+		if !more {
+			return false
+		}
+	}
+}
+
+// SSEvent sends a server-sent event, starting a text/event-stream response
+// on the first call. data is sent as-is if it is a string or []byte and as
+// JSON otherwise; multi-line data becomes several data lines. It returns
+// the write error, e.g. once the client has disconnected.
+func (c *FDContext) SSEvent(event string, data any) error {
+	if !c.streaming {
+		if c.responseHeaders["Cache-Control"] == "" {
+			c.SetHeader("Cache-Control", "no-cache")
+		}
+		c.SetHeader("X-Accel-Buffering", "no")
+		if err := c.startStream("text/event-stream"); err != nil {
+			return err
+		}
+	}
+
+	var payload []byte
+	switch v := data.(type) {
+	case string:
+		payload = []byte(v)
+	case []byte:
+		payload = v
+	default:
+		var err error
+		if payload, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+
+	buf := c.responseBuf[:0]
+	if event != "" {
+		buf = append(buf, "event: "...)
+		buf = append(buf, event...)
+		buf = append(buf, '\n')
+	}
+	for {
+		line, rest, found := bytes.Cut(payload, []byte("\n"))
+		buf = append(buf, "data: "...)
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+		if !found {
+			break
+		}
+		payload = rest
+	}
+	buf = append(buf, '\n')
+	c.responseBuf = buf
+
+	return c.writeChunk(buf)
+}
+
+// Finish completes the response after the handler returns: it sends what
+// a Writer buffered (or the head of a ResponseWriter's declared body),
+// sends the status and headers if nothing was written, ends an open
+// stream and removes the temporary files of a multipart form. A request
+// past its deadline gets 504 instead (see SetDeadline). Called by the
+// engine.
+func (c *FDContext) Finish() {
+	c.body.finish()
+	c.rw.finish()
+	c.releaseMultipart()
+	if c.timedOut.Load() && !c.written {
+		c.replyTimeout()
+		return
+	}
+	if !c.written {
+		c.WriteStatus()
+		return
+	}
+	if c.streaming {
+		c.streaming = false
+		if c.bodyAllowed() && c.request.Proto != "HTTP/1.0" && !c.rawBody {
+			c.writeLastChunk()
+		}
+	}
+}
+
+// startStream sends the head of a streamed response once
+func (c *FDContext) startStream(contentType string) error {
+	if c.streaming {
+		return c.writeErr
+	}
+	if c.written {
+		return ErrResponseWritten
+	}
+	if err := c.FlushBatch(); err != nil {
+		return err
+	}
+
+	c.startResponse(0, contentType, -1)
+	c.streaming = true
+	return c.writeAll(c.responseBuf, netfd.Write)
+}
+
+// writeChunk sends p as one chunk of the streamed body
+func (c *FDContext) writeChunk(p []byte) error {
+	if len(p) == 0 || !c.bodyAllowed() {
+		return c.writeErr
+	}
+	if c.request.Proto == "HTTP/1.0" || c.rawBody {
+		return c.writeAll(p, netfd.Write)
+	}
+
+	var size [16]byte
+	head := strconv.AppendInt(size[:0], int64(len(p)), 16)
+	head = append(head, crlf...)
+	return c.writeVectored(head, p, crlf)
+}
+
+// streamWriter collects what a Stream step writes
+type streamWriter struct {
+	c   *FDContext
+	buf []byte
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if w.c.writeErr != nil {
+		return 0, w.c.writeErr
+	}
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
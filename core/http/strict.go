@@ -0,0 +1,159 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// CheckStrict validates the request line and header block at the start of
+// data against RFC 7230, for servers behind proxies where a lenient parse
+// that disagrees with the proxy's enables request smuggling. It rejects:
+//
+//   - bare LF line endings and obsolete line folding
+//   - whitespace between a field name and its colon, and invalid field
+//     names or values
+//   - a request target with spaces, controls or non-ASCII bytes
+//   - Content-Length together with Transfer-Encoding, differing or
+//     malformed Content-Length values (lists included), and a
+//     Transfer-Encoding that does not end with chunked
+//   - an HTTP/1.1 request without exactly one Host header
+//
+// Errors wrap ErrInvalidRequest. data must hold the complete header block.
+func CheckStrict(data []byte) error {
+	end := bytes.Index(data, []byte("\r\n\r\n"))
+	if end == -1 {
+		return strictError("header block not terminated by CRLF CRLF")
+	}
+	block := data[:end+2]
+	if bytes.Count(block, []byte("\n")) != bytes.Count(block, []byte("\r\n")) {
+		return strictError("bare LF line ending")
+	}
+
+	lineEnd := bytes.Index(block, []byte("\r\n"))
+	http11, err := checkRequestLine(block[:lineEnd])
+	if err != nil {
+		return err
+	}
+
+	var (
+		contentLength  []byte
+		hasLength      bool
+		transferCoding []byte
+		hasTransfer    bool
+		hosts          int
+	)
+	for rest := block[lineEnd+2:]; len(rest) > 0; {
+		i := bytes.Index(rest, []byte("\r\n"))
+		line := rest[:i]
+		rest = rest[i+2:]
+
+		if line[0] == ' ' || line[0] == '\t' {
+			return strictError("obsolete line folding")
+		}
+		colon := bytes.IndexByte(line, ':')
+		if colon <= 0 {
+			return strictError("header line without a field name")
+		}
+		name, value := line[:colon], bytes.Trim(line[colon+1:], " \t")
+		for _, ch := range name {
+			if ch == ' ' || ch == '\t' {
+				return strictError("whitespace before colon")
+			}
+			if !isTokenChar(ch) {
+				return strictError("invalid character in field name")
+			}
+		}
+		for _, ch := range value {
+			if ch < 0x20 && ch != '\t' || ch == 0x7f {
+				return strictError("control character in field value")
+			}
+		}
+
+		switch {
+		case bytes.EqualFold(name, []byte("Content-Length")):
+			// A list ("5, 5") is rejected too: framing reads one number
+			if len(value) == 0 || !isDigits(value) {
+				return strictError("malformed Content-Length")
+			}
+			if hasLength && !bytes.Equal(value, contentLength) {
+				return strictError("conflicting Content-Length values")
+			}
+			contentLength, hasLength = value, true
+		case bytes.EqualFold(name, []byte("Transfer-Encoding")):
+			transferCoding, hasTransfer = value, true
+		case bytes.EqualFold(name, []byte("Host")):
+			hosts++
+		}
+	}
+
+	if hasLength && hasTransfer {
+		return strictError("both Content-Length and Transfer-Encoding")
+	}
+	if hasTransfer {
+		codings := bytes.Split(transferCoding, []byte(","))
+		if !bytes.EqualFold(bytes.Trim(codings[len(codings)-1], " \t"), []byte("chunked")) {
+			return strictError("Transfer-Encoding does not end with chunked")
+		}
+	}
+	if http11 && hosts != 1 {
+		return strictError("HTTP/1.1 request without exactly one Host header")
+	}
+	return nil
+}
+
+// checkRequestLine validates "METHOD SP request-target SP HTTP-version"
+// and reports whether the version is HTTP/1.1
+func checkRequestLine(line []byte) (http11 bool, err error) {
+	sp1 := bytes.IndexByte(line, ' ')
+	if sp1 <= 0 {
+		return false, strictError("malformed request line")
+	}
+	for _, ch := range line[:sp1] {
+		if !isTokenChar(ch) {
+			return false, strictError("invalid method")
+		}
+	}
+
+	sp2 := bytes.LastIndexByte(line, ' ')
+	target, proto := line[sp1+1:sp2], line[sp2+1:]
+	if sp2 == sp1 || len(target) == 0 {
+		return false, strictError("malformed request line")
+	}
+	for _, ch := range target {
+		if ch <= ' ' || ch >= 0x7f {
+			return false, strictError("invalid character in request target")
+		}
+	}
+
+	switch string(proto) {
+	case "HTTP/1.1":
+		return true, nil
+	case "HTTP/1.0":
+		return false, nil
+	}
+	return false, strictError("unsupported HTTP version")
+}
+
+// strictError returns a CheckStrict error
+func strictError(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRequest, reason)
+}
+
+// isTokenChar reports whether ch may appear in an RFC 7230 token
+func isTokenChar(ch byte) bool {
+	switch {
+	case 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', '0' <= ch && ch <= '9':
+		return true
+	}
+	return bytes.IndexByte([]byte("!#$%&'*+-.^_`|~"), ch) >= 0
+}
+
+// isDigits reports whether b is all ASCII digits
+func isDigits(b []byte) bool {
+	for _, ch := range b {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,145 @@
+//go:build !fastserver_notemplate
+
+package http
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/searchktools/fast-server/core/clock"
+)
+
+// templateReloadInterval is how often a reloading registry checks its
+// files for changes
+const templateReloadInterval = time.Second
+
+// TemplateConfig configures a template registry (see NewTemplates)
+type TemplateConfig struct {
+	// FS holds the template files. Default: the working directory.
+	FS fs.FS
+
+	// Patterns select the files, as for fs.Glob, e.g. "views/*.html".
+	// Templates are named after their file's base name.
+	Patterns []string
+
+	// Funcs are made available to every template
+	Funcs template.FuncMap
+
+	// Reload parses the files again when one changes, checking at most
+	// once a second; for development
+	Reload bool
+}
+
+// Templates is a registry of html/template templates, parsed once when
+// it is created so requests only execute them
+type Templates struct {
+	cfg TemplateConfig
+
+	// set is the parsed templates; stamp describes the files parsed
+	set   atomic.Pointer[template.Template]
+	stamp string
+
+	// Reload state: time of the last check and the lock held parsing
+	checked atomic.Int64
+	mu      sync.Mutex
+}
+
+// NewTemplates parses the templates described by cfg
+func NewTemplates(cfg TemplateConfig) (*Templates, error) {
+	if cfg.FS == nil {
+		cfg.FS = os.DirFS(".")
+	}
+	t := &Templates{cfg: cfg}
+	if err := t.Reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Reload parses the template files again and swaps them in; renders in
+// flight finish with the previous ones
+func (t *Templates) Reload() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stamp, err := t.fileStamp()
+	if err != nil {
+		return err
+	}
+	set := template.New("").Funcs(t.cfg.Funcs)
+	for _, pattern := range t.cfg.Patterns {
+		if set, err = set.ParseFS(t.cfg.FS, pattern); err != nil {
+			return err
+		}
+	}
+	t.set.Store(set)
+	t.stamp = stamp
+	t.checked.Store(clock.Now().UnixNano())
+	return nil
+}
+
+// Lookup returns the template named name, or nil
+func (t *Templates) Lookup(name string) *template.Template {
+	t.reloadIfChanged()
+	return t.set.Load().Lookup(name)
+}
+
+// Execute renders the template named name with data to w
+func (t *Templates) Execute(w io.Writer, name string, data any) error {
+	t.reloadIfChanged()
+	return t.set.Load().ExecuteTemplate(w, name, data)
+}
+
+// reloadIfChanged reparses the files if Reload is set and one of them
+// was added, removed or modified since they were parsed
+func (t *Templates) reloadIfChanged() {
+	if !t.cfg.Reload {
+		return
+	}
+	now := clock.Now().UnixNano()
+	last := t.checked.Load()
+	if now-last < int64(templateReloadInterval) || !t.checked.CompareAndSwap(last, now) {
+		return
+	}
+
+	t.mu.Lock()
+	stamp, err := t.fileStamp()
+	changed := err == nil && stamp != t.stamp
+	t.mu.Unlock()
+	if changed {
+		// A broken edit keeps the previous templates
+		t.Reload()
+	}
+}
+
+// fileStamp describes the names, sizes and modification times of the
+// template files
+func (t *Templates) fileStamp() (string, error) {
+	var b []byte
+	for _, pattern := range t.cfg.Patterns {
+		names, err := fs.Glob(t.cfg.FS, pattern)
+		if err != nil {
+			return "", err
+		}
+		if len(names) == 0 {
+			return "", errors.New("template: pattern matches no files: " + pattern)
+		}
+		for _, name := range names {
+			info, err := fs.Stat(t.cfg.FS, name)
+			if err != nil {
+				return "", err
+			}
+			b = append(b, name...)
+			b = appendInt(b, int(info.Size()))
+			b = appendInt(b, int(info.ModTime().UnixNano()))
+			b = append(b, 0)
+		}
+	}
+	return string(b), nil
+}
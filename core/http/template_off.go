@@ -0,0 +1,14 @@
+//go:build fastserver_notemplate
+
+package http
+
+import "io"
+
+// Templates stands in for the template registry, which builds with the
+// fastserver_notemplate tag leave out along with html/template
+type Templates struct{}
+
+// Execute fails: this build has no templates
+func (t *Templates) Execute(w io.Writer, name string, data any) error {
+	return ErrNoTemplates
+}
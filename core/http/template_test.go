@@ -0,0 +1,57 @@
+//go:build !fastserver_notemplate
+
+package http
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// TestFDContextRender 测试模板渲染与热重载
+func TestFDContextRender(t *testing.T) {
+	files := fstest.MapFS{
+		"views/layout.html": {Data: []byte(`<main>{{template "body" .}}</main>`)},
+		"views/user.html":   {Data: []byte(`{{define "body"}}<h1>{{upper .}}</h1>{{end}}{{template "layout.html" .}}`)},
+	}
+	tmpl, err := NewTemplates(TemplateConfig{
+		FS:       files,
+		Patterns: []string{"views/*.html"},
+		Funcs:    map[string]any{"upper": strings.ToUpper},
+		Reload:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd, read := newSocketPair(t)
+	ctx := NewFDContext(fd, &Request{Method: "GET", Path: "/u", Proto: "HTTP/1.1"})
+	ctx.SetTemplates(tmpl)
+	if err := ctx.Render(200, "user.html", "<ann>"); err != nil {
+		t.Fatal(err)
+	}
+	out := read()
+	if !strings.Contains(out, "Content-Type: text/html; charset=utf-8\r\n") ||
+		!strings.HasSuffix(out, "<main><h1>&lt;ANN&gt;</h1></main>") {
+		t.Errorf("Unexpected page %q", out)
+	}
+
+	ctx.Reset(fd, &Request{Method: "GET", Path: "/u", Proto: "HTTP/1.1"})
+	ctx.SetTemplates(tmpl)
+	if err := ctx.Render(200, "missing.html", nil); err == nil {
+		t.Error("Expected an error for a missing template")
+	}
+	if out := read(); !strings.HasPrefix(out, "HTTP/1.1 500") {
+		t.Errorf("Expected 500, got %q", out)
+	}
+
+	// Edited files are picked up once the reload interval has passed
+	files["views/layout.html"] = &fstest.MapFile{Data: []byte(`<section>{{template "body" .}}</section>`), ModTime: time.Now()}
+	tmpl.checked.Store(0)
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, "user.html", "bo"); err != nil || buf.String() != "<section><h1>BO</h1></section>" {
+		t.Errorf("Expected the reloaded layout, got %q (%v)", buf.String(), err)
+	}
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"net/textproto"
+	"strings"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// Trailer returns a trailer field sent after the request's chunked body
+// (e.g. a checksum computed while streaming). Trailers are kept apart from
+// the headers: Header does not see them.
+func (c *FDContext) Trailer(key string) string {
+	return c.request.Trailer(key)
+}
+
+// SetTrailer sets a trailer field sent after the body, for protocols that
+// report a result once the body has gone out (grpc-status, checksums).
+// Called before the response starts, it also declares the field in the
+// Trailer header. Trailers need a chunked body, so they go out with
+// Stream, SSEvent and Writer responses (a small Writer body is chunked
+// too) and are dropped otherwise, e.g. for HTTP/1.0 clients. Keys or
+// values containing CR or LF are dropped.
+func (c *FDContext) SetTrailer(key, value string) {
+	if strings.ContainsAny(key, "\r\n") || strings.ContainsAny(value, "\r\n") {
+		return
+	}
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	for i := 0; i < len(c.trailers); i += 2 {
+		if c.trailers[i] == key {
+			c.trailers[i+1] = value
+			return
+		}
+	}
+	c.trailers = append(c.trailers, key, value)
+	if !c.written {
+		c.AddHeader("Trailer", key)
+	}
+}
+
+// writeLastChunk ends a chunked body with the terminal chunk and the
+// trailers
+func (c *FDContext) writeLastChunk() {
+	if len(c.trailers) == 0 {
+		c.writeAll(lastChunk, netfd.Write)
+		return
+	}
+	buf := append(c.responseBuf[:0], "0\r\n"...)
+	for i := 0; i < len(c.trailers); i += 2 {
+		buf = append(buf, c.trailers[i]...)
+		buf = append(buf, ": "...)
+		buf = append(buf, c.trailers[i+1]...)
+		buf = append(buf, crlf...)
+	}
+	buf = append(buf, crlf...)
+	c.responseBuf = buf
+	c.writeAll(buf, netfd.Write)
+}
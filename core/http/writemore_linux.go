@@ -0,0 +1,11 @@
+//go:build linux
+
+package http
+
+import "golang.org/x/sys/unix"
+
+// writeMore writes b with MSG_MORE so the kernel holds it for the data that
+// follows (e.g. sendfile) and sends header and body in as few segments as possible
+func writeMore(fd int, b []byte) (int, error) {
+	return unix.SendmsgN(fd, b, nil, nil, unix.MSG_MORE)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package http
+
+import "github.com/searchktools/fast-server/core/netfd"
+
+// writeMore writes b; platforms without MSG_MORE send the header on its own
+func writeMore(fd int, b []byte) (int, error) {
+	return netfd.Write(fd, b)
+}
@@ -0,0 +1,90 @@
+package http
+
+import "io"
+
+// writerChunkSize is how much a body Writer buffers before sending it as
+// a chunk
+const writerChunkSize = 32 * 1024
+
+// Writer returns a writer for the response body, for bodies too large or
+// of unknown length to build in memory (templates, CSV exports, io.Copy
+// from a reader). Status and headers must be set before the first write.
+//
+// Writes are buffered: a body that fits in 32KB is sent with a
+// Content-Length when the handler returns; a larger one, or one with
+// trailers (see SetTrailer), switches the response to chunked
+// Transfer-Encoding and is sent 32KB at a time.
+// Writes fail once the client has gone away. Long bodies should be
+// written off the event loop (Offload or Detach).
+func (c *FDContext) Writer() io.Writer {
+	c.body.c = c
+	return &c.body
+}
+
+// bodyWriter is the writer returned by Writer
+type bodyWriter struct {
+	c   *FDContext
+	buf []byte
+}
+
+func (w *bodyWriter) Write(p []byte) (int, error) {
+	c := w.c
+	if c.writeErr != nil {
+		return 0, c.writeErr
+	}
+	if c.Disconnected() {
+		return 0, ErrClientDisconnected
+	}
+	if c.written && !c.streaming {
+		return 0, ErrResponseWritten
+	}
+
+	if len(w.buf)+len(p) <= writerChunkSize {
+		w.buf = append(w.buf, p...)
+		return len(p), nil
+	}
+	if err := w.flush(); err != nil {
+		return 0, err
+	}
+	if len(p) >= writerChunkSize {
+		// Send large writes as they are rather than copying them
+		if err := c.writeChunk(p); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+// flush starts the streamed response if needed and sends what is buffered
+func (w *bodyWriter) flush() error {
+	c := w.c
+	if !c.streaming {
+		if err := c.startStream(c.implicitType("application/octet-stream")); err != nil {
+			return err
+		}
+	}
+	err := c.writeChunk(w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
+
+// finish sends what the handler left buffered: as a complete response if
+// nothing was streamed, else as the last chunk
+func (w *bodyWriter) finish() {
+	if len(w.buf) == 0 {
+		return
+	}
+	c := w.c
+	switch {
+	case !c.written && len(c.trailers) > 0:
+		w.flush() // Trailers need a chunked body
+		return
+	case !c.written:
+		c.respond(c.statusCode, c.implicitType("application/octet-stream"), w.buf)
+	case c.streaming:
+		c.writeChunk(w.buf)
+	}
+	w.buf = w.buf[:0]
+}
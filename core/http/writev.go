@@ -0,0 +1,85 @@
+package http
+
+import (
+	"unsafe"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// writevThreshold is the body size from which responses are sent as
+// header buffer + body with one writev instead of copying the body
+const writevThreshold = 16 * 1024
+
+// writeBody finishes a response started by startResponse. Small bodies are
+// appended to the header buffer; large ones are written alongside it.
+func (c *FDContext) writeBody(body []byte) error {
+	if !c.bodyAllowed() {
+		return c.writeResponse()
+	}
+	if len(body) < writevThreshold {
+		c.responseBuf = append(c.responseBuf, body...)
+		return c.writeResponse()
+	}
+	return c.writeVectored(c.responseBuf, body)
+}
+
+// writeVectored writes bufs with writev, handling partial writes
+func (c *FDContext) writeVectored(bufs ...[]byte) error {
+	if err := c.checkDeadline(); err != nil {
+		return err
+	}
+	if err := c.FlushBatch(); err != nil {
+		return err
+	}
+	c.written = true
+	if c.dumping {
+		for _, buf := range bufs {
+			c.captureDump(buf)
+		}
+	}
+
+	if c.backlog.Pending() {
+		if c.deferWrite(bufs...) {
+			return nil
+		}
+		if err := c.drainBacklog(); err != nil {
+			return err
+		}
+	}
+
+	var stall writeStall
+	for len(bufs) > 0 {
+		n, err := netfd.Writev(c.fd, bufs)
+		if err != nil {
+			if isAgain(err) && stall.start.IsZero() && c.deferWrite(bufs...) {
+				return nil
+			}
+			if c.retryWrite(err, &stall) {
+				continue
+			}
+			return c.writeErr
+		}
+		c.bytesSent += int64(n)
+		bufs = consumeBufs(bufs, n)
+	}
+	c.endStall(&stall)
+	return nil
+}
+
+// consumeBufs drops the first n written bytes from bufs
+func consumeBufs(bufs [][]byte, n int) [][]byte {
+	for len(bufs) > 0 && n >= len(bufs[0]) {
+		n -= len(bufs[0])
+		bufs = bufs[1:]
+	}
+	if len(bufs) > 0 {
+		bufs[0] = bufs[0][n:]
+	}
+	return bufs
+}
+
+// unsafeBytes converts a string to a byte slice without allocation.
+// The result must not be modified.
+func unsafeBytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"encoding/xml"
+	"mime"
+	"strings"
+)
+
+// XML sends v encoded as XML, after the standard XML declaration
+func (c *FDContext) XML(code int, v any) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		c.Error(500, "Failed to marshal XML")
+		return
+	}
+	body := make([]byte, 0, len(xml.Header)+len(data))
+	body = append(append(body, xml.Header...), data...)
+
+	c.respond(code, c.implicitType("application/xml; charset=utf-8"), body)
+}
+
+// BindXML decodes an XML request body into v
+func (c *FDContext) BindXML(v any) error {
+	body, err := c.BodyReader()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return xml.NewDecoder(body).Decode(v)
+}
+
+// isXMLBody reports whether the request body is XML: application/xml,
+// text/xml or a +xml type
+func (c *FDContext) isXMLBody() bool {
+	mediaType, _, _ := mime.ParseMediaType(c.request.ContentType)
+	return mediaType == "application/xml" || mediaType == "text/xml" ||
+		strings.HasSuffix(mediaType, "+xml")
+}
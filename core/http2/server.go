@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"sync"
 	"time"
@@ -105,6 +106,24 @@ func (s *Server) ListenAndServe() error {
 	return s.server.ListenAndServe()
 }
 
+// Serve serves HTTP/2 on connections from ln, e.g. an alpn.Mux listener
+// for "h2". TLS connections must already be negotiated by the listener.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return fmt.Errorf("server is closed")
+	}
+	if err := http2.ConfigureServer(s.server, s.h2); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+
+	log.Printf("🚀 HTTP/2 Server serving on %s", ln.Addr())
+	return s.server.Serve(ln)
+}
+
 // Close gracefully shuts down the server
 func (s *Server) Close() error {
 	s.mu.Lock()
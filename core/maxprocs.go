@@ -0,0 +1,187 @@
+package core
+
+import (
+	"bufio"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// CPUInfo describes the CPUs available to the process
+type CPUInfo struct {
+	NumCPU     int     `json:"num_cpu"`        // Logical CPUs of the machine
+	Quota      float64 `json:"cpu_quota"`      // cgroup CPU limit in CPUs (0: none)
+	Effective  int     `json:"effective_cpus"` // NumCPU capped by the quota, rounded up
+	GOMAXPROCS int     `json:"gomaxprocs"`
+	Workers    int     `json:"workers"` // Worker pool size (see Engine.MaxProcsAdmin)
+}
+
+// CPUs reports the logical CPUs, the cgroup CPU quota of the container
+// the process runs in (cgroup v2 cpu.max or v1 cfs quota) and the
+// resulting effective CPU count
+func CPUs() CPUInfo {
+	info := CPUInfo{
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+	}
+	info.Effective = info.NumCPU
+	if quota, ok := cgroupCPUQuota(); ok {
+		info.Quota = quota
+		info.Effective = min(info.NumCPU, max(1, int(math.Ceil(quota))))
+	}
+	return info
+}
+
+// EffectiveCPUs returns the CPUs the process may use: the logical CPUs,
+// capped by the container's CPU quota
+func EffectiveCPUs() int {
+	return CPUs().Effective
+}
+
+// SetAutoMaxProcs sets whether Run lowers GOMAXPROCS to the effective CPU
+// count when a container CPU quota is below the machine's CPUs (default
+// true). A GOMAXPROCS environment variable always wins.
+func (e *Engine) SetAutoMaxProcs(enabled bool) {
+	e.noAutoMaxProcs = !enabled
+}
+
+// SetMaxProcs sets GOMAXPROCS while the server runs and returns the
+// previous value. n <= 0 returns to the runtime's default, which follows
+// the container's CPU quota. The worker pool keeps the size it was
+// created with.
+func (e *Engine) SetMaxProcs(n int) int {
+	prev := runtime.GOMAXPROCS(0)
+	if n <= 0 {
+		runtime.SetDefaultGOMAXPROCS()
+		n = runtime.GOMAXPROCS(0)
+	} else {
+		runtime.GOMAXPROCS(n)
+	}
+	if prev != n {
+		log.Printf("⚙️  GOMAXPROCS %d -> %d", prev, n)
+	}
+	return prev
+}
+
+// applyMaxProcs fits GOMAXPROCS to the container's CPU quota at startup
+func (e *Engine) applyMaxProcs() {
+	if e.noAutoMaxProcs || os.Getenv("GOMAXPROCS") != "" {
+		return
+	}
+	info := CPUs()
+	if info.Quota > 0 && info.GOMAXPROCS > info.Effective {
+		runtime.GOMAXPROCS(info.Effective)
+		log.Printf("⚙️  GOMAXPROCS=%d from CPU quota %.2f (%d CPUs)", info.Effective, info.Quota, info.NumCPU)
+	}
+}
+
+// MaxProcsAdmin returns a handler reporting the CPU configuration (GET)
+// and setting GOMAXPROCS at runtime (PUT or POST with n; n=0 returns to
+// the runtime default). Mount it behind authentication.
+func (e *Engine) MaxProcsAdmin() HandlerFunc {
+	return func(ctx http.Context) {
+		switch ctx.Method() {
+		case "GET":
+		case "PUT", "POST":
+			n, err := strconv.Atoi(ctx.Query("n"))
+			if err != nil || n < 0 || n > 4*runtime.NumCPU() {
+				ctx.Error(400, "n must be between 0 and "+strconv.Itoa(4*runtime.NumCPU()))
+				return
+			}
+			e.SetMaxProcs(n)
+		default:
+			if fc, ok := ctx.(*http.FDContext); ok {
+				fc.SetHeader("Allow", "GET, PUT, POST")
+			}
+			ctx.Error(405, "Method Not Allowed")
+			return
+		}
+
+		info := CPUs()
+		info.Workers = e.workerPool.Stats().NumWorkers
+		ctx.JSON(200, info)
+	}
+}
+
+// cgroupCPUQuota returns the CPU limit of the process's cgroup in CPUs,
+// the lowest along its hierarchy for cgroup v2
+func cgroupCPUQuota() (float64, bool) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	// Lines are "id:controllers:path"; v2 has the empty controller list
+	var v1Path, v2Path string
+	v2 := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[1] == "" {
+			v2, v2Path = true, parts[2]
+			continue
+		}
+		for _, c := range strings.Split(parts[1], ",") {
+			if c == "cpu" {
+				v1Path = parts[2]
+			}
+		}
+	}
+
+	if v1Path != "" {
+		for _, dir := range []string{"/sys/fs/cgroup/cpu", "/sys/fs/cgroup/cpu,cpuacct"} {
+			for _, p := range []string{dir + v1Path, dir} {
+				quota, ok1 := readProcInt(p + "/cpu.cfs_quota_us")
+				period, ok2 := readProcInt(p + "/cpu.cfs_period_us")
+				if ok1 && ok2 {
+					if quota <= 0 || period <= 0 {
+						return 0, false
+					}
+					return float64(quota) / float64(period), true
+				}
+			}
+		}
+	}
+
+	if v2 {
+		limit, found := math.Inf(1), false
+		for p := strings.TrimSuffix(v2Path, "/"); ; p = p[:strings.LastIndexByte(p, '/')] {
+			if quota, ok := readCPUMax("/sys/fs/cgroup" + p + "/cpu.max"); ok {
+				limit, found = min(limit, quota), true
+			}
+			if p == "" {
+				break
+			}
+		}
+		return limit, found
+	}
+	return 0, false
+}
+
+// readCPUMax parses a cgroup v2 cpu.max file ("max 100000" or
+// "150000 100000"); it reports false when there is no limit
+func readCPUMax(path string) (float64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0, false
+	}
+	quota, err1 := strconv.ParseFloat(fields[0], 64)
+	period, err2 := strconv.ParseFloat(fields[1], 64)
+	if err1 != nil || err2 != nil || quota <= 0 || period <= 0 {
+		return 0, false
+	}
+	return quota / period, true
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// Spec declares one middleware of a configured chain, e.g. from a config
+// file entry "recovery" or {"name": "cors", "allow_origins": ["https://a.example"]}
+type Spec struct {
+	Name    string
+	Options map[string]any
+}
+
+// Factory builds a middleware from its configured options
+type Factory func(opts map[string]any) (HandlerFunc, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{
+		"recovery":  recoveryFactory,
+		"logger":    loggerFactory,
+		"requestid": requestIDFactory,
+		"cors":      corsFactory,
+		"ratelimit": rateLimitFactory,
+		"compress":  compressFactory,
+	}
+)
+
+// Register makes a middleware available to configured chains under name
+func Register(name string, f Factory) {
+	factoriesMu.Lock()
+	factories[strings.ToLower(name)] = f
+	factoriesMu.Unlock()
+}
+
+// ParseSpecs converts a decoded JSON list (strings or objects with a
+// "name" key and options) into middleware specs
+func ParseSpecs(v any) ([]Spec, error) {
+	items, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("middleware: expected a list, got %T", v)
+	}
+
+	specs := make([]Spec, 0, len(items))
+	for i, item := range items {
+		switch it := item.(type) {
+		case string:
+			specs = append(specs, Spec{Name: it})
+		case map[string]any:
+			name, _ := it["name"].(string)
+			if name == "" {
+				return nil, fmt.Errorf("middleware[%d]: missing name", i)
+			}
+			opts := make(map[string]any, len(it))
+			for k, v := range it {
+				if k != "name" {
+					opts[k] = v
+				}
+			}
+			specs = append(specs, Spec{Name: name, Options: opts})
+		default:
+			return nil, fmt.Errorf("middleware[%d]: expected a name or an object, got %T", i, item)
+		}
+	}
+	return specs, nil
+}
+
+// Build builds the middleware chain declared by specs, in order
+func Build(specs []Spec) ([]HandlerFunc, error) {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	handlers := make([]HandlerFunc, 0, len(specs))
+	for _, spec := range specs {
+		f, ok := factories[strings.ToLower(spec.Name)]
+		if !ok {
+			return nil, fmt.Errorf("middleware: unknown %q (available: %s)", spec.Name, availableNames())
+		}
+		h, err := f(spec.Options)
+		if err != nil {
+			return nil, fmt.Errorf("middleware %q: %w", spec.Name, err)
+		}
+		handlers = append(handlers, h)
+	}
+	return handlers, nil
+}
+
+// availableNames lists registered middleware; callers hold factoriesMu
+func availableNames() string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func recoveryFactory(opts map[string]any) (HandlerFunc, error) {
+	return Recovery(), nil
+}
+
+func loggerFactory(opts map[string]any) (HandlerFunc, error) {
+	headers, err := optStrings(opts, "headers")
+	if err != nil {
+		return nil, err
+	}
+	return HandlerFunc(LoggerWithRedactor(nil, headers...)), nil
+}
+
+func requestIDFactory(opts map[string]any) (HandlerFunc, error) {
+	return RequestID(), nil
+}
+
+func corsFactory(opts map[string]any) (HandlerFunc, error) {
+	var cfg CORSConfig
+	var err error
+	if cfg.AllowOrigins, err = optStrings(opts, "allow_origins"); err != nil {
+		return nil, err
+	}
+	if cfg.AllowMethods, err = optStrings(opts, "allow_methods"); err != nil {
+		return nil, err
+	}
+	if cfg.AllowHeaders, err = optStrings(opts, "allow_headers"); err != nil {
+		return nil, err
+	}
+	if v, ok := opts["max_age"]; ok {
+		secs, ok := v.(float64)
+		if !ok || secs < 0 {
+			return nil, fmt.Errorf("max_age must be a number of seconds >= 0")
+		}
+		cfg.MaxAge = time.Duration(secs) * time.Second
+	}
+	return CORSWithConfig(cfg), nil
+}
+
+func compressFactory(opts map[string]any) (HandlerFunc, error) {
+	var cfg http.Compression
+	var err error
+	if cfg.Encodings, err = optStrings(opts, "encodings"); err != nil {
+		return nil, err
+	}
+	if v, ok := opts["min_bytes"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("min_bytes must be a number >= 0")
+		}
+		cfg.MinBytes = int(n)
+	}
+	return Compress(&cfg), nil
+}
+
+func rateLimitFactory(opts map[string]any) (HandlerFunc, error) {
+	rps, ok := opts["rps"].(float64)
+	if !ok || rps < 1 {
+		return nil, fmt.Errorf("rps must be a number >= 1")
+	}
+	return RateLimiter(int(rps)), nil
+}
+
+// optStrings reads an optional list of strings from decoded JSON options
+func optStrings(opts map[string]any, key string) ([]string, error) {
+	v, ok := opts[key]
+	if !ok {
+		return nil, nil
+	}
+	items, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of strings", key)
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a list of strings", key)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// TestBuildFromConfig 测试从配置构建中间件链
+func TestBuildFromConfig(t *testing.T) {
+	var raw any
+	err := json.Unmarshal([]byte(`["recovery", "requestid",
+		{"name": "cors", "allow_origins": ["https://app.example"]},
+		{"name": "ratelimit", "rps": 100}]`), &raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specs, err := ParseSpecs(raw)
+	if err != nil {
+		t.Fatalf("ParseSpecs: %v", err)
+	}
+	if len(specs) != 4 || specs[2].Name != "cors" || specs[3].Options["rps"] != 100.0 {
+		t.Fatalf("Unexpected specs %+v", specs)
+	}
+
+	handlers, err := Build(specs)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if len(handlers) != 4 {
+		t.Errorf("Expected 4 handlers, got %d", len(handlers))
+	}
+}
+
+// TestBuildErrors 测试无效配置的错误
+func TestBuildErrors(t *testing.T) {
+	if _, err := Build([]Spec{{Name: "compression"}}); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("Expected unknown middleware error, got %v", err)
+	}
+	if _, err := Build([]Spec{{Name: "ratelimit"}}); err == nil {
+		t.Error("Expected error for ratelimit without rps")
+	}
+	if _, err := ParseSpecs([]any{map[string]any{"rps": 1.0}}); err == nil {
+		t.Error("Expected error for entry without name")
+	}
+
+	Register("custom", func(opts map[string]any) (HandlerFunc, error) {
+		return func(ctx *http.FDContext) {}, nil
+	})
+	if _, err := Build([]Spec{{Name: "Custom"}}); err != nil {
+		t.Errorf("Expected registered middleware to build: %v", err)
+	}
+}
+
+// TestCORSWithConfig 测试按来源限制的 CORS
+func TestCORSWithConfig(t *testing.T) {
+	cors := CORSWithConfig(CORSConfig{AllowOrigins: []string{"https://app.example"}})
+	respond := func(origin string) string {
+		req := &http.Request{Method: "OPTIONS", Path: "/", ExtraHeaders: map[string]string{"Origin": origin}}
+		out, err := http.ServeRaw(req, func(ctx http.Context) {
+			cors(ctx.(*http.FDContext))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	out := respond("https://app.example")
+	if !strings.Contains(out, "Access-Control-Allow-Origin: https://app.example\r\n") ||
+		!strings.HasPrefix(out, "HTTP/1.1 204") {
+		t.Errorf("Expected CORS preflight for allowed origin, got %q", out)
+	}
+
+	out = respond("https://evil.example")
+	if strings.Contains(out, "Access-Control-Allow-Origin") {
+		t.Errorf("Expected no CORS headers for other origins, got %q", out)
+	}
+}
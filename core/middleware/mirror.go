@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	nethttp "net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/pools"
+)
+
+// MirrorConfig configures shadow traffic mirroring
+type MirrorConfig struct {
+	Upstream     string            // Shadow base URL, e.g. "http://shadow:8080"
+	Percent      float64           // Share of requests to mirror (0-100)
+	MaxBodyBytes int               // Requests with larger bodies are not mirrored (default: 64KB)
+	Timeout      time.Duration     // Shadow request timeout (default: 2s)
+	Pool         *pools.WorkerPool // Dispatch pool (default: global worker pool)
+	Client       *nethttp.Client   // HTTP client (default: one with Timeout)
+}
+
+// Mirror copies a sample of requests to a shadow upstream.
+// Shadow responses are discarded and never affect the client response.
+type Mirror struct {
+	cfg      MirrorConfig
+	upstream *url.URL
+
+	stats struct {
+		mirrored atomic.Uint64
+		skipped  atomic.Uint64
+		failed   atomic.Uint64
+	}
+}
+
+// MirrorStats contains mirroring statistics
+type MirrorStats struct {
+	Mirrored uint64 // Shadow requests completed
+	Skipped  uint64 // Sampled requests not mirrored (body too large, pool closed)
+	Failed   uint64 // Shadow requests that errored
+}
+
+// NewMirror creates a request mirror
+func NewMirror(cfg MirrorConfig) (*Mirror, error) {
+	upstream, err := url.Parse(cfg.Upstream)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxBodyBytes == 0 {
+		cfg.MaxBodyBytes = 64 * 1024
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if cfg.Pool == nil {
+		cfg.Pool = pools.GetGlobalPool()
+	}
+	if cfg.Client == nil {
+		cfg.Client = &nethttp.Client{Timeout: cfg.Timeout}
+	}
+
+	return &Mirror{cfg: cfg, upstream: upstream}, nil
+}
+
+// Handler returns the mirroring middleware
+func (m *Mirror) Handler() HandlerFunc {
+	return func(ctx *http.FDContext) {
+		if m.cfg.Percent <= 0 || rand.Float64()*100 >= m.cfg.Percent {
+			return
+		}
+
+		req := ctx.Request()
+		if len(req.Body) > m.cfg.MaxBodyBytes {
+			m.stats.skipped.Add(1)
+			return
+		}
+
+		// Copy everything now: the request buffer is reused after the handler
+		shadow, err := m.buildShadow(req)
+		if err != nil {
+			m.stats.failed.Add(1)
+			return
+		}
+
+		if !m.cfg.Pool.Submit(func() { m.send(shadow) }) {
+			m.stats.skipped.Add(1)
+		}
+	}
+}
+
+// Stats returns mirroring statistics
+func (m *Mirror) Stats() MirrorStats {
+	return MirrorStats{
+		Mirrored: m.stats.mirrored.Load(),
+		Skipped:  m.stats.skipped.Load(),
+		Failed:   m.stats.failed.Load(),
+	}
+}
+
+func (m *Mirror) buildShadow(req *http.Request) (*nethttp.Request, error) {
+	// The path and query are sent as the client escaped them
+	target := *m.upstream
+	target.RawPath = strings.TrimSuffix(target.EscapedPath(), "/") + req.Path
+	path, err := url.PathUnescape(target.RawPath)
+	if err != nil {
+		return nil, err
+	}
+	target.Path = path
+	target.RawQuery = req.RawQuery
+
+	var body io.Reader
+	if len(req.Body) > 0 {
+		body = bytes.NewReader(bytes.Clone(req.Body))
+	}
+
+	shadow, err := nethttp.NewRequest(strings.Clone(req.Method), target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	setHeader := func(k, v string) {
+		if v != "" {
+			shadow.Header.Set(k, strings.Clone(v))
+		}
+	}
+	setHeader("Content-Type", req.ContentType)
+	setHeader("User-Agent", req.UserAgent)
+	setHeader("Accept", req.Accept)
+	for k, v := range req.ExtraHeaders {
+		setHeader(k, v)
+	}
+	if req.Host != "" {
+		shadow.Header.Set("X-Forwarded-Host", strings.Clone(req.Host))
+	}
+	shadow.Header.Set("X-Shadow-Request", "1")
+
+	return shadow, nil
+}
+
+func (m *Mirror) send(shadow *nethttp.Request) {
+	resp, err := m.cfg.Client.Do(shadow)
+	if err != nil {
+		m.stats.failed.Add(1)
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	m.stats.mirrored.Add(1)
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/pools"
+)
+
+// TestMirror 测试影子流量镜像
+func TestMirror(t *testing.T) {
+	received := make(chan *nethttp.Request, 1)
+	bodies := make(chan string, 1)
+	shadow := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+		received <- r
+	}))
+	defer shadow.Close()
+
+	pool := pools.NewWorkerPool(1)
+	defer pool.Close()
+
+	mirror, err := NewMirror(MirrorConfig{Upstream: shadow.URL, Percent: 100, Pool: pool})
+	if err != nil {
+		t.Fatalf("NewMirror failed: %v", err)
+	}
+
+	req := &http.Request{
+		Method:       "POST",
+		Path:         "/api/users/a%20b",
+		RawQuery:     "tag=x%20y&page=2&tag=z",
+		ContentType:  "application/json",
+		ExtraHeaders: map[string]string{"X-Trace": "abc"},
+		Body:         []byte(`{"name":"bob"}`),
+	}
+	mirror.Handler()(http.NewFDContext(-1, req))
+
+	select {
+	case r := <-received:
+		if r.Method != "POST" || r.RequestURI != "/api/users/a%20b?tag=x%20y&page=2&tag=z" {
+			t.Errorf("Unexpected shadow request %s %s", r.Method, r.URL)
+		}
+		if r.Header.Get("X-Trace") != "abc" || r.Header.Get("X-Shadow-Request") != "1" {
+			t.Errorf("Expected headers to be mirrored, got %v", r.Header)
+		}
+		if body := <-bodies; body != `{"name":"bob"}` {
+			t.Errorf("Expected body to be mirrored, got %q", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Shadow request was not sent")
+	}
+}
+
+// TestMirrorSkipsLargeBody 测试超过限制的请求体不被镜像
+func TestMirrorSkipsLargeBody(t *testing.T) {
+	mirror, _ := NewMirror(MirrorConfig{Upstream: "http://127.0.0.1:1", Percent: 100, MaxBodyBytes: 4})
+
+	req := &http.Request{Method: "POST", Path: "/", Body: []byte("too large")}
+	mirror.Handler()(http.NewFDContext(-1, req))
+
+	if stats := mirror.Stats(); stats.Skipped != 1 || stats.Mirrored != 0 {
+		t.Errorf("Expected request to be skipped, got %+v", stats)
+	}
+}
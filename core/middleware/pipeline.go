@@ -3,11 +3,15 @@ package middleware
 import (
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/searchktools/fast-server/core/clock"
 	"github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/redact"
 )
 
 // HandlerFunc is the signature for middleware handlers
@@ -162,10 +166,16 @@ func (p *AsyncPipeline) Execute(ctx *http.FDContext, finalHandler HandlerFunc) {
 
 // Recovery recovers from panics
 func Recovery() HandlerFunc {
+	return RecoveryWithRedactor(nil)
+}
+
+// RecoveryWithRedactor recovers from panics, masking sensitive values
+// in the logged panic message
+func RecoveryWithRedactor(r *redact.Redactor) HandlerFunc {
 	return func(ctx *http.FDContext) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("Panic recovered: %v", err)
+				log.Printf("Panic recovered: %s", r.String(fmt.Sprint(err)))
 				ctx.Abort()
 				ctx.JSON(500, map[string]interface{}{
 					"error": "Internal Server Error",
@@ -177,28 +187,97 @@ func Recovery() HandlerFunc {
 
 // Logger logs requests (async)
 func Logger() AsyncHandlerFunc {
+	return LoggerWithRedactor(nil)
+}
+
+// LoggerWithRedactor logs requests along with the named request headers,
+// masking sensitive header values (async)
+func LoggerWithRedactor(r *redact.Redactor, headers ...string) AsyncHandlerFunc {
 	return func(ctx *http.FDContext) {
 		method := ctx.Method()
 		path := ctx.Path()
-		log.Printf("[%s] %s", method, path)
+		if len(headers) == 0 {
+			log.Printf("[%s] %s", method, path)
+			return
+		}
+
+		line := make([]byte, 0, 128)
+		for _, name := range headers {
+			value := ctx.Header(name)
+			if value == "" {
+				continue
+			}
+			line = append(line, ' ')
+			line = append(line, name...)
+			line = append(line, '=')
+			line = append(line, r.Header(name, value)...)
+		}
+		log.Printf("[%s] %s%s", method, path, line)
 	}
 }
 
+// CORSConfig configures CORSWithConfig. Empty fields use the CORS() defaults.
+type CORSConfig struct {
+	AllowOrigins []string // Allowed origins; empty or "*" allows any
+	AllowMethods []string // Default: GET, POST, PUT, DELETE, OPTIONS
+	AllowHeaders []string // Default: Content-Type, Authorization
+
+	// MaxAge lets browsers cache preflight answers (0: not sent)
+	MaxAge time.Duration
+}
+
 // CORS adds CORS headers
 func CORS() HandlerFunc {
+	return CORSWithConfig(CORSConfig{})
+}
+
+// CORSWithConfig adds CORS headers for the configured origins.
+// Requests from other origins get no CORS headers.
+func CORSWithConfig(cfg CORSConfig) HandlerFunc {
+	methods := "GET, POST, PUT, DELETE, OPTIONS"
+	if len(cfg.AllowMethods) > 0 {
+		methods = strings.Join(cfg.AllowMethods, ", ")
+	}
+	headers := "Content-Type, Authorization"
+	if len(cfg.AllowHeaders) > 0 {
+		headers = strings.Join(cfg.AllowHeaders, ", ")
+	}
+
+	anyOrigin := len(cfg.AllowOrigins) == 0
+	origins := make(map[string]struct{}, len(cfg.AllowOrigins))
+	for _, o := range cfg.AllowOrigins {
+		if o == "*" {
+			anyOrigin = true
+		}
+		origins[o] = struct{}{}
+	}
+
 	return func(ctx *http.FDContext) {
-		ctx.SetHeader("Access-Control-Allow-Origin", "*")
-		ctx.SetHeader("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		ctx.SetHeader("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		if anyOrigin {
+			ctx.SetHeader("Access-Control-Allow-Origin", "*")
+		} else {
+			origin := ctx.Header("Origin")
+			if _, ok := origins[origin]; !ok {
+				return
+			}
+			ctx.SetHeader("Access-Control-Allow-Origin", origin)
+			ctx.SetHeader("Vary", "Origin")
+		}
+		ctx.SetHeader("Access-Control-Allow-Methods", methods)
+		ctx.SetHeader("Access-Control-Allow-Headers", headers)
 
 		if ctx.Method() == "OPTIONS" {
+			if cfg.MaxAge > 0 {
+				ctx.SetHeader("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge/time.Second)))
+			}
 			ctx.Abort()
 			ctx.Status(204)
 		}
 	}
 }
 
-// RateLimiter implements rate limiting
+// RateLimiter implements rate limiting. Throttled requests get 429 with
+// Retry-After set to when tokens refill.
 func RateLimiter(requestsPerSecond int) HandlerFunc {
 	var (
 		tokens     int
@@ -207,12 +286,12 @@ func RateLimiter(requestsPerSecond int) HandlerFunc {
 	)
 
 	tokens = requestsPerSecond
-	lastRefill = time.Now()
+	lastRefill = clock.Now()
 
 	return func(ctx *http.FDContext) {
 		mu.Lock()
 
-		now := time.Now()
+		now := clock.Now()
 		elapsed := now.Sub(lastRefill)
 		if elapsed > time.Second {
 			tokens = requestsPerSecond
@@ -225,13 +304,12 @@ func RateLimiter(requestsPerSecond int) HandlerFunc {
 			return
 		}
 
+		wait := lastRefill.Add(time.Second).Sub(now)
 		mu.Unlock()
 
+		// The engine's 429 response (see Engine.SetBackoff)
 		ctx.Abort()
-		ctx.Status(429)
-		ctx.JSON(429, map[string]interface{}{
-			"error": "Too Many Requests",
-		})
+		ctx.Backoff(429, wait)
 	}
 }
 
@@ -245,6 +323,18 @@ func RequestID() HandlerFunc {
 	}
 }
 
+// Compress compresses complete responses with the coding the client
+// prefers among cfg's (nil: br and zstd if registered, gzip, deflate;
+// bodies of at least 1KB). See http.RegisterCompressor.
+func Compress(cfg *http.Compression) HandlerFunc {
+	if cfg == nil {
+		cfg = &http.Compression{}
+	}
+	return func(ctx *http.FDContext) {
+		ctx.SetCompression(cfg)
+	}
+}
+
 // Metrics collects request metrics (async)
 func Metrics() AsyncHandlerFunc {
 	return func(ctx *http.FDContext) {
@@ -0,0 +1,583 @@
+package msgpack
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// Unmarshal decodes the MessagePack value in data into the value v points
+// to. Maps decode into structs by field name (exact, else case-
+// insensitive); unknown keys are skipped. Into an interface, maps become
+// map[string]any (any keys: map[any]any), arrays []any, integers int64
+// (uint64 beyond its range), floats float64 and bin []byte.
+func Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("msgpack: Unmarshal needs a non-nil pointer")
+	}
+	d := decoder{data: data}
+	if err := d.value(rv.Elem()); err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return errors.New("msgpack: trailing data after the value")
+	}
+	return nil
+}
+
+// maxDepth bounds the nesting of arrays and maps, so hostile input
+// cannot exhaust the stack
+const maxDepth = 10000
+
+type decoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+// enter counts a nesting level
+func (d *decoder) enter() error {
+	d.depth++
+	if d.depth > maxDepth {
+		return errors.New("msgpack: value nested too deeply")
+	}
+	return nil
+}
+
+// TypeError is returned when a value cannot be stored in a Go type
+type TypeError struct {
+	Value string // MessagePack type
+	Type  reflect.Type
+}
+
+func (e *TypeError) Error() string {
+	return "msgpack: cannot decode " + e.Value + " into " + e.Type.String()
+}
+
+func (d *decoder) peek() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return d.data[d.pos], nil
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// uintN reads an n-byte big-endian unsigned integer
+func (d *decoder) uintN(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+func (d *decoder) value(rv reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+
+	c, err := d.peek()
+	if err != nil {
+		return err
+	}
+	if c == 0xc0 {
+		d.pos++
+		rv.SetZero()
+		return nil
+	}
+
+	switch {
+	case rv.Type() == timeType && !isStr(c):
+		t, err := d.time()
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	case rv.Kind() == reflect.Pointer:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return d.value(rv.Elem())
+	case rv.Kind() == reflect.Interface && rv.NumMethod() == 0:
+		v, err := d.any()
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			rv.SetZero()
+		} else {
+			rv.Set(reflect.ValueOf(v))
+		}
+		return nil
+	case isStr(c) && rv.CanAddr() && rv.Addr().Type().Implements(textUnmarshalerType):
+		s, err := d.str()
+		if err != nil {
+			return err
+		}
+		return rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(s)
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		if c != 0xc2 && c != 0xc3 {
+			return &TypeError{typeName(c), rv.Type()}
+		}
+		d.pos++
+		rv.SetBool(c == 0xc3)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, u, signed, err := d.int()
+		if err != nil {
+			return err
+		}
+		if !signed && u > math.MaxInt64 || rv.OverflowInt(i) {
+			return fmt.Errorf("msgpack: integer overflows %v", rv.Type())
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, u, signed, err := d.int()
+		if err != nil {
+			return err
+		}
+		if signed && i < 0 || rv.OverflowUint(u) {
+			return fmt.Errorf("msgpack: integer overflows %v", rv.Type())
+		}
+		rv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := d.float()
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+	case reflect.String:
+		s, err := d.str()
+		if err != nil {
+			return err
+		}
+		rv.SetString(string(s))
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 && !isArray(c) {
+			s, err := d.str()
+			if err != nil {
+				return err
+			}
+			rv.SetBytes(append([]byte(nil), s...))
+			return nil
+		}
+		n, err := d.arrayLen()
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.MakeSlice(rv.Type(), n, n))
+		for i := 0; i < n; i++ {
+			if err := d.value(rv.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		n, err := d.arrayLen()
+		if err != nil {
+			return err
+		}
+		rv.SetZero()
+		for i := 0; i < n; i++ {
+			if i >= rv.Len() {
+				if err := d.skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.value(rv.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		n, err := d.mapLen()
+		if err != nil {
+			return err
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMapWithSize(rv.Type(), n))
+		}
+		kt, vt := rv.Type().Key(), rv.Type().Elem()
+		for i := 0; i < n; i++ {
+			k := reflect.New(kt).Elem()
+			if err := d.value(k); err != nil {
+				return err
+			}
+			v := reflect.New(vt).Elem()
+			if err := d.value(v); err != nil {
+				return err
+			}
+			rv.SetMapIndex(k, v)
+		}
+	case reflect.Struct:
+		n, err := d.mapLen()
+		if err != nil {
+			return err
+		}
+		fields := fieldsOf(rv.Type())
+		for i := 0; i < n; i++ {
+			key, err := d.str()
+			if err != nil {
+				return err
+			}
+			f := fields.lookup(string(key))
+			if f == nil {
+				if err := d.skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.value(rv.FieldByIndex(f.index)); err != nil {
+				return err
+			}
+		}
+	default:
+		return &TypeError{typeName(c), rv.Type()}
+	}
+	return nil
+}
+
+// any decodes a value into its default Go type
+func (d *decoder) any() (any, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c == 0xc0:
+		d.pos++
+		return nil, nil
+	case c == 0xc2 || c == 0xc3:
+		d.pos++
+		return c == 0xc3, nil
+	case c <= 0x7f || c >= 0xe0 || (c >= 0xcc && c <= 0xd3):
+		i, u, signed, err := d.int()
+		if !signed && u > math.MaxInt64 {
+			return u, err
+		}
+		return i, err
+	case c == 0xca || c == 0xcb:
+		return d.float()
+	case isStr(c):
+		s, err := d.str()
+		return string(s), err
+	case c >= 0xc4 && c <= 0xc6:
+		b, err := d.str()
+		return append([]byte(nil), b...), err
+	case isArray(c):
+		n, err := d.arrayLen()
+		if err != nil {
+			return nil, err
+		}
+		a := make([]any, n)
+		for i := range a {
+			if a[i], err = d.any(); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	case c <= 0x8f || c == 0xde || c == 0xdf:
+		n, err := d.mapLen()
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]any, n)
+		for i := 0; i < n; i++ {
+			k, err := d.any()
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.any()
+			if err != nil {
+				return nil, err
+			}
+			s, ok := k.(string)
+			if !ok {
+				return d.anyKeyed(m, k, v, n-i-1)
+			}
+			m[s] = v
+		}
+		return m, nil
+	}
+	return d.time()
+}
+
+// anyKeyed finishes decoding a map with a non-string key into map[any]any
+func (d *decoder) anyKeyed(m map[string]any, k, v any, left int) (any, error) {
+	if k != nil && !reflect.TypeOf(k).Comparable() {
+		return nil, errors.New("msgpack: map key is not comparable")
+	}
+	out := make(map[any]any, len(m)+1+left)
+	for mk, mv := range m {
+		out[mk] = mv
+	}
+	out[k] = v
+	for i := 0; i < left; i++ {
+		k, err := d.any()
+		if err != nil {
+			return nil, err
+		}
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, errors.New("msgpack: map key is not comparable")
+		}
+		if out[k], err = d.any(); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// skip discards a value
+func (d *decoder) skip() error {
+	_, err := d.any()
+	return err
+}
+
+// int reads an integer: i if signed or within int64, u if unsigned
+func (d *decoder) int() (i int64, u uint64, signed bool, err error) {
+	c, err := d.peek()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	d.pos++
+	switch {
+	case c <= 0x7f:
+		return int64(c), uint64(c), false, nil
+	case c >= 0xe0:
+		return int64(int8(c)), 0, true, nil
+	case c >= 0xcc && c <= 0xcf:
+		u, err = d.uintN(1 << (c - 0xcc))
+		return int64(u), u, false, err
+	case c >= 0xd0 && c <= 0xd3:
+		n := 1 << (c - 0xd0)
+		u, err = d.uintN(n)
+		switch n {
+		case 1:
+			i = int64(int8(u))
+		case 2:
+			i = int64(int16(u))
+		case 4:
+			i = int64(int32(u))
+		default:
+			i = int64(u)
+		}
+		if i >= 0 {
+			// Encoded signed but non-negative: usable unsigned as well
+			return i, uint64(i), false, err
+		}
+		return i, 0, true, err
+	}
+	d.pos--
+	return 0, 0, false, fmt.Errorf("msgpack: expected integer, got %s", typeName(c))
+}
+
+// float reads a float or an integer as float64
+func (d *decoder) float() (float64, error) {
+	c, err := d.peek()
+	if err != nil {
+		return 0, err
+	}
+	switch c {
+	case 0xca:
+		d.pos++
+		u, err := d.uintN(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		d.pos++
+		u, err := d.uintN(8)
+		return math.Float64frombits(u), err
+	}
+	i, u, signed, err := d.int()
+	if err != nil {
+		return 0, fmt.Errorf("msgpack: expected number, got %s", typeName(c))
+	}
+	if !signed && u > math.MaxInt64 {
+		return float64(u), nil
+	}
+	return float64(i), nil
+}
+
+// str reads a string or bin, without copying
+func (d *decoder) str() ([]byte, error) {
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+	d.pos++
+	var n uint64
+	switch {
+	case c >= 0xa0 && c <= 0xbf:
+		n = uint64(c & 0x1f)
+	case c >= 0xd9 && c <= 0xdb:
+		n, err = d.uintN(1 << (c - 0xd9))
+	case c >= 0xc4 && c <= 0xc6:
+		n, err = d.uintN(1 << (c - 0xc4))
+	default:
+		d.pos--
+		return nil, fmt.Errorf("msgpack: expected string, got %s", typeName(c))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.next(int(min(n, math.MaxInt32)))
+}
+
+// arrayLen reads an array header
+func (d *decoder) arrayLen() (int, error) {
+	c, err := d.peek()
+	if err != nil {
+		return 0, err
+	}
+	d.pos++
+	var n uint64
+	switch {
+	case c >= 0x90 && c <= 0x9f:
+		n = uint64(c & 0x0f)
+	case c == 0xdc:
+		n, err = d.uintN(2)
+	case c == 0xdd:
+		n, err = d.uintN(4)
+	default:
+		d.pos--
+		return 0, fmt.Errorf("msgpack: expected array, got %s", typeName(c))
+	}
+	return d.checkLen(n, 1, err)
+}
+
+// mapLen reads a map header
+func (d *decoder) mapLen() (int, error) {
+	c, err := d.peek()
+	if err != nil {
+		return 0, err
+	}
+	d.pos++
+	var n uint64
+	switch {
+	case c >= 0x80 && c <= 0x8f:
+		n = uint64(c & 0x0f)
+	case c == 0xde:
+		n, err = d.uintN(2)
+	case c == 0xdf:
+		n, err = d.uintN(4)
+	default:
+		d.pos--
+		return 0, fmt.Errorf("msgpack: expected map, got %s", typeName(c))
+	}
+	return d.checkLen(n, 2, err)
+}
+
+// checkLen rejects headers announcing more elements (of at least size
+// bytes) than the data holds, before anything is allocated for them
+func (d *decoder) checkLen(n uint64, size int, err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.data)-d.pos)/uint64(size) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return int(n), nil
+}
+
+// time reads a timestamp extension
+func (d *decoder) time() (time.Time, error) {
+	c, err := d.peek()
+	if err != nil {
+		return time.Time{}, err
+	}
+	var n int
+	switch c {
+	case 0xd6:
+		n = 4
+	case 0xd7:
+		n = 8
+	case 0xc7:
+		if len(d.data)-d.pos < 2 || d.data[d.pos+1] != 12 {
+			return time.Time{}, fmt.Errorf("msgpack: unsupported %s", typeName(c))
+		}
+		n = 12
+		d.pos++
+	default:
+		return time.Time{}, fmt.Errorf("msgpack: unsupported %s", typeName(c))
+	}
+	b, err := d.next(2 + n)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if int8(b[1]) != -1 {
+		return time.Time{}, fmt.Errorf("msgpack: unsupported extension type %d", int8(b[1]))
+	}
+	b = b[2:]
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(b)), 0), nil
+	case 8:
+		v := binary.BigEndian.Uint64(b)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)), nil
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(b[4:])), int64(binary.BigEndian.Uint32(b))), nil
+}
+
+func isStr(c byte) bool {
+	return (c >= 0xa0 && c <= 0xbf) || (c >= 0xd9 && c <= 0xdb)
+}
+
+func isArray(c byte) bool {
+	return (c >= 0x90 && c <= 0x9f) || c == 0xdc || c == 0xdd
+}
+
+// typeName describes a type byte, for errors
+func typeName(c byte) string {
+	switch {
+	case c <= 0x7f || c >= 0xe0 || (c >= 0xcc && c <= 0xd3):
+		return "integer"
+	case c <= 0x8f || c == 0xde || c == 0xdf:
+		return "map"
+	case c <= 0x9f || c == 0xdc || c == 0xdd:
+		return "array"
+	case c <= 0xbf || isStr(c):
+		return "string"
+	case c == 0xc0:
+		return "nil"
+	case c == 0xc2 || c == 0xc3:
+		return "bool"
+	case c >= 0xc4 && c <= 0xc6:
+		return "bin"
+	case c == 0xca || c == 0xcb:
+		return "float"
+	case (c >= 0xc7 && c <= 0xc9) || (c >= 0xd4 && c <= 0xd8):
+		return "extension"
+	}
+	return fmt.Sprintf("invalid type 0x%02x", c)
+}
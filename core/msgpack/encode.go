@@ -0,0 +1,347 @@
+// Package msgpack encodes and decodes MessagePack (msgpack.org), the
+// binary counterpart of JSON, for HTTP APIs serving compact payloads
+// (ctx.Msgpack, ctx.Bind).
+//
+// Go values map as in encoding/json: structs become maps keyed by field
+// name, taken from the msgpack tag, else the json tag ("-" skips a field,
+// omitempty is honored); []byte becomes bin; time.Time uses the timestamp
+// extension; other encoding.TextMarshaler values become strings.
+package msgpack
+
+import (
+	"encoding"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// UnsupportedTypeError is returned for values MessagePack cannot carry
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "msgpack: unsupported type " + e.Type.String()
+}
+
+var (
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	timeType          = reflect.TypeFor[time.Time]()
+)
+
+// Marshal returns the MessagePack encoding of v
+func Marshal(v any) ([]byte, error) {
+	return Append(nil, v)
+}
+
+// Append appends the MessagePack encoding of v to dst
+func Append(dst []byte, v any) ([]byte, error) {
+	e := encoder{buf: dst}
+	err := e.encode(v)
+	return e.buf, err
+}
+
+type encoder struct {
+	buf []byte
+}
+
+// encode handles common types without reflection
+func (e *encoder) encode(v any) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf = append(e.buf, 0xc0)
+	case bool:
+		e.bool(v)
+	case int:
+		e.int(int64(v))
+	case int64:
+		e.int(v)
+	case uint64:
+		e.uint(v)
+	case float64:
+		e.float64(v)
+	case string:
+		e.string(v)
+	case []byte:
+		e.bytes(v)
+	case time.Time:
+		e.time(v)
+	case []any:
+		e.arrayHeader(len(v))
+		for _, item := range v {
+			if err := e.encode(item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		e.mapHeader(len(v))
+		for _, k := range keys {
+			e.string(k)
+			if err := e.encode(v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return e.value(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+func (e *encoder) value(rv reflect.Value) error {
+	if !rv.IsValid() {
+		e.buf = append(e.buf, 0xc0)
+		return nil
+	}
+	t := rv.Type()
+	if t == timeType {
+		e.time(rv.Interface().(time.Time))
+		return nil
+	}
+	if t.Implements(textMarshalerType) {
+		if (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) && rv.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		text, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		e.string(string(text))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		e.bool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.int(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.uint(rv.Uint())
+	case reflect.Float32:
+		e.buf = append(e.buf, 0xca)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(float32(rv.Float())))
+	case reflect.Float64:
+		e.float64(rv.Float())
+	case reflect.String:
+		e.string(rv.String())
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.value(rv.Elem())
+	case reflect.Slice:
+		if rv.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			e.bytes(rv.Bytes())
+			return nil
+		}
+		return e.array(rv)
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			e.binHeader(rv.Len())
+			for i := 0; i < rv.Len(); i++ {
+				e.buf = append(e.buf, byte(rv.Index(i).Uint()))
+			}
+			return nil
+		}
+		return e.array(rv)
+	case reflect.Map:
+		if rv.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.mapValue(rv)
+	case reflect.Struct:
+		return e.structValue(rv)
+	default:
+		return &UnsupportedTypeError{Type: t}
+	}
+	return nil
+}
+
+func (e *encoder) array(rv reflect.Value) error {
+	e.arrayHeader(rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		if err := e.value(rv.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapValue encodes a map, with string keys sorted for stable output
+func (e *encoder) mapValue(rv reflect.Value) error {
+	keys := rv.MapKeys()
+	if rv.Type().Key().Kind() == reflect.String {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	}
+	e.mapHeader(len(keys))
+	for _, k := range keys {
+		if err := e.value(k); err != nil {
+			return err
+		}
+		if err := e.value(rv.MapIndex(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) structValue(rv reflect.Value) error {
+	fields := fieldsOf(rv.Type())
+	n := 0
+	for i := range fields.list {
+		if !fields.list[i].omitEmpty || !isEmpty(rv.FieldByIndex(fields.list[i].index)) {
+			n++
+		}
+	}
+	e.mapHeader(n)
+	for i := range fields.list {
+		f := &fields.list[i]
+		fv := rv.FieldByIndex(f.index)
+		if f.omitEmpty && isEmpty(fv) {
+			continue
+		}
+		e.string(f.name)
+		if err := e.value(fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isEmpty reports whether omitempty drops v, as in encoding/json
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		return false
+	}
+	return v.IsZero()
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.buf = append(e.buf, 0xc3)
+	} else {
+		e.buf = append(e.buf, 0xc2)
+	}
+}
+
+// int uses the shortest format for i
+func (e *encoder) int(i int64) {
+	switch {
+	case i >= 0:
+		e.uint(uint64(i))
+	case i >= -32:
+		e.buf = append(e.buf, byte(int8(i)))
+	case i >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(int8(i)))
+	case i >= math.MinInt16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xd2), uint32(i))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xd3), uint64(i))
+	}
+}
+
+// uint uses the shortest format for u
+func (e *encoder) uint(u uint64) {
+	switch {
+	case u <= math.MaxInt8:
+		e.buf = append(e.buf, byte(u))
+	case u <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xce), uint32(u))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcf), u)
+	}
+}
+
+func (e *encoder) float64(f float64) {
+	e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcb), math.Float64bits(f))
+}
+
+func (e *encoder) string(s string) {
+	switch n := len(s); {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xda), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdb), uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.binHeader(len(b))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) binHeader(n int) {
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xc5), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xc6), uint32(n))
+	}
+}
+
+func (e *encoder) arrayHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xdc), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdd), uint32(n))
+	}
+}
+
+func (e *encoder) mapHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xde), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdf), uint32(n))
+	}
+}
+
+// time uses the timestamp extension (type -1) in its shortest form
+func (e *encoder) time(t time.Time) {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case sec >= 0 && sec>>34 == 0 && nsec == 0:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xd6, 0xff), uint32(sec))
+	case sec >= 0 && sec>>34 == 0:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xd7, 0xff), nsec<<34|uint64(sec))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xc7, 12, 0xff), uint32(nsec))
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(sec))
+	}
+}
@@ -0,0 +1,81 @@
+package msgpack
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// field is an encoded struct field
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields are the encoded fields of a struct type
+type structFields struct {
+	list   []field
+	byName map[string]*field
+}
+
+var fieldCache sync.Map // reflect.Type -> *structFields
+
+// fieldsOf returns the encoded fields of struct type t
+func fieldsOf(t reflect.Type) *structFields {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.(*structFields)
+	}
+	fields := &structFields{byName: make(map[string]*field)}
+	collectFields(t, nil, fields)
+	for i := range fields.list {
+		fields.byName[fields.list[i].name] = &fields.list[i]
+	}
+	f, _ := fieldCache.LoadOrStore(t, fields)
+	return f.(*structFields)
+}
+
+// collectFields adds the fields of t, flattening untagged embedded structs
+func collectFields(t reflect.Type, index []int, fields *structFields) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("msgpack")
+		if !ok {
+			tag = sf.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		idx := append(append([]int(nil), index...), i)
+
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			collectFields(sf.Type, idx, fields)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields.list = append(fields.list, field{
+			name:      name,
+			index:     idx,
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+		})
+	}
+}
+
+// lookup finds the field for key, case-insensitively if no name matches
+func (f *structFields) lookup(key string) *field {
+	if fd, ok := f.byName[key]; ok {
+		return fd
+	}
+	for i := range f.list {
+		if strings.EqualFold(f.list[i].name, key) {
+			return &f.list[i]
+		}
+	}
+	return nil
+}
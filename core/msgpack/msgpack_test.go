@@ -0,0 +1,118 @@
+package msgpack
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type inner struct {
+	Tags []string `json:"tags"`
+}
+
+type record struct {
+	inner
+	ID      uint64            `msgpack:"id"`
+	Name    string            `json:"name"`
+	Score   float64           `json:"score"`
+	Delta   int16             `json:"delta"`
+	Raw     []byte            `json:"raw"`
+	Attrs   map[string]int    `json:"attrs"`
+	When    time.Time         `json:"when"`
+	Next    *record           `json:"next,omitempty"`
+	Note    string            `json:"note,omitempty"`
+	Any     any               `json:"any"`
+	Skipped string            `json:"-"`
+	Labels  map[string]string `json:"labels"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := record{
+		inner:   inner{Tags: []string{"a", "b"}},
+		ID:      math.MaxUint64,
+		Name:    "fast",
+		Score:   1.5,
+		Delta:   -300,
+		Raw:     []byte{0, 1, 2},
+		Attrs:   map[string]int{"x": 1, "y": -1},
+		When:    time.Unix(1700000000, 123456789),
+		Next:    &record{Name: "next", When: time.Unix(1<<35, 0)},
+		Any:     []any{"s", int64(-1), true, nil},
+		Skipped: "gone",
+	}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out record
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	in.Skipped = ""
+	if !out.When.Equal(in.When) || !out.Next.When.Equal(in.Next.When) {
+		t.Errorf("Timestamps %v, %v", out.When, out.Next.When)
+	}
+	out.When, out.Next.When, in.When, in.Next.When = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Decoded\n%+v\nwant\n%+v", out, in)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		v    any
+		want []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{127, []byte{0x7f}},
+		{-32, []byte{0xe0}},
+		{-33, []byte{0xd0, 0xdf}},
+		{256, []byte{0xcd, 0x01, 0x00}},
+		{"hi", []byte{0xa2, 'h', 'i'}},
+		{[]int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{map[string]any{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02}},
+		{struct {
+			A int `json:"a,omitempty"`
+			B int
+		}{B: 1}, []byte{0x81, 0xa1, 'B', 0x01}},
+	}
+	for _, tt := range tests {
+		got, err := Marshal(tt.v)
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("Marshal(%v) = % x, %v; want % x", tt.v, got, err, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalAny(t *testing.T) {
+	data, _ := Marshal(map[string]any{"n": 1, "list": []any{"x", 2.5}, "bin": []byte("b")})
+	var v any
+	if err := Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"n": int64(1), "list": []any{"x", 2.5}, "bin": []byte("b")}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("Got %#v", v)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var n int8
+	if err := Unmarshal([]byte{0xcd, 0x01, 0x00}, &n); err == nil {
+		t.Error("Expected 256 to overflow int8")
+	}
+	var s []int
+	if err := Unmarshal([]byte{0xdd, 0xff, 0xff, 0xff, 0xff}, &s); err == nil {
+		t.Error("Expected an error for an array longer than the data")
+	}
+	var v any
+	if err := Unmarshal(bytes.Repeat([]byte{0x91}, maxDepth+1), &v); err == nil {
+		t.Error("Expected an error for deep nesting")
+	}
+	if err := Unmarshal([]byte{0xa1, 'x', 0x00}, &v); err == nil {
+		t.Error("Expected an error for trailing data")
+	}
+}
@@ -0,0 +1,27 @@
+// Package netfd provides the socket calls the engine makes on integer
+// descriptors. On Unix they are the system calls themselves. Windows has
+// no readiness polling for plain sockets, so there descriptors are
+// emulated over the net package (backed by IOCP): good enough to develop
+// on Windows, not to serve production traffic.
+package netfd
+
+import (
+	"net"
+	"syscall"
+)
+
+// sockaddrOf converts a net.Addr to a socket address (nil if it is not TCP)
+func sockaddrOf(addr net.Addr) syscall.Sockaddr {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return nil
+	}
+	if ip4 := tcp.IP.To4(); ip4 != nil {
+		sa := &syscall.SockaddrInet4{Port: tcp.Port}
+		copy(sa.Addr[:], ip4)
+		return sa
+	}
+	sa := &syscall.SockaddrInet6{Port: tcp.Port}
+	copy(sa.Addr[:], tcp.IP.To16())
+	return sa
+}
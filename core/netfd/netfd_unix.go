@@ -0,0 +1,185 @@
+//go:build !windows
+
+package netfd
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Listen opens a non-blocking TCP listener on addr and returns its
+// descriptor and a function that closes it
+func Listen(addr string) (int, func() error, error) {
+	laddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return -1, nil, err
+	}
+	ln, err := net.ListenTCP("tcp", laddr)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	// File returns a blocking duplicate of the listener's descriptor
+	f, err := ln.File()
+	if err != nil {
+		ln.Close()
+		return -1, nil, err
+	}
+	lfd := int(f.Fd())
+	if err := syscall.SetNonblock(lfd, true); err != nil {
+		f.Close()
+		ln.Close()
+		return -1, nil, err
+	}
+
+	return lfd, func() error {
+		f.Close()
+		return ln.Close()
+	}, nil
+}
+
+// Accept accepts a connection on a non-blocking listener
+func Accept(lfd int) (int, syscall.Sockaddr, error) {
+	return syscall.Accept(lfd)
+}
+
+// Pair returns the two ends of a connected stream socket, as a server
+// connection and its client for tests
+func Pair() (int, int, error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return -1, -1, err
+	}
+	syscall.CloseOnExec(fds[0])
+	syscall.CloseOnExec(fds[1])
+	return fds[0], fds[1], nil
+}
+
+// SetNonblock puts fd in non-blocking mode
+func SetNonblock(fd int) error {
+	return syscall.SetNonblock(fd, true)
+}
+
+// Read reads from fd; EAGAIN means no data is buffered
+func Read(fd int, p []byte) (int, error) {
+	return syscall.Read(fd, p)
+}
+
+// Write writes to fd; EAGAIN means the send buffer is full
+func Write(fd int, p []byte) (int, error) {
+	return syscall.Write(fd, p)
+}
+
+// Writev writes bufs to fd with one call
+func Writev(fd int, bufs [][]byte) (int, error) {
+	return unix.Writev(fd, bufs)
+}
+
+// WaitWritable blocks until fd is writable or timeout has passed
+// (negative: no timeout)
+func WaitWritable(fd int, timeout time.Duration) error {
+	return wait(fd, unix.POLLOUT, timeout)
+}
+
+// WaitReadable blocks until fd is readable or timeout has passed
+// (negative: no timeout)
+func WaitReadable(fd int, timeout time.Duration) error {
+	return wait(fd, unix.POLLIN, timeout)
+}
+
+// wait polls fd for events
+func wait(fd int, events int16, timeout time.Duration) error {
+	ms := -1
+	if timeout >= 0 {
+		ms = int((timeout + time.Millisecond - 1) / time.Millisecond)
+	}
+	fds := []unix.PollFd{{Fd: int32(fd), Events: events}}
+	for {
+		_, err := unix.Poll(fds, ms)
+		if err != unix.EINTR {
+			return err
+		}
+	}
+}
+
+// Sendfile copies count bytes of in, starting at *offset, to fd in the
+// kernel and advances *offset
+func Sendfile(fd int, in *os.File, offset *int64, count int) (int, error) {
+	return syscall.Sendfile(fd, int(in.Fd()), offset, count)
+}
+
+// Close closes fd
+func Close(fd int) error {
+	return syscall.Close(fd)
+}
+
+// Conn returns a net.Conn over a duplicate of fd, for a handler taking
+// the connection over. fd must still be closed; that leaves the returned
+// conn open.
+func Conn(fd int) (net.Conn, error) {
+	dup, err := syscall.Dup(fd)
+	if err != nil {
+		return nil, err
+	}
+	syscall.CloseOnExec(dup)
+	f := os.NewFile(uintptr(dup), "conn")
+	defer f.Close()
+	return net.FileConn(f)
+}
+
+// Shutdown shuts fd down in both directions: pending and later reads and
+// writes fail, without releasing the descriptor
+func Shutdown(fd int) error {
+	return syscall.Shutdown(fd, syscall.SHUT_RDWR)
+}
+
+// PeerClosed reports whether the peer has closed or reset the connection.
+// It peeks without consuming data.
+func PeerClosed(fd int) bool {
+	var b [1]byte
+	n, _, err := syscall.Recvfrom(fd, b[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+	return n == 0 && err == nil || err != nil && err != syscall.EAGAIN && err != syscall.EINTR
+}
+
+// Getpeername returns the remote address of fd
+func Getpeername(fd int) (syscall.Sockaddr, error) {
+	return syscall.Getpeername(fd)
+}
+
+// Getsockname returns the local address of fd
+func Getsockname(fd int) (syscall.Sockaddr, error) {
+	return syscall.Getsockname(fd)
+}
+
+// SetsockoptInt sets an integer socket option
+func SetsockoptInt(fd, level, opt, value int) error {
+	return syscall.SetsockoptInt(fd, level, opt, value)
+}
+
+// GetsockoptInt reads an integer socket option
+func GetsockoptInt(fd, level, opt int) (int, error) {
+	return syscall.GetsockoptInt(fd, level, opt)
+}
+
+// FileLimit returns the soft and hard limits on open descriptors
+func FileLimit() (cur, max uint64, err error) {
+	var rlim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlim); err != nil {
+		return 0, 0, err
+	}
+	return uint64(rlim.Cur), uint64(rlim.Max), nil
+}
+
+// SetFileLimit sets the soft limit on open descriptors
+func SetFileLimit(cur uint64) error {
+	var rlim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlim); err != nil {
+		return err
+	}
+	rlim.Cur = cur
+	return syscall.Setrlimit(syscall.RLIMIT_NOFILE, &rlim)
+}
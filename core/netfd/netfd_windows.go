@@ -0,0 +1,545 @@
+//go:build windows
+
+package netfd
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// maxBuffered is how much a connection's reader buffers before it waits
+// for the engine to read
+const maxBuffered = 64 * 1024
+
+// socket is an emulated descriptor: a connection whose reader goroutine
+// buffers incoming data, or a listener whose acceptor queues connections
+type socket struct {
+	mu    sync.Mutex
+	space sync.Cond // signaled when buf drains
+
+	conn net.Conn
+	buf  []byte // received, not yet read
+	err  error  // set once reading stopped (io.EOF: peer closed)
+
+	ln       net.Listener
+	backlog  []net.Conn
+	watchers []chan struct{}
+	closed   bool
+	detached bool // handed out by Conn; Close leaves conn open
+}
+
+var (
+	tableMu sync.Mutex
+	table   = map[int]*socket{}
+	nextFD  = 3
+)
+
+// register assigns s a descriptor
+func register(s *socket) int {
+	s.space.L = &s.mu
+	tableMu.Lock()
+	fd := nextFD
+	nextFD++
+	table[fd] = s
+	tableMu.Unlock()
+	return fd
+}
+
+func lookup(fd int) *socket {
+	tableMu.Lock()
+	s := table[fd]
+	tableMu.Unlock()
+	return s
+}
+
+// notify wakes the pollers watching s. Must be called with s.mu held.
+func (s *socket) notify() {
+	for _, ch := range s.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// readLoop buffers what the peer sends until it closes or s is closed
+func (s *socket) readLoop() {
+	chunk := make([]byte, 16*1024)
+	for {
+		n, err := s.conn.Read(chunk)
+
+		s.mu.Lock()
+		s.buf = append(s.buf, chunk[:n]...)
+		if err != nil && s.err == nil {
+			s.err = err
+		}
+		s.notify()
+		for len(s.buf) >= maxBuffered && !s.closed {
+			s.space.Wait()
+		}
+		stop := s.err != nil || s.closed
+		s.mu.Unlock()
+
+		if stop {
+			return
+		}
+	}
+}
+
+// acceptLoop queues incoming connections until the listener is closed
+func (s *socket) acceptLoop() {
+	for {
+		c, err := s.ln.Accept()
+
+		s.mu.Lock()
+		if err != nil {
+			s.err = err
+		} else {
+			s.backlog = append(s.backlog, c)
+		}
+		s.notify()
+		s.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Listen opens a TCP listener on addr and returns its descriptor and a
+// function that closes it
+func Listen(addr string) (int, func() error, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return -1, nil, err
+	}
+	s := &socket{ln: ln}
+	fd := register(s)
+	go s.acceptLoop()
+	return fd, func() error { return Close(fd) }, nil
+}
+
+// Accept returns a queued connection, or EAGAIN if there is none
+func Accept(lfd int) (int, syscall.Sockaddr, error) {
+	s := lookup(lfd)
+	if s == nil || s.ln == nil {
+		return -1, nil, syscall.EBADF
+	}
+
+	s.mu.Lock()
+	if len(s.backlog) == 0 {
+		err := s.err
+		s.mu.Unlock()
+		if err != nil {
+			return -1, nil, err
+		}
+		return -1, nil, syscall.EAGAIN
+	}
+	c := s.backlog[0]
+	s.backlog = s.backlog[1:]
+	s.mu.Unlock()
+
+	cs := &socket{conn: c}
+	fd := register(cs)
+	go cs.readLoop()
+	return fd, sockaddrOf(c.RemoteAddr()), nil
+}
+
+// Pair returns the two ends of an in-memory connection, as a server
+// connection and its client for tests. Writes never block: they land in
+// the other end's buffer however much it holds.
+func Pair() (int, int, error) {
+	a, b := &socket{}, &socket{}
+	ac, bc := &pairConn{s: a}, &pairConn{s: b}
+	ac.peer, bc.peer = bc, ac
+	a.conn, b.conn = ac, bc
+	return register(a), register(b), nil
+}
+
+// pairConn is one end of a Pair. It has no reader goroutine: the other
+// end writes straight into its socket's buffer.
+type pairConn struct {
+	s      *socket
+	peer   *pairConn
+	closed atomic.Bool
+}
+
+func (c *pairConn) Read(p []byte) (int, error) {
+	return 0, errors.ErrUnsupported
+}
+
+func (c *pairConn) Write(p []byte) (int, error) {
+	if c.closed.Load() || c.peer.closed.Load() {
+		return 0, net.ErrClosed
+	}
+	r := c.peer.s
+	r.mu.Lock()
+	r.buf = append(r.buf, p...)
+	r.notify()
+	r.mu.Unlock()
+	return len(p), nil
+}
+
+// Close ends both directions: the other end reads EOF after what it has
+// buffered, and writes either way fail
+func (c *pairConn) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	for _, s := range []*socket{c.s, c.peer.s} {
+		s.mu.Lock()
+		if s.err == nil {
+			s.err = io.EOF
+		}
+		s.notify()
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+func (c *pairConn) LocalAddr() net.Addr                { return pairAddr{} }
+func (c *pairConn) RemoteAddr() net.Addr               { return pairAddr{} }
+func (c *pairConn) SetDeadline(t time.Time) error      { return nil }
+func (c *pairConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *pairConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// pairAddr is the address of both ends of a Pair
+type pairAddr struct{}
+
+func (pairAddr) Network() string { return "pair" }
+func (pairAddr) String() string  { return "pair" }
+
+// SetNonblock is a no-op: emulated reads never block
+func SetNonblock(fd int) error {
+	return nil
+}
+
+// Read returns buffered data, or EAGAIN if none has arrived yet
+func Read(fd int, p []byte) (int, error) {
+	s := lookup(fd)
+	if s == nil || s.conn == nil {
+		return 0, syscall.EBADF
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.buf) == 0 {
+		switch {
+		case s.err == nil:
+			return 0, syscall.EAGAIN
+		case s.err == io.EOF:
+			return 0, nil
+		default:
+			return 0, syscall.ECONNRESET
+		}
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[:copy(s.buf, s.buf[n:])]
+	s.space.Signal()
+	return n, nil
+}
+
+// Write writes p, blocking until it is sent
+func Write(fd int, p []byte) (int, error) {
+	s := lookup(fd)
+	if s == nil || s.conn == nil {
+		return 0, syscall.EBADF
+	}
+	n, err := s.conn.Write(p)
+	return n, writeErr(err)
+}
+
+// Writev writes bufs, blocking until they are sent
+func Writev(fd int, bufs [][]byte) (int, error) {
+	s := lookup(fd)
+	if s == nil || s.conn == nil {
+		return 0, syscall.EBADF
+	}
+	nb := net.Buffers(bufs)
+	n, err := nb.WriteTo(s.conn)
+	return int(n), writeErr(err)
+}
+
+// WaitWritable returns at once: writes block until they are sent
+func WaitWritable(fd int, timeout time.Duration) error {
+	return nil
+}
+
+// WaitReadable blocks until fd is readable or timeout has passed
+// (negative: no timeout)
+func WaitReadable(fd int, timeout time.Duration) error {
+	ch := make(chan struct{}, 1)
+	if err := Watch(fd, ch); err != nil {
+		return err
+	}
+	defer Unwatch(fd, ch)
+
+	var expired <-chan time.Time
+	if timeout >= 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	for !Readable(fd) {
+		select {
+		case <-ch:
+		case <-expired:
+			return nil
+		}
+		if lookup(fd) == nil {
+			return syscall.EBADF
+		}
+	}
+	return nil
+}
+
+// Sendfile copies count bytes of in, starting at *offset, to fd through a
+// user-space buffer and advances *offset
+func Sendfile(fd int, in *os.File, offset *int64, count int) (int, error) {
+	s := lookup(fd)
+	if s == nil || s.conn == nil {
+		return 0, syscall.EBADF
+	}
+	n, err := io.Copy(s.conn, io.NewSectionReader(in, *offset, int64(count)))
+	*offset += n
+	return int(n), writeErr(err)
+}
+
+// writeErr maps a failed write to the errno the engine expects
+func writeErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, net.ErrClosed) {
+		return syscall.EPIPE
+	}
+	return syscall.ECONNRESET
+}
+
+// Close closes fd
+func Close(fd int) error {
+	tableMu.Lock()
+	s := table[fd]
+	delete(table, fd)
+	tableMu.Unlock()
+	if s == nil {
+		return syscall.EBADF
+	}
+
+	s.mu.Lock()
+	if s.detached {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.notify()
+	s.watchers = nil
+	s.space.Broadcast()
+	s.mu.Unlock()
+
+	if s.ln != nil {
+		return s.ln.Close()
+	}
+	return s.conn.Close()
+}
+
+// Conn returns a net.Conn for fd, for a handler taking the connection
+// over. It reads what is buffered for fd before what arrives later; read
+// deadlines are not supported. fd must still be closed; that leaves the
+// returned conn open.
+func Conn(fd int) (net.Conn, error) {
+	s := lookup(fd)
+	if s == nil || s.conn == nil {
+		return nil, syscall.EBADF
+	}
+	s.mu.Lock()
+	s.detached = true
+	s.mu.Unlock()
+	return &socketConn{Conn: s.conn, s: s}, nil
+}
+
+// socketConn is a detached socket read through its buffer
+type socketConn struct {
+	net.Conn
+	s *socket
+}
+
+func (c *socketConn) Read(p []byte) (int, error) {
+	s := c.s
+	ready := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.buf) == 0 && s.err == nil && !s.closed {
+		s.watchers = append(s.watchers, ready)
+		s.mu.Unlock()
+		<-ready
+		s.mu.Lock()
+		s.unwatch(ready)
+	}
+	if s.closed {
+		return 0, net.ErrClosed
+	}
+	if len(s.buf) == 0 {
+		return 0, s.err
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[:copy(s.buf, s.buf[n:])]
+	s.space.Signal()
+	return n, nil
+}
+
+func (c *socketConn) Close() error {
+	s := c.s
+	s.mu.Lock()
+	s.closed = true
+	s.notify()
+	s.space.Broadcast()
+	s.mu.Unlock()
+	return s.conn.Close()
+}
+
+// Shutdown closes the connection behind fd: reads and writes fail, and
+// the descriptor stays valid until Close
+func Shutdown(fd int) error {
+	s := lookup(fd)
+	if s == nil || s.conn == nil {
+		return syscall.EBADF
+	}
+	return s.conn.Close()
+}
+
+// PeerClosed reports whether the peer has closed or reset the connection
+// and everything it sent has been read
+func PeerClosed(fd int) bool {
+	s := lookup(fd)
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buf) == 0 && s.err != nil
+}
+
+// Getpeername returns the remote address of fd
+func Getpeername(fd int) (syscall.Sockaddr, error) {
+	s := lookup(fd)
+	if s == nil || s.conn == nil {
+		return nil, syscall.EBADF
+	}
+	return sockaddrOf(s.conn.RemoteAddr()), nil
+}
+
+// Getsockname returns the local address of fd
+func Getsockname(fd int) (syscall.Sockaddr, error) {
+	s := lookup(fd)
+	if s == nil {
+		return nil, syscall.EBADF
+	}
+	if s.ln != nil {
+		return sockaddrOf(s.ln.Addr()), nil
+	}
+	return sockaddrOf(s.conn.LocalAddr()), nil
+}
+
+// SetsockoptInt applies the TCP options the engine uses through the net
+// package; others are unsupported
+func SetsockoptInt(fd, level, opt, value int) error {
+	s := lookup(fd)
+	if s == nil {
+		return syscall.EBADF
+	}
+	tcp, ok := s.conn.(*net.TCPConn)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+
+	switch {
+	case level == syscall.IPPROTO_TCP && opt == syscall.TCP_NODELAY:
+		return tcp.SetNoDelay(value != 0)
+	case level == syscall.SOL_SOCKET && opt == syscall.SO_KEEPALIVE:
+		return tcp.SetKeepAlive(value != 0)
+	case level == syscall.SOL_SOCKET && opt == syscall.SO_RCVBUF:
+		return tcp.SetReadBuffer(value)
+	case level == syscall.SOL_SOCKET && opt == syscall.SO_SNDBUF:
+		return tcp.SetWriteBuffer(value)
+	}
+	return errors.ErrUnsupported
+}
+
+// GetsockoptInt is unsupported on Windows
+func GetsockoptInt(fd, level, opt int) (int, error) {
+	return 0, errors.ErrUnsupported
+}
+
+// FileLimit is unsupported: Windows has no descriptor limit to speak of
+func FileLimit() (cur, max uint64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
+
+// SetFileLimit is unsupported on Windows
+func SetFileLimit(cur uint64) error {
+	return errors.ErrUnsupported
+}
+
+// Readable reports whether a read or accept on fd would not return EAGAIN
+func Readable(fd int) bool {
+	s := lookup(fd)
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buf) > 0 || len(s.backlog) > 0 || s.err != nil
+}
+
+// Hungup reports whether the peer of fd has closed or reset the connection
+func Hungup(fd int) bool {
+	s := lookup(fd)
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err != nil
+}
+
+// Watch makes fd signal ch (without blocking) whenever it may have become
+// readable
+func Watch(fd int, ch chan struct{}) error {
+	s := lookup(fd)
+	if s == nil {
+		return syscall.EBADF
+	}
+	s.mu.Lock()
+	s.watchers = append(s.watchers, ch)
+	s.mu.Unlock()
+	return nil
+}
+
+// Unwatch stops fd from signaling ch
+func Unwatch(fd int, ch chan struct{}) {
+	s := lookup(fd)
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.unwatch(ch)
+	s.mu.Unlock()
+}
+
+// unwatch removes ch from the watchers. Must be called with s.mu held.
+func (s *socket) unwatch(ch chan struct{}) {
+	for i, w := range s.watchers {
+		if w == ch {
+			s.watchers = append(s.watchers[:i], s.watchers[i+1:]...)
+			return
+		}
+	}
+}
@@ -19,6 +19,9 @@ type EBPFTracer struct {
 	networkStats sync.Map // map[string]*NetworkStats
 	lockStats    sync.Map // map[string]*LockStats
 
+	// Last retransmit count sampled per connection (see TraceTCPInfo)
+	retransmits sync.Map // map[int]uint32
+
 	// Sampling
 	sampleRate atomic.Uint32 // 0-100 percentage
 }
@@ -53,6 +56,12 @@ type NetworkStats struct {
 	Connections atomic.Uint64
 	Retransmits atomic.Uint64
 	Errors      atomic.Uint64
+
+	// Connection quality from TCP_INFO samples (see TraceTCPInfo)
+	Samples     atomic.Uint64
+	TotalRTT    atomic.Uint64 // microseconds
+	rttBuckets  [len(RTTBuckets) + 1]atomic.Uint64
+	cwndBuckets [len(CwndBuckets) + 1]atomic.Uint64
 }
 
 // LockStats tracks lock contention
@@ -218,14 +227,25 @@ func (t *EBPFTracer) GetNetworkStats() map[string]NetworkSnapshot {
 		protocol := key.(string)
 		stats := value.(*NetworkStats)
 
-		result[protocol] = NetworkSnapshot{
+		snap := NetworkSnapshot{
 			Protocol:    protocol,
 			BytesSent:   stats.BytesSent.Load(),
 			BytesRecv:   stats.BytesRecv.Load(),
 			Connections: stats.Connections.Load(),
 			Retransmits: stats.Retransmits.Load(),
 			Errors:      stats.Errors.Load(),
+			Samples:     stats.Samples.Load(),
+		}
+		if snap.Samples > 0 {
+			snap.AvgRTT = time.Duration(stats.TotalRTT.Load()/snap.Samples) * time.Microsecond
+		}
+		for i := range stats.rttBuckets {
+			snap.RTT[i] = stats.rttBuckets[i].Load()
 		}
+		for i := range stats.cwndBuckets {
+			snap.Cwnd[i] = stats.cwndBuckets[i].Load()
+		}
+		result[protocol] = snap
 		return true
 	})
 
@@ -292,6 +312,13 @@ type NetworkSnapshot struct {
 	Connections uint64
 	Retransmits uint64
 	Errors      uint64
+
+	// TCP_INFO samples: average RTT and sample counts per RTTBuckets and
+	// CwndBuckets bucket
+	Samples uint64
+	AvgRTT  time.Duration
+	RTT     [len(RTTBuckets) + 1]uint64
+	Cwnd    [len(CwndBuckets) + 1]uint64
 }
 
 type LockSnapshot struct {
@@ -327,10 +354,14 @@ func (t *EBPFTracer) Report() string {
 		report += "  No data\n"
 	}
 	for protocol, stats := range network {
-		report += fmt.Sprintf("  • %s: %d conns, sent=%d MB, recv=%d MB, errors=%d\n",
+		report += fmt.Sprintf("  • %s: %d conns, sent=%d MB, recv=%d MB, errors=%d, retransmits=%d\n",
 			protocol, stats.Connections,
 			stats.BytesSent/(1024*1024), stats.BytesRecv/(1024*1024),
-			stats.Errors)
+			stats.Errors, stats.Retransmits)
+		if stats.Samples > 0 {
+			report += fmt.Sprintf("    rtt avg=%v %s\n", stats.AvgRTT, formatBuckets(RTTBuckets[:], stats.RTT[:]))
+			report += fmt.Sprintf("    cwnd %s\n", formatBuckets(CwndBuckets[:], stats.Cwnd[:]))
+		}
 	}
 
 	// Lock stats
@@ -51,6 +51,36 @@ func TestNetworkTracing(t *testing.T) {
 	}
 }
 
+func TestTraceTCPInfo(t *testing.T) {
+	tracer := NewEBPFTracer()
+
+	tracer.TraceTCPInfo("tcp", 5, TCPInfo{RTT: 200 * time.Microsecond, Cwnd: 10, Retransmits: 2})
+	tracer.TraceTCPInfo("tcp", 5, TCPInfo{RTT: 30 * time.Millisecond, Cwnd: 50, Retransmits: 5})
+	tracer.TraceTCPInfo("tcp", 6, TCPInfo{RTT: 2 * time.Second, Cwnd: 2, Retransmits: 1})
+
+	stats := tracer.GetNetworkStats()["tcp"]
+	if stats.Samples != 3 {
+		t.Fatalf("Expected 3 samples, got %d", stats.Samples)
+	}
+	// Retransmits count deltas per connection: 2 + 3 on fd 5, 1 on fd 6
+	if stats.Retransmits != 6 {
+		t.Errorf("Expected 6 retransmits, got %d", stats.Retransmits)
+	}
+	if stats.RTT[0] != 1 || stats.RTT[3] != 1 || stats.RTT[len(RTTBuckets)] != 1 {
+		t.Errorf("Unexpected RTT distribution: %v", stats.RTT)
+	}
+	if stats.Cwnd[0] != 1 || stats.Cwnd[2] != 1 || stats.Cwnd[3] != 1 {
+		t.Errorf("Unexpected cwnd distribution: %v", stats.Cwnd)
+	}
+
+	// A new connection reusing fd 6 starts from zero again
+	tracer.ForgetConn(6)
+	tracer.TraceTCPInfo("tcp", 6, TCPInfo{Retransmits: 1})
+	if got := tracer.GetNetworkStats()["tcp"].Retransmits; got != 7 {
+		t.Errorf("Expected 7 retransmits after reuse, got %d", got)
+	}
+}
+
 func BenchmarkTraceSyscall(b *testing.B) {
 	tracer := NewEBPFTracer()
 	duration := 10 * time.Microsecond
@@ -0,0 +1,18 @@
+package observability
+
+import "github.com/searchktools/fast-server/core/events"
+
+// Subscribe records the engine's completed requests (events.TopicRequest
+// on bus) in the performance monitor, under the name returned by name
+// (nil: the method; avoid raw paths, which are unbounded). 5xx responses
+// count as errors.
+func (o *Observatory) Subscribe(bus *events.Bus, name func(events.Request) string) *events.Subscription[events.Request] {
+	topic := events.NewTopic[events.Request](bus, events.TopicRequest)
+	return topic.Subscribe(0, func(r events.Request) {
+		handler := r.Method
+		if name != nil {
+			handler = name(r)
+		}
+		o.Monitor.RecordRequest(handler, r.Duration, r.Status >= 500)
+	})
+}
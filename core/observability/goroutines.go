@@ -0,0 +1,263 @@
+package observability
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"sync"
+	"time"
+)
+
+// LeakConfig configures a LeakWatchdog
+type LeakConfig struct {
+	// Interval between goroutine snapshots. Each one briefly stops the
+	// world to collect the stacks. Default: 30s.
+	Interval time.Duration
+
+	// Samples is how many consecutive snapshots a creation site must grow
+	// over, never shrinking, to be reported as leaking. Default: 5.
+	Samples int
+
+	// MinGoroutines ignores sites with fewer goroutines. Default: 10.
+	MinGoroutines int
+
+	// OnLeak is called from the watchdog goroutine when a site starts
+	// leaking, e.g. to page someone
+	OnLeak func(Leak)
+
+	// MaxGoroutines lets the process crash: above it the watchdog writes
+	// every goroutine stack to Output and exits with status 2, for the
+	// supervisor to restart it. 0: no limit.
+	MaxGoroutines int
+
+	// Output receives the stacks dumped before exiting. Default: os.Stderr.
+	Output io.Writer
+}
+
+// Leak is a creation site whose goroutine count keeps growing
+type Leak struct {
+	Site   string    // creating function and the file:line of its go statement
+	Count  int       // goroutines created there still running
+	Growth int       // over the samples that flagged it
+	Since  time.Time // first of those samples
+	Stack  string    // stack of one of the goroutines
+}
+
+// LeakWatchdog periodically counts goroutines by creation site and flags
+// sites with monotonic growth: Hub pumps, SSE handlers or async
+// middleware that never return. Sites that grow with the load (accepted
+// connections) can be flagged while traffic ramps up; they clear once
+// their count drops.
+type LeakWatchdog struct {
+	cfg  LeakConfig
+	exit func(int)
+
+	mu    sync.Mutex
+	sites map[string]*siteHistory
+	total int
+	dump  bytes.Buffer
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// siteHistory is the recent counts of a creation site
+type siteHistory struct {
+	counts []int
+	times  []time.Time
+	stack  string
+	leak   *Leak
+}
+
+// NewLeakWatchdog creates a watchdog; Start runs it
+func NewLeakWatchdog(cfg LeakConfig) *LeakWatchdog {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.Samples <= 0 {
+		cfg.Samples = 5
+	}
+	if cfg.MinGoroutines <= 0 {
+		cfg.MinGoroutines = 10
+	}
+	if cfg.Output == nil {
+		cfg.Output = os.Stderr
+	}
+	return &LeakWatchdog{
+		cfg:   cfg,
+		exit:  os.Exit,
+		sites: make(map[string]*siteHistory),
+		stop:  make(chan struct{}),
+	}
+}
+
+// Start takes a snapshot every Interval until Stop
+func (w *LeakWatchdog) Start() {
+	go func() {
+		ticker := time.NewTicker(w.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.Sample()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops taking snapshots
+func (w *LeakWatchdog) Stop() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+// Sample takes a snapshot now and returns the sites that started leaking
+func (w *LeakWatchdog) Sample() []Leak {
+	w.mu.Lock()
+	w.dump.Reset()
+	pprof.Lookup("goroutine").WriteTo(&w.dump, 2)
+	snapshot := goroutineSites(w.dump.Bytes())
+	w.total = runtime.NumGoroutine()
+
+	if w.cfg.MaxGoroutines > 0 && w.total > w.cfg.MaxGoroutines {
+		fmt.Fprintf(w.cfg.Output, "goroutine watchdog: %d goroutines exceed the limit of %d, exiting\n\n",
+			w.total, w.cfg.MaxGoroutines)
+		w.cfg.Output.Write(w.dump.Bytes())
+		w.mu.Unlock()
+		w.exit(2)
+		return nil
+	}
+
+	now := time.Now()
+	var started []Leak
+	for site := range w.sites {
+		if _, ok := snapshot[site]; !ok {
+			delete(w.sites, site) // All exited
+		}
+	}
+	for site, g := range snapshot {
+		h := w.sites[site]
+		if h == nil {
+			h = &siteHistory{}
+			w.sites[site] = h
+		}
+		h.stack = g.stack
+		if l := h.record(site, g.count, now, w.cfg.Samples, w.cfg.MinGoroutines); l != nil {
+			started = append(started, *l)
+		}
+	}
+	w.mu.Unlock()
+
+	if w.cfg.OnLeak != nil {
+		for _, l := range started {
+			w.cfg.OnLeak(l)
+		}
+	}
+	return started
+}
+
+// record adds a count and returns the leak if the site just started
+// leaking
+func (h *siteHistory) record(site string, n int, now time.Time, samples, min int) *Leak {
+	if last := len(h.counts) - 1; last >= 0 && n < h.counts[last] {
+		// Shrinking: not a leak, or no longer one
+		h.counts, h.times, h.leak = h.counts[:0], h.times[:0], nil
+	}
+	h.counts = append(h.counts, n)
+	h.times = append(h.times, now)
+	if len(h.counts) > samples+1 {
+		h.counts = append(h.counts[:0], h.counts[1:]...)
+		h.times = append(h.times[:0], h.times[1:]...)
+	}
+
+	if h.leak != nil {
+		h.leak.Count = n
+		h.leak.Growth = n - h.counts[0]
+		h.leak.Stack = h.stack
+		return nil
+	}
+	if len(h.counts) <= samples || n < min || n == h.counts[0] {
+		return nil
+	}
+	h.leak = &Leak{Site: site, Count: n, Growth: n - h.counts[0], Since: h.times[0], Stack: h.stack}
+	return h.leak
+}
+
+// Leaks returns the sites currently leaking, most goroutines first
+func (w *LeakWatchdog) Leaks() []Leak {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var leaks []Leak
+	for _, h := range w.sites {
+		if h.leak != nil {
+			leaks = append(leaks, *h.leak)
+		}
+	}
+	slices.SortFunc(leaks, func(a, b Leak) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Site, b.Site))
+	})
+	return leaks
+}
+
+// Report generates a human-readable goroutine leak report
+func (w *LeakWatchdog) Report() string {
+	leaks := w.Leaks()
+	w.mu.Lock()
+	total := w.total
+	w.mu.Unlock()
+
+	report := "🧵 Goroutines:\n"
+	report += fmt.Sprintf("  • At last snapshot: %d\n", total)
+	if len(leaks) == 0 {
+		report += "  ✅ No leaks detected\n"
+		return report
+	}
+	report += fmt.Sprintf("  ⚠️  %d sites growing:\n", len(leaks))
+	for _, l := range leaks {
+		report += fmt.Sprintf("    %s: %d goroutines, +%d since %s\n",
+			l.Site, l.Count, l.Growth, l.Since.Format(time.TimeOnly))
+	}
+	return report
+}
+
+// siteGoroutines is a creation site's goroutines in one snapshot
+type siteGoroutines struct {
+	count int
+	stack string // the first one's
+}
+
+// goroutineSites counts the goroutines of a debug=2 goroutine profile by
+// creation site. Goroutines not created by a go statement (main) are
+// skipped.
+func goroutineSites(dump []byte) map[string]*siteGoroutines {
+	sites := make(map[string]*siteGoroutines)
+	var key []byte
+	for len(dump) > 0 {
+		var block []byte
+		block, dump, _ = bytes.Cut(dump, []byte("\n\n"))
+
+		_, created, ok := bytes.Cut(block, []byte("\ncreated by "))
+		if !ok {
+			continue
+		}
+		fn, location, _ := bytes.Cut(created, []byte("\n"))
+		fn, _, _ = bytes.Cut(fn, []byte(" in goroutine "))
+		location = bytes.TrimSpace(location)
+		location, _, _ = bytes.Cut(location, []byte(" +0x"))
+
+		key = append(append(append(key[:0], fn...), ' '), location...)
+		if g := sites[string(key)]; g != nil {
+			g.count++
+			continue
+		}
+		sites[string(key)] = &siteGoroutines{count: 1, stack: string(block)}
+	}
+	return sites
+}
@@ -0,0 +1,65 @@
+package observability
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// leakOne starts a goroutine that blocks until release is closed
+func leakOne(release chan struct{}) {
+	go func() { <-release }()
+}
+
+func TestLeakWatchdog(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	var alerts []Leak
+	w := NewLeakWatchdog(LeakConfig{Samples: 3, MinGoroutines: 4, OnLeak: func(l Leak) { alerts = append(alerts, l) }})
+
+	for i := 0; i < 3; i++ {
+		leakOne(release)
+		leakOne(release)
+		if started := w.Sample(); len(started) != 0 {
+			t.Fatalf("Sample %d: flagged %v before enough samples", i, started)
+		}
+	}
+	leakOne(release)
+	leakOne(release)
+	started := w.Sample()
+	if len(started) != 1 || len(alerts) != 1 {
+		t.Fatalf("Expected one leaking site, got %+v", started)
+	}
+	l := started[0]
+	if !strings.Contains(l.Site, "leakOne") || !strings.Contains(l.Site, "goroutines_test.go:") {
+		t.Errorf("Expected the creation site of leakOne, got %q", l.Site)
+	}
+	if l.Count != 8 || l.Growth != 6 || !strings.Contains(l.Stack, "goroutine ") {
+		t.Errorf("Unexpected leak %+v", l)
+	}
+	if !strings.Contains(w.Report(), "1 sites growing") {
+		t.Errorf("Report misses the leak:\n%s", w.Report())
+	}
+
+	// Still growing: reported once
+	leakOne(release)
+	if started := w.Sample(); len(started) != 0 || len(alerts) != 1 || w.Leaks()[0].Count != 9 {
+		t.Errorf("Expected the leak to be updated, not reported again: %+v", w.Leaks())
+	}
+}
+
+func TestLeakWatchdogCrash(t *testing.T) {
+	var out bytes.Buffer
+	w := NewLeakWatchdog(LeakConfig{MaxGoroutines: 1, Output: &out})
+	code := -1
+	w.exit = func(c int) { code = c }
+
+	release := make(chan struct{})
+	defer close(release)
+	leakOne(release)
+	w.Sample()
+	if code != 2 || !strings.Contains(out.String(), "exceed the limit of 1") || !strings.Contains(out.String(), "leakOne") {
+		t.Errorf("Expected a stack dump and exit 2, got code %d:\n%s", code, out.String())
+	}
+}
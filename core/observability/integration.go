@@ -3,15 +3,19 @@ package observability
 import (
 	"fmt"
 	"runtime"
-	"syscall"
 	"time"
+
+	"github.com/searchktools/fast-server/core/netfd"
 )
 
 // Observatory is the central observability hub
 type Observatory struct {
 	Monitor *PerformanceMonitor
 	Tracer  *EBPFTracer
-	enabled bool
+	Writes  *WriteMonitor
+	// Goroutine leak watchdog (nil until WatchGoroutines)
+	Goroutines *LeakWatchdog
+	enabled    bool
 }
 
 // NewObservatory creates a new observatory
@@ -19,6 +23,7 @@ func NewObservatory() *Observatory {
 	return &Observatory{
 		Monitor: NewPerformanceMonitor(),
 		Tracer:  NewEBPFTracer(),
+		Writes:  NewWriteMonitor(),
 		enabled: true,
 	}
 }
@@ -106,11 +111,22 @@ func (o *Observatory) GetFullReport() string {
 				i+1, b.Type, b.Location, b.Details, b.Severity)
 		}
 	}
+	if panics := o.Monitor.Panics(); panics > 0 {
+		report += fmt.Sprintf("  ⚠️  %d handler panics recovered\n", panics)
+	}
 	report += "\n"
 
 	// eBPF trace data
 	report += o.Tracer.Report()
 
+	// Slow clients
+	report += "\n" + o.Writes.Report()
+
+	// Goroutine leaks
+	if o.Goroutines != nil {
+		report += "\n" + o.Goroutines.Report()
+	}
+
 	// System metrics
 	report += "\n💻 System Metrics:\n"
 	var m runtime.MemStats
@@ -123,11 +139,23 @@ func (o *Observatory) GetFullReport() string {
 	return report
 }
 
+// WatchGoroutines starts a goroutine leak watchdog whose leaks are
+// included in the full report
+func (o *Observatory) WatchGoroutines(cfg LeakConfig) *LeakWatchdog {
+	if o.Goroutines != nil {
+		o.Goroutines.Stop()
+	}
+	o.Goroutines = NewLeakWatchdog(cfg)
+	o.Goroutines.Start()
+	return o.Goroutines
+}
+
 // Enable enables all observability
 func (o *Observatory) Enable() {
 	o.enabled = true
 	o.Monitor.enabled.Store(true)
 	o.Tracer.Enable()
+	o.Writes.enabled.Store(true)
 }
 
 // Disable disables all observability
@@ -135,6 +163,7 @@ func (o *Observatory) Disable() {
 	o.enabled = false
 	o.Monitor.enabled.Store(false)
 	o.Tracer.Disable()
+	o.Writes.enabled.Store(false)
 }
 
 // Helper functions
@@ -148,11 +177,11 @@ func getMemStats() uint64 {
 // WrapSyscallWrite wraps syscall.Write with tracing
 func (o *Observatory) WrapSyscallWrite(fd int, p []byte) (n int, err error) {
 	if !o.enabled {
-		return syscall.Write(fd, p)
+		return netfd.Write(fd, p)
 	}
 
 	callback := o.TraceNetworkIO("tcp", fd, "write")
-	n, err = syscall.Write(fd, p)
+	n, err = netfd.Write(fd, p)
 	callback(n, err)
 	return
 }
@@ -160,11 +189,11 @@ func (o *Observatory) WrapSyscallWrite(fd int, p []byte) (n int, err error) {
 // WrapSyscallRead wraps syscall.Read with tracing
 func (o *Observatory) WrapSyscallRead(fd int, p []byte) (n int, err error) {
 	if !o.enabled {
-		return syscall.Read(fd, p)
+		return netfd.Read(fd, p)
 	}
 
 	callback := o.TraceNetworkIO("tcp", fd, "read")
-	n, err = syscall.Read(fd, p)
+	n, err = netfd.Read(fd, p)
 	callback(n, err)
 	return
 }
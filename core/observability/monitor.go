@@ -16,6 +16,7 @@ type PerformanceMonitor struct {
 		totalDuration   atomic.Uint64
 		totalCPUTime    atomic.Uint64
 		totalAllocBytes atomic.Uint64
+		totalPanics     atomic.Uint64
 	}
 	bottlenecks  []Bottleneck
 	bottleneckMu sync.RWMutex
@@ -26,6 +27,7 @@ type HandlerMetrics struct {
 	Name           string
 	Count          atomic.Uint64
 	Errors         atomic.Uint64
+	Panics         atomic.Uint64
 	TotalDuration  atomic.Uint64
 	MinDuration    atomic.Uint64
 	MaxDuration    atomic.Uint64
@@ -207,3 +209,22 @@ func (pm *PerformanceMonitor) EndTrace(handler string, startTime int64, isError
 	duration := time.Duration(time.Now().UnixNano() - startTime)
 	pm.RecordRequest(handler, duration, isError)
 }
+
+// RecordPanic records a recovered handler panic as a failed request
+func (pm *PerformanceMonitor) RecordPanic(handler string) {
+	if !pm.enabled.Load() {
+		return
+	}
+
+	val, _ := pm.handlers.LoadOrStore(handler, &HandlerMetrics{Name: handler})
+	metrics := val.(*HandlerMetrics)
+	metrics.Panics.Add(1)
+	metrics.Errors.Add(1)
+
+	pm.global.totalPanics.Add(1)
+}
+
+// Panics returns the number of recovered handler panics
+func (pm *PerformanceMonitor) Panics() uint64 {
+	return pm.global.totalPanics.Load()
+}
@@ -30,6 +30,25 @@ func TestPerformanceMonitor(t *testing.T) {
 	}
 }
 
+func TestRecordPanic(t *testing.T) {
+	pm := NewPerformanceMonitor()
+	before := pm.Panics()
+
+	pm.RecordPanic("GET /boom")
+
+	val, ok := pm.handlers.Load("GET /boom")
+	if !ok {
+		t.Fatal("Handler metrics not found")
+	}
+	metrics := val.(*HandlerMetrics)
+	if metrics.Panics.Load() != 1 || metrics.Errors.Load() != 1 {
+		t.Errorf("Expected 1 panic and 1 error, got %d and %d", metrics.Panics.Load(), metrics.Errors.Load())
+	}
+	if pm.Panics() != before+1 {
+		t.Errorf("Expected total panics %d, got %d", before+1, pm.Panics())
+	}
+}
+
 func TestBottleneckDetection(t *testing.T) {
 	pm := NewPerformanceMonitor()
 
@@ -0,0 +1,99 @@
+package observability
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// TCPInfo is the kernel's view of a TCP connection's quality (Linux
+// TCP_INFO, see ReadTCPInfo)
+type TCPInfo struct {
+	RTT         time.Duration // smoothed round-trip time
+	RTTVar      time.Duration // round-trip time variance
+	Retransmits uint32        // segments retransmitted over the connection's life
+	Cwnd        uint32        // congestion window, in segments
+	Unsent      uint32        // bytes in the send buffer not yet sent
+}
+
+// Upper bounds of the RTT and congestion window buckets in NetworkStats;
+// the last bucket holds everything above
+var (
+	RTTBuckets = [...]time.Duration{
+		time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond,
+		100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	}
+	CwndBuckets = [...]uint32{4, 10, 32, 100, 300}
+)
+
+// TraceTCPInfo records a TCP_INFO sample of connection fd: its RTT and
+// congestion window go into the distributions, and retransmits since the
+// previous sample of fd into Retransmits
+func (t *EBPFTracer) TraceTCPInfo(protocol string, fd int, info TCPInfo) {
+	if !t.enabled.Load() {
+		return
+	}
+
+	val, _ := t.networkStats.LoadOrStore(protocol, &NetworkStats{Protocol: protocol})
+	stats := val.(*NetworkStats)
+
+	stats.Samples.Add(1)
+	stats.TotalRTT.Add(uint64(info.RTT.Microseconds()))
+	stats.rttBuckets[bucketOf(RTTBuckets[:], info.RTT)].Add(1)
+	stats.cwndBuckets[bucketOf(CwndBuckets[:], info.Cwnd)].Add(1)
+
+	var last uint32
+	if prev, ok := t.retransmits.Swap(fd, info.Retransmits); ok {
+		last = prev.(uint32)
+	}
+	if info.Retransmits > last {
+		stats.Retransmits.Add(uint64(info.Retransmits - last))
+	}
+}
+
+// ForgetConn drops the per-connection state of a closed connection
+func (t *EBPFTracer) ForgetConn(fd int) {
+	t.retransmits.Delete(fd)
+}
+
+// bucketOf returns the index of the first bound v does not exceed
+func bucketOf[T time.Duration | uint32](bounds []T, v T) int {
+	for i, b := range bounds {
+		if v < b {
+			return i
+		}
+	}
+	return len(bounds)
+}
+
+// sendBuffer returns the bytes in fd's send buffer not yet sent and the
+// buffer's size
+func sendBuffer(fd int) (queued, size int, err error) {
+	info, err := ReadTCPInfo(fd)
+	if err != nil {
+		return 0, 0, err
+	}
+	size, err = netfd.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(info.Unsent), size, nil
+}
+
+// formatBuckets renders a distribution as "<bound:count" pairs
+func formatBuckets[T time.Duration | uint32](bounds []T, counts []uint64) string {
+	s := ""
+	for i, n := range counts {
+		if i > 0 {
+			s += " "
+		}
+		if i < len(bounds) {
+			s += fmt.Sprintf("<%v:%d", bounds[i], n)
+		} else {
+			s += fmt.Sprintf(">=%v:%d", bounds[i-1], n)
+		}
+	}
+	return s
+}
@@ -0,0 +1,24 @@
+//go:build linux
+
+package observability
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ReadTCPInfo reads the kernel's TCP_INFO for connection fd
+func ReadTCPInfo(fd int) (TCPInfo, error) {
+	info, err := unix.GetsockoptTCPInfo(fd, unix.IPPROTO_TCP, unix.TCP_INFO)
+	if err != nil {
+		return TCPInfo{}, err
+	}
+	return TCPInfo{
+		RTT:         time.Duration(info.Rtt) * time.Microsecond,
+		RTTVar:      time.Duration(info.Rttvar) * time.Microsecond,
+		Retransmits: info.Total_retrans,
+		Cwnd:        info.Snd_cwnd,
+		Unsent:      info.Notsent_bytes,
+	}, nil
+}
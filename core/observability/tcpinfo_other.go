@@ -0,0 +1,10 @@
+//go:build !linux
+
+package observability
+
+import "errors"
+
+// ReadTCPInfo is only implemented on Linux
+func ReadTCPInfo(fd int) (TCPInfo, error) {
+	return TCPInfo{}, errors.ErrUnsupported
+}
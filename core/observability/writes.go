@@ -0,0 +1,194 @@
+package observability
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WriteMonitor tracks response write backpressure per connection and in
+// aggregate: bytes queued in user space before a write, writes stalled on
+// a full socket buffer, and how full the kernel send buffer was when they
+// stalled. Slow clients show up as long stalls and full send buffers.
+type WriteMonitor struct {
+	enabled atomic.Bool
+	conns   sync.Map // map[int]*ConnWriteStats
+
+	flushes   atomic.Uint64
+	queued    atomic.Uint64 // total bytes across flushes
+	maxQueued atomic.Uint64
+	stalls    atomic.Uint64
+	stallTime atomic.Uint64 // nanoseconds
+	maxStall  atomic.Uint64 // nanoseconds
+}
+
+// ConnWriteStats tracks write backpressure of one connection
+type ConnWriteStats struct {
+	FD          int
+	LastQueued  atomic.Uint64 // bytes in the last queued flush
+	MaxQueued   atomic.Uint64
+	Stalls      atomic.Uint64
+	StallTime   atomic.Uint64 // nanoseconds
+	KernelQueue atomic.Uint64 // unsent bytes in the send buffer at the last stall
+	SendBuffer  atomic.Uint64 // send buffer size (SO_SNDBUF)
+}
+
+// NewWriteMonitor creates a write monitor
+func NewWriteMonitor() *WriteMonitor {
+	wm := &WriteMonitor{}
+	wm.enabled.Store(true)
+	return wm
+}
+
+// conn returns the stats of fd, creating them on first use
+func (wm *WriteMonitor) conn(fd int) *ConnWriteStats {
+	if val, ok := wm.conns.Load(fd); ok {
+		return val.(*ConnWriteStats)
+	}
+	val, _ := wm.conns.LoadOrStore(fd, &ConnWriteStats{FD: fd})
+	return val.(*ConnWriteStats)
+}
+
+// RecordQueue records n bytes of responses queued for fd and sent with
+// one write (pipelined responses)
+func (wm *WriteMonitor) RecordQueue(fd, n int) {
+	if !wm.enabled.Load() || n <= 0 {
+		return
+	}
+	wm.flushes.Add(1)
+	wm.queued.Add(uint64(n))
+	storeMax(&wm.maxQueued, uint64(n))
+
+	cs := wm.conn(fd)
+	cs.LastQueued.Store(uint64(n))
+	storeMax(&cs.MaxQueued, uint64(n))
+}
+
+// RecordStall records a write to fd that was blocked for d on a full
+// socket buffer, sampling the kernel send buffer
+func (wm *WriteMonitor) RecordStall(fd int, d time.Duration) {
+	if !wm.enabled.Load() {
+		return
+	}
+	ns := uint64(d.Nanoseconds())
+	wm.stalls.Add(1)
+	wm.stallTime.Add(ns)
+	storeMax(&wm.maxStall, ns)
+
+	cs := wm.conn(fd)
+	cs.Stalls.Add(1)
+	cs.StallTime.Add(ns)
+	if queued, size, err := sendBuffer(fd); err == nil {
+		cs.KernelQueue.Store(uint64(queued))
+		cs.SendBuffer.Store(uint64(size))
+	}
+}
+
+// Forget drops the stats of a closed connection
+func (wm *WriteMonitor) Forget(fd int) {
+	wm.conns.Delete(fd)
+}
+
+// WriteSnapshot is a point-in-time view of write backpressure
+type WriteSnapshot struct {
+	Flushes   uint64        // queued flushes
+	AvgQueued uint64        // average bytes per queued flush
+	MaxQueued uint64        // largest queued flush
+	Stalls    uint64        // writes blocked on a full socket buffer
+	AvgStall  time.Duration // average time blocked
+	MaxStall  time.Duration
+	Conns     []ConnWriteSnapshot // open connections that stalled, worst first
+}
+
+// ConnWriteSnapshot is the write backpressure of one connection
+type ConnWriteSnapshot struct {
+	FD          int
+	LastQueued  uint64
+	MaxQueued   uint64
+	Stalls      uint64
+	StallTime   time.Duration
+	KernelQueue uint64
+	SendBuffer  uint64
+}
+
+// SendBufferFill returns how full the send buffer was at the last stall
+// (0-1, 0 if unknown)
+func (s ConnWriteSnapshot) SendBufferFill() float64 {
+	if s.SendBuffer == 0 {
+		return 0
+	}
+	return float64(s.KernelQueue) / float64(s.SendBuffer)
+}
+
+// Snapshot returns the aggregate stats and the open connections that
+// stalled, longest total stall first
+func (wm *WriteMonitor) Snapshot() WriteSnapshot {
+	snap := WriteSnapshot{
+		Flushes:   wm.flushes.Load(),
+		MaxQueued: wm.maxQueued.Load(),
+		Stalls:    wm.stalls.Load(),
+		MaxStall:  time.Duration(wm.maxStall.Load()),
+	}
+	if snap.Flushes > 0 {
+		snap.AvgQueued = wm.queued.Load() / snap.Flushes
+	}
+	if snap.Stalls > 0 {
+		snap.AvgStall = time.Duration(wm.stallTime.Load() / snap.Stalls)
+	}
+
+	wm.conns.Range(func(_, value any) bool {
+		cs := value.(*ConnWriteStats)
+		if cs.Stalls.Load() == 0 {
+			return true
+		}
+		snap.Conns = append(snap.Conns, ConnWriteSnapshot{
+			FD:          cs.FD,
+			LastQueued:  cs.LastQueued.Load(),
+			MaxQueued:   cs.MaxQueued.Load(),
+			Stalls:      cs.Stalls.Load(),
+			StallTime:   time.Duration(cs.StallTime.Load()),
+			KernelQueue: cs.KernelQueue.Load(),
+			SendBuffer:  cs.SendBuffer.Load(),
+		})
+		return true
+	})
+	slices.SortFunc(snap.Conns, func(a, b ConnWriteSnapshot) int {
+		return cmp.Compare(b.StallTime, a.StallTime)
+	})
+
+	return snap
+}
+
+// Report generates a human-readable write backpressure report
+func (wm *WriteMonitor) Report() string {
+	snap := wm.Snapshot()
+
+	report := "✍️  Write Backpressure:\n"
+	report += fmt.Sprintf("  • Queued flushes: %d, avg=%d B, max=%d B\n",
+		snap.Flushes, snap.AvgQueued, snap.MaxQueued)
+	report += fmt.Sprintf("  • Stalls: %d, avg=%v, max=%v\n",
+		snap.Stalls, snap.AvgStall, snap.MaxStall)
+
+	for i, c := range snap.Conns {
+		if i == 5 {
+			report += fmt.Sprintf("    ... %d more slow connections\n", len(snap.Conns)-i)
+			break
+		}
+		report += fmt.Sprintf("    fd=%d: %d stalls, %v blocked, send buffer %.0f%% full\n",
+			c.FD, c.Stalls, c.StallTime, c.SendBufferFill()*100)
+	}
+	return report
+}
+
+// storeMax raises m to v if v is larger
+func storeMax(m *atomic.Uint64, v uint64) {
+	for {
+		cur := m.Load()
+		if v <= cur || m.CompareAndSwap(cur, v) {
+			return
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package observability
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMonitor(t *testing.T) {
+	wm := NewWriteMonitor()
+
+	wm.RecordQueue(7, 300)
+	wm.RecordQueue(7, 100)
+	wm.RecordStall(7, 20*time.Millisecond)
+	wm.RecordStall(8, 50*time.Millisecond)
+	wm.RecordQueue(9, 10)
+
+	snap := wm.Snapshot()
+	if snap.Flushes != 3 || snap.AvgQueued != 136 || snap.MaxQueued != 300 {
+		t.Errorf("Unexpected queue stats: %+v", snap)
+	}
+	if snap.Stalls != 2 || snap.AvgStall != 35*time.Millisecond || snap.MaxStall != 50*time.Millisecond {
+		t.Errorf("Unexpected stall stats: %+v", snap)
+	}
+
+	// Only connections that stalled are listed, worst first
+	if len(snap.Conns) != 2 || snap.Conns[0].FD != 8 || snap.Conns[1].MaxQueued != 300 {
+		t.Errorf("Unexpected connections: %+v", snap.Conns)
+	}
+
+	wm.Forget(8)
+	if conns := wm.Snapshot().Conns; len(conns) != 1 || conns[0].FD != 7 {
+		t.Errorf("Expected fd 8 to be forgotten, got %+v", conns)
+	}
+	if !strings.Contains(wm.Report(), "fd=7: 1 stalls") {
+		t.Errorf("Report misses the slow connection:\n%s", wm.Report())
+	}
+}
@@ -8,10 +8,14 @@ import (
 	"unsafe"
 )
 
+// Backend names the I/O multiplexer of this platform
+const Backend = "epoll"
+
 // EpollPoller is an epoll-based I/O multiplexer
 type EpollPoller struct {
 	epfd   int
 	events []syscall.EpollEvent
+	mask   uint32
 }
 
 // NewPoller creates a new Poller (Linux)
@@ -24,16 +28,32 @@ func NewPoller() (Poller, error) {
 	return &EpollPoller{
 		epfd:   epfd,
 		events: make([]syscall.EpollEvent, 1024),
+		// EPOLLIN: Read events
+		// EPOLLRDHUP (0x2000): Detect peer shutdown
+		// Use level-triggered (default, no EPOLLET) for reliability
+		mask: uint32(syscall.EPOLLIN) | uint32(0x2000),
+	}, nil
+}
+
+// NewHangupPoller creates a Poller that reports only peer shutdown
+// (EPOLLRDHUP, edge-triggered), so unread request data does not wake it
+func NewHangupPoller() (Poller, error) {
+	epfd, err := syscall.EpollCreate1(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EpollPoller{
+		epfd:   epfd,
+		events: make([]syscall.EpollEvent, 256),
+		mask:   uint32(0x2000) | 1<<31, // EPOLLRDHUP | EPOLLET
 	}, nil
 }
 
 // Add adds a file descriptor to the watch list
 func (p *EpollPoller) Add(fd int) error {
 	ev := syscall.EpollEvent{
-		// EPOLLIN: Read events
-		// EPOLLRDHUP (0x2000): Detect peer shutdown
-		// Use level-triggered (default, no EPOLLET) for reliability
-		Events: uint32(syscall.EPOLLIN) | uint32(0x2000),
+		Events: p.mask,
 		Fd:     int32(fd),
 	}
 
@@ -45,6 +65,21 @@ func (p *EpollPoller) Remove(fd int) error {
 	return syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_DEL, fd, nil)
 }
 
+// WatchWrite switches fd between read and write readiness. Only
+// EPOLLOUT is watched while enabled: pipelined requests or a peer shutdown
+// would otherwise wake Wait until the response is sent.
+func (p *EpollPoller) WatchWrite(fd int, enabled bool) error {
+	ev := syscall.EpollEvent{
+		Events: p.mask,
+		Fd:     int32(fd),
+	}
+	if enabled {
+		ev.Events = uint32(syscall.EPOLLOUT)
+	}
+
+	return syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_MOD, fd, &ev)
+}
+
 // Wait waits for I/O events
 func (p *EpollPoller) Wait(timeout int) ([]int, error) {
 	n, err := syscall.EpollWait(p.epfd, p.events, timeout)
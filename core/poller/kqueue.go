@@ -8,10 +8,14 @@ import (
 	"unsafe"
 )
 
+// Backend names the I/O multiplexer of this platform
+const Backend = "kqueue"
+
 // KqueuePoller is a kqueue-based I/O multiplexer
 type KqueuePoller struct {
 	kqfd   int
 	events []syscall.Kevent_t
+	flags  uint16
 }
 
 // NewPoller creates a new Poller (macOS)
@@ -24,6 +28,25 @@ func NewPoller() (Poller, error) {
 	return &KqueuePoller{
 		kqfd:   kqfd,
 		events: make([]syscall.Kevent_t, 1024),
+		// Use level-triggered (default) for reliability
+		// EV_CLEAR (edge-triggered) can miss events if not handled carefully
+		flags: syscall.EV_ADD | syscall.EV_ENABLE,
+	}, nil
+}
+
+// NewHangupPoller creates a Poller for detecting peer shutdown. kqueue has
+// no hangup-only filter, so reads are edge-triggered (EV_CLEAR) and callers
+// confirm EOF themselves.
+func NewHangupPoller() (Poller, error) {
+	kqfd, err := syscall.Kqueue()
+	if err != nil {
+		return nil, err
+	}
+
+	return &KqueuePoller{
+		kqfd:   kqfd,
+		events: make([]syscall.Kevent_t, 256),
+		flags:  syscall.EV_ADD | syscall.EV_ENABLE | syscall.EV_CLEAR,
 	}, nil
 }
 
@@ -32,9 +55,7 @@ func (p *KqueuePoller) Add(fd int) error {
 	ev := syscall.Kevent_t{
 		Ident:  uint64(fd),
 		Filter: syscall.EVFILT_READ,
-		// Use level-triggered (default) for reliability
-		// EV_CLEAR (edge-triggered) can miss events if not handled carefully
-		Flags: syscall.EV_ADD | syscall.EV_ENABLE,
+		Flags:  p.flags,
 	}
 
 	_, err := syscall.Kevent(p.kqfd, []syscall.Kevent_t{ev}, nil, nil)
@@ -53,6 +74,28 @@ func (p *KqueuePoller) Remove(fd int) error {
 	return err
 }
 
+// WatchWrite switches fd between read and write readiness: the read
+// filter is disabled while the write filter is watched
+func (p *KqueuePoller) WatchWrite(fd int, enabled bool) error {
+	read := syscall.Kevent_t{
+		Ident:  uint64(fd),
+		Filter: syscall.EVFILT_READ,
+		Flags:  syscall.EV_DISABLE,
+	}
+	write := syscall.Kevent_t{
+		Ident:  uint64(fd),
+		Filter: syscall.EVFILT_WRITE,
+		Flags:  syscall.EV_ADD | syscall.EV_ENABLE,
+	}
+	if !enabled {
+		read.Flags = p.flags
+		write.Flags = syscall.EV_DELETE
+	}
+
+	_, err := syscall.Kevent(p.kqfd, []syscall.Kevent_t{read, write}, nil, nil)
+	return err
+}
+
 // Wait waits for I/O events
 func (p *KqueuePoller) Wait(timeout int) ([]int, error) {
 	var ts *syscall.Timespec
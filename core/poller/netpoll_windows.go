@@ -0,0 +1,159 @@
+//go:build windows
+
+package poller
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// Backend names the I/O multiplexer of this platform (the Go runtime's
+// network poller)
+const Backend = "netpoll"
+
+// errClosed is returned by Wait once the poller is closed
+var errClosed = errors.New("poller closed")
+
+// NetPoller polls the descriptors emulated by netfd (Windows). It is level
+// triggered like epoll: Wait reports every watched descriptor that is
+// ready, checking them all after each wakeup.
+type NetPoller struct {
+	mu     sync.Mutex
+	fds    map[int]bool // fd -> already reported (hangup pollers only)
+	wake   chan struct{}
+	closed bool
+	// Descriptors watched for writing: netfd writes block, so they are
+	// always writable
+	writable map[int]bool
+
+	// ready reports whether fd should be returned by Wait
+	ready  func(fd int) bool
+	hangup bool
+}
+
+// NewPoller creates a new Poller (Windows, over netfd)
+func NewPoller() (Poller, error) {
+	return &NetPoller{
+		fds:   make(map[int]bool),
+		wake:  make(chan struct{}, 1),
+		ready: netfd.Readable,
+	}, nil
+}
+
+// NewHangupPoller creates a Poller for detecting peer shutdown. Each
+// hangup is reported once per Add, and callers confirm EOF themselves.
+func NewHangupPoller() (Poller, error) {
+	return &NetPoller{
+		fds:    make(map[int]bool),
+		wake:   make(chan struct{}, 1),
+		ready:  netfd.Hungup,
+		hangup: true,
+	}, nil
+}
+
+// Add adds a file descriptor to the watch list
+func (p *NetPoller) Add(fd int) error {
+	if err := netfd.Watch(fd, p.wake); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.fds[fd] = false
+	p.mu.Unlock()
+
+	// It may be ready already
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Remove removes a file descriptor from the watch list
+func (p *NetPoller) Remove(fd int) error {
+	netfd.Unwatch(fd, p.wake)
+	p.mu.Lock()
+	delete(p.fds, fd)
+	delete(p.writable, fd)
+	p.mu.Unlock()
+	return nil
+}
+
+// WatchWrite switches fd between read and write readiness
+func (p *NetPoller) WatchWrite(fd int, enabled bool) error {
+	p.mu.Lock()
+	if enabled {
+		if p.writable == nil {
+			p.writable = make(map[int]bool)
+		}
+		p.writable[fd] = true
+	} else {
+		delete(p.writable, fd)
+	}
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Wait waits up to timeout milliseconds for ready descriptors
+func (p *NetPoller) Wait(timeout int) ([]int, error) {
+	if fds, err := p.collect(); len(fds) > 0 || err != nil {
+		return fds, err
+	}
+
+	timer := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-p.wake:
+	case <-timer.C:
+	}
+	return p.collect()
+}
+
+// collect returns the watched descriptors that are ready
+func (p *NetPoller) collect() ([]int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, errClosed
+	}
+
+	var fds []int
+	for fd, reported := range p.fds {
+		if p.writable[fd] {
+			fds = append(fds, fd)
+			continue
+		}
+		if reported || !p.ready(fd) {
+			continue
+		}
+		fds = append(fds, fd)
+		if p.hangup {
+			p.fds[fd] = true
+		}
+	}
+	return fds, nil
+}
+
+// Close closes the poller
+func (p *NetPoller) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// SetNonblock sets non-blocking mode
+func SetNonblock(fd int) error {
+	return netfd.SetNonblock(fd)
+}
@@ -4,6 +4,9 @@ package poller
 type Poller interface {
 	Add(fd int) error
 	Remove(fd int) error
+	// WatchWrite switches fd between read and write readiness: while
+	// enabled, Wait reports it once writable instead of readable
+	WatchWrite(fd int, enabled bool) error
 	Wait(timeout int) ([]int, error)
 	Close() error
 }
@@ -41,6 +41,11 @@ func NewBytePoolWithSizes(sizes []int) *BytePool {
 	return bp
 }
 
+// Sizes returns the buffer sizes of the tiers
+func (bp *BytePool) Sizes() []int {
+	return append([]int(nil), bp.sizes...)
+}
+
 // Get returns a byte slice of at least the requested size
 func (bp *BytePool) Get(size int) []byte {
 	// Find the appropriate pool
@@ -30,6 +30,11 @@ func NewConnectionPool(capacity int, newFunc func() any) *ConnectionPool {
 	return cp
 }
 
+// Capacity returns the capacity the pool was created with
+func (cp *ConnectionPool) Capacity() int {
+	return cp.capacity
+}
+
 // Get retrieves a connection from the pool
 func (cp *ConnectionPool) Get() any {
 	cp.gets.Add(1)
@@ -42,7 +42,7 @@ type worker struct {
 // NewWorkerPool creates a new work-stealing worker pool
 func NewWorkerPool(numWorkers int) *WorkerPool {
 	if numWorkers <= 0 {
-		numWorkers = runtime.NumCPU()
+		numWorkers = runtime.GOMAXPROCS(0)
 	}
 
 	pool := &WorkerPool{
@@ -210,7 +210,7 @@ var (
 // GetGlobalPool returns the global worker pool
 func GetGlobalPool() *WorkerPool {
 	globalPoolOnce.Do(func() {
-		globalPool = NewWorkerPool(runtime.NumCPU())
+		globalPool = NewWorkerPool(runtime.GOMAXPROCS(0))
 	})
 	return globalPool
 }
@@ -0,0 +1,322 @@
+// Package queue is a bounded in-process queue for handing work from many
+// goroutines to one consumer: a lock-free ring buffer drained in batches,
+// with drop-on-full semantics and an optional disk overflow file that
+// takes items the ring has no room for.
+package queue
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrFull is returned by Push when the ring (and the overflow file,
+	// if any) has no room; the item is dropped
+	ErrFull = errors.New("queue: full")
+
+	// ErrClosed is returned by Push after Close
+	ErrClosed = errors.New("queue: closed")
+)
+
+// Config configures a Queue
+type Config[T any] struct {
+	// Capacity is the ring size, rounded up to a power of two.
+	// Default: 1024.
+	Capacity int
+
+	// BatchSize is the most items handed to the consumer at once.
+	// Default: 64.
+	BatchSize int
+
+	// Overflow spills items to disk while the ring is full (optional)
+	Overflow *Overflow[T]
+}
+
+// Overflow configures the disk overflow of a Queue. Items are appended to
+// Path while the ring is full and read back, in order, once it drains.
+// Items left in the file when the process stops are delivered by the next
+// Queue opened on it.
+type Overflow[T any] struct {
+	Path string
+
+	// Encode and Decode convert items to and from their stored form
+	Encode func(T) ([]byte, error)
+	Decode func([]byte) (T, error)
+
+	// MaxBytes caps the file; Push drops items beyond it. Default: 1GB.
+	MaxBytes int64
+
+	// Sync fsyncs every spilled item, so they survive a crash and not only
+	// a clean shutdown. It makes spilling much slower.
+	Sync bool
+}
+
+// Stats are queue counters
+type Stats struct {
+	Pushed    uint64 // Items accepted by Push
+	Delivered uint64 // Items handed to the consumer
+	Dropped   uint64 // Items refused because the queue was full
+	Spilled   uint64 // Items written to the overflow file
+	Len       int    // Items waiting in the ring
+	Spill     int64  // Bytes waiting in the overflow file
+}
+
+// slot is a ring entry; seq tells producers and the consumer whose turn
+// it is
+type slot[T any] struct {
+	seq atomic.Uint64
+	val T
+}
+
+// Queue is a bounded multi-producer, single-consumer queue
+type Queue[T any] struct {
+	slots []slot[T]
+	mask  uint64
+	batch int
+
+	_    [64]byte
+	tail atomic.Uint64 // next position to push
+	_    [56]byte
+	head atomic.Uint64 // next position to pop (written by the consumer only)
+	_    [56]byte
+
+	// sleeping is set while the consumer waits on wake
+	sleeping atomic.Bool
+	wake     chan struct{}
+
+	// spilling is set while the overflow file holds items; pushes then go
+	// to the file so order is kept
+	spilling atomic.Bool
+	spillMu  sync.Mutex
+	spill    *spillFile[T]
+
+	closed  atomic.Bool
+	running atomic.Bool
+	done    chan struct{}
+
+	stats struct {
+		pushed, delivered, dropped, spilled atomic.Uint64
+	}
+}
+
+// New creates a Queue, opening the overflow file if one is configured
+func New[T any](cfg Config[T]) (*Queue[T], error) {
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = 1024
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 64
+	}
+	size := 1
+	for size < cfg.Capacity {
+		size <<= 1
+	}
+
+	q := &Queue[T]{
+		slots: make([]slot[T], size),
+		mask:  uint64(size - 1),
+		batch: cfg.BatchSize,
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	for i := range q.slots {
+		q.slots[i].seq.Store(uint64(i))
+	}
+
+	if cfg.Overflow != nil {
+		spill, err := openSpill(cfg.Overflow)
+		if err != nil {
+			return nil, err
+		}
+		q.spill = spill
+		q.spilling.Store(spill.pending() > 0)
+	}
+	return q, nil
+}
+
+// Push adds v without blocking. It returns ErrFull if there is no room,
+// or ErrClosed after Close. Any number of goroutines may push.
+func (q *Queue[T]) Push(v T) error {
+	if q.closed.Load() {
+		return ErrClosed
+	}
+
+	if !q.spilling.Load() && q.enqueue(v) {
+		q.stats.pushed.Add(1)
+		q.signal()
+		return nil
+	}
+	if q.spill == nil {
+		q.stats.dropped.Add(1)
+		return ErrFull
+	}
+
+	q.spillMu.Lock()
+	err := q.spill.write(v)
+	if err == nil {
+		q.spilling.Store(true)
+	}
+	q.spillMu.Unlock()
+	if err != nil {
+		q.stats.dropped.Add(1)
+		return err
+	}
+	q.stats.pushed.Add(1)
+	q.stats.spilled.Add(1)
+	q.signal()
+	return nil
+}
+
+// Run hands queued items to fn, up to BatchSize at a time, until Close.
+// It is the queue's single consumer: call it once, usually in its own
+// goroutine. The batch slice is reused after fn returns. Once closed, Run
+// delivers what is left in the ring and returns; items in the overflow
+// file stay there for the next Queue opened on it.
+func (q *Queue[T]) Run(fn func(batch []T)) {
+	if q.running.Swap(true) {
+		panic("queue: Run called twice")
+	}
+	defer close(q.done)
+
+	batch := make([]T, 0, q.batch)
+	for {
+		batch = q.dequeue(batch[:0])
+		if len(batch) == 0 && q.spilling.Load() && !q.closed.Load() {
+			batch = q.unspill(batch)
+		}
+		if len(batch) > 0 {
+			q.stats.delivered.Add(uint64(len(batch)))
+			fn(batch)
+			clear(batch)
+			continue
+		}
+
+		if q.Len() > 0 {
+			// A producer claimed a slot and is about to fill it
+			runtime.Gosched()
+			continue
+		}
+		if q.closed.Load() {
+			return
+		}
+		q.sleeping.Store(true)
+		if q.Len() == 0 && !q.spilling.Load() && !q.closed.Load() {
+			<-q.wake
+		}
+		q.sleeping.Store(false)
+	}
+}
+
+// Close stops accepting items and waits until Run has delivered what is
+// in the ring and returned, or ctx ends. It closes the overflow file.
+func (q *Queue[T]) Close(ctx context.Context) error {
+	if q.closed.Swap(true) {
+		return nil
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	var err error
+	if q.running.Load() {
+		select {
+		case <-q.done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	if q.spill != nil {
+		q.spillMu.Lock()
+		q.spill.close()
+		q.spillMu.Unlock()
+	}
+	return err
+}
+
+// Len returns the number of items waiting in the ring
+func (q *Queue[T]) Len() int {
+	return int(q.tail.Load() - q.head.Load())
+}
+
+// Stats returns the queue counters
+func (q *Queue[T]) Stats() Stats {
+	s := Stats{
+		Pushed:    q.stats.pushed.Load(),
+		Delivered: q.stats.delivered.Load(),
+		Dropped:   q.stats.dropped.Load(),
+		Spilled:   q.stats.spilled.Load(),
+		Len:       q.Len(),
+	}
+	if q.spill != nil {
+		q.spillMu.Lock()
+		s.Spill = q.spill.pending()
+		q.spillMu.Unlock()
+	}
+	return s
+}
+
+// enqueue claims the slot at the tail and publishes v in it. It reports
+// false if the ring is full.
+func (q *Queue[T]) enqueue(v T) bool {
+	for {
+		pos := q.tail.Load()
+		s := &q.slots[pos&q.mask]
+		switch seq := s.seq.Load(); {
+		case seq == pos:
+			if q.tail.CompareAndSwap(pos, pos+1) {
+				s.val = v
+				s.seq.Store(pos + 1)
+				return true
+			}
+		case seq < pos:
+			// The consumer has not freed this slot yet
+			return false
+		}
+		// Another producer took the slot; retry
+	}
+}
+
+// dequeue appends published items to batch, up to its capacity
+func (q *Queue[T]) dequeue(batch []T) []T {
+	var zero T
+	pos := q.head.Load()
+	for len(batch) < cap(batch) {
+		s := &q.slots[pos&q.mask]
+		if s.seq.Load() != pos+1 {
+			break
+		}
+		batch = append(batch, s.val)
+		s.val = zero
+		s.seq.Store(pos + q.mask + 1)
+		pos++
+	}
+	q.head.Store(pos)
+	return batch
+}
+
+// unspill reads a batch back from the overflow file. The ring is empty
+// and pushes go to the file while it holds items, so order is kept.
+func (q *Queue[T]) unspill(batch []T) []T {
+	q.spillMu.Lock()
+	defer q.spillMu.Unlock()
+	batch = q.spill.read(batch)
+	if q.spill.pending() == 0 {
+		q.spill.reset()
+		q.spilling.Store(false)
+	}
+	return batch
+}
+
+// signal wakes the consumer if it is waiting
+func (q *Queue[T]) signal() {
+	if q.sleeping.Load() {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+}
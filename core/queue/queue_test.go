@@ -0,0 +1,128 @@
+package queue
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestQueueDeliversInOrder(t *testing.T) {
+	q, err := New(Config[[2]int]{Capacity: 64, BatchSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const producers, per = 4, 5000
+	next := make([]int, producers)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(func(batch [][2]int) {
+			if len(batch) > 16 {
+				t.Errorf("Batch of %d exceeds BatchSize", len(batch))
+			}
+			for _, v := range batch {
+				if v[1] != next[v[0]] {
+					t.Errorf("Producer %d: got item %d, want %d", v[0], v[1], next[v[0]])
+				}
+				next[v[0]] = v[1] + 1
+			}
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < per; {
+				// Retry dropped items so every one is delivered
+				if q.Push([2]int{p, i}) == nil {
+					i++
+				} else {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if st := q.Stats(); st.Pushed != producers*per || st.Delivered != producers*per {
+		t.Errorf("Unexpected stats %+v", st)
+	}
+	if err := q.Push([2]int{}); err != ErrClosed {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestQueueDropsWhenFull(t *testing.T) {
+	q, _ := New(Config[int]{Capacity: 4})
+	for i := 0; i < 4; i++ {
+		if err := q.Push(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Push(4); err != ErrFull {
+		t.Fatalf("Expected ErrFull, got %v", err)
+	}
+	if st := q.Stats(); st.Dropped != 1 || st.Len != 4 {
+		t.Errorf("Unexpected stats %+v", st)
+	}
+}
+
+func TestQueueOverflow(t *testing.T) {
+	overflow := &Overflow[string]{
+		Path:   filepath.Join(t.TempDir(), "events.spill"),
+		Encode: func(s string) ([]byte, error) { return []byte(s), nil },
+		Decode: func(b []byte) (string, error) { return string(b), nil },
+	}
+
+	// Nothing consumes: items past the ring go to the file and stay
+	// there across Close
+	q, err := New(Config[string]{Capacity: 2, Overflow: overflow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := q.Push("item-" + strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := q.Stats(); st.Spilled != 8 || st.Spill == 0 {
+		t.Fatalf("Expected 8 spilled items, got %+v", st)
+	}
+	q.Close(context.Background())
+
+	q, err = New(Config[string]{Capacity: 2, Overflow: overflow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(func(batch []string) {
+			got = append(got, batch...)
+			if len(got) == 8 {
+				go q.Close(context.Background())
+			}
+		})
+	}()
+	<-done
+
+	for i, s := range got {
+		if s != "item-"+strconv.Itoa(i+2) {
+			t.Fatalf("Expected the spilled items in order, got %v", got)
+		}
+	}
+	if len(got) != 8 || q.Stats().Spill != 0 {
+		t.Errorf("Expected 8 recovered items and an empty file, got %d", len(got))
+	}
+}
@@ -0,0 +1,175 @@
+package queue
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"os"
+)
+
+// The overflow file starts with the offset of the next unread record,
+// followed by records: a 4-byte big-endian length and the encoded item
+const (
+	spillHeader   = 8
+	spillReadSize = 64 * 1024
+)
+
+// spillFile is the overflow file of a Queue. It is used under the
+// queue's spillMu.
+type spillFile[T any] struct {
+	cfg  *Overflow[T]
+	f    *os.File
+	r, w int64 // offsets of the next record to read and to write
+	buf  []byte
+}
+
+// openSpill opens or creates the overflow file, keeping the unread items
+// of a previous run and dropping a record torn by a crash
+func openSpill[T any](cfg *Overflow[T]) (*spillFile[T], error) {
+	if cfg.Path == "" || cfg.Encode == nil || cfg.Decode == nil {
+		return nil, errors.New("queue: overflow needs a Path, Encode and Decode")
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 1 << 30
+	}
+
+	f, err := os.OpenFile(cfg.Path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	s := &spillFile[T]{cfg: cfg, f: f}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var head [spillHeader]byte
+	if info.Size() >= spillHeader {
+		if _, err := f.ReadAt(head[:], 0); err != nil {
+			f.Close()
+			return nil, err
+		}
+		s.r = int64(binary.BigEndian.Uint64(head[:]))
+	}
+	if s.r < spillHeader || s.r > info.Size() {
+		if err := s.reset(); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return s, nil
+	}
+
+	// Find the end of the last whole record
+	s.w = s.r
+	for s.w+4 <= info.Size() {
+		var size [4]byte
+		if _, err := f.ReadAt(size[:], s.w); err != nil {
+			break
+		}
+		end := s.w + 4 + int64(binary.BigEndian.Uint32(size[:]))
+		if end > info.Size() {
+			break
+		}
+		s.w = end
+	}
+	if s.w < info.Size() {
+		f.Truncate(s.w)
+	}
+	return s, nil
+}
+
+// write appends v
+func (s *spillFile[T]) write(v T) error {
+	data, err := s.cfg.Encode(v)
+	if err != nil {
+		return err
+	}
+	if s.w+4+int64(len(data)) > s.cfg.MaxBytes {
+		return ErrFull
+	}
+
+	rec := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(rec, uint32(len(data)))
+	copy(rec[4:], data)
+	if _, err := s.f.WriteAt(rec, s.w); err != nil {
+		return err
+	}
+	if s.cfg.Sync {
+		if err := s.f.Sync(); err != nil {
+			return err
+		}
+	}
+	s.w += int64(len(rec))
+	return nil
+}
+
+// read appends unread items to batch, up to its capacity, and records
+// how far it got. Items that fail to decode are skipped.
+func (s *spillFile[T]) read(batch []T) []T {
+	if s.r >= s.w {
+		return batch
+	}
+	n := min(s.w-s.r, int64(max(spillReadSize, cap(s.buf))))
+	if cap(s.buf) < int(n) {
+		s.buf = make([]byte, n)
+	}
+	buf := s.buf[:n]
+	if _, err := s.f.ReadAt(buf, s.r); err != nil {
+		log.Printf("⚠️  queue: reading %s: %v", s.cfg.Path, err)
+		return batch
+	}
+
+	for len(batch) < cap(batch) && len(buf) >= 4 {
+		size := int(binary.BigEndian.Uint32(buf))
+		if 4+size > len(buf) {
+			if len(batch) == 0 {
+				// A record larger than a read: read it whole next time
+				s.buf = make([]byte, 4+size)
+			}
+			break
+		}
+		v, err := s.cfg.Decode(buf[4 : 4+size])
+		if err == nil {
+			batch = append(batch, v)
+		} else {
+			log.Printf("⚠️  queue: dropping undecodable item in %s: %v", s.cfg.Path, err)
+		}
+		buf = buf[4+size:]
+		s.r += int64(4 + size)
+	}
+	s.saveOffset()
+	return batch
+}
+
+// pending returns the number of unread bytes
+func (s *spillFile[T]) pending() int64 {
+	return s.w - s.r
+}
+
+// reset empties the file once everything was read
+func (s *spillFile[T]) reset() error {
+	if err := s.f.Truncate(spillHeader); err != nil {
+		return err
+	}
+	s.r, s.w = spillHeader, spillHeader
+	return s.saveOffset()
+}
+
+// saveOffset stores the read offset in the header
+func (s *spillFile[T]) saveOffset() error {
+	var head [spillHeader]byte
+	binary.BigEndian.PutUint64(head[:], uint64(s.r))
+	if _, err := s.f.WriteAt(head[:], 0); err != nil {
+		return err
+	}
+	if s.cfg.Sync {
+		return s.f.Sync()
+	}
+	return nil
+}
+
+func (s *spillFile[T]) close() error {
+	s.saveOffset()
+	return s.f.Close()
+}
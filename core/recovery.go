@@ -0,0 +1,60 @@
+package core
+
+import (
+	"log"
+	"runtime/debug"
+
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// PanicHandler is called after a handler panic has been recovered, with
+// the recovered value and the goroutine's stack trace
+type PanicHandler func(ctx *http.FDContext, recovered any, stack []byte)
+
+// SetPanicHandler sets a hook for recovered handler panics, e.g. to
+// report them to an error tracker. Panics are always logged.
+func (e *Engine) SetPanicHandler(h PanicHandler) {
+	e.panicHandler = h
+}
+
+// execute runs the request hooks, middleware chain and handler. A panic
+// is recovered and answered with 500, and the connection closes after
+// the response.
+func (e *Engine) execute(conn *Connection, ctx *http.FDContext) {
+	defer func() {
+		if r := recover(); r != nil {
+			e.recoverPanic(conn, ctx, r)
+			// Complete a handle the handler took before panicking; a
+			// later Respond gets ErrAsyncCompleted
+			if ctx.IsAsync() {
+				ctx.Async().Respond(func(http.Context) {})
+			}
+		}
+	}()
+
+	if len(e.hooks.request) > 0 {
+		e.runRequestHooks(e.hooks.request, ctx)
+	}
+
+	// Global middleware runs before routing; Abort skips dispatch
+	e.middleware.Execute(ctx, e.dispatchFn)
+}
+
+// recoverPanic reports a recovered panic and sends 500 unless the
+// handler already started its response
+func (e *Engine) recoverPanic(conn *Connection, ctx *http.FDContext, r any) {
+	stack := debug.Stack()
+	log.Printf("⚠️  panic serving %s %s: %v\n%s", ctx.Method(), ctx.Path(), r, stack)
+
+	if e.observatory != nil {
+		e.observatory.Monitor.RecordPanic(ctx.Method() + " " + ctx.Path())
+	}
+	if e.panicHandler != nil {
+		e.panicHandler(ctx, r, stack)
+	}
+
+	conn.closeAfter = true
+	if !ctx.Written() {
+		e.replyError(ctx, &PanicError{Value: r})
+	}
+}
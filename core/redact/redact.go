@@ -0,0 +1,187 @@
+package redact
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// DefaultMask replaces redacted values
+const DefaultMask = "[REDACTED]"
+
+// Config configures a Redactor
+type Config struct {
+	Headers []string // Header names to mask (case-insensitive)
+	Fields  []string // JSON keys masked at any depth (case-insensitive)
+	Paths   []string // Dotted JSON paths masked exactly, e.g. "user.card.number"
+	Mask    string   // Replacement value (default: DefaultMask)
+}
+
+// DefaultConfig returns a configuration covering common credentials
+func DefaultConfig() Config {
+	return Config{
+		Headers: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"},
+		Fields:  []string{"password", "passwd", "secret", "token", "access_token", "refresh_token", "api_key"},
+		Mask:    DefaultMask,
+	}
+}
+
+// Redactor masks sensitive headers and JSON fields before they reach
+// logs or error responses. A nil *Redactor passes values through unchanged.
+type Redactor struct {
+	headers map[string]struct{}
+	fields  map[string]struct{}
+	paths   map[string]struct{}
+	mask    string
+
+	// Matches key=value and "key": "value" pairs for configured fields
+	inline *regexp.Regexp
+}
+
+// New creates a redactor from cfg
+func New(cfg Config) *Redactor {
+	r := &Redactor{
+		headers: make(map[string]struct{}, len(cfg.Headers)),
+		fields:  make(map[string]struct{}, len(cfg.Fields)),
+		paths:   make(map[string]struct{}, len(cfg.Paths)),
+		mask:    cfg.Mask,
+	}
+	if r.mask == "" {
+		r.mask = DefaultMask
+	}
+
+	for _, h := range cfg.Headers {
+		r.headers[strings.ToLower(h)] = struct{}{}
+	}
+	for _, f := range cfg.Fields {
+		r.fields[strings.ToLower(f)] = struct{}{}
+	}
+	for _, p := range cfg.Paths {
+		r.paths[strings.ToLower(p)] = struct{}{}
+	}
+
+	if len(cfg.Fields) > 0 {
+		quoted := make([]string, len(cfg.Fields))
+		for i, f := range cfg.Fields {
+			quoted[i] = regexp.QuoteMeta(f)
+		}
+		// A quoted value runs to its closing quote (or the end of the
+		// text), an unquoted one to the next separator
+		r.inline = regexp.MustCompile(`(?i)("?(?:` + strings.Join(quoted, "|") + `)"?\s*[:=]\s*)("(?:[^"\\]|\\.)*"?|[^"&,\s}]+)`)
+	}
+
+	return r
+}
+
+// Header returns value, masked if name is a sensitive header
+func (r *Redactor) Header(name, value string) string {
+	if r == nil || value == "" {
+		return value
+	}
+	if _, ok := r.headers[strings.ToLower(name)]; ok {
+		return r.mask
+	}
+	return value
+}
+
+// Headers returns a copy of headers with sensitive values masked
+func (r *Redactor) Headers(headers map[string]string) map[string]string {
+	result := make(map[string]string, len(headers))
+	for k, v := range headers {
+		result[k] = r.Header(k, v)
+	}
+	return result
+}
+
+// JSON returns a copy of a JSON document with sensitive fields masked.
+// Input that is not valid JSON is masked inline with String.
+func (r *Redactor) JSON(data []byte) []byte {
+	if r == nil || len(data) == 0 {
+		return data
+	}
+
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return []byte(r.String(string(data)))
+	}
+
+	out, err := json.Marshal(r.walk("", doc))
+	if err != nil {
+		return []byte(r.mask)
+	}
+	return out
+}
+
+// Value returns a copy of a decoded JSON-like value with sensitive fields masked
+func (r *Redactor) Value(v any) any {
+	if r == nil {
+		return v
+	}
+	return r.walk("", v)
+}
+
+// String masks key=value and "key":"value" pairs for configured fields
+// inside free-form text such as panic messages and query strings
+func (r *Redactor) String(s string) string {
+	if r == nil || r.inline == nil {
+		return s
+	}
+	matches := r.inline.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m[3]]) // Up to the value
+		value := s[m[4]:m[5]]
+		if value[0] == '"' {
+			b.WriteByte('"')
+			b.WriteString(r.mask)
+			if len(value) > 1 && value[len(value)-1] == '"' {
+				b.WriteByte('"')
+			}
+		} else {
+			b.WriteString(r.mask)
+		}
+		last = m[5]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// walk masks fields in a decoded JSON value
+func (r *Redactor) walk(path string, v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, child := range val {
+			childPath := strings.ToLower(k)
+			if path != "" {
+				childPath = path + "." + childPath
+			}
+			if r.sensitive(k, childPath) {
+				out[k] = r.mask
+				continue
+			}
+			out[k] = r.walk(childPath, child)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, child := range val {
+			out[i] = r.walk(path, child)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func (r *Redactor) sensitive(key, path string) bool {
+	if _, ok := r.fields[strings.ToLower(key)]; ok {
+		return true
+	}
+	_, ok := r.paths[path]
+	return ok
+}
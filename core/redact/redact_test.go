@@ -0,0 +1,78 @@
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedactHeader(t *testing.T) {
+	r := New(DefaultConfig())
+
+	if got := r.Header("authorization", "Bearer abc"); got != DefaultMask {
+		t.Errorf("Expected masked Authorization, got %q", got)
+	}
+	if got := r.Header("Accept", "text/html"); got != "text/html" {
+		t.Errorf("Expected Accept untouched, got %q", got)
+	}
+
+	var nilRedactor *Redactor
+	if got := nilRedactor.Header("Authorization", "x"); got != "x" {
+		t.Errorf("Nil redactor should pass through, got %q", got)
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	r := New(Config{
+		Fields: []string{"password"},
+		Paths:  []string{"card.number"},
+	})
+
+	out := r.JSON([]byte(`{"user":"bob","Password":"hunter2","card":{"number":"4111","exp":"12/30"},"list":[{"password":"x"}]}`))
+
+	var doc map[string]any
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("Redacted output is not JSON: %v", err)
+	}
+	if doc["user"] != "bob" {
+		t.Errorf("Expected user untouched, got %v", doc["user"])
+	}
+	if doc["Password"] != DefaultMask {
+		t.Errorf("Expected password masked, got %v", doc["Password"])
+	}
+	card := doc["card"].(map[string]any)
+	if card["number"] != DefaultMask || card["exp"] != "12/30" {
+		t.Errorf("Expected only card.number masked, got %v", card)
+	}
+	item := doc["list"].([]any)[0].(map[string]any)
+	if item["password"] != DefaultMask {
+		t.Errorf("Expected nested password masked, got %v", item)
+	}
+}
+
+func TestRedactString(t *testing.T) {
+	r := New(DefaultConfig())
+
+	out := r.String(`login failed: password=hunter2&user=bob {"token": "abc123"}`)
+	if strings.Contains(out, "hunter2") || strings.Contains(out, "abc123") {
+		t.Errorf("Expected secrets masked, got %q", out)
+	}
+	if !strings.Contains(out, "user=bob") {
+		t.Errorf("Expected non-sensitive pairs kept, got %q", out)
+	}
+
+	tests := []struct {
+		in, want string
+	}{
+		{`{"password": "correct horse battery", "user": "bob"}`, `{"password": "[REDACTED]", "user": "bob"}`},
+		{`{"password":"say \"hi\" now","n":1}`, `{"password":"[REDACTED]","n":1}`},
+		{`password="open sesame" user=bob`, `password="[REDACTED]" user=bob`},
+		{`password: "cut off here`, `password: "[REDACTED]`},
+		{`password=hunter2, token=abc`, `password=[REDACTED], token=[REDACTED]`},
+	}
+	for _, tt := range tests {
+		if out := r.String(tt.in); out != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.in, out, tt.want)
+		}
+	}
+}
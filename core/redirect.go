@@ -0,0 +1,87 @@
+package core
+
+import (
+	"path"
+	"strings"
+
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// SetRedirectTrailingSlash redirects a request for an unknown path to the
+// route that differs only by a trailing slash: /users/ to /users and back
+// (off by default). GET and HEAD get 301, other methods 308, which keeps
+// the method and body.
+func (e *Engine) SetRedirectTrailingSlash(enabled bool) {
+	e.redirectTrailingSlash = enabled
+}
+
+// SetRedirectFixedPath redirects a request for an unknown path to its
+// cleaned form if a route matches it: repeated slashes collapsed and
+// . and .. elements resolved, so //users/../users goes to /users (off by
+// default). With SetRedirectTrailingSlash, the cleaned path may also
+// differ by a trailing slash.
+func (e *Engine) SetRedirectFixedPath(enabled bool) {
+	e.redirectFixedPath = enabled
+}
+
+// redirectPath redirects a request no route matches to the route it was
+// likely meant for, and reports whether it did
+func (e *Engine) redirectPath(ctx *http.FDContext, method, p string) bool {
+	if p == "" || p[0] != '/' {
+		return false
+	}
+	target := p
+	if e.redirectFixedPath {
+		target = cleanPath(p)
+		if target != p && e.hasRoute(method, target) {
+			e.redirectTo(ctx, method, target)
+			return true
+		}
+	}
+	if e.redirectTrailingSlash && target != "/" {
+		if strings.HasSuffix(target, "/") {
+			target = target[:len(target)-1]
+		} else {
+			target += "/"
+		}
+		if e.hasRoute(method, target) {
+			e.redirectTo(ctx, method, target)
+			return true
+		}
+	}
+	return false
+}
+
+// hasRoute reports whether a handler serves method and p
+func (e *Engine) hasRoute(method, p string) bool {
+	m := e.router.Lookup(method, p)
+	if m.Handler == nil && method == "HEAD" && m.Allow != "" {
+		m = e.router.Lookup("GET", p)
+	}
+	return m.Handler != nil
+}
+
+// redirectTo sends a permanent redirect to target, keeping the query
+func (e *Engine) redirectTo(ctx *http.FDContext, method, target string) {
+	// "//host", and "/\host" to browsers, would be read as another host
+	target = "/" + strings.TrimLeft(target, "/\\")
+	if q := ctx.Request().RawQuery; q != "" {
+		target += "?" + q
+	}
+	ctx.SetHeader("Location", target)
+	if method == "GET" || method == "HEAD" {
+		ctx.NoContent(301)
+	} else {
+		ctx.NoContent(308)
+	}
+}
+
+// cleanPath resolves . and .. elements and repeated slashes in p, keeping
+// a trailing slash
+func cleanPath(p string) string {
+	clean := path.Clean(p)
+	if strings.HasSuffix(p, "/") && clean != "/" {
+		clean += "/"
+	}
+	return clean
+}
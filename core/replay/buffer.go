@@ -0,0 +1,138 @@
+// Package replay keeps the latest messages published to an SSE Broker or
+// a WebSocket Hub, numbered in publishing order, so clients can resume
+// after a disconnect (SSE Last-Event-ID) and clients that cannot hold a
+// stream open can fetch them by long polling (see LongPoll).
+package replay
+
+import (
+	"strconv"
+	"sync"
+)
+
+// DefaultSize is the number of messages a buffer keeps by default
+const DefaultSize = 1024
+
+// Message is a published message
+type Message struct {
+	Seq   uint64 `json:"seq"`             // Position in publishing order, from 1
+	ID    string `json:"id,omitempty"`    // Publisher's ID (default: Seq)
+	Event string `json:"event,omitempty"` // Event type
+	Data  string `json:"data"`
+	Topic string `json:"topic,omitempty"` // Room, "" for every client
+}
+
+// Buffer is a ring of the latest messages
+type Buffer struct {
+	mu   sync.Mutex
+	ring []Message
+	head int    // Index of the oldest message
+	n    int    // Messages held
+	last uint64 // Seq of the newest message (0: none yet)
+
+	// changed is closed and replaced when a message is appended
+	changed chan struct{}
+}
+
+// NewBuffer creates a buffer keeping size messages (<= 0: DefaultSize)
+func NewBuffer(size int) *Buffer {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Buffer{ring: make([]Message, size), changed: make(chan struct{})}
+}
+
+// Append numbers m, stores it in place of the oldest message if the
+// buffer is full and returns it
+func (b *Buffer) Append(m Message) Message {
+	b.mu.Lock()
+	b.last++
+	m.Seq = b.last
+	if m.ID == "" {
+		m.ID = strconv.FormatUint(m.Seq, 10)
+	}
+	if b.n < len(b.ring) {
+		b.ring[(b.head+b.n)%len(b.ring)] = m
+		b.n++
+	} else {
+		b.ring[b.head] = m
+		b.head = (b.head + 1) % len(b.ring)
+	}
+	changed := b.changed
+	b.changed = make(chan struct{})
+	b.mu.Unlock()
+
+	close(changed)
+	return m
+}
+
+// Last returns the sequence number of the newest message (0: none)
+func (b *Buffer) Last() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last
+}
+
+// After returns up to max messages (<= 0: all) published after seq, for
+// clients of topics (nil: every message; otherwise those for every
+// client and for one of topics). missed reports that messages after seq
+// were dropped from the buffer before they could be returned.
+func (b *Buffer) After(seq uint64, topics []string, max int) (msgs []Message, missed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	oldest := b.last - uint64(b.n) + 1
+	if seq+1 < oldest {
+		missed = true
+		seq = oldest - 1
+	}
+	for i := int(seq + 1 - oldest); i < b.n; i++ {
+		m := b.ring[(b.head+i)%len(b.ring)]
+		if !matches(m, topics) {
+			continue
+		}
+		msgs = append(msgs, m)
+		if max > 0 && len(msgs) == max {
+			break
+		}
+	}
+	return msgs, missed
+}
+
+// Find returns the sequence number of the message with ID id, if the
+// buffer still holds it
+func (b *Buffer) Find(id string) (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := b.n - 1; i >= 0; i-- {
+		if m := &b.ring[(b.head+i)%len(b.ring)]; m.ID == id {
+			return m.Seq, true
+		}
+	}
+	return 0, false
+}
+
+// Wait returns a channel closed once a message newer than seq is
+// appended; it is closed already if there is one
+func (b *Buffer) Wait(seq uint64) <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.last > seq {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return b.changed
+}
+
+// matches reports whether m is for clients of topics
+func matches(m Message, topics []string) bool {
+	if topics == nil || m.Topic == "" {
+		return true
+	}
+	for _, t := range topics {
+		if t == m.Topic {
+			return true
+		}
+	}
+	return false
+}
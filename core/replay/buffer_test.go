@@ -0,0 +1,61 @@
+package replay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBufferAfter(t *testing.T) {
+	b := NewBuffer(4)
+	for i, topic := range []string{"", "a", "b", "", "a", "b"} {
+		m := b.Append(Message{Data: string(rune('0' + i)), Topic: topic})
+		if m.Seq != uint64(i+1) || m.ID != string(rune('1'+i)) {
+			t.Fatalf("Message %d numbered %d, ID %q", i, m.Seq, m.ID)
+		}
+	}
+
+	// 1 and 2 were dropped
+	msgs, missed := b.After(0, nil, 0)
+	if !missed || len(msgs) != 4 || msgs[0].Seq != 3 {
+		t.Fatalf("Expected 3..6 and missed, got %+v, %v", msgs, missed)
+	}
+	msgs, missed = b.After(3, []string{"a"}, 0)
+	if missed || len(msgs) != 2 || msgs[0].Seq != 4 || msgs[1].Seq != 5 {
+		t.Errorf("Expected 4 (everyone) and 5 (a), got %+v", msgs)
+	}
+	if msgs, _ = b.After(2, nil, 2); len(msgs) != 2 || msgs[1].Seq != 4 {
+		t.Errorf("Expected 3 and 4, got %+v", msgs)
+	}
+	if msgs, _ = b.After(6, nil, 0); len(msgs) != 0 {
+		t.Errorf("Expected nothing after the last message, got %+v", msgs)
+	}
+
+	if seq, ok := b.Find("5"); !ok || seq != 5 {
+		t.Errorf("Find(5) = %d, %v", seq, ok)
+	}
+	if _, ok := b.Find("1"); ok {
+		t.Error("Expected dropped messages not to be found")
+	}
+}
+
+func TestBufferWait(t *testing.T) {
+	b := NewBuffer(0)
+	select {
+	case <-b.Wait(0):
+		t.Fatal("Expected an empty buffer to wait")
+	default:
+	}
+
+	wait := b.Wait(0)
+	go b.Append(Message{Data: "x"})
+	select {
+	case <-wait:
+	case <-time.After(time.Second):
+		t.Fatal("Append did not wake the waiter")
+	}
+	select {
+	case <-b.Wait(0):
+	default:
+		t.Error("Expected Wait to return at once when newer messages exist")
+	}
+}
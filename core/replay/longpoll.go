@@ -0,0 +1,123 @@
+package replay
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// LongPollConfig configures a LongPoll handler
+type LongPollConfig struct {
+	// Timeout is how long a poll waits for a message before it is
+	// answered with none. Keep it below proxies' idle timeouts.
+	// Default: 25s.
+	Timeout time.Duration
+
+	// MaxMessages caps the messages in one response; clients poll again
+	// from the last one. Default: 100.
+	MaxMessages int
+}
+
+// Poll is a long-poll response
+type Poll struct {
+	Messages []Message `json:"messages"`
+	Last     uint64    `json:"last"`   // Seq to poll from next
+	Missed   bool      `json:"missed"` // Messages were dropped before this poll
+}
+
+// LongPoll returns a handler serving b to clients that cannot keep a
+// WebSocket or SSE stream open. A GET with ?since=<seq> is answered with
+// the messages after seq, waiting up to Timeout for one; ?topic=a,b
+// limits them to messages for every client and for those rooms. Clients
+// start without since, which returns no messages and the current Last,
+// then poll from the Last of each response. An SSE event ID works as
+// since as well, so clients can switch transports.
+//
+// Waiting polls are detached from the event loop (ctx.Async).
+func LongPoll(b *Buffer, cfg LongPollConfig) func(ctx http.Context) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 25 * time.Second
+	}
+	if cfg.MaxMessages <= 0 {
+		cfg.MaxMessages = 100
+	}
+
+	return func(ctx http.Context) {
+		if fc, ok := ctx.(*http.FDContext); ok {
+			fc.SetHeader("Cache-Control", "no-store")
+		}
+
+		var topics []string
+		if t := ctx.Query("topic"); t != "" {
+			topics = strings.Split(t, ",")
+		}
+
+		raw := ctx.Query("since")
+		if raw == "" {
+			ctx.JSON(200, Poll{Messages: []Message{}, Last: b.Last()})
+			return
+		}
+		since, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			seq, ok := b.Find(raw)
+			if !ok {
+				// Unknown event ID: start over from the oldest message
+				ctx.JSON(200, poll(b, 0, topics, cfg.MaxMessages, true))
+				return
+			}
+			since = seq
+		}
+		if since > b.Last() {
+			// A cursor of an earlier process (the buffer is not persisted)
+			ctx.JSON(200, poll(b, 0, topics, cfg.MaxMessages, true))
+			return
+		}
+		if p := poll(b, since, topics, cfg.MaxMessages, false); len(p.Messages) > 0 || p.Missed {
+			ctx.JSON(200, p)
+			return
+		}
+
+		done := ctx.Context().Done()
+		handle := ctx.Async()
+		go func() {
+			timer := time.NewTimer(cfg.Timeout)
+			defer timer.Stop()
+			for {
+				select {
+				case <-b.Wait(since):
+					p := poll(b, since, topics, cfg.MaxMessages, false)
+					if len(p.Messages) == 0 && !p.Missed {
+						// Only messages for other topics
+						since = p.Last
+						continue
+					}
+					handle.Respond(func(ctx http.Context) { ctx.JSON(200, p) })
+				case <-timer.C:
+					handle.Respond(func(ctx http.Context) {
+						ctx.JSON(200, Poll{Messages: []Message{}, Last: since})
+					})
+				case <-done:
+					handle.Fail(503, "poll cancelled")
+				}
+				return
+			}
+		}()
+	}
+}
+
+// poll collects the messages after since
+func poll(b *Buffer, since uint64, topics []string, max int, missed bool) Poll {
+	last := b.Last()
+	msgs, dropped := b.After(since, topics, max)
+	p := Poll{Messages: msgs, Last: last, Missed: missed || dropped}
+	if p.Messages == nil {
+		p.Messages = []Message{}
+	}
+	if n := len(msgs); n == max || (n > 0 && msgs[n-1].Seq > last) {
+		// More may follow, or were appended since Last was read
+		p.Last = msgs[n-1].Seq
+	}
+	return p
+}
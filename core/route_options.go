@@ -0,0 +1,281 @@
+package core
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/router"
+)
+
+// RouteOption configures a route: its response defaults, applied by the
+// response methods (String, JSON, Bytes, Data), how the handler runs and
+// the connection limits that differ from the engine's
+type RouteOption func(*routeConfig)
+
+type routeConfig struct {
+	defaults    http.ResponseDefaults
+	offload     bool
+	limits      routeLimits
+	concurrency *concurrencyLimit
+	timeout     time.Duration
+}
+
+// routeLimits are a route's overrides of the engine's connection limits
+// (zero values keep the engine's)
+type routeLimits struct {
+	idleTimeout    time.Duration
+	keepAlive      *bool
+	maxHeaderBytes int
+	maxBodyBytes   int
+}
+
+// set reports whether any limit is overridden
+func (l *routeLimits) set() bool {
+	return l.idleTimeout > 0 || l.keepAlive != nil || l.maxHeaderBytes > 0 || l.maxBodyBytes > 0
+}
+
+// Compress compresses response bodies of at least 1KB for clients that
+// accept it, with br or zstd if registered, else gzip or deflate (see
+// http.RegisterCompressor)
+func Compress(enabled bool) RouteOption {
+	return func(r *routeConfig) {
+		r.defaults.Compress = enabled
+	}
+}
+
+// ETag tags the route's 200 responses to GET and HEAD with an automatic
+// ETag and answers matching If-None-Match requests with 304, e.g.
+// ETag(http.ETagWeak). See FDContext.SetAutoETag.
+func ETag(mode http.ETagMode) RouteOption {
+	return func(r *routeConfig) {
+		r.defaults.ETag = mode
+	}
+}
+
+// CacheControl sets the Cache-Control header unless the handler sets one,
+// e.g. CacheControl("public, max-age=60")
+func CacheControl(value string) RouteOption {
+	return func(r *routeConfig) {
+		r.defaults.CacheControl = value
+	}
+}
+
+// ContentType replaces the implicit Content-Type of String, JSON and Bytes
+func ContentType(value string) RouteOption {
+	return func(r *routeConfig) {
+		r.defaults.ContentType = value
+	}
+}
+
+// Offload runs the route's handler on the worker pool instead of the
+// event loop, for CPU-heavy or blocking handlers (see Context.Detach)
+func Offload() RouteOption {
+	return func(r *routeConfig) {
+		r.offload = true
+	}
+}
+
+// IdleTimeout sets how long the connection may wait for its next request
+// after this route's response, e.g. minutes for long-poll endpoints whose
+// clients come straight back
+func IdleTimeout(d time.Duration) RouteOption {
+	return func(r *routeConfig) {
+		r.limits.idleTimeout = d
+	}
+}
+
+// KeepAlive sets whether the connection stays open after this route's
+// response, overriding Engine.SetKeepAlive (but not a client that asked
+// to close)
+func KeepAlive(enabled bool) RouteOption {
+	return func(r *routeConfig) {
+		r.limits.keepAlive = &enabled
+	}
+}
+
+// MaxHeaderBytes rejects requests to this route whose request line plus
+// headers exceed n bytes with 431, once the header has arrived and before
+// the body is read. It can only be stricter than
+// Engine.SetMaxHeaderBytes, which bounds what is read.
+func MaxHeaderBytes(n int) RouteOption {
+	return func(r *routeConfig) {
+		r.limits.maxHeaderBytes = n
+	}
+}
+
+// MaxBodyBytes rejects requests to this route whose body is larger than n
+// bytes with 413, whether it is sent with a Content-Length or chunked,
+// kept in memory or spooled to a BodyStore. A Content-Length is checked
+// before the body is read, a chunked body as it arrives. It can only be
+// stricter than Engine.SetMaxBodyBytes, which bounds what is read.
+func MaxBodyBytes(n int) RouteOption {
+	return func(r *routeConfig) {
+		r.limits.maxBodyBytes = n
+	}
+}
+
+// MaxConcurrent bounds the route's in-flight requests to n, protecting a
+// fragile downstream. Up to queue more requests wait, parked off the
+// event loop, for at most timeout (<= 0: until admitted) and then run on
+// the worker pool; the rest get 429 with Retry-After. The limit covers
+// the whole response, including Async and offloaded handlers.
+func MaxConcurrent(n, queue int, timeout time.Duration) RouteOption {
+	return func(r *routeConfig) {
+		r.concurrency = &concurrencyLimit{max: max(n, 1), queue: max(queue, 0), timeout: timeout}
+	}
+}
+
+// Timeout answers the route's requests with 504 if they are not answered
+// within d, including time spent waiting for MaxConcurrent or the worker
+// pool. ctx.Context() is canceled at the deadline; see
+// FDContext.SetDeadline for how the response is enforced.
+func Timeout(d time.Duration) RouteOption {
+	return func(r *routeConfig) {
+		r.timeout = d
+	}
+}
+
+// withRouteOptions wraps handler, the route for method and path, to apply
+// the route's options, and records its size limits
+func (e *Engine) withRouteOptions(method, path string, handler HandlerFunc, opts []RouteOption) HandlerFunc {
+	cfg := &routeConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	e.routeSizes.add(method, path, cfg.limits)
+	if len(opts) == 0 {
+		return handler
+	}
+
+	defaults := &cfg.defaults
+	inner := handler
+	if cfg.offload {
+		handler = func(ctx http.Context) {
+			ctx.Detach(inner)
+		}
+	}
+	if cfg.concurrency != nil {
+		handler = withConcurrencyLimit(handler, inner, cfg.concurrency)
+	}
+	if cfg.limits.set() {
+		handler = withRouteLimits(handler, cfg.limits)
+	}
+	timeout := cfg.timeout
+	return func(ctx http.Context) {
+		if fc, ok := ctx.(*http.FDContext); ok {
+			fc.SetResponseDefaults(defaults)
+			if timeout > 0 {
+				fc.SetDeadline(e.clock.Now().Add(timeout))
+			}
+		}
+		handler(ctx)
+	}
+}
+
+// withRouteLimits wraps handler to enforce the route's size limits and
+// apply its keep-alive and idle timeout
+func withRouteLimits(handler HandlerFunc, l routeLimits) HandlerFunc {
+	return func(ctx http.Context) {
+		fc, ok := ctx.(*http.FDContext)
+		if !ok {
+			handler(ctx)
+			return
+		}
+		if l.keepAlive != nil {
+			// Not past a client that asked to close
+			fc.SetKeepAlive(*l.keepAlive && fc.Request().KeepAlive())
+		}
+		if l.idleTimeout > 0 {
+			fc.SetIdleTimeout(l.idleTimeout)
+		}
+
+		// The engine applied the size limits once the header arrived
+		// (see sizeLimits); this covers requests it could not route
+		// then. Close rather than read another request from a client
+		// that ignores the limits.
+		req := fc.Request()
+		if l.maxHeaderBytes > 0 && req.HeaderSize > l.maxHeaderBytes {
+			fc.SetKeepAlive(false)
+			fc.String(431, "Request Header Fields Too Large")
+			return
+		}
+		if l.maxBodyBytes > 0 {
+			if n, ok := bodySize(req); !ok || n > l.maxBodyBytes {
+				fc.SetKeepAlive(false)
+				fc.String(413, "Request Entity Too Large")
+				return
+			}
+		}
+		handler(ctx)
+	}
+}
+
+// bodySize returns the size of req's body: the decoded size of a chunked
+// body, or the Content-Length of one kept in memory or spooled (a body is
+// only spooled with a Content-Length). It reports false for an invalid
+// Content-Length.
+func bodySize(req *http.Request) (int, bool) {
+	n := len(req.Body)
+	if req.ContentLength != "" {
+		cl, err := strconv.Atoi(req.ContentLength)
+		if err != nil {
+			return 0, false
+		}
+		n = max(n, cl)
+	}
+	return n, true
+}
+
+// sizeLimits finds the size limits of the route a request is for from its
+// header, so the engine applies them before the body is buffered or
+// spooled. Once a route has one it holds every route, so that a route
+// without limits matches its paths as it does in the router.
+type sizeLimits struct {
+	routes []route // Routes added while no route has a size limit
+	tree   *router.RadixRouter
+}
+
+// route is a route's method and path
+type route struct {
+	method, path string
+}
+
+// add records the limits of the route for method and path
+func (s *sizeLimits) add(method, path string, l routeLimits) {
+	if s.tree == nil {
+		if l.maxHeaderBytes <= 0 && l.maxBodyBytes <= 0 {
+			s.routes = append(s.routes, route{method, path})
+			return
+		}
+		s.tree = router.NewRadixRouter()
+		for _, rt := range s.routes {
+			s.tree.Add(rt.method, rt.path, noSizeLimits)
+		}
+		s.routes = nil
+	}
+	// The handler reports the limits into the routeLimits it is given
+	s.tree.Add(method, path, func(ctx any) {
+		*ctx.(*routeLimits) = l
+	})
+}
+
+// find returns the limits of the route for method and path, falling back
+// to GET for HEAD as the router does
+func (s *sizeLimits) find(method, path string) routeLimits {
+	var l routeLimits
+	if s.tree == nil {
+		return l
+	}
+	h, _ := s.tree.Find(method, path)
+	if h == nil && method == "HEAD" {
+		h, _ = s.tree.Find("GET", path)
+	}
+	if h != nil {
+		h(&l)
+	}
+	return l
+}
+
+// noSizeLimits is the sizeLimits handler of a route without size limits
+func noSizeLimits(any) {}
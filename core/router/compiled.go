@@ -1,242 +1,420 @@
 package router
 
 import (
+	"sort"
 	"strings"
-	"sync"
+	"sync/atomic"
 )
 
-// CompiledRouter is a compile-time optimized router with O(1) lookup
+// CompiledRouter serves lookups from dispatch tables generated from the
+// registered routes: a perfect hash for static routes and prefix/suffix
+// matchers for routes with a single parameter. Other routes, paths they
+// could match, and every route until Build is called, are served by a
+// radix tree.
 type CompiledRouter struct {
-	// Static routes: direct map lookup O(1)
-	staticRoutes map[string]map[string]HandlerFunc // path -> method -> handler
+	tree   *RadixRouter
+	routes []compiledRoute
 
-	// Parameterized routes: optimized tree structure
-	paramRoutes *compiledNode
+	// Route groups, for introspection (see Group)
+	groupRegistry
 
-	// Wildcard routes: cached patterns
-	wildcardRoutes []*wildcardRoute
+	// table is the installed dispatch table (nil: use the tree)
+	table atomic.Pointer[dispatchTable]
 
-	// Route cache for hot paths
-	cache sync.Map // "METHOD:PATH" -> cachedResult
-
-	// Statistics
-	hits   uint64
-	misses uint64
+	// Statistics: lookups answered by the table and by the tree
+	hits   atomic.Uint64
+	misses atomic.Uint64
 }
 
-type compiledNode struct {
-	// Fixed array for common path segments (cache-friendly)
-	staticChildren [128]*compiledNode // indexed by first byte
-
-	// Parameter node (e.g., :id)
-	paramChild *compiledNode
-	paramName  string
+type compiledRoute struct {
+	method  string
+	path    string
+	handler HandlerFunc
+}
 
-	// Handler for this path
-	handlers map[string]HandlerFunc
+// dispatchTable is an immutable snapshot of the route set
+type dispatchTable struct {
+	// Static routes: slots[hash(seed, path)&mask] holds the only path that
+	// can be at that slot. byPath replaces slots if no seed was found.
+	seed   uint64
+	mask   uint64
+	slots  []*staticEntry
+	byPath map[string]*staticEntry
+
+	// Single-parameter routes, most specific prefix first
+	params []paramMatcher
+
+	// Static prefixes of the routes left to the tree: only paths outside
+	// them can be answered by params
+	treePrefixes []string
+}
 
-	// Path segment
-	segment string
+type staticEntry struct {
+	path     string
+	methods  []string
+	handlers []HandlerFunc
+	allow    string // sorted methods, comma-separated
 }
 
-type wildcardRoute struct {
+// paramMatcher matches prefix + one non-empty segment + suffix, with a
+// handler per method
+type paramMatcher struct {
 	prefix   string
-	paramKey string
-	handlers map[string]HandlerFunc
+	suffix   string
+	name     string
+	methods  []string
+	handlers []HandlerFunc
+	allow    string // as in staticEntry
 }
 
-type cachedResult struct {
-	handler HandlerFunc
-	params  map[string]string
-}
+const (
+	// Seeds tried per table size, and the largest size tried relative to
+	// the number of static paths, before falling back to a map
+	perfectHashSeeds   = 256
+	perfectHashMaxLoad = 16
+)
 
 // NewCompiledRouter creates a new compiled router
 func NewCompiledRouter() *CompiledRouter {
-	return &CompiledRouter{
-		staticRoutes:   make(map[string]map[string]HandlerFunc),
-		paramRoutes:    &compiledNode{handlers: make(map[string]HandlerFunc)},
-		wildcardRoutes: make([]*wildcardRoute, 0),
-	}
+	return &CompiledRouter{tree: NewRadixRouter()}
 }
 
-// Add adds a route and compiles it
+// Add adds a route. Once the router is built, the tables are rebuilt and
+// swapped in.
 func (r *CompiledRouter) Add(method, path string, handler HandlerFunc) {
-	if path[0] != '/' {
-		panic("path must begin with '/'")
+	r.tree.Add(method, path, handler)
+	r.routes = append(r.routes, compiledRoute{method: method, path: path, handler: handler})
+	if r.table.Load() != nil {
+		r.Build()
 	}
+}
 
-	// Classify route type
-	if !strings.Contains(path, ":") && !strings.Contains(path, "*") {
-		// Static route - O(1) map lookup
-		r.addStaticRoute(method, path, handler)
-	} else if strings.Contains(path, "*") {
-		// Wildcard route
-		r.addWildcardRoute(method, path, handler)
-	} else {
-		// Parameterized route
-		r.addParamRoute(method, path, handler)
+// Group returns a group adding routes under prefix, wrapped in mw
+func (r *CompiledRouter) Group(prefix string, mw ...Middleware) *Group {
+	return r.newGroup(r.Add, prefix, mw)
+}
+
+// Find finds the handler for method and path. Static routes take
+// precedence over parameter routes, as in the tree; a single-parameter
+// route also matches paths the tree gives up on because a static sibling
+// shares their first bytes (e.g. /users/newest next to /users/new).
+func (r *CompiledRouter) Find(method, path string) (HandlerFunc, map[string]string) {
+	if t := r.table.Load(); t != nil {
+		if handler, params, ok := t.find(method, path); ok {
+			r.hits.Add(1)
+			return handler, params
+		}
+		r.misses.Add(1)
 	}
+	return r.tree.Find(method, path)
 }
 
-// addStaticRoute adds a static route (fastest path)
-func (r *CompiledRouter) addStaticRoute(method, path string, handler HandlerFunc) {
-	if r.staticRoutes[path] == nil {
-		r.staticRoutes[path] = make(map[string]HandlerFunc)
+// Lookup finds the handler for method and path like Find and, if there is
+// none, tells an unknown path from an unknown method
+func (r *CompiledRouter) Lookup(method, path string) Match {
+	handler, params := r.Find(method, path)
+	if handler != nil {
+		return Match{Handler: handler, Params: params}
 	}
-	r.staticRoutes[path][method] = handler
+	return Match{Allow: r.Allowed(path)}
 }
 
-// addParamRoute adds a parameterized route
-func (r *CompiledRouter) addParamRoute(method, path string, handler HandlerFunc) {
-	segments := strings.Split(path[1:], "/") // Skip leading /
-	node := r.paramRoutes
+// Methods returns the sorted methods registered for path
+func (r *CompiledRouter) Methods(path string) []string {
+	return r.tree.Methods(path)
+}
 
-	for _, segment := range segments {
-		if segment == "" {
-			continue
+// Allowed returns the methods registered for path, sorted and
+// comma-separated, or "" if no route matches. Routes served by the
+// dispatch table answer without allocating.
+func (r *CompiledRouter) Allowed(path string) string {
+	if t := r.table.Load(); t != nil {
+		if allow, ok := t.allowed(path); ok {
+			return allow
 		}
+	}
+	return strings.Join(r.tree.Methods(path), ", ")
+}
 
-		if segment[0] == ':' {
-			// Parameter segment
-			paramName := segment[1:]
-			if node.paramChild == nil {
-				node.paramChild = &compiledNode{
-					paramName: paramName,
-					handlers:  make(map[string]HandlerFunc),
-				}
-			}
-			node = node.paramChild
-		} else {
-			// Static segment - use array index for cache-friendly access
-			idx := segment[0]
-			if node.staticChildren[idx] == nil {
-				node.staticChildren[idx] = &compiledNode{
-					segment:  segment,
-					handlers: make(map[string]HandlerFunc),
-				}
+// Build analyzes the registered routes, generates the dispatch tables
+// and atomically installs them; lookups in flight finish on the previous
+// ones. Call it once the routes are registered.
+func (r *CompiledRouter) Build() {
+	t := &dispatchTable{}
+
+	statics := make(map[string]*staticEntry)
+	var order []string
+	params := make(map[string]int) // prefix+"\x00"+suffix -> index in t.params
+	for _, rt := range r.routes {
+		if !strings.ContainsAny(rt.path, ":*") {
+			e := statics[rt.path]
+			if e == nil {
+				e = &staticEntry{path: rt.path}
+				statics[rt.path] = e
+				order = append(order, rt.path)
 			}
-			node = node.staticChildren[idx]
+			e.set(rt.method, rt.handler)
+			continue
 		}
-	}
 
-	node.handlers[method] = handler
-}
+		m, ok := newParamMatcher(rt.path)
+		if !ok {
+			// Left to the tree, with every path it could match
+			t.treePrefixes = append(t.treePrefixes, rt.path[:strings.IndexAny(rt.path, ":*")])
+			continue
+		}
+		key := m.prefix + "\x00" + m.suffix
+		i, ok := params[key]
+		if !ok {
+			i = len(t.params)
+			params[key] = i
+			t.params = append(t.params, m)
+		}
+		t.params[i].set(rt.method, rt.handler)
+	}
 
-// addWildcardRoute adds a wildcard route
-func (r *CompiledRouter) addWildcardRoute(method, path string, handler HandlerFunc) {
-	idx := strings.Index(path, "*")
-	prefix := path[:idx]
-	paramKey := path[idx+1:]
+	entries := make([]*staticEntry, len(order))
+	for i, path := range order {
+		entries[i] = statics[path]
+		entries[i].allow = joinSorted(entries[i].methods)
+	}
+	t.buildStatic(entries)
 
-	route := &wildcardRoute{
-		prefix:   prefix,
-		paramKey: paramKey,
-		handlers: make(map[string]HandlerFunc),
+	for i := range t.params {
+		t.params[i].allow = joinSorted(t.params[i].methods)
 	}
-	route.handlers[method] = handler
-	r.wildcardRoutes = append(r.wildcardRoutes, route)
-}
 
-// Find finds a handler with O(1) complexity for static routes
-func (r *CompiledRouter) Find(method, path string) (HandlerFunc, map[string]string) {
-	// Step 1: Check cache first (hot path optimization)
-	cacheKey := method + ":" + path
-	if cached, ok := r.cache.Load(cacheKey); ok {
-		result := cached.(*cachedResult)
-		return result.handler, result.params
-	}
-
-	// Step 2: Try static routes (O(1) map lookup)
-	if methods, ok := r.staticRoutes[path]; ok {
-		if handler, ok := methods[method]; ok {
-			// Cache the result
-			r.cache.Store(cacheKey, &cachedResult{handler: handler, params: nil})
-			return handler, nil
+	// Longer prefixes first, as the tree prefers static segments, then
+	// longer suffixes
+	sort.SliceStable(t.params, func(i, j int) bool {
+		a, b := t.params[i], t.params[j]
+		if len(a.prefix) != len(b.prefix) {
+			return len(a.prefix) > len(b.prefix)
 		}
-	}
+		return len(a.suffix) > len(b.suffix)
+	})
 
-	// Step 3: Try parameterized routes (O(k) where k = path segments)
-	if handler, params := r.findParamRoute(method, path); handler != nil {
-		// Cache the result
-		r.cache.Store(cacheKey, &cachedResult{handler: handler, params: params})
-		return handler, params
-	}
+	r.table.Store(t)
+}
 
-	// Step 4: Try wildcard routes
-	if handler, params := r.findWildcardRoute(method, path); handler != nil {
-		return handler, params
+// Stats returns how many lookups the dispatch table answered (hits) and
+// how many fell back to the tree (misses) since the router was built
+func (r *CompiledRouter) Stats() (hits, misses uint64, hitRate float64) {
+	hits, misses = r.hits.Load(), r.misses.Load()
+	if total := hits + misses; total > 0 {
+		hitRate = float64(hits) / float64(total)
 	}
+	return hits, misses, hitRate
+}
 
-	return nil, nil
+// ClearCache drops the dispatch table; lookups go to the tree until the
+// next Build
+func (r *CompiledRouter) ClearCache() {
+	r.table.Store(nil)
 }
 
-// findParamRoute finds a parameterized route
-func (r *CompiledRouter) findParamRoute(method, path string) (HandlerFunc, map[string]string) {
-	segments := strings.Split(path[1:], "/")
-	node := r.paramRoutes
-	params := make(map[string]string, 4) // Pre-allocate for common case
+// TableStats describes the installed dispatch table
+type TableStats struct {
+	Routes      int  `json:"routes"`       // Registered routes
+	Static      int  `json:"static"`       // Paths in the static table
+	Params      int  `json:"params"`       // Single-parameter matchers
+	PerfectHash bool `json:"perfect_hash"` // Static paths hashed without a map
+	Built       bool `json:"built"`        // false: every lookup uses the tree
+}
 
-	for _, segment := range segments {
-		if segment == "" {
-			continue
+// TableStats returns the layout of the dispatch table
+func (r *CompiledRouter) TableStats() TableStats {
+	s := TableStats{Routes: len(r.routes)}
+	t := r.table.Load()
+	if t == nil {
+		return s
+	}
+	s.Built, s.Params = true, len(t.params)
+	if t.slots == nil {
+		s.Static = len(t.byPath)
+		return s
+	}
+	s.PerfectHash = true
+	for _, e := range t.slots {
+		if e != nil {
+			s.Static++
 		}
+	}
+	return s
+}
 
-		// Try static match first (cache-friendly array access)
-		idx := segment[0]
-		if child := node.staticChildren[idx]; child != nil && child.segment == segment {
-			node = child
-			continue
+// newParamMatcher compiles a route path with exactly one :param spanning
+// a whole segment. It reports false for other paths.
+func newParamMatcher(path string) (paramMatcher, bool) {
+	if strings.Count(path, ":") != 1 || strings.Contains(path, "*") {
+		return paramMatcher{}, false
+	}
+	start := strings.IndexByte(path, ':')
+	if path[start-1] != '/' {
+		return paramMatcher{}, false
+	}
+	end := start + 1
+	for end < len(path) && path[end] != '/' {
+		end++
+	}
+	if end == start+1 {
+		return paramMatcher{}, false
+	}
+	return paramMatcher{
+		prefix: path[:start],
+		suffix: path[end:],
+		name:   path[start+1 : end],
+	}, true
+}
+
+// set registers handler for method, replacing an earlier one
+func (m *paramMatcher) set(method string, handler HandlerFunc) {
+	for i, have := range m.methods {
+		if have == method {
+			m.handlers[i] = handler
+			return
 		}
+	}
+	m.methods = append(m.methods, method)
+	m.handlers = append(m.handlers, handler)
+}
 
-		// Try parameter match
-		if node.paramChild != nil {
-			params[node.paramChild.paramName] = segment
-			node = node.paramChild
-			continue
+// match returns the parameter value if path fits m
+func (m *paramMatcher) match(path string) (string, bool) {
+	if len(path) <= len(m.prefix)+len(m.suffix) ||
+		path[:len(m.prefix)] != m.prefix ||
+		path[len(path)-len(m.suffix):] != m.suffix {
+		return "", false
+	}
+	value := path[len(m.prefix) : len(path)-len(m.suffix)]
+	if strings.IndexByte(value, '/') >= 0 {
+		return "", false
+	}
+	return value, true
+}
+
+// set registers handler for method, replacing an earlier one
+func (e *staticEntry) set(method string, handler HandlerFunc) {
+	for i, m := range e.methods {
+		if m == method {
+			e.handlers[i] = handler
+			return
 		}
+	}
+	e.methods = append(e.methods, method)
+	e.handlers = append(e.handlers, handler)
+}
 
-		// No match
-		return nil, nil
+// buildStatic looks for a seed that hashes every static path to its own
+// slot, growing the table if none is found, and falls back to a map
+func (t *dispatchTable) buildStatic(entries []*staticEntry) {
+	size := 1
+	for size < 2*len(entries) {
+		size <<= 1
+	}
+	for ; size <= perfectHashMaxLoad*max(len(entries), 1); size <<= 1 {
+		slots := make([]*staticEntry, size)
+		mask := uint64(size - 1)
+	seeds:
+		for seed := uint64(1); seed <= perfectHashSeeds; seed++ {
+			clear(slots)
+			for _, e := range entries {
+				i := pathHash(seed, e.path) & mask
+				if slots[i] != nil {
+					continue seeds
+				}
+				slots[i] = e
+			}
+			t.seed, t.mask, t.slots = seed, mask, slots
+			return
+		}
 	}
 
-	if handler, ok := node.handlers[method]; ok {
-		return handler, params
+	t.byPath = make(map[string]*staticEntry, len(entries))
+	for _, e := range entries {
+		t.byPath[e.path] = e
 	}
+}
 
-	return nil, nil
+// entry returns the static route for path, or nil
+func (t *dispatchTable) entry(path string) *staticEntry {
+	if t.slots == nil {
+		return t.byPath[path]
+	}
+	if e := t.slots[pathHash(t.seed, path)&t.mask]; e != nil && e.path == path {
+		return e
+	}
+	return nil
 }
 
-// findWildcardRoute finds a wildcard route
-func (r *CompiledRouter) findWildcardRoute(method, path string) (HandlerFunc, map[string]string) {
-	for _, route := range r.wildcardRoutes {
-		if strings.HasPrefix(path, route.prefix) {
-			if handler, ok := route.handlers[method]; ok {
-				params := make(map[string]string)
-				params[route.paramKey] = path[len(route.prefix):]
-				return handler, params
+// find looks method and path up. It reports false if the tree must be
+// consulted.
+func (t *dispatchTable) find(method, path string) (HandlerFunc, map[string]string, bool) {
+	if e := t.entry(path); e != nil {
+		for i, m := range e.methods {
+			if m == method {
+				return e.handlers[i], nil, true
 			}
 		}
+		// A static path takes precedence over parameters even for
+		// methods it does not have
+		return nil, nil, false
 	}
-	return nil, nil
+
+	m, value := t.param(path)
+	if m == nil {
+		return nil, nil, false
+	}
+	for i, have := range m.methods {
+		if have == method {
+			return m.handlers[i], map[string]string{m.name: value}, true
+		}
+	}
+	return nil, nil, false
 }
 
-// Build optimizes the router (pre-warming cache, etc.)
-func (r *CompiledRouter) Build() {
-	// Pre-warm common routes
-	// This could include JIT compilation in future versions
+// allowed returns the methods of the route path matches. It reports
+// false if the tree must be consulted.
+func (t *dispatchTable) allowed(path string) (string, bool) {
+	if e := t.entry(path); e != nil {
+		return e.allow, true
+	}
+	if m, _ := t.param(path); m != nil {
+		return m.allow, true
+	}
+	return "", false
+}
 
-	// TODO: Generate optimized lookup tables
-	// TODO: Analyze route patterns and generate specialized code
-	// TODO: Use code generation for ultra-fast path matching
+// param returns the single-parameter route path matches, as the tree
+// would choose it, and the parameter value. It returns nil if there is
+// none or a route left to the tree could match path.
+func (t *dispatchTable) param(path string) (*paramMatcher, string) {
+	for _, prefix := range t.treePrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return nil, ""
+		}
+	}
+	for i := range t.params {
+		if value, ok := t.params[i].match(path); ok {
+			return &t.params[i], value
+		}
+	}
+	return nil, ""
 }
 
-// Stats returns router statistics
-func (r *CompiledRouter) Stats() (hits, misses uint64, hitRate float64) {
-	// TODO: Implement atomic counters for stats
-	return 0, 0, 0.0
+// joinSorted returns methods sorted and comma-separated
+func joinSorted(methods []string) string {
+	sorted := append([]string(nil), methods...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ", ")
 }
 
-// ClearCache clears the route cache
-func (r *CompiledRouter) ClearCache() {
-	r.cache = sync.Map{}
+// pathHash is FNV-1a seeded for the perfect hash search
+func pathHash(seed uint64, path string) uint64 {
+	h := 14695981039346656037 ^ seed*0x9e3779b97f4a7c15
+	for i := 0; i < len(path); i++ {
+		h ^= uint64(path[i])
+		h *= 1099511628211
+	}
+	return h ^ h>>32
 }
@@ -0,0 +1,211 @@
+package router
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestCompiledRouterBuild tests that the dispatch table agrees with the tree
+func TestCompiledRouterBuild(t *testing.T) {
+	router := NewCompiledRouter()
+
+	names := map[string]string{}
+	add := func(method, path string) {
+		router.Add(method, path, func(ctx any) { names[method+" "+path] = "" })
+		names[method+" "+path] = method + " " + path
+	}
+	add("GET", "/")
+	add("GET", "/users")
+	add("POST", "/users")
+	add("GET", "/users/new")
+	add("GET", "/users/:id")
+	add("GET", "/users/:id/posts")
+	add("GET", "/orgs/:org/users/:id")
+	for i := 0; i < 200; i++ {
+		add("GET", "/bulk/"+strconv.Itoa(i))
+	}
+
+	tests := []struct {
+		method, path string
+		route        string
+		params       map[string]string
+	}{
+		{"GET", "/", "GET /", nil},
+		{"POST", "/users", "POST /users", nil},
+		{"GET", "/users/new", "GET /users/new", nil},
+		{"GET", "/users/42", "GET /users/:id", map[string]string{"id": "42"}},
+		{"GET", "/users/42/posts", "GET /users/:id/posts", map[string]string{"id": "42"}},
+		{"GET", "/orgs/acme/users/7", "GET /orgs/:org/users/:id", map[string]string{"org": "acme", "id": "7"}},
+		{"GET", "/bulk/199", "GET /bulk/199", nil},
+		{"DELETE", "/users/new", "", nil},
+		{"GET", "/users/42/comments", "", nil},
+		{"GET", "/missing", "", nil},
+	}
+
+	for _, built := range []bool{false, true} {
+		if built {
+			router.Build()
+		}
+		for _, tt := range tests {
+			h, params := router.Find(tt.method, tt.path)
+			if (h != nil) != (tt.route != "") {
+				t.Errorf("built=%v %s %s: expected match=%v", built, tt.method, tt.path, tt.route != "")
+				continue
+			}
+			if h == nil {
+				continue
+			}
+			h(nil)
+			if names[tt.route] != "" {
+				t.Errorf("built=%v %s %s: expected route %s", built, tt.method, tt.path, tt.route)
+			}
+			names[tt.route] = tt.route
+			for k, v := range tt.params {
+				if params[k] != v {
+					t.Errorf("built=%v %s %s: param %s=%q, expected %q", built, tt.method, tt.path, k, params[k], v)
+				}
+			}
+		}
+	}
+
+	if hits, misses, _ := router.Stats(); hits == 0 || misses == 0 {
+		t.Errorf("Expected hits and misses, got %d/%d", hits, misses)
+	}
+
+	allowed := map[string]string{
+		"/users":           "GET, POST",
+		"/users/9":         "GET",
+		"/orgs/a/users/b":  "GET",
+		"/nothing/here/at": "",
+	}
+	for path, want := range allowed {
+		if got := router.Allowed(path); got != want {
+			t.Errorf("Allowed(%s) = %q, expected %q", path, got, want)
+		}
+	}
+
+	if ts := router.TableStats(); ts.Routes != 207 || ts.Static != 203 || ts.Params != 2 || !ts.Built {
+		t.Errorf("Unexpected table stats %+v", ts)
+	}
+
+	// Routes added after Build are swapped in
+	router.Add("GET", "/late", func(ctx any) {})
+	if h, _ := router.Find("GET", "/late"); h == nil {
+		t.Error("Expected route added after Build to match")
+	}
+}
+
+// BenchmarkCompiledRouterStatic benchmarks static lookups from the dispatch table
+func BenchmarkCompiledRouterStatic(b *testing.B) {
+	router := NewCompiledRouter()
+	for i := 0; i < 100; i++ {
+		router.Add("GET", "/api/v1/resource"+strconv.Itoa(i), func(ctx any) {})
+	}
+	router.Build()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.Find("GET", "/api/v1/resource57")
+	}
+}
+
+// TestRouterLookup tests that Lookup tells an unknown path from an unknown method
+func TestRouterLookup(t *testing.T) {
+	for name, r := range map[string]interface {
+		Add(method, path string, handler HandlerFunc)
+		Lookup(method, path string) Match
+	}{
+		"radix":    NewRadixRouter(),
+		"fast":     NewFastRouter(),
+		"compiled": NewCompiledRouter(),
+	} {
+		handler := func(ctx any) {}
+		r.Add("GET", "/health", handler)
+		r.Add("GET", "/users", handler)
+		r.Add("POST", "/users", handler)
+		r.Add("GET", "/users/:id", handler)
+		r.Add("DELETE", "/users/:id", handler)
+		if c, ok := r.(*CompiledRouter); ok {
+			c.Build()
+		}
+
+		tests := []struct {
+			method, path string
+			found        bool
+			allow        string
+		}{
+			{"GET", "/users", true, ""},
+			{"PUT", "/users", false, "GET, POST"},
+			{"PATCH", "/users/42", false, "DELETE, GET"},
+			{"POST", "/health", false, "GET"},
+			{"GET", "/missing", false, ""},
+		}
+		for _, tt := range tests {
+			m := r.Lookup(tt.method, tt.path)
+			if (m.Handler != nil) != tt.found || m.Allow != tt.allow || m.MethodNotAllowed() != (tt.allow != "") {
+				t.Errorf("%s: %s %s: got found=%v allow=%q", name, tt.method, tt.path, m.Handler != nil, m.Allow)
+			}
+		}
+		if m := r.Lookup("DELETE", "/users/7"); m.Params["id"] != "7" {
+			t.Errorf("%s: expected params, got %v", name, m.Params)
+		}
+	}
+}
+
+// TestCompiledRouterMatchesTree tests that the dispatch table answers
+// every lookup as the tree does, with routes it leaves to the tree
+// overlapping the ones it compiles
+func TestCompiledRouterMatchesTree(t *testing.T) {
+	router := NewCompiledRouter()
+	tree := NewRadixRouter()
+
+	var matched string
+	add := func(method, path string) {
+		route := method + " " + path
+		handler := func(ctx any) { matched = route }
+		router.Add(method, path, handler)
+		tree.Add(method, path, handler)
+	}
+	add("GET", "/")
+	add("GET", "/static/*filepath")
+	add("GET", "/:lang/about")
+	add("POST", "/:lang/contact")
+	add("POST", "/a/:y")
+	add("GET", "/:lang/c")
+	add("GET", "/users/new")
+	add("GET", "/users/:id")
+	add("PUT", "/users/:id")
+	add("DELETE", "/users/:id")
+	add("GET", "/users/:id/posts")
+	add("GET", "/orgs/:org/users/:id")
+	add("GET", "/files/:name")
+	router.Build()
+
+	route := func(h HandlerFunc) string {
+		matched = ""
+		if h != nil {
+			h(nil)
+		}
+		return matched
+	}
+	paths := []string{
+		"/", "/static/about", "/static/css/app.css", "/static/", "/en/about",
+		"/en/contact", "/en/c", "/a/c", "/a/x", "/users/new", "/users/42",
+		"/users/42/posts", "/users/42/comments", "/orgs/acme/users/7",
+		"/orgs/acme", "/files/readme", "/files/", "/missing", "/en",
+	}
+	for _, path := range paths {
+		for _, method := range []string{"GET", "POST", "PUT", "DELETE"} {
+			h, params := router.Find(method, path)
+			th, tparams := tree.Find(method, path)
+			if got, want := route(h), route(th); got != want || !reflect.DeepEqual(params, tparams) {
+				t.Errorf("%s %s: table gave %q %v, tree gave %q %v", method, path, got, params, want, tparams)
+			}
+		}
+		if got, want := router.Allowed(path), strings.Join(tree.Methods(path), ", "); got != want {
+			t.Errorf("Allowed(%s) = %q, tree gave %q", path, got, want)
+		}
+	}
+}
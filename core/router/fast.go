@@ -1,6 +1,7 @@
 package router
 
 import (
+	"slices"
 	"strings"
 	"unsafe"
 )
@@ -22,6 +23,12 @@ type FastRouter struct {
 
 	// Fallback to radix tree for complex routes
 	radix *RadixRouter
+
+	// Methods with routes, sorted (see Methods)
+	methods []string
+
+	// Route groups, for introspection (see Group)
+	groupRegistry
 }
 
 type paramRoute struct {
@@ -45,6 +52,10 @@ func NewFastRouter() *FastRouter {
 
 // Add adds a route with compile-time optimization hints
 func (r *FastRouter) Add(method, path string, handler HandlerFunc) {
+	if i, found := slices.BinarySearch(r.methods, method); !found {
+		r.methods = slices.Insert(r.methods, i, method)
+	}
+
 	// Detect common routes for inline fast path
 	if method == "GET" && path == "/health" {
 		r.hasHealthCheck = true
@@ -116,6 +127,11 @@ func (r *FastRouter) Add(method, path string, handler HandlerFunc) {
 	r.radix.Add(method, path, handler)
 }
 
+// Group returns a group adding routes under prefix, wrapped in mw
+func (r *FastRouter) Group(prefix string, mw ...Middleware) *Group {
+	return r.newGroup(r.Add, prefix, mw)
+}
+
 // Find finds a handler with optimized fast paths
 //
 //go:inline
@@ -143,6 +159,28 @@ func (r *FastRouter) Find(method, path string) (HandlerFunc, map[string]string)
 	return r.radix.Find(method, path)
 }
 
+// Lookup finds the handler for method and path like Find and, if there is
+// none, tells an unknown path from an unknown method
+func (r *FastRouter) Lookup(method, path string) Match {
+	handler, params := r.Find(method, path)
+	if handler != nil {
+		return Match{Handler: handler, Params: params}
+	}
+	return Match{Allow: strings.Join(r.Methods(path), ", ")}
+}
+
+// Methods returns the sorted methods registered for path. Routes are
+// spread over several tables, so each method is looked up as Find would.
+func (r *FastRouter) Methods(path string) []string {
+	var methods []string
+	for _, method := range r.methods {
+		if handler, _ := r.Find(method, path); handler != nil {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
 // findParamRouteFast uses optimized string operations
 //
 //go:inline
@@ -0,0 +1,110 @@
+package router
+
+import "strings"
+
+// Middleware wraps a handler, e.g. to authenticate a request before it
+// runs or to time it
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Group mounts routes under a shared path prefix, each wrapped in the
+// group's middleware chain. Groups are registered with their router, which
+// lists them with Groups.
+type Group struct {
+	registry   *groupRegistry
+	add        func(method, path string, handler HandlerFunc)
+	prefix     string
+	middleware []Middleware
+	routes     []Route
+}
+
+// Route is a registered method and full path
+type Route struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// GroupInfo describes a group for introspection
+type GroupInfo struct {
+	Prefix     string  `json:"prefix"`
+	Middleware int     `json:"middleware"` // Inherited ones included
+	Routes     []Route `json:"routes"`
+}
+
+// groupRegistry records the groups of a router
+type groupRegistry struct {
+	groups []*Group
+}
+
+// newGroup creates and records a group adding its routes with add
+func (g *groupRegistry) newGroup(add func(method, path string, handler HandlerFunc), prefix string, mw []Middleware) *Group {
+	group := &Group{
+		registry:   g,
+		add:        add,
+		prefix:     JoinPath(prefix, ""),
+		middleware: append([]Middleware(nil), mw...),
+	}
+	g.groups = append(g.groups, group)
+	return group
+}
+
+// Groups returns the router's groups, nested ones included, in the order
+// they were created
+func (g *groupRegistry) Groups() []GroupInfo {
+	infos := make([]GroupInfo, 0, len(g.groups))
+	for _, group := range g.groups {
+		infos = append(infos, GroupInfo{
+			Prefix:     group.prefix,
+			Middleware: len(group.middleware),
+			Routes:     append([]Route(nil), group.routes...),
+		})
+	}
+	return infos
+}
+
+// Group creates a nested group inheriting this group's prefix and
+// middleware
+func (g *Group) Group(prefix string, mw ...Middleware) *Group {
+	combined := make([]Middleware, 0, len(g.middleware)+len(mw))
+	combined = append(combined, g.middleware...)
+	combined = append(combined, mw...)
+	return g.registry.newGroup(g.add, JoinPath(g.prefix, prefix), combined)
+}
+
+// Use adds middleware to the group. It applies to routes added afterwards.
+func (g *Group) Use(mw ...Middleware) {
+	g.middleware = append(g.middleware, mw...)
+}
+
+// Prefix returns the group's path prefix
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+// Add adds a route under the group's prefix. The group's middleware runs
+// in the order it was added, the first outermost.
+func (g *Group) Add(method, path string, handler HandlerFunc) {
+	for i := len(g.middleware) - 1; i >= 0; i-- {
+		handler = g.middleware[i](handler)
+	}
+	fullPath := JoinPath(g.prefix, path)
+	g.routes = append(g.routes, Route{Method: method, Path: fullPath})
+	g.add(method, fullPath, handler)
+}
+
+// JoinPath joins a group prefix and a route path with exactly one slash
+func JoinPath(prefix, path string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" && prefix[0] != '/' {
+		prefix = "/" + prefix
+	}
+	if path == "" || path == "/" {
+		if prefix == "" {
+			return "/"
+		}
+		return prefix
+	}
+	if path[0] != '/' {
+		path = "/" + path
+	}
+	return prefix + path
+}
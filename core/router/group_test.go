@@ -0,0 +1,66 @@
+package router
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRouterGroup tests prefixes, middleware order and introspection of groups
+func TestRouterGroup(t *testing.T) {
+	var trace []string
+	tag := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx any) {
+				trace = append(trace, name)
+				next(ctx)
+			}
+		}
+	}
+	handler := func(ctx any) { trace = append(trace, "handler") }
+
+	for name, r := range map[string]interface {
+		Group(prefix string, mw ...Middleware) *Group
+		Find(method, path string) (HandlerFunc, map[string]string)
+		Groups() []GroupInfo
+	}{
+		"radix":    NewRadixRouter(),
+		"fast":     NewFastRouter(),
+		"compiled": NewCompiledRouter(),
+	} {
+		api := r.Group("/api/", tag("auth"))
+		api.Add("GET", "/status", handler)
+		v1 := api.Group("v1", tag("v1"))
+		v1.Use(tag("log"))
+		v1.Add("GET", "/users/:id", handler)
+		v1.Add("POST", "/", handler)
+
+		tests := []struct {
+			method, path string
+			trace        string
+		}{
+			{"GET", "/api/status", "auth,handler"},
+			{"GET", "/api/v1/users/42", "auth,v1,log,handler"},
+			{"POST", "/api/v1", "auth,v1,log,handler"},
+		}
+		for _, tt := range tests {
+			trace = nil
+			h, _ := r.Find(tt.method, tt.path)
+			if h == nil {
+				t.Errorf("%s: %s %s not found", name, tt.method, tt.path)
+				continue
+			}
+			h(nil)
+			if got := strings.Join(trace, ","); got != tt.trace {
+				t.Errorf("%s: %s %s ran %s, want %s", name, tt.method, tt.path, got, tt.trace)
+			}
+		}
+
+		groups := r.Groups()
+		if len(groups) != 2 || groups[0].Prefix != "/api" || groups[1].Prefix != "/api/v1" {
+			t.Fatalf("%s: unexpected groups %+v", name, groups)
+		}
+		if groups[1].Middleware != 3 || len(groups[1].Routes) != 2 || groups[1].Routes[0] != (Route{"GET", "/api/v1/users/:id"}) {
+			t.Errorf("%s: unexpected nested group %+v", name, groups[1])
+		}
+	}
+}
@@ -1,11 +1,19 @@
 package router
 
+import (
+	"sort"
+	"strings"
+)
+
 // HandlerFunc defines the handler function type
 type HandlerFunc func(ctx any)
 
 // RadixRouter is a Radix tree based router with parameter support
 type RadixRouter struct {
 	root *node
+
+	// Route groups, for introspection (see Group)
+	groupRegistry
 }
 
 type nodeType uint8
@@ -43,6 +51,11 @@ func (r *RadixRouter) Add(method, path string, handler HandlerFunc) {
 	r.root.addRoute(method, path, handler)
 }
 
+// Group returns a group adding routes under prefix, wrapped in mw
+func (r *RadixRouter) Group(prefix string, mw ...Middleware) *Group {
+	return r.newGroup(r.Add, prefix, mw)
+}
+
 // Find finds a handler for the given method and path
 func (r *RadixRouter) Find(method, path string) (HandlerFunc, map[string]string) {
 	if r.root == nil {
@@ -52,6 +65,51 @@ func (r *RadixRouter) Find(method, path string) (HandlerFunc, map[string]string)
 	return handler, params
 }
 
+// Match is the result of Lookup
+type Match struct {
+	Handler HandlerFunc
+	Params  map[string]string
+
+	// Allow lists the methods path is registered under, sorted and
+	// comma-separated, when Handler is nil: "" if the path is unknown
+	// (404), else the method is not allowed (405)
+	Allow string
+}
+
+// MethodNotAllowed reports whether the path is registered, but not for
+// the method looked up
+func (m Match) MethodNotAllowed() bool {
+	return m.Handler == nil && m.Allow != ""
+}
+
+// Lookup finds the handler for method and path like Find and, if there is
+// none, tells an unknown path from an unknown method
+func (r *RadixRouter) Lookup(method, path string) Match {
+	handler, params := r.Find(method, path)
+	if handler != nil {
+		return Match{Handler: handler, Params: params}
+	}
+	return Match{Allow: strings.Join(r.Methods(path), ", ")}
+}
+
+// Methods returns the sorted methods registered for path
+func (r *RadixRouter) Methods(path string) []string {
+	if r.root == nil {
+		return nil
+	}
+	leaf, _ := r.root.lookup(path)
+	if leaf == nil || len(leaf.handlers) == 0 {
+		return nil
+	}
+
+	methods := make([]string, 0, len(leaf.handlers))
+	for m := range leaf.handlers {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
 func (n *node) addRoute(method, path string, handler HandlerFunc) {
 	fullPath := path
 
@@ -88,11 +146,6 @@ func (n *node) addRoute(method, path string, handler HandlerFunc) {
 		if i < len(path) {
 			path = path[i:]
 
-			if n.nType == param {
-				n.priority++
-				continue
-			}
-
 			idxc := path[0]
 
 			// '/' after param
@@ -116,6 +169,15 @@ func (n *node) addRoute(method, path string, handler HandlerFunc) {
 				continue
 			}
 
+			// Descend into an existing wildcard child for the same name
+			if idxc == ':' || idxc == '*' {
+				if child := n.wildcardChild(path); child != nil {
+					n.priority++
+					n = child
+					continue
+				}
+			}
+
 			// Otherwise insert it
 			if idxc != ':' && idxc != '*' {
 				n.indices += string([]byte{idxc})
@@ -197,10 +259,11 @@ func (n *node) insertChild(method, path string, handler HandlerFunc) {
 			panic("catch-all routes are only allowed at the end of the path")
 		}
 
-		if len(n.path) > 0 && n.path[len(n.path)-1] == '/' {
+		if i > 0 && path[i-1] == '/' {
 			// Insert prefix before the current wildcard
 			n.path = path[:i]
-
+		}
+		if strings.HasSuffix(n.path, "/") {
 			child := &node{
 				nType:     catchAll,
 				path:      wildcard,
@@ -223,14 +286,47 @@ func (n *node) insertChild(method, path string, handler HandlerFunc) {
 	n.handlers[method] = handler
 }
 
+// wildcardChild returns the param or catch-all child whose wildcard is the
+// first segment of path, if any
+func (n *node) wildcardChild(path string) *node {
+	for _, child := range n.children {
+		if child.nType == static || !strings.HasPrefix(path, child.path) {
+			continue
+		}
+		if len(path) == len(child.path) || path[len(child.path)] == '/' {
+			return child
+		}
+	}
+	return nil
+}
+
+// addChild adds child, keeping a wildcard child last: lookup tries the
+// static children by index first and falls back to the last child
 func (n *node) addChild(child *node) {
 	if n.children == nil {
 		n.children = make([]*node, 0, 1)
 	}
+	if last := len(n.children) - 1; child.nType == static && last >= 0 && n.children[last].nType != static {
+		wildcard := n.children[last]
+		n.children = append(n.children[:last], child, wildcard)
+		return
+	}
 	n.children = append(n.children, child)
 }
 
 func (n *node) getValue(method, path string) (HandlerFunc, map[string]string) {
+	leaf, params := n.lookup(path)
+	if leaf == nil {
+		return nil, nil
+	}
+	if handler := leaf.handlers[method]; handler != nil {
+		return handler, params
+	}
+	return nil, nil
+}
+
+// lookup finds the node matching path regardless of method
+func (n *node) lookup(path string) (*node, map[string]string) {
 	var params map[string]string
 
 	for {
@@ -290,20 +386,12 @@ func (n *node) getValue(method, path string) (HandlerFunc, map[string]string) {
 								return nil, nil
 							}
 
-							if handler := n.handlers[method]; handler != nil {
-								return handler, params
-							}
-
-							return nil, nil
+							return n, params
 
 						case catchAll:
 							params[n.paramName] = path
 
-							if handler := n.handlers[method]; handler != nil {
-								return handler, params
-							}
-
-							return nil, nil
+							return n, params
 
 						default:
 							panic("invalid node type")
@@ -322,11 +410,7 @@ func (n *node) getValue(method, path string) (HandlerFunc, map[string]string) {
 		}
 
 		// We should have reached the node containing the handler
-		if handler := n.handlers[method]; handler != nil {
-			return handler, params
-		}
-
-		return nil, nil
+		return n, params
 	}
 }
 
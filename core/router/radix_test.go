@@ -90,3 +90,65 @@ for i := 0; i < b.N; i++ {
 router.Find("GET", "/user/123")
 }
 }
+
+func TestRadixRouterMethods(t *testing.T) {
+router := NewRadixRouter()
+handler := func(ctx any) {}
+router.Add("GET", "/user/:id", handler)
+router.Add("DELETE", "/user/:id", handler)
+
+methods := router.Methods("/user/42")
+if len(methods) != 2 || methods[0] != "DELETE" || methods[1] != "GET" {
+t.Errorf("Expected [DELETE GET], got %v", methods)
+}
+
+if methods := router.Methods("/missing"); methods != nil {
+t.Errorf("Expected no methods, got %v", methods)
+}
+}
+
+func TestRadixRouterSharedParam(t *testing.T) {
+router := NewRadixRouter()
+router.Add("GET", "/user/:id", func(ctx any) {})
+router.Add("PUT", "/user/:id", func(ctx any) {})
+router.Add("GET", "/user/:id/posts", func(ctx any) {})
+
+for _, tc := range []struct{ method, path string }{
+{"GET", "/user/1"},
+{"PUT", "/user/2"},
+{"GET", "/user/3/posts"},
+} {
+handler, params := router.Find(tc.method, tc.path)
+if handler == nil {
+t.Errorf("%s %s: expected handler", tc.method, tc.path)
+continue
+}
+if params["id"] == "" {
+t.Errorf("%s %s: expected id param", tc.method, tc.path)
+}
+}
+}
+
+func TestRadixRouterCatchAll(t *testing.T) {
+for _, order := range [][]string{
+{"/static/*filepath", "/", "/static/app.css"},
+{"/", "/static/app.css", "/static/*filepath"},
+} {
+router := NewRadixRouter()
+for _, path := range order {
+path := path
+router.Add("GET", path, func(ctx any) { _ = path })
+}
+
+if h, _ := router.Find("GET", "/"); h == nil {
+t.Errorf("%v: expected / to match", order)
+}
+if h, params := router.Find("GET", "/static/app.css"); h == nil || params != nil {
+t.Errorf("%v: expected the static route for /static/app.css, got params %v", order, params)
+}
+h, params := router.Find("GET", "/static/css/site.css")
+if h == nil || params["filepath"] != "css/site.css" {
+t.Errorf("%v: expected the catch-all to match, got %v", order, params)
+}
+}
+}
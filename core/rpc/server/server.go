@@ -101,6 +101,13 @@ func (s *Server) Serve(ln net.Listener) error {
 	}
 }
 
+// ServeConn serves a single connection accepted elsewhere, e.g. by an
+// HTTP engine sniffing its port, and returns when it closes
+func (s *Server) ServeConn(conn net.Conn) {
+	s.trackConn(conn, true)
+	s.handleConn(conn)
+}
+
 // trackConn tracks active connections
 func (s *Server) trackConn(conn net.Conn, add bool) {
 	s.mu.Lock()
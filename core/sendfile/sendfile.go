@@ -2,15 +2,21 @@ package sendfile
 
 import (
 	"container/list"
+	"mime"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
+
+	"github.com/searchktools/fast-server/core/netfd"
 )
 
-// FileCache caches open file descriptors using LRU
+// FileCache caches open file descriptors using LRU. Files are checked
+// out with a release function: an entry evicted or replaced while a
+// request still sends it is closed when the last one releases it.
 type FileCache struct {
-	mu       sync.RWMutex
+	mu       sync.Mutex
 	cache    map[string]*cacheEntry
 	lruList  *list.List
 	maxFiles int
@@ -18,7 +24,13 @@ type FileCache struct {
 
 type cacheEntry struct {
 	file    *os.File
+	info    os.FileInfo // nil if opened with Get
 	element *list.Element
+
+	// refs counts the requests holding the file; dropped is set once it
+	// left the cache, to close it on the last release
+	refs    int
+	dropped bool
 }
 
 // NewFileCache creates a new file cache
@@ -30,36 +42,67 @@ func NewFileCache(maxFiles int) *FileCache {
 	}
 }
 
-// Get gets a file from cache or opens it
-func (fc *FileCache) Get(path string) (*os.File, error) {
-	fc.mu.RLock()
+// Get gets a file from cache or opens it. release must be called once
+// the file is no longer used.
+func (fc *FileCache) Get(path string) (file *os.File, release func(), err error) {
+	fc.mu.Lock()
 	if entry, ok := fc.cache[path]; ok {
-		fc.mu.RUnlock()
-
 		// Move to front (most recently used)
-		fc.mu.Lock()
 		fc.lruList.MoveToFront(entry.element)
+		entry.refs++
 		fc.mu.Unlock()
+		return entry.file, fc.releaser(entry), nil
+	}
+	fc.mu.Unlock()
+
+	return fc.open(path, nil)
+}
 
-		return entry.file, nil
+// Open gets the file at path from the cache if it is still the file info
+// (from os.Stat) describes, or opens it again: a file replaced or
+// modified since it was cached is not served from the stale descriptor.
+// release must be called once the file is no longer used.
+func (fc *FileCache) Open(path string, info os.FileInfo) (file *os.File, release func(), err error) {
+	fc.mu.Lock()
+	if entry, ok := fc.cache[path]; ok && entry.info != nil && sameFile(entry.info, info) {
+		fc.lruList.MoveToFront(entry.element)
+		entry.refs++
+		fc.mu.Unlock()
+		return entry.file, fc.releaser(entry), nil
 	}
-	fc.mu.RUnlock()
+	fc.mu.Unlock()
+
+	return fc.open(path, info)
+}
 
-	// Open new file
+// sameFile reports whether a and b describe the same, unmodified file
+func sameFile(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+// open opens path and caches it, replacing any previous entry
+func (fc *FileCache) open(path string, info os.FileInfo) (*os.File, func(), error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
 
+	if old, ok := fc.cache[path]; ok {
+		fc.drop(path, old)
+	}
+
 	// Add to cache
 	element := fc.lruList.PushFront(path)
-	fc.cache[path] = &cacheEntry{
+	entry := &cacheEntry{
 		file:    file,
+		info:    info,
 		element: element,
+		refs:    1,
 	}
+	fc.cache[path] = entry
 
 	// Evict oldest if over limit
 	if fc.lruList.Len() > fc.maxFiles {
@@ -67,26 +110,48 @@ func (fc *FileCache) Get(path string) (*os.File, error) {
 		if oldest != nil {
 			oldPath := oldest.Value.(string)
 			if oldEntry, ok := fc.cache[oldPath]; ok {
-				oldEntry.file.Close()
-				delete(fc.cache, oldPath)
+				fc.drop(oldPath, oldEntry)
 			}
-			fc.lruList.Remove(oldest)
 		}
 	}
 
-	return file, nil
+	return file, fc.releaser(entry), nil
 }
 
-// Close closes all cached files
+// drop removes entry from the cache, closing its file unless it is held
+func (fc *FileCache) drop(path string, entry *cacheEntry) {
+	delete(fc.cache, path)
+	fc.lruList.Remove(entry.element)
+	entry.dropped = true
+	if entry.refs == 0 {
+		entry.file.Close()
+	}
+}
+
+// releaser returns the function releasing one hold of entry
+func (fc *FileCache) releaser(entry *cacheEntry) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			fc.mu.Lock()
+			defer fc.mu.Unlock()
+			entry.refs--
+			if entry.refs == 0 && entry.dropped {
+				entry.file.Close()
+			}
+		})
+	}
+}
+
+// Close closes all cached files; files still held are closed when
+// released
 func (fc *FileCache) Close() {
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
 
-	for _, entry := range fc.cache {
-		entry.file.Close()
+	for path, entry := range fc.cache {
+		fc.drop(path, entry)
 	}
-	fc.cache = make(map[string]*cacheEntry)
-	fc.lruList.Init()
 }
 
 // Global file cache
@@ -94,25 +159,41 @@ var globalFileCache = NewFileCache(1000)
 
 // SendFile sends a file using zero-copy sendfile syscall
 func SendFile(connFd int, filePath string, offset int64, count int) (int, error) {
-	file, err := globalFileCache.Get(filePath)
+	file, release, err := globalFileCache.Get(filePath)
 	if err != nil {
 		return 0, err
 	}
+	defer release()
+	return Send(connFd, file, offset, count)
+}
 
-	// Get file descriptor
-	fileFd := int(file.Fd())
+// Open gets the file at path from the global cache (see FileCache.Open)
+func Open(path string, info os.FileInfo) (*os.File, func(), error) {
+	return globalFileCache.Open(path, info)
+}
 
+// Send sends count bytes of file, starting at offset, using the zero-copy
+// sendfile syscall. It does not wait for a full socket buffer: it
+// returns EAGAIN with the bytes sent so far, and the caller resumes once
+// the socket is writable. Fewer bytes and no error mean the file ended.
+func Send(connFd int, file *os.File, offset int64, count int) (int, error) {
 	// Use sendfile syscall for zero-copy
 	written := 0
 	for written < count {
-		n, err := syscall.Sendfile(connFd, fileFd, &offset, count-written)
+		n, err := netfd.Sendfile(connFd, file, &offset, count-written)
+		if n > 0 {
+			// Partial writes may come with EAGAIN (BSD)
+			written += n
+		}
 		if err != nil {
-			if err == syscall.EAGAIN || err == syscall.EINTR {
+			if err == syscall.EINTR {
 				continue
 			}
+			if err == syscall.EWOULDBLOCK {
+				err = syscall.EAGAIN
+			}
 			return written, err
 		}
-		written += n
 		if n == 0 {
 			break
 		}
@@ -123,7 +204,7 @@ func SendFile(connFd int, filePath string, offset int64, count int) (int, error)
 
 // GetContentType returns MIME type based on file extension
 func GetContentType(filename string) string {
-	ext := filepath.Ext(filename)
+	ext := strings.ToLower(filepath.Ext(filename))
 	switch ext {
 	case ".html", ".htm":
 		return "text/html; charset=utf-8"
@@ -153,9 +234,36 @@ func GetContentType(filename string) string {
 		return "application/gzip"
 	case ".txt":
 		return "text/plain; charset=utf-8"
-	default:
-		return "application/octet-stream"
+	case ".mjs":
+		return "text/javascript; charset=utf-8"
+	case ".map":
+		return "application/json; charset=utf-8"
+	case ".csv":
+		return "text/csv; charset=utf-8"
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	case ".wasm":
+		return "application/wasm"
+	case ".webp":
+		return "image/webp"
+	case ".avif":
+		return "image/avif"
+	case ".woff":
+		return "font/woff"
+	case ".woff2":
+		return "font/woff2"
+	case ".mp4":
+		return "video/mp4"
+	case ".webm":
+		return "video/webm"
+	case ".mp3":
+		return "audio/mpeg"
+	}
+	// Types registered with the mime package and the system tables
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
 	}
+	return "application/octet-stream"
 }
 
 // CloseFileCache closes the global file cache
@@ -0,0 +1,76 @@
+package sendfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFileCacheRelease 测试被淘汰或替换的文件在最后一次释放后才关闭
+func TestFileCacheRelease(t *testing.T) {
+	dir := t.TempDir()
+	paths := make([]string, 3)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, string(rune('a'+i)))
+		if err := os.WriteFile(paths[i], []byte("content"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stat := func(path string) os.FileInfo {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+	readable := func(f *os.File) bool {
+		_, err := f.ReadAt(make([]byte, 1), 0)
+		return err == nil
+	}
+
+	fc := NewFileCache(1)
+	held, release, err := fc.Open(paths[0], stat(paths[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, releaseAgain, _ := fc.Open(paths[0], stat(paths[0]))
+	if again != held {
+		t.Fatal("Expected the cached file")
+	}
+	releaseAgain()
+
+	// 淘汰不关闭仍在使用的文件
+	_, releaseB, _ := fc.Open(paths[1], stat(paths[1]))
+	if !readable(held) {
+		t.Fatal("Evicted file closed while held")
+	}
+	release()
+	release()
+	if readable(held) {
+		t.Error("Expected the evicted file closed on its last release")
+	}
+
+	// 未被持有的条目在淘汰时立即关闭
+	releaseB()
+	b, releaseB, _ := fc.Open(paths[1], stat(paths[1]))
+	releaseB()
+	_, releaseC, _ := fc.Open(paths[2], stat(paths[2]))
+	if readable(b) {
+		t.Error("Expected an unheld evicted file closed")
+	}
+
+	// Close 关闭缓存，仍被持有的文件在释放时关闭
+	c, releaseGet, _ := fc.Get(paths[2])
+	fc.Close()
+	if !readable(c) {
+		t.Fatal("Close closed a held file")
+	}
+	releaseC()
+	if !readable(c) {
+		t.Fatal("File closed while still held")
+	}
+	releaseGet()
+	if readable(c) {
+		t.Error("Expected the file closed on its last release")
+	}
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/searchktools/fast-server/core/netfd"
+	"github.com/searchktools/fast-server/core/rpc/protocol"
+)
+
+// SniffConfig selects the protocols served next to HTTP/1.1 on the
+// engine's port, told apart by the first bytes of each connection
+type SniffConfig struct {
+	// RPC serves connections that start with the RPC frame magic, e.g.
+	// an rpc server's ServeConn. It owns the connection. nil: such
+	// connections are answered as HTTP (400).
+	RPC func(conn net.Conn)
+
+	// TLSAddr is where connections opening with a TLS ClientHello are
+	// forwarded, typically the HTTPS listener (e.g. an alpn.Mux), so
+	// clients that used https:// on the plaintext port still reach the
+	// server. Empty: they get a handshake_failure alert and are closed.
+	TLSAddr string
+
+	// DialTimeout bounds connecting to TLSAddr. Default: 5s.
+	DialTimeout time.Duration
+}
+
+// SetSniffing makes the engine inspect the first bytes of connections
+// and hand RPC and TLS connections to cfg. It must be called before Run.
+func (e *Engine) SetSniffing(cfg SniffConfig) {
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+	e.sniff = &cfg
+}
+
+// Sniffed protocols
+const (
+	sniffMore = iota // Not enough bytes to tell
+	sniffHTTP
+	sniffRPC
+	sniffTLS
+)
+
+// sniffProtocol tells the protocol of a connection from its first bytes
+func sniffProtocol(b []byte) int {
+	var magic [4]byte
+	binary.BigEndian.PutUint32(magic[:], protocol.Magic)
+
+	switch {
+	case len(b) == 0:
+		return sniffMore
+	case b[0] == 0x16: // TLS handshake record, version 3.x
+		if len(b) < 3 {
+			if len(b) == 2 && b[1] != 0x03 {
+				return sniffHTTP
+			}
+			return sniffMore
+		}
+		if b[1] == 0x03 && b[2] <= 0x04 {
+			return sniffTLS
+		}
+	case bytes.HasPrefix(magic[:], b[:min(len(b), 4)]):
+		if len(b) < 4 {
+			return sniffMore
+		}
+		return sniffRPC
+	}
+	return sniffHTTP
+}
+
+// sniffConn inspects the bytes read from a new connection. It reports
+// false if the connection is not (or not yet) served as HTTP.
+func (e *Engine) sniffConn(conn *Connection) bool {
+	proto := sniffProtocol(conn.readBuf[:conn.readOffset])
+	switch {
+	case proto == sniffMore:
+		return false
+	case proto == sniffRPC && e.sniff.RPC != nil:
+		if nc := e.takeOver(conn); nc != nil {
+			go e.sniff.RPC(nc)
+		}
+		return false
+	case proto == sniffTLS && e.sniff.TLSAddr != "":
+		if nc := e.takeOver(conn); nc != nil {
+			go e.forwardTLS(nc)
+		}
+		return false
+	case proto == sniffTLS:
+		// Fatal handshake_failure alert: TLS clients cannot read HTTP
+		netfd.Write(conn.fd, []byte{0x15, 0x03, 0x01, 0x00, 0x02, 0x02, 0x28})
+		e.closeConnection(conn.fd)
+		return false
+	}
+	conn.sniffed = true
+	return true
+}
+
+// takeOver removes conn from the engine and returns it as a net.Conn
+// that first yields the bytes read so far (nil: it was closed)
+func (e *Engine) takeOver(conn *Connection) net.Conn {
+	nc, err := netfd.Conn(conn.fd)
+	read := append([]byte(nil), conn.readBuf[:conn.readOffset]...)
+	e.closeConnection(conn.fd) // nc holds its own descriptor
+	if err != nil {
+		return nil
+	}
+	return &prefixConn{Conn: nc, prefix: read}
+}
+
+// forwardTLS relays a TLS connection to the TLS listener
+func (e *Engine) forwardTLS(nc net.Conn) {
+	defer nc.Close()
+	upstream, err := net.DialTimeout("tcp", e.sniff.TLSAddr, e.sniff.DialTimeout)
+	if err != nil {
+		log.Printf("TLS forward to %s: %v", e.sniff.TLSAddr, err)
+		return
+	}
+	defer upstream.Close()
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(upstream, nc)
+		if tc, ok := upstream.(*net.TCPConn); ok {
+			tc.CloseWrite()
+		}
+		close(done)
+	}()
+	io.Copy(nc, upstream)
+	nc.Close()
+	<-done
+}
+
+// prefixConn returns bytes already read from a connection before reading
+// it
+type prefixConn struct {
+	net.Conn
+	prefix []byte
+}
+
+func (c *prefixConn) Read(p []byte) (int, error) {
+	if len(c.prefix) > 0 {
+		n := copy(p, c.prefix)
+		c.prefix = c.prefix[n:]
+		return n, nil
+	}
+	return c.Conn.Read(p)
+}
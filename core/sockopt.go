@@ -0,0 +1,77 @@
+package core
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// SocketOptions configures accepted connections. Zero values leave the
+// OS default in place, except where DefaultSocketOptions says otherwise.
+type SocketOptions struct {
+	NoDelay bool // TCP_NODELAY: disable Nagle's algorithm
+
+	KeepAlive         bool          // SO_KEEPALIVE
+	KeepAliveIdle     time.Duration // Idle time before the first probe
+	KeepAliveInterval time.Duration // Time between probes
+	KeepAliveCount    int           // Unanswered probes before the connection drops
+
+	RecvBuffer int // SO_RCVBUF in bytes
+	SendBuffer int // SO_SNDBUF in bytes
+
+	// DeferAccept wakes the accept loop only once a connection has data,
+	// waiting at most this long (Linux TCP_DEFER_ACCEPT; ignored elsewhere)
+	DeferAccept time.Duration
+}
+
+// DefaultSocketOptions returns the options used unless SetSocketOptions is called
+func DefaultSocketOptions() SocketOptions {
+	return SocketOptions{
+		NoDelay:       true,
+		KeepAlive:     true,
+		KeepAliveIdle: 30 * time.Second,
+	}
+}
+
+// SetSocketOptions sets the options applied to the listener and to every
+// accepted connection. It must be called before Run.
+func (e *Engine) SetSocketOptions(opts SocketOptions) {
+	e.sockOpts = opts
+}
+
+// applyListenerOptions configures the listening socket
+func (e *Engine) applyListenerOptions(lfd int) error {
+	if e.sockOpts.DeferAccept > 0 {
+		return setDeferAccept(lfd, seconds(e.sockOpts.DeferAccept))
+	}
+	return nil
+}
+
+// applyConnOptions configures an accepted connection. Failures are not
+// fatal: the connection works with the OS defaults.
+func (e *Engine) applyConnOptions(fd int) {
+	opts := &e.sockOpts
+
+	if opts.NoDelay {
+		netfd.SetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_NODELAY, 1)
+	}
+	if opts.RecvBuffer > 0 {
+		netfd.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF, opts.RecvBuffer)
+	}
+	if opts.SendBuffer > 0 {
+		netfd.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_SNDBUF, opts.SendBuffer)
+	}
+	if opts.KeepAlive {
+		netfd.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 1)
+		setKeepAliveTiming(fd, seconds(opts.KeepAliveIdle), seconds(opts.KeepAliveInterval), opts.KeepAliveCount)
+	}
+}
+
+// seconds rounds a positive duration up to whole seconds (0 stays 0)
+func seconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,25 @@
+//go:build darwin
+// +build darwin
+
+package core
+
+import "golang.org/x/sys/unix"
+
+// setKeepAliveTiming sets keepalive idle/interval (seconds) and probe count;
+// zero values keep the OS defaults
+func setKeepAliveTiming(fd, idle, interval, count int) {
+	if idle > 0 {
+		unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPALIVE, idle)
+	}
+	if interval > 0 {
+		unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, interval)
+	}
+	if count > 0 {
+		unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPCNT, count)
+	}
+}
+
+// setDeferAccept is a no-op: macOS has no TCP_DEFER_ACCEPT
+func setDeferAccept(lfd, secs int) error {
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build linux
+// +build linux
+
+package core
+
+import "golang.org/x/sys/unix"
+
+// setKeepAliveTiming sets keepalive idle/interval (seconds) and probe count;
+// zero values keep the OS defaults
+func setKeepAliveTiming(fd, idle, interval, count int) {
+	if idle > 0 {
+		unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPIDLE, idle)
+	}
+	if interval > 0 {
+		unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, interval)
+	}
+	if count > 0 {
+		unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPCNT, count)
+	}
+}
+
+// setDeferAccept enables TCP_DEFER_ACCEPT on the listener
+func setDeferAccept(lfd, secs int) error {
+	return unix.SetsockoptInt(lfd, unix.IPPROTO_TCP, unix.TCP_DEFER_ACCEPT, secs)
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package core
+
+// setKeepAliveTiming is a no-op: the emulated sockets keep the keepalive
+// timing chosen by the net package
+func setKeepAliveTiming(fd, idle, interval, count int) {}
+
+// setDeferAccept is a no-op: Windows has no TCP_DEFER_ACCEPT
+func setDeferAccept(lfd, secs int) error {
+	return nil
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"errors"
+	"hash/fnv"
+	"math/rand/v2"
+	nethttp "net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/observability"
+)
+
+// Variant is one handler of a traffic split and its share of requests
+type Variant struct {
+	Name    string
+	Weight  int
+	Handler HandlerFunc
+}
+
+// SplitConfig configures a traffic split
+type SplitConfig struct {
+	// Name prefixes the variant names in metrics (default: the route)
+	Name string
+
+	Variants []Variant
+
+	// StickyHeader hashes this request header (e.g. X-User-ID) to pick
+	// the variant, so a client always gets the same one
+	StickyHeader string
+
+	// StickyCookie does the same with a cookie. Clients without it get
+	// one holding a random ID.
+	StickyCookie string
+
+	// Monitor records latency and errors of each variant (optional)
+	Monitor *observability.PerformanceMonitor
+}
+
+// Split spreads a route's requests over weighted variants
+type Split struct {
+	cfg      SplitConfig
+	total    int
+	labels   []string // metric names: "Name [variant]"
+	served   []atomic.Uint64
+	variants []Variant
+}
+
+// NewSplit validates cfg and creates a Split
+func NewSplit(cfg SplitConfig) (*Split, error) {
+	if len(cfg.Variants) == 0 {
+		return nil, errors.New("split: no variants")
+	}
+	s := &Split{
+		cfg:      cfg,
+		variants: cfg.Variants,
+		labels:   make([]string, len(cfg.Variants)),
+		served:   make([]atomic.Uint64, len(cfg.Variants)),
+	}
+	for i, v := range cfg.Variants {
+		if v.Weight < 0 || v.Handler == nil {
+			return nil, errors.New("split: variant " + v.Name + " needs a handler and a non-negative weight")
+		}
+		s.total += v.Weight
+		s.labels[i] = cfg.Name + " [" + v.Name + "]"
+	}
+	if s.total == 0 {
+		return nil, errors.New("split: weights sum to zero")
+	}
+	return s, nil
+}
+
+// Split registers a route whose requests are spread over cfg.Variants by
+// weight. Variant metrics go to the observatory's monitor, so call
+// SetObservatory first.
+func (e *Engine) Split(method, path string, cfg SplitConfig, opts ...RouteOption) (*Split, error) {
+	if cfg.Name == "" {
+		cfg.Name = method + " " + path
+	}
+	if cfg.Monitor == nil && e.observatory != nil {
+		cfg.Monitor = e.observatory.Monitor
+	}
+	s, err := NewSplit(cfg)
+	if err != nil {
+		return nil, err
+	}
+	e.Handle(method, path, s.Handler(), opts...)
+	return s, nil
+}
+
+// Handler returns the handler serving the split route
+func (s *Split) Handler() HandlerFunc {
+	return func(ctx http.Context) {
+		i := s.pick(ctx)
+		s.served[i].Add(1)
+
+		if s.cfg.Monitor == nil {
+			s.variants[i].Handler(ctx)
+			return
+		}
+		start := time.Now()
+		s.variants[i].Handler(ctx)
+		failed := false
+		if fc, ok := ctx.(*http.FDContext); ok {
+			failed = fc.StatusCode() >= 500
+		}
+		s.cfg.Monitor.RecordRequest(s.labels[i], time.Since(start), failed)
+	}
+}
+
+// Served returns how many requests each variant served, by name
+func (s *Split) Served() map[string]uint64 {
+	served := make(map[string]uint64, len(s.variants))
+	for i, v := range s.variants {
+		served[v.Name] += s.served[i].Load()
+	}
+	return served
+}
+
+// pick chooses the variant for a request
+func (s *Split) pick(ctx http.Context) int {
+	var key string
+	switch {
+	case s.cfg.StickyHeader != "":
+		key = ctx.Header(s.cfg.StickyHeader)
+	case s.cfg.StickyCookie != "":
+		key = s.stickyCookie(ctx)
+	}
+
+	var n int
+	if key != "" {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		n = int(h.Sum64() % uint64(s.total))
+	} else {
+		n = rand.IntN(s.total)
+	}
+	for i, v := range s.variants {
+		if n < v.Weight {
+			return i
+		}
+		n -= v.Weight
+	}
+	return len(s.variants) - 1
+}
+
+// stickyCookie returns the client's sticky ID, assigning one if missing
+func (s *Split) stickyCookie(ctx http.Context) string {
+	fc, ok := ctx.(*http.FDContext)
+	if !ok {
+		return ""
+	}
+	if v, ok := fc.CookieValue(s.cfg.StickyCookie); ok && v != "" {
+		return v
+	}
+	id := strconv.FormatUint(rand.Uint64(), 36)
+	fc.SetCookie(&nethttp.Cookie{
+		Name:     s.cfg.StickyCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   30 * 24 * 3600,
+		HttpOnly: true,
+		SameSite: nethttp.SameSiteLaxMode,
+	})
+	return id
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/searchktools/fast-server/core/queue"
+	"github.com/searchktools/fast-server/core/replay"
 )
 
 // Event represents a Server-Sent Event
@@ -12,6 +15,8 @@ type Event struct {
 	Event string
 	Data  string
 	Retry int // milliseconds
+
+	seq uint64 // Position in the broker's replay buffer (0: not recorded)
 }
 
 // Client represents an SSE client connection
@@ -73,7 +78,7 @@ type Broker struct {
 	clients     sync.Map
 	newClients  chan *Client
 	deadClients chan *Client
-	messages    chan *Event
+	messages    *queue.Queue[*Event]
 
 	totalClients  int64
 	messagesCount int64
@@ -81,6 +86,8 @@ type Broker struct {
 
 	keepaliveInterval time.Duration
 	maxClients        int
+
+	replay *replay.Buffer
 }
 
 // NewBroker creates a new SSE broker
@@ -92,15 +99,19 @@ func NewBroker(maxClients int, keepaliveInterval time.Duration) *Broker {
 		keepaliveInterval = 30 * time.Second
 	}
 
+	// Without an overflow file New cannot fail
+	messages, _ := queue.New(queue.Config[*Event]{Capacity: 1024})
+
 	broker := &Broker{
 		newClients:        make(chan *Client, 100),
 		deadClients:       make(chan *Client, 100),
-		messages:          make(chan *Event, 1000),
+		messages:          messages,
 		keepaliveInterval: keepaliveInterval,
 		maxClients:        maxClients,
 	}
 
 	go broker.run()
+	go broker.messages.Run(broker.fanOut)
 	go broker.keepalive()
 
 	return broker
@@ -116,14 +127,36 @@ func (b *Broker) run() {
 		case client := <-b.deadClients:
 			b.clients.Delete(client.ID)
 			client.Close()
+		}
+	}
+}
 
-		case event := <-b.messages:
-			b.messagesCount++
-			b.broadcast(event)
+// fanOut sends published events to the clients
+func (b *Broker) fanOut(events []*Event) {
+	for _, event := range events {
+		b.messagesCount++
+		if b.replay != nil {
+			m := b.replay.Append(replay.Message{ID: event.ID, Event: event.Event, Data: event.Data})
+			event.ID, event.seq = m.ID, m.Seq
 		}
+		b.broadcast(event)
 	}
 }
 
+// SetReplay records published events in buf, so clients reconnecting
+// with Last-Event-ID get the events they missed (Handler.HandleResume)
+// and long-polling clients can read them (replay.LongPoll). Events
+// without an ID get their sequence number as ID. It must be called
+// before events are published.
+func (b *Broker) SetReplay(buf *replay.Buffer) {
+	b.replay = buf
+}
+
+// Replay returns the buffer set by SetReplay
+func (b *Broker) Replay() *replay.Buffer {
+	return b.replay
+}
+
 func (b *Broker) keepalive() {
 	ticker := time.NewTicker(b.keepaliveInterval)
 	defer ticker.Stop()
@@ -166,8 +199,10 @@ func (b *Broker) Unregister(client *Client) {
 	b.deadClients <- client
 }
 
+// Publish queues event for all clients. It does not block: when the
+// queue is full the event is dropped.
 func (b *Broker) Publish(event *Event) {
-	b.messages <- event
+	b.messages.Push(event)
 }
 
 func (b *Broker) PublishToClient(clientID string, event *Event) bool {
@@ -203,6 +238,7 @@ func (b *Broker) Stats() map[string]interface{} {
 		"current_clients":  b.ClientCount(),
 		"messages_sent":    b.messagesCount,
 		"messages_dropped": b.droppedCount,
+		"publish_dropped":  b.messages.Stats().Dropped,
 	}
 }
 
@@ -0,0 +1,14 @@
+package sse
+
+import "github.com/searchktools/fast-server/core/events"
+
+// Forward publishes topic's events to all of b's clients, converted by
+// encode (nil results are skipped), so publishers need not know the
+// broker. Close the subscription to stop.
+func Forward[T any](topic *events.Topic[T], b *Broker, encode func(T) *Event) *events.Subscription[T] {
+	return topic.Subscribe(0, func(v T) {
+		if event := encode(v); event != nil {
+			b.Publish(event)
+		}
+	})
+}
@@ -3,10 +3,14 @@ package sse
 import (
 	"fmt"
 	"time"
+
+	"github.com/searchktools/fast-server/core/connlimit"
+	"github.com/searchktools/fast-server/core/replay"
 )
 
 type Handler struct {
-	stream *Stream
+	stream  *Stream
+	limiter *connlimit.Limiter
 }
 
 func NewHandler(stream *Stream) *Handler {
@@ -15,12 +19,44 @@ func NewHandler(stream *Stream) *Handler {
 	}
 }
 
+// SetLimiter caps concurrent SSE streams and the subscribe rate per client IP
+func (h *Handler) SetLimiter(l *connlimit.Limiter) {
+	h.limiter = l
+}
+
 func (h *Handler) HandleConnection(clientID string, onEvent func([]byte) error, onClose func()) error {
+	return h.HandleConnectionFrom("", clientID, onEvent, onClose)
+}
+
+// HandleConnectionFrom is HandleConnection with the client's remote address
+// for per-IP limits. Refused streams return connlimit.ErrTooManyConnections
+// or connlimit.ErrUpgradeRateLimited before any event is sent.
+func (h *Handler) HandleConnectionFrom(remoteAddr, clientID string, onEvent func([]byte) error, onClose func()) error {
+	return h.HandleResume(remoteAddr, clientID, "", onEvent, onClose)
+}
+
+// HandleResume is HandleConnectionFrom for a client reconnecting with the
+// Last-Event-ID header: if the broker keeps a replay buffer (SetReplay)
+// still holding that event, the events published after it are sent
+// before live ones. Events the client's queue dropped are recovered from
+// the buffer as well.
+func (h *Handler) HandleResume(remoteAddr, clientID, lastEventID string, onEvent func([]byte) error, onClose func()) error {
+	release := func() {}
+	if h.limiter != nil {
+		r, err := h.limiter.Acquire(connlimit.SSE, remoteAddr)
+		if err != nil {
+			return err
+		}
+		release = r
+	}
+
 	client, err := h.stream.Subscribe(clientID)
 	if err != nil {
+		release()
 		return err
 	}
 	defer func() {
+		release()
 		h.stream.Unsubscribe(client)
 		if onClose != nil {
 			onClose()
@@ -36,6 +72,17 @@ func (h *Handler) HandleConnection(clientID string, onEvent func([]byte) error,
 		return err
 	}
 
+	buf := h.stream.broker.Replay()
+	var last uint64 // Seq of the last event sent
+	if buf != nil && lastEventID != "" {
+		if seq, ok := buf.Find(lastEventID); ok {
+			last = seq
+			if err := sendReplayed(buf, &last, 0, onEvent); err != nil {
+				return err
+			}
+		}
+	}
+
 	for {
 		select {
 		case event, ok := <-client.Channel:
@@ -43,6 +90,19 @@ func (h *Handler) HandleConnection(clientID string, onEvent func([]byte) error,
 				return nil
 			}
 
+			if event.seq != 0 {
+				if event.seq <= last {
+					continue // Replayed already
+				}
+				if last != 0 && event.seq > last+1 {
+					// Dropped from the client's queue or published while
+					// the client was registering
+					if err := sendReplayed(buf, &last, int(event.seq-last-1), onEvent); err != nil {
+						return err
+					}
+				}
+				last = event.seq
+			}
 			if err := onEvent(FormatEvent(event)); err != nil {
 				return err
 			}
@@ -53,6 +113,19 @@ func (h *Handler) HandleConnection(clientID string, onEvent func([]byte) error,
 	}
 }
 
+// sendReplayed sends up to max (<= 0: all) buffered events after *last
+func sendReplayed(buf *replay.Buffer, last *uint64, max int, onEvent func([]byte) error) error {
+	msgs, _ := buf.After(*last, nil, max)
+	for _, m := range msgs {
+		event := &Event{ID: m.ID, Event: m.Event, Data: m.Data}
+		if err := onEvent(FormatEvent(event)); err != nil {
+			return err
+		}
+		*last = m.Seq
+	}
+	return nil
+}
+
 func WriteSSEHeaders() map[string]string {
 	return map[string]string{
 		"Content-Type":      "text/event-stream",
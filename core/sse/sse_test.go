@@ -1,9 +1,12 @@
 package sse
 
 import (
+"errors"
 "strings"
 "testing"
 "time"
+
+"github.com/searchktools/fast-server/core/replay"
 )
 
 // TestBrokerBasic - Basic broker functionality
@@ -85,3 +88,32 @@ if event.Retry != 3000 {
 t.Errorf("Expected retry 3000, got %d", event.Retry)
 }
 }
+
+// TestHandleResume - Reconnecting clients get the events they missed
+func TestHandleResume(t *testing.T) {
+	broker := NewBroker(100, time.Hour)
+	broker.SetReplay(replay.NewBuffer(16))
+	stream := NewStream("test").WithBroker(broker)
+	for _, data := range []string{"a", "b", "c"} {
+		stream.Send("message", data)
+	}
+	for broker.Replay().Last() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var got []string
+	errDone := errors.New("done")
+	err := NewHandler(stream).HandleResume("", "c1", "test-1", func(b []byte) error {
+		got = append(got, string(b))
+		if len(got) == 3 {
+			return errDone
+		}
+		return nil
+	}, nil)
+	if err != errDone {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !strings.Contains(got[1], "id: test-2\n") || !strings.Contains(got[2], "data: c\n") {
+		t.Errorf("Expected events 2 and 3 after connecting, got %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"github.com/searchktools/fast-server/core/stats"
+)
+
+// ConnectionStats are the engine's connection counters
+type ConnectionStats struct {
+	Open      int    `json:"open"`
+	Max       int    `json:"max"`
+	Refused   uint64 `json:"refused"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// ConnectionStats returns the engine's connection counters
+func (e *Engine) ConnectionStats() ConnectionStats {
+	e.connMu.RLock()
+	open := len(e.connections)
+	e.connMu.RUnlock()
+	return ConnectionStats{
+		Open:      open,
+		Max:       e.maxConnections,
+		Refused:   e.refused.Load(),
+		Evictions: e.evictions.Load(),
+	}
+}
+
+// Stats returns the engine's metrics registry. It holds the connection,
+// memory pool and worker pool stats; other components (Hub, Broker, RPC
+// server, ...) register theirs to be exported along:
+//
+//	e.Stats().Register("websocket", stats.Func(hub.Stats))
+//	e.GET("/metrics", e.Stats().Handler())
+func (e *Engine) Stats() *stats.Registry {
+	return e.stats
+}
+
+// registerStats registers the engine's own providers
+func (e *Engine) registerStats() {
+	e.stats = stats.NewRegistry("fastserver")
+	e.stats.Register("connections", stats.Func(e.ConnectionStats))
+	e.stats.Register("pools", stats.Func(e.GetPoolStats))
+	e.stats.Register("workers", stats.Func(e.workerPool.Stats))
+}
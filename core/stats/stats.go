@@ -0,0 +1,198 @@
+// Package stats collects the metrics of every subsystem in one registry.
+// Components register named providers (usually their Stats method), and
+// the registry serves them together as JSON or in the Prometheus text
+// format instead of one bespoke endpoint per component.
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/searchktools/fast-server/core/http"
+)
+
+// Provider returns a component's current metrics: a struct or map that
+// encodes as JSON. Numeric and boolean fields, nested ones included,
+// become Prometheus metrics.
+type Provider func() any
+
+// Func adapts a typed Stats method: Func(hub.Stats)
+func Func[T any](fn func() T) Provider {
+	return func() any { return fn() }
+}
+
+// Registry is a set of named providers
+type Registry struct {
+	namespace string
+
+	mu        sync.RWMutex
+	providers map[string]Provider
+}
+
+// NewRegistry creates a registry whose Prometheus metrics are named
+// <namespace>_<component>_<field> ("fastserver_websocket_current_clients")
+func NewRegistry(namespace string) *Registry {
+	return &Registry{
+		namespace: namespace,
+		providers: make(map[string]Provider),
+	}
+}
+
+// Register adds a provider under name, replacing any provider of the
+// same name
+func (r *Registry) Register(name string, p Provider) {
+	r.mu.Lock()
+	r.providers[name] = p
+	r.mu.Unlock()
+}
+
+// Unregister removes the provider of name
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	delete(r.providers, name)
+	r.mu.Unlock()
+}
+
+// Names returns the names of the registered providers, sorted
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	slices.Sort(names)
+	return names
+}
+
+// Snapshot calls every provider and returns their metrics by name
+func (r *Registry) Snapshot() map[string]any {
+	r.mu.RLock()
+	providers := make(map[string]Provider, len(r.providers))
+	for name, p := range r.providers {
+		providers[name] = p
+	}
+	r.mu.RUnlock()
+
+	// Providers are called unlocked: they may take their own locks
+	snapshot := make(map[string]any, len(providers))
+	for name, p := range providers {
+		snapshot[name] = p()
+	}
+	return snapshot
+}
+
+// WriteJSON writes the snapshot as a JSON object keyed by provider name
+func (r *Registry) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.Snapshot())
+}
+
+// WritePrometheus writes the snapshot in the Prometheus text exposition
+// format. Metrics are untyped since providers do not tell counters from
+// gauges; strings and lists are left out.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	var buf bytes.Buffer
+	snapshot := r.Snapshot()
+	for _, name := range sortedKeys(snapshot) {
+		data, err := json.Marshal(snapshot[name])
+		if err != nil {
+			return err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber() // Keeps uint64 counters exact
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		writeMetrics(&buf, metricName(r.namespace, name), v)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeMetrics writes the numeric leaves of a decoded JSON value
+func writeMetrics(buf *bytes.Buffer, name string, v any) {
+	switch v := v.(type) {
+	case json.Number:
+		buf.WriteString(name)
+		buf.WriteByte(' ')
+		buf.WriteString(v.String())
+		buf.WriteByte('\n')
+	case bool:
+		buf.WriteString(name)
+		if v {
+			buf.WriteString(" 1\n")
+		} else {
+			buf.WriteString(" 0\n")
+		}
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			writeMetrics(buf, metricName(name, key), v[key])
+		}
+	}
+}
+
+// metricName joins prefix and a snake_cased key, replacing characters
+// Prometheus does not allow
+func metricName(prefix, key string) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	if prefix != "" {
+		b.WriteByte('_')
+	}
+	runes := []rune(key)
+	for i, c := range runes {
+		switch {
+		case unicode.IsUpper(c):
+			// "TasksPending" -> "tasks_pending", "HitRate" -> "hit_rate"
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(c))
+		case c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c)):
+			b.WriteRune(c)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// sortedKeys returns the keys of m, sorted
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Handler returns a handler serving the registry: JSON for ?format=json
+// or an Accept of application/json, the Prometheus text format otherwise
+func (r *Registry) Handler() func(ctx http.Context) {
+	return func(ctx http.Context) {
+		var buf bytes.Buffer
+		if ctx.Query("format") == "json" || strings.Contains(ctx.Header("Accept"), "application/json") {
+			if err := r.WriteJSON(&buf); err != nil {
+				ctx.Error(500, err.Error())
+				return
+			}
+			ctx.Data(200, "application/json", buf.Bytes())
+			return
+		}
+		if err := r.WritePrometheus(&buf); err != nil {
+			ctx.Error(500, err.Error())
+			return
+		}
+		ctx.Data(200, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+	}
+}
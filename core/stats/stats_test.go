@@ -0,0 +1,71 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type poolStats struct {
+	NumWorkers   int
+	TasksPending uint64
+	HitRate      float64
+	Closed       bool
+	Name         string
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry("fastserver")
+	r.Register("workers", Func(func() poolStats {
+		return poolStats{NumWorkers: 4, TasksPending: 1<<63 + 1, HitRate: 0.5, Name: "main"}
+	}))
+	r.Register("websocket", Func(func() map[string]any {
+		return map[string]any{"current_clients": 3, "rooms": map[string]int{"lobby": 2}}
+	}))
+
+	var prom bytes.Buffer
+	if err := r.WritePrometheus(&prom); err != nil {
+		t.Fatal(err)
+	}
+	want := `fastserver_websocket_current_clients 3
+fastserver_websocket_rooms_lobby 2
+fastserver_workers_closed 0
+fastserver_workers_hit_rate 0.5
+fastserver_workers_num_workers 4
+fastserver_workers_tasks_pending 9223372036854775809
+`
+	if prom.String() != want {
+		t.Errorf("Unexpected Prometheus output:\n%s", prom.String())
+	}
+
+	var js bytes.Buffer
+	if err := r.WriteJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]map[string]any
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["workers"]["Name"] != "main" || decoded["websocket"]["current_clients"] != 3.0 {
+		t.Errorf("Unexpected JSON output:\n%s", js.String())
+	}
+
+	r.Unregister("websocket")
+	if names := r.Names(); strings.Join(names, ",") != "workers" {
+		t.Errorf("Expected only workers after Unregister, got %v", names)
+	}
+}
+
+func TestMetricName(t *testing.T) {
+	for key, want := range map[string]string{
+		"TasksPending":   "x_tasks_pending",
+		"HTTPRequests":   "x_http_requests",
+		"tier_512b":      "x_tier_512b",
+		"p99.latency-ms": "x_p99_latency_ms",
+	} {
+		if got := metricName("x", key); got != want {
+			t.Errorf("metricName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/searchktools/fast-server/core/observability"
+)
+
+// SetTCPInfoSampling sets how often the engine reads TCP_INFO (RTT,
+// retransmits, congestion window) of every open connection into the
+// observatory's network stats. It needs an observatory and Linux; zero
+// disables sampling. The default is 10s.
+func (e *Engine) SetTCPInfoSampling(interval time.Duration) {
+	e.tcpInfoInterval = interval
+}
+
+// sampleTCPInfo periodically records connection quality while an
+// observatory is set
+func (e *Engine) sampleTCPInfo() {
+	if e.tcpInfoInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(e.tcpInfoInterval)
+	defer ticker.Stop()
+
+	var fds []int
+	for range ticker.C {
+		o := e.observatory
+		if o == nil {
+			continue
+		}
+
+		fds = fds[:0]
+		e.connMu.RLock()
+		for fd := range e.connections {
+			fds = append(fds, fd)
+		}
+		e.connMu.RUnlock()
+
+		for _, fd := range fds {
+			info, err := observability.ReadTCPInfo(fd)
+			if err != nil {
+				if errors.Is(err, errors.ErrUnsupported) {
+					log.Printf("⚠️  TCP_INFO sampling not supported on this platform")
+					return
+				}
+				continue // closed since
+			}
+			o.Tracer.TraceTCPInfo("tcp", fd, info)
+		}
+	}
+}
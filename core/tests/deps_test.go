@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestEngineDependencies keeps optional protocols out of the engine's
+// imports, so programs that do not use them do not link them
+func TestEngineDependencies(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go list")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+
+	optional := []string{
+		"github.com/searchktools/fast-server/core/http2",
+		"github.com/searchktools/fast-server/core/rpc/server",
+		"github.com/searchktools/fast-server/core/rpc/codec",
+		"github.com/searchktools/fast-server/core/websocket",
+		"github.com/searchktools/fast-server/core/sse",
+		"github.com/searchktools/fast-server/core/replay",
+		"github.com/searchktools/fast-server/core/alpn",
+	}
+	tagged := []string{"html/template", "google.golang.org/protobuf/proto"}
+
+	for _, tags := range []string{"", "fastserver_notemplate,fastserver_noprotobuf"} {
+		out, err := exec.Command("go", "list", "-deps", "-tags", tags, "github.com/searchktools/fast-server/core").Output()
+		if err != nil {
+			t.Fatalf("go list: %v", err)
+		}
+		deps := make(map[string]bool)
+		for _, dep := range strings.Fields(string(out)) {
+			deps[dep] = true
+		}
+
+		excluded := optional
+		if tags != "" {
+			excluded = append(excluded, tagged...)
+		}
+		for _, pkg := range excluded {
+			if deps[pkg] {
+				t.Errorf("core depends on %s (tags %q)", pkg, tags)
+			}
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/searchktools/fast-server/core"
+	fshttp "github.com/searchktools/fast-server/core/http"
+	"github.com/searchktools/fast-server/core/router"
+)
+
+// startEngine runs an engine configured by setup on a free port and
+// returns its address. The engine runs until the test binary exits.
+func startEngine(t *testing.T, setup func(e *core.Engine)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	e := core.NewEngine()
+	e.SetBootLog(core.BootLogOff)
+	setup(e)
+	go e.Run(addr)
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return addr
+		}
+	}
+	t.Fatal("engine did not start")
+	return ""
+}
+
+// roundTrip sends a raw request and reads the response
+func roundTrip(t *testing.T, addr, req string) *http.Response {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("reading the response: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// TestEngineHeaderLimit tests that header blocks larger than the read
+// buffer are read up to SetMaxHeaderBytes and answered 431 past it
+func TestEngineHeaderLimit(t *testing.T) {
+	handler := func(ctx fshttp.Context) { ctx.String(200, "ok") }
+	byDefault := startEngine(t, func(e *core.Engine) {
+		e.GET("/", handler)
+	})
+	raised := startEngine(t, func(e *core.Engine) {
+		e.SetMaxHeaderBytes(64 << 10)
+		e.GET("/", handler)
+	})
+
+	request := func(headerBytes int) string {
+		return "GET / HTTP/1.1\r\nHost: x\r\nX-Big: " + strings.Repeat("a", headerBytes) + "\r\n\r\n"
+	}
+	tests := []struct {
+		addr        string
+		headerBytes int
+		want        int
+	}{
+		{byDefault, 4 << 10, 200},
+		{byDefault, 10 << 10, 431},
+		{raised, 10 << 10, 200},
+		{raised, 60 << 10, 200},
+		{raised, 100 << 10, 431},
+	}
+	for _, tt := range tests {
+		if resp := roundTrip(t, tt.addr, request(tt.headerBytes)); resp.StatusCode != tt.want {
+			t.Errorf("%s with a %d byte header: got %d, expected %d", tt.addr, tt.headerBytes, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+// TestEngineRouteBodyLimit tests that a route's MaxBodyBytes applies to
+// chunked bodies as well as to a Content-Length
+func TestEngineRouteBodyLimit(t *testing.T) {
+	addr := startEngine(t, func(e *core.Engine) {
+		e.POST("/upload", func(ctx fshttp.Context) { ctx.String(200, "ok") }, core.MaxBodyBytes(16))
+	})
+
+	chunked := func(body string) string {
+		return "POST /upload HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n" +
+			strconv.FormatInt(int64(len(body)), 16) + "\r\n" + body + "\r\n0\r\n\r\n"
+	}
+	sized := func(body string) string {
+		return "POST /upload HTTP/1.1\r\nHost: x\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+	}
+	tests := []struct {
+		name string
+		req  string
+		want int
+	}{
+		{"small chunked", chunked("hello"), 200},
+		{"large chunked", chunked(strings.Repeat("a", 64)), 413},
+		{"small sized", sized("hello"), 200},
+		{"large sized", sized(strings.Repeat("a", 64)), 413},
+	}
+	for _, tt := range tests {
+		if resp := roundTrip(t, addr, tt.req); resp.StatusCode != tt.want {
+			t.Errorf("%s: got %d, expected %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+// TestEngineChunkedSpill tests that a chunked body larger than the read
+// buffers allow is decoded into the BodyStore, with its trailer and the
+// request pipelined after it intact
+func TestEngineChunkedSpill(t *testing.T) {
+	addr := startEngine(t, func(e *core.Engine) {
+		e.SetBodyStore(fshttp.NewDiskStore(t.TempDir()), 64<<10)
+		e.POST("/upload", func(ctx fshttp.Context) {
+			fc := ctx.(*fshttp.FDContext)
+			r, err := fc.BodyReader()
+			if err != nil {
+				ctx.String(500, err.Error())
+				return
+			}
+			defer r.Close()
+			h := sha256.New()
+			n, _ := io.Copy(h, r)
+			ctx.String(200, fmt.Sprintf("%d %x %s", n, h.Sum(nil), fc.Trailer("X-Checksum")))
+		})
+		e.GET("/next", func(ctx fshttp.Context) { ctx.String(200, "next") })
+	})
+
+	body := make([]byte, 8<<20)
+	for i := range body {
+		body[i] = byte(i * 7)
+	}
+	var req bytes.Buffer
+	req.WriteString("POST /upload HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n")
+	for rest := body; len(rest) > 0; {
+		n := min(len(rest), 100<<10+3)
+		fmt.Fprintf(&req, "%x;ext=1\r\n", n)
+		req.Write(rest[:n])
+		req.WriteString("\r\n")
+		rest = rest[n:]
+	}
+	req.WriteString("0\r\nX-Checksum: abc\r\n\r\nGET /next HTTP/1.1\r\nHost: x\r\n\r\n")
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	go conn.Write(req.Bytes())
+
+	br := bufio.NewReader(conn)
+	sum := sha256.Sum256(body)
+	for _, want := range []string{fmt.Sprintf("%d %x abc", len(body), sum), "next"} {
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatalf("reading the response: %v", err)
+		}
+		got, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != 200 || string(got) != want {
+			t.Errorf("Got %d %q, expected 200 %q", resp.StatusCode, got, want)
+		}
+	}
+}
+
+// TestEngineRouteLimitsEarly tests that route size limits are applied once
+// the header arrives, without waiting for a body that is never sent, and
+// only to the paths the route matches
+func TestEngineRouteLimitsEarly(t *testing.T) {
+	handler := func(ctx fshttp.Context) { ctx.String(200, "ok") }
+	addr := startEngine(t, func(e *core.Engine) {
+		e.SetBodyStore(fshttp.NewDiskStore(t.TempDir()), 1<<10)
+		e.POST("/files/new", handler)
+		e.POST("/files/:id", handler, core.MaxBodyBytes(16), core.MaxHeaderBytes(512))
+		api := e.Group("/api").With(core.MaxBodyBytes(16))
+		api.POST("/upload", handler)
+	})
+
+	header := func(path, fields string) string {
+		return "POST " + path + " HTTP/1.1\r\nHost: x\r\n" + fields + "\r\n"
+	}
+	tests := []struct {
+		name string
+		req  string
+		want int
+	}{
+		{"large sized, body not sent", header("/files/1", "Content-Length: 1048576\r\n"), 413},
+		{"spooled size, body not sent", header("/files/1", "Content-Length: 4096\r\n"), 413},
+		{"large header, body not sent", header("/files/1", "X-Big: "+strings.Repeat("a", 1024)+"\r\nContent-Length: 8\r\n"), 431},
+		{"chunked, rest not sent", header("/files/1", "Transfer-Encoding: chunked\r\n") + "10000\r\n" + strings.Repeat("a", 64<<10), 413},
+		{"group route", header("/api/upload", "Content-Length: 1048576\r\n"), 413},
+		{"static sibling", header("/files/new", "Content-Length: 64\r\n") + strings.Repeat("a", 64), 200},
+		{"within the limits", header("/files/1", "Content-Length: 5\r\n") + "hello", 200},
+	}
+	for _, tt := range tests {
+		if resp := roundTrip(t, addr, tt.req); resp.StatusCode != tt.want {
+			t.Errorf("%s: got %d, expected %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+// TestEngineSlowReader tests that a response waiting on a slow reader is
+// bounded by the write timeout, not the shorter idle timeout
+func TestEngineSlowReader(t *testing.T) {
+	body := make([]byte, 2<<20)
+	addr := startEngine(t, func(e *core.Engine) {
+		opts := core.DefaultSocketOptions()
+		opts.SendBuffer = 64 << 10
+		e.SetSocketOptions(opts)
+		e.SetIdleTimeout(time.Second)
+		e.SetWriteTimeout(5 * time.Second)
+		e.GET("/large", func(ctx fshttp.Context) { ctx.Bytes(200, body) })
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.(*net.TCPConn).SetReadBuffer(64 << 10)
+	if _, err := conn.Write([]byte("GET /large HTTP/1.1\r\nHost: x\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Past the idle timeout and a sweep, within the write timeout
+	time.Sleep(2500 * time.Millisecond)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("reading the response: %v", err)
+	}
+	defer resp.Body.Close()
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil || n != int64(len(body)) {
+		t.Errorf("Read %d of %d bytes (%v)", n, len(body), err)
+	}
+}
+
+// TestEngineGroup tests that engine groups register through the router,
+// which lists them, and that aborting group middleware stops the chain
+func TestEngineGroup(t *testing.T) {
+	var groups []router.GroupInfo
+	addr := startEngine(t, func(e *core.Engine) {
+		auth := func(ctx *fshttp.FDContext) {
+			if ctx.Header("X-Token") == "" {
+				ctx.String(401, "unauthorized")
+				ctx.Abort()
+			}
+		}
+		api := e.Group("api/", auth)
+		api.GET("/status", func(ctx fshttp.Context) { ctx.String(200, "ok") })
+		v1 := api.Group("/v1")
+		v1.POST("users", func(ctx fshttp.Context) { ctx.String(201, "created") })
+		groups = e.Groups()
+	})
+
+	if len(groups) != 2 || groups[0].Prefix != "/api" || groups[1].Prefix != "/api/v1" {
+		t.Fatalf("unexpected groups %+v", groups)
+	}
+	if groups[1].Middleware != 1 || len(groups[1].Routes) != 1 || groups[1].Routes[0] != (router.Route{Method: "POST", Path: "/api/v1/users"}) {
+		t.Errorf("unexpected nested group %+v", groups[1])
+	}
+
+	tests := []struct {
+		req  string
+		want int
+	}{
+		{"GET /api/status HTTP/1.1\r\nHost: x\r\nX-Token: t\r\n\r\n", 200},
+		{"GET /api/status HTTP/1.1\r\nHost: x\r\n\r\n", 401},
+		{"POST /api/v1/users HTTP/1.1\r\nHost: x\r\nX-Token: t\r\nContent-Length: 0\r\n\r\n", 201},
+		{"POST /api/v1/users HTTP/1.1\r\nHost: x\r\nContent-Length: 0\r\n\r\n", 401},
+	}
+	for _, tt := range tests {
+		if resp := roundTrip(t, addr, tt.req); resp.StatusCode != tt.want {
+			t.Errorf("%q: got %d, expected %d", strings.SplitN(tt.req, "\r\n", 2)[0], resp.StatusCode, tt.want)
+		}
+	}
+}
+
+// TestEngineRedirects tests trailing-slash and fixed-path redirects, their
+// status codes and the Location they send
+func TestEngineRedirects(t *testing.T) {
+	handler := func(ctx fshttp.Context) { ctx.String(200, "ok") }
+	routes := func(e *core.Engine) {
+		e.GET("/users", handler)
+		e.POST("/users", handler)
+		e.GET("/docs/", handler)
+		e.GET("/b", handler)
+		e.GET("/x", handler)
+	}
+	slash := startEngine(t, func(e *core.Engine) {
+		e.SetRedirectTrailingSlash(true)
+		routes(e)
+	})
+	fixed := startEngine(t, func(e *core.Engine) {
+		e.SetRedirectFixedPath(true)
+		routes(e)
+	})
+	both := startEngine(t, func(e *core.Engine) {
+		e.SetRedirectTrailingSlash(true)
+		e.SetRedirectFixedPath(true)
+		routes(e)
+	})
+	off := startEngine(t, routes)
+	// Paths a browser would read as another host in a Location header
+	hosts := startEngine(t, func(e *core.Engine) {
+		e.SetRedirectTrailingSlash(true)
+		e.GET("/:page", handler)
+		e.GET("/:page/:sub", handler)
+	})
+
+	tests := []struct {
+		name         string
+		addr         string
+		method, path string
+		want         int
+		location     string
+	}{
+		{"remove trailing slash", slash, "GET", "/users/", 301, "/users"},
+		{"add trailing slash", slash, "GET", "/docs", 301, "/docs/"},
+		{"HEAD", slash, "HEAD", "/users/", 301, "/users"},
+		{"POST keeps the method", slash, "POST", "/users/", 308, "/users"},
+		{"keep the query", slash, "GET", "/users/?page=2&sort=name", 301, "/users?page=2&sort=name"},
+		{"no route either way", slash, "GET", "/missing/", 404, ""},
+		{"slash off", off, "GET", "/users/", 404, ""},
+		{"dot dot", fixed, "GET", "/a/../b", 301, "/b"},
+		{"double slash", fixed, "GET", "//x", 301, "/x"},
+		{"fixed keeps the query", fixed, "POST", "/a/../users?q=1", 308, "/users?q=1"},
+		{"fixed alone keeps the slash", fixed, "GET", "/a/../users/", 404, ""},
+		{"fixed and slash", both, "GET", "/a/../users/", 301, "/users"},
+		{"double slash host", hosts, "GET", "//evil.com/", 301, "/evil.com"},
+		{"backslash host", hosts, "GET", "/\\evil.com/", 301, "/evil.com"},
+	}
+	for _, tt := range tests {
+		req := tt.method + " " + tt.path + " HTTP/1.1\r\nHost: x\r\nContent-Length: 0\r\n\r\n"
+		resp := roundTrip(t, tt.addr, req)
+		if resp.StatusCode != tt.want || resp.Header.Get("Location") != tt.location {
+			t.Errorf("%s: got %d to %q, expected %d to %q", tt.name, resp.StatusCode, resp.Header.Get("Location"), tt.want, tt.location)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/searchktools/fast-server/core/netfd"
+)
+
+// TuningAdvice is a single file-limit or kernel setting recommendation
+type TuningAdvice struct {
+	Setting   string // e.g. "RLIMIT_NOFILE", "net.core.somaxconn"
+	Current   int64
+	Suggested int64
+	Fix       string // Command that applies the suggestion
+}
+
+// kernelSetting is a /proc/sys tunable with a recommended minimum
+type kernelSetting struct {
+	name    string
+	path    string
+	minimum int64
+}
+
+var kernelSettings = []kernelSetting{
+	{"net.core.somaxconn", "/proc/sys/net/core/somaxconn", 4096},
+	{"net.ipv4.tcp_max_syn_backlog", "/proc/sys/net/ipv4/tcp_max_syn_backlog", 4096},
+	{"net.ipv4.tcp_tw_reuse", "/proc/sys/net/ipv4/tcp_tw_reuse", 1},
+	{"net.ipv4.tcp_syncookies", "/proc/sys/net/ipv4/tcp_syncookies", 1},
+}
+
+// SetAutoRaiseNoFile makes Run raise the RLIMIT_NOFILE soft limit to the
+// hard limit when it is below maxConnections
+func (e *Engine) SetAutoRaiseNoFile(enabled bool) {
+	e.autoRaiseNoFile = enabled
+}
+
+// CheckTuning inspects file descriptor limits and kernel network settings
+// and returns recommendations for anything below what the engine needs.
+// Settings that cannot be read on this platform are skipped.
+func (e *Engine) CheckTuning() []TuningAdvice {
+	var advice []TuningAdvice
+
+	if cur, _, err := netfd.FileLimit(); err == nil {
+		want := uint64(e.maxConnections)
+		if cur < want {
+			advice = append(advice, TuningAdvice{
+				Setting:   "RLIMIT_NOFILE",
+				Current:   int64(cur),
+				Suggested: int64(want),
+				Fix:       "ulimit -n " + strconv.FormatUint(want, 10),
+			})
+		}
+	}
+
+	for _, ks := range kernelSettings {
+		current, ok := readProcInt(ks.path)
+		if !ok || current >= ks.minimum {
+			continue
+		}
+		advice = append(advice, TuningAdvice{
+			Setting:   ks.name,
+			Current:   current,
+			Suggested: ks.minimum,
+			Fix:       "sysctl -w " + ks.name + "=" + strconv.FormatInt(ks.minimum, 10),
+		})
+	}
+
+	return advice
+}
+
+// adviseTuning logs tuning warnings at startup, raising the soft file
+// limit first when auto-raise is enabled
+func (e *Engine) adviseTuning() {
+	if e.autoRaiseNoFile {
+		raiseNoFileLimit()
+	}
+
+	for _, a := range e.CheckTuning() {
+		log.Printf("⚠️  tuning setting=%s current=%d suggested=%d fix=%q",
+			a.Setting, a.Current, a.Suggested, a.Fix)
+	}
+}
+
+// raiseNoFileLimit raises the RLIMIT_NOFILE soft limit to the hard limit
+func raiseNoFileLimit() {
+	cur, max, err := netfd.FileLimit()
+	if err != nil || cur >= max {
+		return
+	}
+
+	if err := netfd.SetFileLimit(max); err != nil {
+		log.Printf("⚠️  tuning: failed to raise RLIMIT_NOFILE: %v", err)
+		return
+	}
+	log.Printf("🔧 tuning: raised RLIMIT_NOFILE soft limit %d -> %d", cur, max)
+}
+
+// readProcInt reads a single integer from a /proc/sys file
+func readProcInt(path string) (int64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
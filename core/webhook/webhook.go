@@ -0,0 +1,374 @@
+// Package webhook delivers events to subscriber URLs: a bounded queue
+// drained by a few workers over a pooled HTTP client, HMAC-signed
+// requests, exponential backoff retries and a dead-letter list for events
+// that could not be delivered.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	nethttp "net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned by Send when the queue has no room
+	ErrQueueFull = errors.New("webhook: queue full")
+
+	// ErrClosed is returned by Send after Close
+	ErrClosed = errors.New("webhook: dispatcher closed")
+)
+
+// Config configures a Dispatcher
+type Config struct {
+	// Secret signs every request (see Sign). Empty sends unsigned requests.
+	Secret []byte
+
+	// MaxAttempts is how many times an event is tried before it is
+	// dead-lettered. Default: 5.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry; each retry
+	// doubles it, with jitter, up to MaxBackoff. Defaults: 1s and 5m.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Timeout bounds each attempt. Default: 10s.
+	Timeout time.Duration
+
+	// QueueSize is how many events may wait for a worker. Default: 1024.
+	QueueSize int
+
+	// Workers is the number of concurrent deliveries. Default: 4.
+	Workers int
+
+	// Client sends the requests. Default: a client with a pooled
+	// keep-alive transport.
+	Client *nethttp.Client
+
+	// DeadLetter is called when an event is given up on (optional).
+	// Dead-lettered deliveries are also kept for DeadLetters.
+	DeadLetter func(d *Delivery)
+
+	// MaxDeadLetters is how many dead-lettered deliveries are kept,
+	// oldest dropped first. Default: 1000.
+	MaxDeadLetters int
+}
+
+// Event is a payload to deliver to a URL
+type Event struct {
+	ID      string            // Unique ID, sent as Webhook-Id (default: random)
+	Type    string            // Sent as Webhook-Type (optional)
+	URL     string            // Subscriber endpoint
+	Payload []byte            // Request body, sent as JSON
+	Header  map[string]string // Extra request headers (optional)
+}
+
+// Delivery is an event and its delivery attempts so far
+type Delivery struct {
+	Event
+	Attempts   int
+	LastStatus int   // Status of the last response (0: no response)
+	LastError  error // Why the last attempt failed
+	Created    time.Time
+}
+
+// Stats are delivery counters
+type Stats struct {
+	Queued       uint64        // Events accepted by Send
+	Delivered    uint64        // Events answered with a 2xx
+	Attempts     uint64        // Requests sent
+	Retries      uint64        // Attempts scheduled after a failure
+	DeadLettered uint64        // Events given up on
+	Dropped      uint64        // Events refused (queue full) or abandoned at Close
+	AvgLatency   time.Duration // Mean request duration
+}
+
+// Dispatcher queues and delivers webhook events
+type Dispatcher struct {
+	cfg   Config
+	queue chan *Delivery
+	done  chan struct{}
+
+	// pending counts events not yet delivered, dead-lettered or dropped
+	pending sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	dead   []*Delivery
+
+	stats struct {
+		queued, delivered, attempts, retries atomic.Uint64
+		deadLettered, dropped                atomic.Uint64
+		latency                              atomic.Int64
+	}
+}
+
+// New creates a Dispatcher and starts its workers
+func New(cfg Config) *Dispatcher {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.MaxDeadLetters <= 0 {
+		cfg.MaxDeadLetters = 1000
+	}
+	if cfg.Client == nil {
+		cfg.Client = &nethttp.Client{
+			Timeout: cfg.Timeout,
+			Transport: &nethttp.Transport{
+				Proxy:               nethttp.ProxyFromEnvironment,
+				MaxIdleConns:        100,
+				MaxIdleConnsPerHost: cfg.Workers,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		}
+	}
+
+	d := &Dispatcher{
+		cfg:   cfg,
+		queue: make(chan *Delivery, cfg.QueueSize),
+		done:  make(chan struct{}),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+// Send queues e for delivery. It does not block: a full queue returns
+// ErrQueueFull.
+func (d *Dispatcher) Send(e Event) error {
+	if e.ID == "" {
+		e.ID = newID()
+	}
+	del := &Delivery{Event: e, Created: time.Now()}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	d.pending.Add(1)
+	select {
+	case d.queue <- del:
+		d.stats.queued.Add(1)
+		return nil
+	default:
+		d.pending.Done()
+		d.stats.dropped.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting events and waits until the queued ones, retries
+// included, are delivered or dead-lettered, or ctx ends. Events still
+// pending then are dropped.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	d.mu.Unlock()
+
+	idle := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(idle)
+	}()
+
+	select {
+	case <-idle:
+		close(d.done)
+		return nil
+	case <-ctx.Done():
+		close(d.done)
+		for {
+			select {
+			case <-d.queue:
+				d.stats.dropped.Add(1)
+				d.pending.Done()
+			default:
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// DeadLetters returns the kept dead-lettered deliveries, oldest first
+func (d *Dispatcher) DeadLetters() []*Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*Delivery(nil), d.dead...)
+}
+
+// Stats returns the delivery counters
+func (d *Dispatcher) Stats() Stats {
+	s := Stats{
+		Queued:       d.stats.queued.Load(),
+		Delivered:    d.stats.delivered.Load(),
+		Attempts:     d.stats.attempts.Load(),
+		Retries:      d.stats.retries.Load(),
+		DeadLettered: d.stats.deadLettered.Load(),
+		Dropped:      d.stats.dropped.Load(),
+	}
+	if s.Attempts > 0 {
+		s.AvgLatency = time.Duration(d.stats.latency.Load() / int64(s.Attempts))
+	}
+	return s
+}
+
+// worker delivers queued events until the dispatcher is closed
+func (d *Dispatcher) worker() {
+	for {
+		select {
+		case del := <-d.queue:
+			d.attempt(del)
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// attempt sends del once and settles or reschedules it
+func (d *Dispatcher) attempt(del *Delivery) {
+	del.Attempts++
+	retryAfter, err := d.post(del)
+	if err == nil {
+		d.stats.delivered.Add(1)
+		d.pending.Done()
+		return
+	}
+	del.LastError = err
+
+	if del.Attempts >= d.cfg.MaxAttempts || !retryable(del.LastStatus) {
+		d.deadLetter(del)
+		return
+	}
+
+	d.stats.retries.Add(1)
+	delay := max(d.backoff(del.Attempts), retryAfter)
+	time.AfterFunc(delay, func() {
+		select {
+		case <-d.done:
+		default:
+			select {
+			case d.queue <- del:
+				return
+			case <-d.done:
+			}
+		}
+		d.stats.dropped.Add(1)
+		d.pending.Done()
+	})
+}
+
+// post sends one request. It returns the delay asked for by a
+// Retry-After header along with any failure.
+func (d *Dispatcher) post(del *Delivery) (time.Duration, error) {
+	req, err := nethttp.NewRequest("POST", del.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		// A malformed URL never succeeds
+		del.LastStatus = nethttp.StatusBadRequest
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range del.Header {
+		req.Header.Set(k, v)
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Webhook-Id", del.ID)
+	req.Header.Set("Webhook-Timestamp", ts)
+	if del.Type != "" {
+		req.Header.Set("Webhook-Type", del.Type)
+	}
+	if len(d.cfg.Secret) > 0 {
+		req.Header.Set("Webhook-Signature", Sign(d.cfg.Secret, del.ID, ts, del.Payload))
+	}
+
+	start := time.Now()
+	resp, err := d.cfg.Client.Do(req)
+	d.stats.attempts.Add(1)
+	d.stats.latency.Add(int64(time.Since(start)))
+	if err != nil {
+		del.LastStatus = 0
+		return 0, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	del.LastStatus = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+	var retryAfter time.Duration
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		retryAfter = min(time.Duration(secs)*time.Second, d.cfg.MaxBackoff)
+	}
+	return retryAfter, fmt.Errorf("webhook: %s answered %d", del.URL, resp.StatusCode)
+}
+
+// backoff returns the delay before retry n (1-based): InitialBackoff
+// doubled n-1 times, capped at MaxBackoff, with up to 20% jitter
+func (d *Dispatcher) backoff(n int) time.Duration {
+	delay := d.cfg.InitialBackoff
+	for i := 1; i < n && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.cfg.MaxBackoff)
+	return delay - time.Duration(rand.Int64N(int64(delay)/5+1))
+}
+
+// deadLetter gives up on del
+func (d *Dispatcher) deadLetter(del *Delivery) {
+	d.stats.deadLettered.Add(1)
+	log.Printf("⚠️  webhook: giving up on %s to %s after %d attempts: %v", del.ID, del.URL, del.Attempts, del.LastError)
+
+	d.mu.Lock()
+	if len(d.dead) >= d.cfg.MaxDeadLetters {
+		d.dead = append(d.dead[:0], d.dead[1:]...)
+	}
+	d.dead = append(d.dead, del)
+	d.mu.Unlock()
+
+	if d.cfg.DeadLetter != nil {
+		d.cfg.DeadLetter(del)
+	}
+	d.pending.Done()
+}
+
+// retryable reports whether a failure with status (0: no response) may
+// succeed later. Other 4xx responses reject the request itself.
+func retryable(status int) bool {
+	return status == 0 || status == 408 || status == 429 || status >= 500
+}
+
+// newID returns a random event ID
+func newID() string {
+	return "evt_" + crand.Text()
+}
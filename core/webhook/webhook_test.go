@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatcherRetriesAndSigns(t *testing.T) {
+	secret := []byte("s3cret")
+	var calls atomic.Int32
+	var verified atomic.Bool
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if calls.Add(1) < 3 {
+			w.WriteHeader(503)
+			return
+		}
+		err := Verify(secret, r.Header.Get("Webhook-Id"), r.Header.Get("Webhook-Timestamp"),
+			r.Header.Get("Webhook-Signature"), body, time.Minute)
+		verified.Store(err == nil)
+	}))
+	defer srv.Close()
+
+	d := New(Config{Secret: secret, InitialBackoff: time.Millisecond, Workers: 1})
+	if err := d.Send(Event{Type: "order.paid", URL: srv.URL, Payload: []byte(`{"id":1}`)}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	st := d.Stats()
+	if st.Delivered != 1 || st.Attempts != 3 || st.Retries != 2 {
+		t.Errorf("Unexpected stats %+v", st)
+	}
+	if !verified.Load() {
+		t.Error("Signature should verify on the receiving side")
+	}
+	if err := d.Send(Event{URL: srv.URL}); err != ErrClosed {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestDispatcherDeadLetter(t *testing.T) {
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.WriteHeader(410)
+	}))
+	defer srv.Close()
+
+	var dead atomic.Int32
+	d := New(Config{InitialBackoff: time.Millisecond, DeadLetter: func(*Delivery) { dead.Add(1) }})
+	d.Send(Event{ID: "evt-gone", URL: srv.URL})
+	d.Close(context.Background())
+
+	letters := d.DeadLetters()
+	if len(letters) != 1 || letters[0].ID != "evt-gone" || letters[0].LastStatus != 410 {
+		t.Fatalf("Expected one dead letter with status 410, got %+v", letters)
+	}
+	if letters[0].Attempts != 1 || dead.Load() != 1 {
+		t.Errorf("A 4xx should be dead-lettered without retries, got %d attempts", letters[0].Attempts)
+	}
+}
+
+func TestVerifyRejectsForgery(t *testing.T) {
+	secret := []byte("k")
+	ts := "1700000000"
+	sig := Sign(secret, "evt-1", ts, []byte("{}"))
+
+	if err := Verify(secret, "evt-1", ts, "v1,old "+sig, []byte("{}"), 0); err != nil {
+		t.Errorf("One matching signature should verify, got %v", err)
+	}
+	if err := Verify(secret, "evt-1", ts, sig, []byte(`{"x":1}`), 0); err != ErrBadSignature {
+		t.Error("Tampered payload should not verify")
+	}
+	if err := Verify(secret, "evt-1", ts, sig, []byte("{}"), time.Minute); err != ErrBadSignature {
+		t.Error("Stale timestamp should not verify")
+	}
+}
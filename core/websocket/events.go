@@ -0,0 +1,14 @@
+package websocket
+
+import "github.com/searchktools/fast-server/core/events"
+
+// Forward broadcasts topic's events as text messages to the clients of
+// room ("" for all), converted by encode (nil results are skipped). Close
+// the subscription to stop.
+func Forward[T any](topic *events.Topic[T], h *Hub, room string, encode func(T) []byte) *events.Subscription[T] {
+	return topic.Subscribe(0, func(v T) {
+		if payload := encode(v); payload != nil {
+			h.Broadcast(OpText, payload, room)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package websocket
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/searchktools/fast-server/core/clock"
+)
+
+var (
+	// ErrMessageRejected drops a single message and keeps the client connected
+	ErrMessageRejected = errors.New("message rejected")
+	// ErrPolicyViolation drops the message and disconnects the client
+	ErrPolicyViolation = errors.New("policy violation")
+)
+
+// MessageFilter inspects an inbound message before it is dispatched to
+// rooms or handlers. It may modify msg in place. Returning an error drops
+// the message; errors wrapping ErrPolicyViolation also disconnect the client.
+type MessageFilter func(client *Client, msg *Message) error
+
+// UseMessageFilter adds per-message filters, run in order for every
+// inbound message
+func (h *Hub) UseMessageFilter(filters ...MessageFilter) {
+	h.filterMu.Lock()
+	h.filters = append(h.filters, filters...)
+	h.filterMu.Unlock()
+}
+
+// filterMessage runs the filter chain. It returns false if the message must
+// be dropped, and disconnect reports whether the client must be closed.
+func (h *Hub) filterMessage(client *Client, msg *Message) (ok bool, disconnect bool) {
+	h.filterMu.RLock()
+	filters := h.filters
+	h.filterMu.RUnlock()
+
+	for _, f := range filters {
+		if err := f(client, msg); err != nil {
+			h.rejectedCount.Add(1)
+			return false, errors.Is(err, ErrPolicyViolation)
+		}
+	}
+	return true, false
+}
+
+// RequireMetadata rejects messages from clients whose metadata key does not
+// satisfy allow (e.g. a role or scope set at upgrade time)
+func RequireMetadata(key string, allow func(value any) bool) MessageFilter {
+	return func(client *Client, msg *Message) error {
+		value, ok := client.Get(key)
+		if !ok || !allow(value) {
+			return ErrMessageRejected
+		}
+		return nil
+	}
+}
+
+// MaxMessageSize rejects messages with payloads larger than limit bytes
+func MaxMessageSize(limit int) MessageFilter {
+	return func(client *Client, msg *Message) error {
+		if len(msg.Payload) > limit {
+			return ErrMessageRejected
+		}
+		return nil
+	}
+}
+
+// MessageRateLimit limits each client to perSecond messages with the given
+// burst. Clients that exceed it are disconnected when disconnect is true,
+// otherwise excess messages are dropped.
+func MessageRateLimit(perSecond float64, burst int, disconnect bool) MessageFilter {
+	return func(client *Client, msg *Message) error {
+		if client.allowMessage(perSecond, burst) {
+			return nil
+		}
+		if disconnect {
+			return ErrPolicyViolation
+		}
+		return ErrMessageRejected
+	}
+}
+
+// messageBucket is a per-client token bucket for MessageRateLimit
+type messageBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// allowMessage takes one token from the client's message bucket
+func (c *Client) allowMessage(perSecond float64, burst int) bool {
+	c.bucketOnce.Do(func() {
+		c.bucket = &messageBucket{tokens: float64(burst), last: clock.Now()}
+	})
+
+	b := c.bucket
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := clock.Now()
+	b.tokens += now.Sub(b.last).Seconds() * perSecond
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,58 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestMessageFilters - Test per-message filter chain
+func TestMessageFilters(t *testing.T) {
+	hub := NewHub(10)
+	hub.UseMessageFilter(
+		RequireMetadata("role", func(v any) bool { return v == "writer" }),
+		MaxMessageSize(8),
+		func(client *Client, msg *Message) error {
+			msg.Payload = append([]byte("["+client.ID+"] "), msg.Payload...)
+			return nil
+		},
+	)
+
+	reader := NewClient("r", nil)
+	reader.Set("role", "reader")
+	if ok, disconnect := hub.filterMessage(reader, &Message{OpCode: OpText, Payload: []byte("hi")}); ok || disconnect {
+		t.Errorf("Expected reader message dropped without disconnect, got ok=%v disconnect=%v", ok, disconnect)
+	}
+
+	writer := NewClient("w", nil)
+	writer.Set("role", "writer")
+	msg := &Message{OpCode: OpText, Payload: []byte("hi")}
+	if ok, _ := hub.filterMessage(writer, msg); !ok {
+		t.Fatal("Expected writer message accepted")
+	}
+	if string(msg.Payload) != "[w] hi" {
+		t.Errorf("Expected filter to modify payload, got %q", msg.Payload)
+	}
+
+	if ok, _ := hub.filterMessage(writer, &Message{Payload: []byte("way too long")}); ok {
+		t.Error("Expected oversized message rejected")
+	}
+
+	if denied := hub.Stats()["messages_denied"].(int64); denied != 2 {
+		t.Errorf("Expected 2 denied messages, got %d", denied)
+	}
+}
+
+// TestMessageRateLimit - Test per-client message rate limiting
+func TestMessageRateLimit(t *testing.T) {
+	filter := MessageRateLimit(0.001, 2, true)
+	client := NewClient("c", nil)
+
+	for i := 0; i < 2; i++ {
+		if err := filter(client, &Message{}); err != nil {
+			t.Fatalf("Message %d within burst rejected: %v", i, err)
+		}
+	}
+	if err := filter(client, &Message{}); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("Expected ErrPolicyViolation over the limit, got %v", err)
+	}
+}
@@ -2,11 +2,15 @@ package websocket
 
 import (
 	"bufio"
+	"errors"
 	"net"
+
+	"github.com/searchktools/fast-server/core/connlimit"
 )
 
 type Handler struct {
-	hub *Hub
+	hub     *Hub
+	limiter *connlimit.Limiter
 }
 
 func NewHandler(hub *Hub) *Handler {
@@ -15,24 +19,53 @@ func NewHandler(hub *Hub) *Handler {
 	}
 }
 
+// SetLimiter caps concurrent WebSocket connections and the upgrade rate
+// per client IP. Refused upgrades get 429 or 503 before the handshake.
+func (h *Handler) SetLimiter(l *connlimit.Limiter) {
+	h.limiter = l
+}
+
 func (h *Handler) HandleConnection(conn net.Conn, clientID string) error {
+	release := func() {}
+	if h.limiter != nil {
+		r, err := h.limiter.Acquire(connlimit.WebSocket, conn.RemoteAddr().String())
+		if err != nil {
+			rejectUpgrade(conn, err)
+			return err
+		}
+		release = r
+	}
+
 	reader := bufio.NewReader(conn)
 	wsConn, err := Upgrade(conn, reader)
 	if err != nil {
 		conn.Close()
+		release()
 		return err
 	}
 
 	client := NewClient(clientID, wsConn)
+	client.onClose = release
 
 	if err := h.hub.Register(client); err != nil {
 		wsConn.Close()
+		release()
 		return err
 	}
 
 	return nil
 }
 
+// rejectUpgrade answers a refused upgrade and closes the connection
+func rejectUpgrade(conn net.Conn, err error) {
+	status := "503 Service Unavailable"
+	if errors.Is(err, connlimit.ErrUpgradeRateLimited) {
+		status = "429 Too Many Requests"
+	}
+	conn.Write([]byte("HTTP/1.1 " + status + "\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"))
+	conn.Close()
+}
+
 type MessageHandler func(client *Client, msg *Message)
 
 type CustomHub struct {
@@ -81,7 +114,11 @@ func (h *CustomHub) customReadPump(client *Client) {
 			return
 		}
 
-		if h.onMessage != nil {
+		ok, disconnect := h.filterMessage(client, msg)
+		if disconnect {
+			return
+		}
+		if ok && h.onMessage != nil {
 			h.onMessage(client, msg)
 		}
 	}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+
+	"github.com/searchktools/fast-server/core/queue"
+	"github.com/searchktools/fast-server/core/replay"
 )
 
 type Client struct {
@@ -11,6 +14,15 @@ type Client struct {
 	Conn   *Conn
 	Send   chan []byte
 	closed atomic.Bool
+
+	// Metadata set at upgrade time (user, roles, scopes) for message filters
+	metadata sync.Map
+
+	bucketOnce sync.Once
+	bucket     *messageBucket
+
+	// Called once on Close (releases the upgrade limiter slot)
+	onClose func()
 }
 
 func NewClient(id string, conn *Conn) *Client {
@@ -27,22 +39,42 @@ func (c *Client) Close() {
 	}
 	close(c.Send)
 	c.Conn.Close()
+	if c.onClose != nil {
+		c.onClose()
+	}
 }
 
 func (c *Client) IsClosed() bool {
 	return c.closed.Load()
 }
 
+// Set stores a metadata value on the client
+func (c *Client) Set(key string, value any) {
+	c.metadata.Store(key, value)
+}
+
+// Get returns a metadata value stored on the client
+func (c *Client) Get(key string) (any, bool) {
+	return c.metadata.Load(key)
+}
+
 type Hub struct {
 	clients    sync.Map
-	broadcast  chan *BroadcastMessage
+	broadcast  *queue.Queue[*BroadcastMessage]
 	register   chan *Client
 	unregister chan *Client
 	rooms      sync.Map
 
-	totalClients atomic.Int64
-	messageCount atomic.Int64
-	maxClients   int
+	totalClients  atomic.Int64
+	messageCount  atomic.Int64
+	rejectedCount atomic.Int64
+	maxClients    int
+
+	// Per-message filters applied before dispatch
+	filters  []MessageFilter
+	filterMu sync.RWMutex
+
+	replay *replay.Buffer
 }
 
 type BroadcastMessage struct {
@@ -56,14 +88,18 @@ func NewHub(maxClients int) *Hub {
 		maxClients = 10000
 	}
 
+	// Without an overflow file New cannot fail
+	broadcast, _ := queue.New(queue.Config[*BroadcastMessage]{Capacity: 1024})
+
 	hub := &Hub{
-		broadcast:  make(chan *BroadcastMessage, 1000),
+		broadcast:  broadcast,
 		register:   make(chan *Client, 100),
 		unregister: make(chan *Client, 100),
 		maxClients: maxClients,
 	}
 
 	go hub.run()
+	go hub.broadcast.Run(hub.fanOut)
 
 	return hub
 }
@@ -80,29 +116,44 @@ func (h *Hub) run() {
 				h.clients.Delete(client.ID)
 				client.Close()
 			}
+		}
+	}
+}
 
-		case msg := <-h.broadcast:
-			h.messageCount.Add(1)
-
-			if msg.Room == "" {
-				h.clients.Range(func(key, value interface{}) bool {
-					client := value.(*Client)
-					select {
-					case client.Send <- msg.Payload:
-					default:
-						h.unregister <- client
-					}
-					return true
-				})
-			} else {
-				if room, ok := h.GetRoom(msg.Room); ok {
-					room.Broadcast(msg.Payload)
+// fanOut sends broadcast messages to their clients or rooms
+func (h *Hub) fanOut(msgs []*BroadcastMessage) {
+	for _, msg := range msgs {
+		h.messageCount.Add(1)
+		if h.replay != nil && msg.OpCode == OpText {
+			h.replay.Append(replay.Message{Event: "message", Data: string(msg.Payload), Topic: msg.Room})
+		}
+
+		if msg.Room == "" {
+			h.clients.Range(func(key, value interface{}) bool {
+				client := value.(*Client)
+				select {
+				case client.Send <- msg.Payload:
+				default:
+					h.unregister <- client
 				}
+				return true
+			})
+		} else {
+			if room, ok := h.GetRoom(msg.Room); ok {
+				room.Broadcast(msg.Payload)
 			}
 		}
 	}
 }
 
+// SetReplay records broadcast text messages in buf, with their room as
+// topic, so clients that cannot use WebSocket can read them by long
+// polling (replay.LongPoll). It must be called before messages are
+// broadcast.
+func (h *Hub) SetReplay(buf *replay.Buffer) {
+	h.replay = buf
+}
+
 func (h *Hub) Register(client *Client) error {
 	count := 0
 	h.clients.Range(func(_, _ interface{}) bool {
@@ -126,12 +177,14 @@ func (h *Hub) Unregister(client *Client) {
 	h.unregister <- client
 }
 
+// Broadcast queues a message for all clients, or those of room. It does
+// not block: when the queue is full the message is dropped.
 func (h *Hub) Broadcast(opcode OpCode, payload []byte, room string) {
-	h.broadcast <- &BroadcastMessage{
+	h.broadcast.Push(&BroadcastMessage{
 		OpCode:  opcode,
 		Payload: payload,
 		Room:    room,
-	}
+	})
 }
 
 func (h *Hub) BroadcastText(text string, room string) {
@@ -177,10 +230,12 @@ func (h *Hub) ClientCount() int {
 
 func (h *Hub) Stats() map[string]interface{} {
 	return map[string]interface{}{
-		"total_clients":   h.totalClients.Load(),
-		"current_clients": h.ClientCount(),
-		"messages_sent":   h.messageCount.Load(),
-		"rooms":           h.RoomCount(),
+		"total_clients":    h.totalClients.Load(),
+		"current_clients":  h.ClientCount(),
+		"messages_sent":    h.messageCount.Load(),
+		"messages_denied":  h.rejectedCount.Load(),
+		"messages_dropped": h.broadcast.Stats().Dropped,
+		"rooms":            h.RoomCount(),
 	}
 }
 
@@ -194,7 +249,9 @@ func (h *Hub) readPump(client *Client) {
 		if err != nil {
 			return
 		}
-		_ = msg
+		if _, disconnect := h.filterMessage(client, msg); disconnect {
+			return
+		}
 	}
 }
 
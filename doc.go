@@ -59,12 +59,35 @@ The framework is organized into several modules:
   - core/middleware: Middleware pipeline
   - core/pools: Object pooling (workers, buffers, connections)
   - core/poller: I/O multiplexing (epoll/kqueue/io_uring)
+  - core/netfd: Socket calls on descriptors, emulated over net on Windows
   - core/optimize: Performance optimizations (SIMD)
   - core/websocket: WebSocket support
   - core/sse: Server-Sent Events
+  - core/replay: Replay buffer and long-polling transport for SSE/WebSocket messages
+  - core/connlimit: Concurrency and per-IP rate limits for WebSocket/SSE upgrades
   - core/http2: HTTP/2 support
+  - core/alpn: TLS listener sharing one port across protocols via ALPN
   - core/rpc: RPC framework
   - core/observability: Monitoring and tracing
+  - core/cluster: Rolling restart coordination across instances
+  - core/cache: Multi-level LRU response cache with stale-while-revalidate
+  - core/dedup: Request deduplication window for at-most-once handlers
+  - core/webhook: Outbound webhook delivery with signing and retries
+  - core/queue: Bounded MPSC queue with batch consumers and disk overflow
+  - core/clock: Coarse cached clock and fake clock for tests
+  - core/msgpack: MessagePack encoding for binary API responses and binding
+  - core/redact: Masking of sensitive headers and JSON fields in logs
+  - core/events: Typed in-process pub/sub bus shared by subsystems
+  - core/stats: Metrics registry of all components with Prometheus/JSON export
+
+Minimal Builds
+
+Protocol modules (http2, rpc, websocket, sse, replay, alpn, ...) are only
+linked into programs that import them; the engine does not. Build tags
+leave out optional parts of core/http:
+
+  - fastserver_notemplate: html/template registry (ctx.Render fails)
+  - fastserver_noprotobuf: ctx.Protobuf and protobuf binding
 
 Performance
 